@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"api/server"
+	"api/store"
+)
+
+// bundleEnv is what the bundle commands need from the API's configuration
+type bundleEnv struct {
+	natsURL   string
+	dbURL     string
+	boltPath  string
+	retention time.Duration
+	symbol    string
+	settings  map[string]any // recorded in config.json
+}
+
+// runBundle runs `export bundle` and `import bundle` against the store
+// directly, so the API needn't be up; a bolt file has to be released by a
+// running API first. Returns the exit status.
+func runBundle(args []string, env bundleEnv) int {
+	if len(args) < 2 || args[1] != "bundle" {
+		fmt.Fprintln(os.Stderr, "usage: api export bundle [flags] | api import bundle FILE")
+		return 2
+	}
+	var err error
+	if args[0] == "export" {
+		err = exportBundle(args[2:], env)
+	} else {
+		err = importBundle(args[2:], env)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func exportBundle(args []string, env bundleEnv) error {
+	fs := flag.NewFlagSet("export bundle", flag.ExitOnError)
+	symbols := fs.String("symbols", env.symbol, "comma separated symbols to include")
+	last := fs.Duration("last", time.Hour, "range ending now, unless --from is set")
+	from := fs.String("from", "", "start of the range, RFC 3339")
+	to := fs.String("to", "", "end of the range (exclusive), RFC 3339, default now")
+	out := fs.String("o", "", "output file, - for stdout (default bundle-<time>.tar.gz)")
+	fs.Parse(args)
+
+	opts := server.BundleOptions{To: time.Now()}
+	var err error
+	if *to != "" {
+		if opts.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("invalid --to: %v", err)
+		}
+	}
+	opts.From = opts.To.Add(-*last)
+	if *from != "" {
+		if opts.From, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("invalid --from: %v", err)
+		}
+	}
+	if !opts.From.Before(opts.To) {
+		return fmt.Errorf("empty range %s to %s", opts.From.Format(time.RFC3339), opts.To.Format(time.RFC3339))
+	}
+	for _, sym := range strings.Split(*symbols, ",") {
+		if sym = strings.ToLower(strings.TrimSpace(sym)); sym != "" && !slices.Contains(opts.Symbols, sym) {
+			opts.Symbols = append(opts.Symbols, sym)
+		}
+	}
+	opts.Config = map[string]any{"api": env.settings, "processing": processingConfig(env.natsURL)}
+
+	ctx := context.Background()
+	store, err := openBundleStore(ctx, env)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var w io.Writer = os.Stdout
+	name := *out
+	if name == "" {
+		name = "bundle-" + opts.To.UTC().Format("20060102T150405Z") + ".tar.gz"
+	}
+	if name != "-" {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	manifest, err := server.ExportBundle(ctx, store, opts, w)
+	if err != nil {
+		if name != "-" {
+			os.Remove(name)
+		}
+		return err
+	}
+	for _, sym := range opts.Symbols {
+		fmt.Fprintf(os.Stderr, "%-10s %d trades\n", sym, manifest.Trades[sym])
+	}
+	if name != "-" {
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", name)
+	}
+	return nil
+}
+
+func importBundle(args []string, env bundleEnv) error {
+	fs := flag.NewFlagSet("import bundle", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: api import bundle FILE (- for stdin)")
+	}
+
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ctx := context.Background()
+	store, err := openBundleStore(ctx, env)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	manifest, imported, err := server.ImportBundle(ctx, store, r)
+	if err != nil {
+		return err
+	}
+	symbols := make([]string, 0, len(manifest.Trades))
+	for sym := range manifest.Trades {
+		symbols = append(symbols, sym)
+	}
+	slices.Sort(symbols)
+	for _, sym := range symbols {
+		if manifest.Trades[sym] > 0 && imported[sym] == 0 {
+			fmt.Fprintf(os.Stderr, "%-10s skipped, already has trades in the bundle's range\n", sym)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%-10s %d trades imported\n", sym, imported[sym])
+	}
+	if env.boltPath != "" && time.Since(manifest.From) > env.retention {
+		fmt.Fprintf(os.Stderr, "Note: trades older than BOLT_RETENTION (%s) are pruned when the API runs\n", env.retention)
+	}
+	return nil
+}
+
+// openBundleStore opens the configured store once, without the retries the
+// server makes at startup
+func openBundleStore(ctx context.Context, env bundleEnv) (store.Store, error) {
+	if env.boltPath != "" {
+		db, err := store.NewBoltStore(env.boltPath, env.retention)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v (stop the API first, it holds the file)", env.boltPath, err)
+		}
+		return db, nil
+	}
+	if path, ok := store.SQLitePath(env.dbURL); ok {
+		db, err := store.NewSQLiteStore(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return db, nil
+	}
+	db, err := store.NewPostgresStore(ctx, env.dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("database: %v", err)
+	}
+	return db, nil
+}
+
+// processingConfig asks the processing service for its parameters, nil
+// when it doesn't answer
+func processingConfig(natsURL string) any {
+	nc, err := nats.Connect(natsURL, nats.Timeout(2*time.Second))
+	if err != nil {
+		return nil
+	}
+	defer nc.Close()
+	reply, err := nc.Request("control.config", nil, 2*time.Second)
+	if err != nil {
+		return nil
+	}
+	var cfg map[string]any
+	if json.Unmarshal(reply.Data, &cfg) != nil {
+		return nil
+	}
+	return cfg
+}
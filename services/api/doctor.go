@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+	bolt "go.etcd.io/bbolt"
+
+	"api/store"
+)
+
+// How long each doctor check may take
+const doctorTimeout = 5 * time.Second
+
+// doctorCheck is one line of the `api doctor` report
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context) (detail string, err error)
+}
+
+// doctorWarning is a check that passed with a caveat worth reading
+type doctorWarning string
+
+func (w doctorWarning) Error() string { return string(w) }
+
+// runDoctor prints one line per check and returns the exit status: 1 when
+// any check failed
+func runDoctor(checks []doctorCheck) int {
+	status := 0
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+		detail, err := c.run(ctx)
+		cancel()
+
+		result := "ok"
+		var warning doctorWarning
+		switch {
+		case errors.As(err, &warning):
+			result, detail = "warn", err.Error()
+		case err != nil:
+			result, detail = "FAIL", err.Error()
+			status = 1
+		}
+		fmt.Printf("%-5s %-10s %s\n", result, c.name, detail)
+	}
+	return status
+}
+
+// apiChecks covers what the API needs to start: NATS, its store and a free
+// listen address
+func apiChecks(natsURL, dbURL, boltPath, addr string) []doctorCheck {
+	checks := []doctorCheck{{"nats", func(ctx context.Context) (string, error) {
+		nc, err := nats.Connect(natsURL, nats.Timeout(doctorTimeout))
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", natsURL, err)
+		}
+		defer nc.Close()
+		return fmt.Sprintf("%s (server %s)", natsURL, nc.ConnectedServerVersion()), nil
+	}}}
+
+	if boltPath != "" {
+		checks = append(checks, doctorCheck{"database", func(ctx context.Context) (string, error) {
+			if _, err := os.Stat(boltPath); errors.Is(err, os.ErrNotExist) {
+				return boltPath + " will be created", nil
+			}
+			db, err := bolt.Open(boltPath, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+			if errors.Is(err, bolt.ErrTimeout) {
+				return "", doctorWarning(boltPath + " is locked, probably by a running API")
+			}
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", boltPath, err)
+			}
+			db.Close()
+			return "bolt file " + boltPath, nil
+		}})
+	} else if path, ok := store.SQLitePath(dbURL); ok {
+		checks = append(checks, doctorCheck{"database", func(ctx context.Context) (string, error) {
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				return path + " will be created", nil
+			}
+			db, err := store.NewSQLiteStore(ctx, path)
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", path, err)
+			}
+			db.Close()
+			return "SQLite file " + path, nil
+		}})
+	} else {
+		checks = append(checks, doctorCheck{"database", func(ctx context.Context) (string, error) {
+			db, err := pgxpool.New(ctx, dbURL)
+			if err != nil {
+				return "", err
+			}
+			defer db.Close()
+			// The API starts without a database, with history disabled
+			if err := db.Ping(ctx); err != nil {
+				return "", doctorWarning(fmt.Sprintf("TimescaleDB unreachable, history will be disabled: %v", err))
+			}
+			var version string
+			err = db.QueryRow(ctx, "SELECT extversion FROM pg_extension WHERE extname = 'timescaledb'").Scan(&version)
+			if err != nil {
+				return "", doctorWarning("connected, but without the timescaledb extension history will be disabled")
+			}
+			return "TimescaleDB " + version, nil
+		}})
+	}
+
+	checks = append(checks, doctorCheck{"listen", func(ctx context.Context) (string, error) {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return "", fmt.Errorf("%s unavailable (is the API already running?): %v", addr, err)
+		}
+		ln.Close()
+		return addr + " is free", nil
+	}})
+	return checks
+}
+
+// redisCheck connects to the Redis shared with the other instances and
+// reports which instance feeds the trades
+func redisCheck(url string) doctorCheck {
+	return doctorCheck{"redis", func(ctx context.Context) (string, error) {
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			return "", err
+		}
+		rdb := redis.NewClient(opts)
+		defer rdb.Close()
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			return "", fmt.Errorf("%s: %v", opts.Addr, err)
+		}
+		feeder, err := rdb.Get(ctx, "sign:feeder").Result()
+		if errors.Is(err, redis.Nil) {
+			return opts.Addr + ", no instance feeding yet", nil
+		} else if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s, fed by %s", opts.Addr, feeder), nil
+	}}
+}
@@ -0,0 +1,962 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.2
+// 	protoc        (unknown)
+// source: market.proto
+
+// The API service's market data over gRPC, next to its HTTP and WebSocket
+// endpoints. Prices are in the market's quote currency, rounded to its
+// precision, and times are unix milliseconds.
+//
+// Generated code is checked in: Go in services/api/marketpb, Python in
+// proto/python. Run `make proto` after changing this file.
+
+package marketpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetPriceRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetPriceRequest) Reset() {
+	*x = GetPriceRequest{}
+	mi := &file_market_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPriceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPriceRequest) ProtoMessage() {}
+
+func (x *GetPriceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPriceRequest.ProtoReflect.Descriptor instead.
+func (*GetPriceRequest) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{0}
+}
+
+type Price struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Symbol    string  `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Price     float64 `protobuf:"fixed64,2,opt,name=price,proto3" json:"price,omitempty"`
+	Quote     string  `protobuf:"bytes,3,opt,name=quote,proto3" json:"quote,omitempty"`                     // quote currency, e.g. USDT
+	Precision int32   `protobuf:"varint,4,opt,name=precision,proto3" json:"precision,omitempty"`            // decimals prices are rounded to
+	Time      int64   `protobuf:"varint,5,opt,name=time,proto3" json:"time,omitempty"`                      // of the trade, 0 before the first one
+	PriceE8   int64   `protobuf:"varint,6,opt,name=price_e8,json=priceE8,proto3" json:"price_e8,omitempty"` // price exactly, in 1e-8 units; 0 before the first trade
+}
+
+func (x *Price) Reset() {
+	*x = Price{}
+	mi := &file_market_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Price) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Price) ProtoMessage() {}
+
+func (x *Price) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Price.ProtoReflect.Descriptor instead.
+func (*Price) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Price) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Price) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Price) GetQuote() string {
+	if x != nil {
+		return x.Quote
+	}
+	return ""
+}
+
+func (x *Price) GetPrecision() int32 {
+	if x != nil {
+		return x.Precision
+	}
+	return 0
+}
+
+func (x *Price) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *Price) GetPriceE8() int64 {
+	if x != nil {
+		return x.PriceE8
+	}
+	return 0
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_market_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{2}
+}
+
+// Stats are a symbol's session statistics; samples is how many trades the
+// moving average covers, below the window size while warming up
+type Stats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MovingAverage float64            `protobuf:"fixed64,1,opt,name=moving_average,json=movingAverage,proto3" json:"moving_average,omitempty"`
+	High          float64            `protobuf:"fixed64,2,opt,name=high,proto3" json:"high,omitempty"`
+	Low           float64            `protobuf:"fixed64,3,opt,name=low,proto3" json:"low,omitempty"`
+	Vwap          float64            `protobuf:"fixed64,4,opt,name=vwap,proto3" json:"vwap,omitempty"`
+	StdDev        float64            `protobuf:"fixed64,5,opt,name=std_dev,json=stdDev,proto3" json:"std_dev,omitempty"`
+	Ema           map[string]float64 `protobuf:"bytes,6,rep,name=ema,proto3" json:"ema,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"` // by span in trades
+	Volume        float64            `protobuf:"fixed64,7,opt,name=volume,proto3" json:"volume,omitempty"`                                                                                   // base asset this session
+	BuyVolume     float64            `protobuf:"fixed64,8,opt,name=buy_volume,json=buyVolume,proto3" json:"buy_volume,omitempty"`
+	SellVolume    float64            `protobuf:"fixed64,9,opt,name=sell_volume,json=sellVolume,proto3" json:"sell_volume,omitempty"`
+	BuyRatio      float64            `protobuf:"fixed64,10,opt,name=buy_ratio,json=buyRatio,proto3" json:"buy_ratio,omitempty"` // share of sided volume bought by takers, 0 to 1
+	TradesPerSec  float64            `protobuf:"fixed64,11,opt,name=trades_per_sec,json=tradesPerSec,proto3" json:"trades_per_sec,omitempty"`
+	Samples       int32              `protobuf:"varint,12,opt,name=samples,proto3" json:"samples,omitempty"`
+	WindowFull    bool               `protobuf:"varint,13,opt,name=window_full,json=windowFull,proto3" json:"window_full,omitempty"`
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_market_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Stats) GetMovingAverage() float64 {
+	if x != nil {
+		return x.MovingAverage
+	}
+	return 0
+}
+
+func (x *Stats) GetHigh() float64 {
+	if x != nil {
+		return x.High
+	}
+	return 0
+}
+
+func (x *Stats) GetLow() float64 {
+	if x != nil {
+		return x.Low
+	}
+	return 0
+}
+
+func (x *Stats) GetVwap() float64 {
+	if x != nil {
+		return x.Vwap
+	}
+	return 0
+}
+
+func (x *Stats) GetStdDev() float64 {
+	if x != nil {
+		return x.StdDev
+	}
+	return 0
+}
+
+func (x *Stats) GetEma() map[string]float64 {
+	if x != nil {
+		return x.Ema
+	}
+	return nil
+}
+
+func (x *Stats) GetVolume() float64 {
+	if x != nil {
+		return x.Volume
+	}
+	return 0
+}
+
+func (x *Stats) GetBuyVolume() float64 {
+	if x != nil {
+		return x.BuyVolume
+	}
+	return 0
+}
+
+func (x *Stats) GetSellVolume() float64 {
+	if x != nil {
+		return x.SellVolume
+	}
+	return 0
+}
+
+func (x *Stats) GetBuyRatio() float64 {
+	if x != nil {
+		return x.BuyRatio
+	}
+	return 0
+}
+
+func (x *Stats) GetTradesPerSec() float64 {
+	if x != nil {
+		return x.TradesPerSec
+	}
+	return 0
+}
+
+func (x *Stats) GetSamples() int32 {
+	if x != nil {
+		return x.Samples
+	}
+	return 0
+}
+
+func (x *Stats) GetWindowFull() bool {
+	if x != nil {
+		return x.WindowFull
+	}
+	return false
+}
+
+type StreamTradesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Symbol string `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"` // e.g. ethusdt, empty for the one selected when the stream opens
+}
+
+func (x *StreamTradesRequest) Reset() {
+	*x = StreamTradesRequest{}
+	mi := &file_market_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamTradesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamTradesRequest) ProtoMessage() {}
+
+func (x *StreamTradesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamTradesRequest.ProtoReflect.Descriptor instead.
+func (*StreamTradesRequest) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StreamTradesRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+type Trade struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Symbol  string  `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Price   float64 `protobuf:"fixed64,2,opt,name=price,proto3" json:"price,omitempty"`
+	Qty     float64 `protobuf:"fixed64,3,opt,name=qty,proto3" json:"qty,omitempty"` // 0 when the exchange doesn't report it
+	Side    string  `protobuf:"bytes,4,opt,name=side,proto3" json:"side,omitempty"` // taker side, buy or sell, empty when not reported
+	Time    int64   `protobuf:"varint,5,opt,name=time,proto3" json:"time,omitempty"`
+	PriceE8 int64   `protobuf:"varint,6,opt,name=price_e8,json=priceE8,proto3" json:"price_e8,omitempty"` // price exactly, in 1e-8 units
+}
+
+func (x *Trade) Reset() {
+	*x = Trade{}
+	mi := &file_market_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trade) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trade) ProtoMessage() {}
+
+func (x *Trade) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trade.ProtoReflect.Descriptor instead.
+func (*Trade) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Trade) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Trade) GetPrice() float64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Trade) GetQty() float64 {
+	if x != nil {
+		return x.Qty
+	}
+	return 0
+}
+
+func (x *Trade) GetSide() string {
+	if x != nil {
+		return x.Side
+	}
+	return ""
+}
+
+func (x *Trade) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *Trade) GetPriceE8() int64 {
+	if x != nil {
+		return x.PriceE8
+	}
+	return 0
+}
+
+type TradeUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Trade *Trade `protobuf:"bytes,1,opt,name=trade,proto3" json:"trade,omitempty"`
+	Stats *Stats `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+}
+
+func (x *TradeUpdate) Reset() {
+	*x = TradeUpdate{}
+	mi := &file_market_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TradeUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TradeUpdate) ProtoMessage() {}
+
+func (x *TradeUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TradeUpdate.ProtoReflect.Descriptor instead.
+func (*TradeUpdate) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TradeUpdate) GetTrade() *Trade {
+	if x != nil {
+		return x.Trade
+	}
+	return nil
+}
+
+func (x *TradeUpdate) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type SetSymbolRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Symbol string `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+}
+
+func (x *SetSymbolRequest) Reset() {
+	*x = SetSymbolRequest{}
+	mi := &file_market_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetSymbolRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetSymbolRequest) ProtoMessage() {}
+
+func (x *SetSymbolRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetSymbolRequest.ProtoReflect.Descriptor instead.
+func (*SetSymbolRequest) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SetSymbolRequest) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+type SetSymbolResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Symbol  string `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Name    string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Changed bool   `protobuf:"varint,3,opt,name=changed,proto3" json:"changed,omitempty"` // false when it was already selected
+}
+
+func (x *SetSymbolResponse) Reset() {
+	*x = SetSymbolResponse{}
+	mi := &file_market_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetSymbolResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetSymbolResponse) ProtoMessage() {}
+
+func (x *SetSymbolResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetSymbolResponse.ProtoReflect.Descriptor instead.
+func (*SetSymbolResponse) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SetSymbolResponse) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *SetSymbolResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetSymbolResponse) GetChanged() bool {
+	if x != nil {
+		return x.Changed
+	}
+	return false
+}
+
+// StreamFrame is one message of the /ws stream opened with ?format=protobuf,
+// sent as a binary WebSocket frame. Trades and stats come as messages of
+// their own; everything else holds the JSON it would otherwise be.
+type StreamFrame struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seq uint64 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"` // protocol v2 only, as "seq" in JSON messages
+	// Types that are assignable to Payload:
+	//	*StreamFrame_Trade
+	//	*StreamFrame_Stats
+	//	*StreamFrame_Json
+	Payload isStreamFrame_Payload `protobuf_oneof:"payload"`
+}
+
+func (x *StreamFrame) Reset() {
+	*x = StreamFrame{}
+	mi := &file_market_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamFrame) ProtoMessage() {}
+
+func (x *StreamFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamFrame.ProtoReflect.Descriptor instead.
+func (*StreamFrame) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StreamFrame) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (m *StreamFrame) GetPayload() isStreamFrame_Payload {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (x *StreamFrame) GetTrade() *Price {
+	if x, ok := x.GetPayload().(*StreamFrame_Trade); ok {
+		return x.Trade
+	}
+	return nil
+}
+
+func (x *StreamFrame) GetStats() *SymbolStats {
+	if x, ok := x.GetPayload().(*StreamFrame_Stats); ok {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *StreamFrame) GetJson() []byte {
+	if x, ok := x.GetPayload().(*StreamFrame_Json); ok {
+		return x.Json
+	}
+	return nil
+}
+
+type isStreamFrame_Payload interface {
+	isStreamFrame_Payload()
+}
+
+type StreamFrame_Trade struct {
+	Trade *Price `protobuf:"bytes,2,opt,name=trade,proto3,oneof"` // a v2 trade event or price channel update
+}
+
+type StreamFrame_Stats struct {
+	Stats *SymbolStats `protobuf:"bytes,3,opt,name=stats,proto3,oneof"`
+}
+
+type StreamFrame_Json struct {
+	Json []byte `protobuf:"bytes,4,opt,name=json,proto3,oneof"`
+}
+
+func (*StreamFrame_Trade) isStreamFrame_Payload() {}
+
+func (*StreamFrame_Stats) isStreamFrame_Payload() {}
+
+func (*StreamFrame_Json) isStreamFrame_Payload() {}
+
+type SymbolStats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Symbol string `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Stats  *Stats `protobuf:"bytes,2,opt,name=stats,proto3" json:"stats,omitempty"`
+}
+
+func (x *SymbolStats) Reset() {
+	*x = SymbolStats{}
+	mi := &file_market_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SymbolStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SymbolStats) ProtoMessage() {}
+
+func (x *SymbolStats) ProtoReflect() protoreflect.Message {
+	mi := &file_market_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SymbolStats.ProtoReflect.Descriptor instead.
+func (*SymbolStats) Descriptor() ([]byte, []int) {
+	return file_market_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SymbolStats) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *SymbolStats) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+var File_market_proto protoreflect.FileDescriptor
+
+var file_market_proto_rawDesc = []byte{
+	0x0a, 0x0c, 0x6d, 0x61, 0x72, 0x6b, 0x65, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c,
+	0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x22, 0x11, 0x0a, 0x0f,
+	0x47, 0x65, 0x74, 0x50, 0x72, 0x69, 0x63, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22,
+	0x98, 0x01, 0x0a, 0x05, 0x50, 0x72, 0x69, 0x63, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x79, 0x6d,
+	0x62, 0x6f, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f,
+	0x6c, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x71, 0x75, 0x6f, 0x74, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x6f, 0x74, 0x65, 0x12, 0x1c, 0x0a,
+	0x09, 0x70, 0x72, 0x65, 0x63, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x09, 0x70, 0x72, 0x65, 0x63, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12,
+	0x19, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x63, 0x65, 0x5f, 0x65, 0x38, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x07, 0x70, 0x72, 0x69, 0x63, 0x65, 0x45, 0x38, 0x22, 0x11, 0x0a, 0x0f, 0x47, 0x65,
+	0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xbf, 0x03,
+	0x0a, 0x05, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x6d, 0x6f, 0x76, 0x69, 0x6e,
+	0x67, 0x5f, 0x61, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x0d, 0x6d, 0x6f, 0x76, 0x69, 0x6e, 0x67, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x12, 0x12,
+	0x0a, 0x04, 0x68, 0x69, 0x67, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x68, 0x69,
+	0x67, 0x68, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x77, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x03, 0x6c, 0x6f, 0x77, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x77, 0x61, 0x70, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x04, 0x76, 0x77, 0x61, 0x70, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x74, 0x64, 0x5f,
+	0x64, 0x65, 0x76, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x73, 0x74, 0x64, 0x44, 0x65,
+	0x76, 0x12, 0x2e, 0x0a, 0x03, 0x65, 0x6d, 0x61, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c,
+	0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
+	0x61, 0x74, 0x73, 0x2e, 0x45, 0x6d, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x03, 0x65, 0x6d,
+	0x61, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x06, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x75, 0x79,
+	0x5f, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x62,
+	0x75, 0x79, 0x56, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x65, 0x6c, 0x6c,
+	0x5f, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0a, 0x73,
+	0x65, 0x6c, 0x6c, 0x56, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x62, 0x75, 0x79,
+	0x5f, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x62, 0x75,
+	0x79, 0x52, 0x61, 0x74, 0x69, 0x6f, 0x12, 0x24, 0x0a, 0x0e, 0x74, 0x72, 0x61, 0x64, 0x65, 0x73,
+	0x5f, 0x70, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x63, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0c,
+	0x74, 0x72, 0x61, 0x64, 0x65, 0x73, 0x50, 0x65, 0x72, 0x53, 0x65, 0x63, 0x12, 0x18, 0x0a, 0x07,
+	0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x73,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77,
+	0x5f, 0x66, 0x75, 0x6c, 0x6c, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x77, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x46, 0x75, 0x6c, 0x6c, 0x1a, 0x36, 0x0a, 0x08, 0x45, 0x6d, 0x61, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22,
+	0x2d, 0x0a, 0x13, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x54, 0x72, 0x61, 0x64, 0x65, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f, 0x6c,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x22, 0x8a,
+	0x01, 0x0a, 0x05, 0x54, 0x72, 0x61, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x79, 0x6d, 0x62,
+	0x6f, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f, 0x6c,
+	0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x05, 0x70, 0x72, 0x69, 0x63, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x71, 0x74, 0x79, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x03, 0x71, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x64, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x69, 0x64, 0x65, 0x12, 0x12, 0x0a, 0x04,
+	0x74, 0x69, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65,
+	0x12, 0x19, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x63, 0x65, 0x5f, 0x65, 0x38, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x07, 0x70, 0x72, 0x69, 0x63, 0x65, 0x45, 0x38, 0x22, 0x63, 0x0a, 0x0b, 0x54,
+	0x72, 0x61, 0x64, 0x65, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x29, 0x0a, 0x05, 0x74, 0x72,
+	0x61, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x73, 0x69, 0x67, 0x6e,
+	0x61, 0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x64, 0x65, 0x52, 0x05,
+	0x74, 0x72, 0x61, 0x64, 0x65, 0x12, 0x29, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x70, 0x68, 0x61,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73,
+	0x22, 0x2a, 0x0a, 0x10, 0x53, 0x65, 0x74, 0x53, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x22, 0x59, 0x0a, 0x11,
+	0x53, 0x65, 0x74, 0x53, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x22, 0xa0, 0x01, 0x0a, 0x0b, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x2b, 0x0a, 0x05, 0x74, 0x72, 0x61,
+	0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x63, 0x65, 0x48, 0x00, 0x52,
+	0x05, 0x74, 0x72, 0x61, 0x64, 0x65, 0x12, 0x31, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x70, 0x68,
+	0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x73,
+	0x48, 0x00, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x04, 0x6a, 0x73, 0x6f,
+	0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x04, 0x6a, 0x73, 0x6f, 0x6e, 0x42,
+	0x09, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x50, 0x0a, 0x0b, 0x53, 0x79,
+	0x6d, 0x62, 0x6f, 0x6c, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x79, 0x6d,
+	0x62, 0x6f, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x79, 0x6d, 0x62, 0x6f,
+	0x6c, 0x12, 0x29, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x13, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x32, 0xaa, 0x02, 0x0a,
+	0x0a, 0x4d, 0x61, 0x72, 0x6b, 0x65, 0x74, 0x44, 0x61, 0x74, 0x61, 0x12, 0x3e, 0x0a, 0x08, 0x47,
+	0x65, 0x74, 0x50, 0x72, 0x69, 0x63, 0x65, 0x12, 0x1d, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c,
+	0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x72, 0x69, 0x63, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x70,
+	0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x63, 0x65, 0x12, 0x3e, 0x0a, 0x08, 0x47,
+	0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x1d, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c,
+	0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x13, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x70,
+	0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x4e, 0x0a, 0x0c, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x54, 0x72, 0x61, 0x64, 0x65, 0x73, 0x12, 0x21, 0x2e, 0x73, 0x69,
+	0x67, 0x6e, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x54, 0x72, 0x61, 0x64, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19,
+	0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72,
+	0x61, 0x64, 0x65, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x30, 0x01, 0x12, 0x4c, 0x0a, 0x09, 0x53,
+	0x65, 0x74, 0x53, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x12, 0x1e, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x74, 0x53, 0x79, 0x6d, 0x62, 0x6f,
+	0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x6c, 0x70, 0x68, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x74, 0x53, 0x79, 0x6d, 0x62, 0x6f,
+	0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x0e, 0x5a, 0x0c, 0x61, 0x70, 0x69,
+	0x2f, 0x6d, 0x61, 0x72, 0x6b, 0x65, 0x74, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_market_proto_rawDescOnce sync.Once
+	file_market_proto_rawDescData = file_market_proto_rawDesc
+)
+
+func file_market_proto_rawDescGZIP() []byte {
+	file_market_proto_rawDescOnce.Do(func() {
+		file_market_proto_rawDescData = protoimpl.X.CompressGZIP(file_market_proto_rawDescData)
+	})
+	return file_market_proto_rawDescData
+}
+
+var file_market_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_market_proto_goTypes = []any{
+	(*GetPriceRequest)(nil),     // 0: signalpha.v1.GetPriceRequest
+	(*Price)(nil),               // 1: signalpha.v1.Price
+	(*GetStatsRequest)(nil),     // 2: signalpha.v1.GetStatsRequest
+	(*Stats)(nil),               // 3: signalpha.v1.Stats
+	(*StreamTradesRequest)(nil), // 4: signalpha.v1.StreamTradesRequest
+	(*Trade)(nil),               // 5: signalpha.v1.Trade
+	(*TradeUpdate)(nil),         // 6: signalpha.v1.TradeUpdate
+	(*SetSymbolRequest)(nil),    // 7: signalpha.v1.SetSymbolRequest
+	(*SetSymbolResponse)(nil),   // 8: signalpha.v1.SetSymbolResponse
+	(*StreamFrame)(nil),         // 9: signalpha.v1.StreamFrame
+	(*SymbolStats)(nil),         // 10: signalpha.v1.SymbolStats
+	nil,                         // 11: signalpha.v1.Stats.EmaEntry
+}
+var file_market_proto_depIdxs = []int32{
+	11, // 0: signalpha.v1.Stats.ema:type_name -> signalpha.v1.Stats.EmaEntry
+	5,  // 1: signalpha.v1.TradeUpdate.trade:type_name -> signalpha.v1.Trade
+	3,  // 2: signalpha.v1.TradeUpdate.stats:type_name -> signalpha.v1.Stats
+	1,  // 3: signalpha.v1.StreamFrame.trade:type_name -> signalpha.v1.Price
+	10, // 4: signalpha.v1.StreamFrame.stats:type_name -> signalpha.v1.SymbolStats
+	3,  // 5: signalpha.v1.SymbolStats.stats:type_name -> signalpha.v1.Stats
+	0,  // 6: signalpha.v1.MarketData.GetPrice:input_type -> signalpha.v1.GetPriceRequest
+	2,  // 7: signalpha.v1.MarketData.GetStats:input_type -> signalpha.v1.GetStatsRequest
+	4,  // 8: signalpha.v1.MarketData.StreamTrades:input_type -> signalpha.v1.StreamTradesRequest
+	7,  // 9: signalpha.v1.MarketData.SetSymbol:input_type -> signalpha.v1.SetSymbolRequest
+	1,  // 10: signalpha.v1.MarketData.GetPrice:output_type -> signalpha.v1.Price
+	3,  // 11: signalpha.v1.MarketData.GetStats:output_type -> signalpha.v1.Stats
+	6,  // 12: signalpha.v1.MarketData.StreamTrades:output_type -> signalpha.v1.TradeUpdate
+	8,  // 13: signalpha.v1.MarketData.SetSymbol:output_type -> signalpha.v1.SetSymbolResponse
+	10, // [10:14] is the sub-list for method output_type
+	6,  // [6:10] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_market_proto_init() }
+func file_market_proto_init() {
+	if File_market_proto != nil {
+		return
+	}
+	file_market_proto_msgTypes[9].OneofWrappers = []any{
+		(*StreamFrame_Trade)(nil),
+		(*StreamFrame_Stats)(nil),
+		(*StreamFrame_Json)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_market_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_market_proto_goTypes,
+		DependencyIndexes: file_market_proto_depIdxs,
+		MessageInfos:      file_market_proto_msgTypes,
+	}.Build()
+	File_market_proto = out.File
+	file_market_proto_rawDesc = nil
+	file_market_proto_goTypes = nil
+	file_market_proto_depIdxs = nil
+}
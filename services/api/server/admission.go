@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// How long rejected clients are asked to wait before retrying
+const retryAfter = 5 * time.Second
+
+// Limiter caps how many holders may be admitted at once. A max of 0 or less
+// admits everyone but still counts.
+type Limiter struct {
+	max      int64
+	current  atomic.Int64
+	rejected atomic.Int64
+}
+
+func NewLimiter(max int) *Limiter {
+	return &Limiter{max: int64(max)}
+}
+
+// Acquire admits a holder if there is room; every successful Acquire must be
+// paired with a Release
+func (l *Limiter) Acquire() bool {
+	if n := l.current.Add(1); l.max > 0 && n > l.max {
+		l.current.Add(-1)
+		l.rejected.Add(1)
+		return false
+	}
+	return true
+}
+
+func (l *Limiter) Release() {
+	l.current.Add(-1)
+}
+
+// LimiterStatus is the JSON view of a Limiter
+type LimiterStatus struct {
+	Current  int64 `json:"current"`
+	Max      int64 `json:"max"`
+	Rejected int64 `json:"rejected"`
+}
+
+func (l *Limiter) Status() LimiterStatus {
+	return LimiterStatus{
+		Current:  l.current.Load(),
+		Max:      l.max,
+		Rejected: l.rejected.Load(),
+	}
+}
+
+// rejectBusy tells the client the server is at capacity
+func rejectBusy(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	http.Error(w, "Server busy, try again later", http.StatusServiceUnavailable)
+}
+
+// limitRequests caps concurrent HTTP requests. Streaming endpoints are held
+// open for the life of the client, so they are admitted against the client
+// limit in their handlers instead, and /api/status is always answered.
+func (s *Server) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws", "/api/stream", "/api/status":
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.requestLimit.Acquire() {
+			rejectBusy(w)
+			return
+		}
+		defer s.requestLimit.Release()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Longest expression an expr rule accepts
+const maxAlertExpr = 500
+
+// alertVariables are the values an expr rule can compare, read from a
+// closed candle and the indicators over the candles up to it. A variable
+// whose indicator hasn't enough candles yet makes its comparisons false.
+var alertVariables = map[string]func(c Candle, ind Indicators) (float64, bool){
+	"price":       func(c Candle, _ Indicators) (float64, bool) { return c.Close, true },
+	"open":        func(c Candle, _ Indicators) (float64, bool) { return c.Open, true },
+	"high":        func(c Candle, _ Indicators) (float64, bool) { return c.High, true },
+	"low":         func(c Candle, _ Indicators) (float64, bool) { return c.Low, true },
+	"close":       func(c Candle, _ Indicators) (float64, bool) { return c.Close, true },
+	"volume":      func(c Candle, _ Indicators) (float64, bool) { return c.Volume, true },
+	"buy_volume":  func(c Candle, _ Indicators) (float64, bool) { return c.BuyVolume, true },
+	"sell_volume": func(c Candle, _ Indicators) (float64, bool) { return c.SellVolume, true },
+	"trades":      func(c Candle, _ Indicators) (float64, bool) { return float64(c.Trades), true },
+	"change": func(c Candle, _ Indicators) (float64, bool) {
+		return (c.Close - c.Open) / c.Open * 100, c.Open > 0
+	},
+	"rsi": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.RSI == nil {
+			return 0, false
+		}
+		return ind.RSI.Value, true
+	},
+	"macd": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.MACD == nil {
+			return 0, false
+		}
+		return ind.MACD.MACD, true
+	},
+	"macd_signal": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.MACD == nil {
+			return 0, false
+		}
+		return ind.MACD.Signal, true
+	},
+	"macd_histogram": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.MACD == nil {
+			return 0, false
+		}
+		return ind.MACD.Histogram, true
+	},
+	"bb_upper": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.Bollinger == nil {
+			return 0, false
+		}
+		return ind.Bollinger.Upper, true
+	},
+	"bb_middle": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.Bollinger == nil {
+			return 0, false
+		}
+		return ind.Bollinger.Middle, true
+	},
+	"bb_lower": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.Bollinger == nil {
+			return 0, false
+		}
+		return ind.Bollinger.Lower, true
+	},
+	"bb_width": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.Bollinger == nil {
+			return 0, false
+		}
+		return ind.Bollinger.Bandwidth, true
+	},
+	"atr": func(_ Candle, ind Indicators) (float64, bool) {
+		if ind.ATR == nil {
+			return 0, false
+		}
+		return ind.ATR.Value, true
+	},
+}
+
+// alertLookup returns a variable's value on the latest closed candle of an
+// interval, the rule's own when interval is empty
+type alertLookup func(name, interval string) (float64, bool)
+
+// exprNode is a node of a parsed expr rule condition
+type exprNode interface {
+	eval(lookup alertLookup) bool
+}
+
+type exprAnd struct{ left, right exprNode }
+
+func (e exprAnd) eval(lookup alertLookup) bool { return e.left.eval(lookup) && e.right.eval(lookup) }
+
+type exprOr struct{ left, right exprNode }
+
+func (e exprOr) eval(lookup alertLookup) bool { return e.left.eval(lookup) || e.right.eval(lookup) }
+
+type exprNot struct{ x exprNode }
+
+func (e exprNot) eval(lookup alertLookup) bool { return !e.x.eval(lookup) }
+
+// exprCompare compares two operands with <, <=, >, >=, == or !=
+type exprCompare struct {
+	op          string
+	left, right exprOperand
+}
+
+func (e exprCompare) eval(lookup alertLookup) bool {
+	a, ok := e.left.value(lookup)
+	if !ok {
+		return false
+	}
+	b, ok := e.right.value(lookup)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "==":
+		return a == b
+	}
+	return a != b
+}
+
+// exprOperand is a number, or a variable when name is set
+type exprOperand struct {
+	number         float64
+	name, interval string
+}
+
+func (o exprOperand) value(lookup alertLookup) (float64, bool) {
+	if o.name == "" {
+		return o.number, true
+	}
+	return lookup(o.name, o.interval)
+}
+
+// parseAlertExpr parses a condition such as
+//
+//	price > 70000 and volume(5m) > 12 or rsi < 25
+//
+// Comparisons combine with and, or and not (or &&, || and !), and bind
+// tighter in that order; parentheses group. A variable followed by an
+// interval in parentheses reads that interval's latest closed candle
+// instead of the rule's.
+func parseAlertExpr(text string) (exprNode, error) {
+	if len(text) > maxAlertExpr {
+		return nil, fmt.Errorf("expr must be at most %d characters", maxAlertExpr)
+	}
+	tokens, err := lexAlertExpr(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("expr is required")
+	}
+	p := &exprParser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("expr: unexpected %q", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+// lexAlertExpr splits an expression into words (names, numbers, intervals
+// and keywords, lower cased), operators and parentheses
+func lexAlertExpr(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == '-':
+			tokens = append(tokens, text[i:i+1])
+			i++
+		case strings.IndexByte("<>=!&|", c) >= 0:
+			j := i + 1
+			if j < len(text) && strings.IndexByte("=&|", text[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		case isExprWordByte(c):
+			j := i
+			for j < len(text) && isExprWordByte(text[j]) {
+				j++
+			}
+			tokens = append(tokens, strings.ToLower(text[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("expr: unexpected %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func isExprWordByte(c byte) bool {
+	return c == '_' || c == '.' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	left, err := p.and()
+	for err == nil && (p.peek() == "or" || p.peek() == "||") {
+		p.next()
+		var right exprNode
+		right, err = p.and()
+		left = exprOr{left, right}
+	}
+	return left, err
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	left, err := p.not()
+	for err == nil && (p.peek() == "and" || p.peek() == "&&") {
+		p.next()
+		var right exprNode
+		right, err = p.not()
+		left = exprAnd{left, right}
+	}
+	return left, err
+}
+
+func (p *exprParser) not() (exprNode, error) {
+	if p.peek() == "not" || p.peek() == "!" {
+		p.next()
+		x, err := p.not()
+		return exprNot{x}, err
+	}
+	if p.peek() == "(" {
+		p.next()
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("expr: missing )")
+		}
+		return x, nil
+	}
+	return p.compare()
+}
+
+func (p *exprParser) compare() (exprNode, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	if !slices.Contains([]string{"<", "<=", ">", ">=", "==", "!="}, op) {
+		return nil, fmt.Errorf("expr: want <, <=, >, >=, == or != instead of %q", op)
+	}
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return exprCompare{op, left, right}, nil
+}
+
+func (p *exprParser) operand() (exprOperand, error) {
+	tok := p.next()
+	negative := tok == "-"
+	if negative {
+		tok = p.next()
+	}
+	if v, err := strconv.ParseFloat(tok, 64); err == nil {
+		if negative {
+			v = -v
+		}
+		return exprOperand{number: v}, nil
+	}
+	if negative {
+		return exprOperand{}, fmt.Errorf("expr: want a number after -, got %q", tok)
+	}
+	if _, ok := alertVariables[tok]; !ok {
+		if tok == "" {
+			return exprOperand{}, errors.New("expr: unexpected end")
+		}
+		return exprOperand{}, fmt.Errorf("expr: unknown variable %q", tok)
+	}
+	operand := exprOperand{name: tok}
+	if p.peek() == "(" {
+		p.next()
+		operand.interval = p.next()
+		if _, ok := candleIntervals[operand.interval]; !ok {
+			return exprOperand{}, fmt.Errorf("expr: unknown interval %q", operand.interval)
+		}
+		if p.next() != ")" {
+			return exprOperand{}, errors.New("expr: missing )")
+		}
+	}
+	return operand, nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+// exprValues looks variables up by name, or by name(interval) for another
+// interval's; missing ones have no value yet
+func exprValues(values map[string]float64) alertLookup {
+	return func(name, interval string) (float64, bool) {
+		if interval != "" {
+			name += "(" + interval + ")"
+		}
+		v, ok := values[name]
+		return v, ok
+	}
+}
+
+func TestAlertExprEval(t *testing.T) {
+	lookup := exprValues(map[string]float64{
+		"price":      100,
+		"volume":     10,
+		"rsi":        20,
+		"volume(5m)": 50,
+	})
+	for _, tc := range []struct {
+		expr string
+		want bool
+	}{
+		{"price > 50", true},
+		{"price >= 100 and price <= 100", true},
+		{"price == 100 && price != 99", true},
+		// and binds tighter than or, not tighter than and
+		{"price > 50 or price > 200 and volume > 100", true},
+		{"(price > 50 or price > 200) and volume > 100", false},
+		{"price > 200 and volume > 100 or rsi < 25", true},
+		{"not price > 200 and volume > 100", false},
+		{"not (price > 200 and volume > 100)", true},
+		{"!(price > 200) && volume < 100", true},
+		{"not not price > 50", true},
+		{"((price > 50))", true},
+		// Numbers on either side, negative ones too
+		{"price > -5", true},
+		{"-5 < price", true},
+		{"price > - 5", true},
+		{"1 < 2", true},
+		{"price < 1e3", true},
+		// Another interval's candle
+		{"volume(5m) > 40", true},
+		{"volume > 40", false},
+		{"VOLUME(5M) > 40 AND Price > 1", true},
+		// A variable without a value makes its comparison false
+		{"macd > 0", false},
+		{"macd <= 0", false},
+		{"not macd > 0", true},
+		{"rsi(1h) < 30", false},
+	} {
+		expr, err := parseAlertExpr(tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		if got := expr.eval(lookup); got != tc.want {
+			t.Errorf("%s = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestAlertExprErrors(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want string // in the error
+	}{
+		{"", "required"},
+		{"   ", "required"},
+		{"foo > 1", `unknown variable "foo"`},
+		{"price > bar", `unknown variable "bar"`},
+		{"volume(7m) > 1", `unknown interval "7m"`},
+		{"volume() > 1", `unknown interval ")"`},
+		{"volume(5m > 1", "missing )"},
+		{"(price > 1", "missing )"},
+		{"price > 1 2", `unexpected "2"`},
+		{"price > 1)", `unexpected ")"`},
+		{"price > 1 and", "unexpected end"},
+		{"price 1", "want <, <=, >, >=, == or !="},
+		{"price = 1", "want <, <=, >, >=, == or !="},
+		{"price >", "unexpected end"},
+		{"price > -rsi", "want a number after -"},
+		{"price > 1 $", `unexpected '$'`},
+	} {
+		_, err := parseAlertExpr(tc.expr)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: got error %v, want one with %s", tc.expr, err, tc.want)
+		}
+	}
+}
+
+func TestAlertExprLength(t *testing.T) {
+	expr := "price > 1"
+	longest := expr + strings.Repeat(" ", maxAlertExpr-len(expr))
+	if _, err := parseAlertExpr(longest); err != nil {
+		t.Errorf("%d characters: %v", len(longest), err)
+	}
+	if _, err := parseAlertExpr(longest + " "); err == nil || !strings.Contains(err.Error(), "at most 500") {
+		t.Errorf("%d characters: got error %v, want the length limit", len(longest)+1, err)
+	}
+}
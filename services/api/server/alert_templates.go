@@ -0,0 +1,126 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Notifiers an alert message can be templated for
+const (
+	notifierWS       = "ws"
+	notifierWebhook  = "webhook"
+	notifierTelegram = "telegram"
+)
+
+var notifiers = []string{notifierWS, notifierWebhook, notifierTelegram}
+
+// Messages used when a rule has no template of its own
+var defaultAlertTemplates = map[string]string{
+	alertAbove:   `{{.Symbol}} crossed above {{.Threshold}} at {{.Price}}`,
+	alertBelow:   `{{.Symbol}} crossed below {{.Threshold}} at {{.Price}}`,
+	alertChange:  `{{.Symbol}} moved {{printf "%+.2f" .Move}}% in {{.Window}} to {{.Price}}`,
+	alertPattern: `{{.Symbol}} formed a {{.Pattern}} on the {{.Interval}} chart, closing at {{.Price}}`,
+	alertBand:    `{{.Symbol}} closed {{if eq .Band "lower"}}below the lower{{else}}above the upper{{end}} {{.Interval}} Bollinger Band ({{.Threshold}}) at {{.Price}}`,
+	alertATR:     `{{.Symbol}} {{.Interval}} candle ranged {{printf "%.1f" .Move}} ATRs, closing at {{.Price}}`,
+	alertExpr:    `{{.Symbol}} matched {{.Expression}} as the {{.Interval}} candle closed at {{.Price}}`,
+}
+
+// alertPrice prints without exponents or trailing zeros in templates, while
+// still working with printf verbs like %.2f
+type alertPrice float64
+
+func (p alertPrice) String() string { return formatAlertPrice(float64(p)) }
+
+// AlertTemplateData is what alert templates can refer to, e.g.
+// "{{.Symbol}} crossed {{.Threshold}} at {{.Price}}"
+type AlertTemplateData struct {
+	ID         string
+	Symbol     string // upper case, e.g. BTCUSDT
+	Condition  string
+	Threshold  alertPrice // price for above/below, percent for change, the band crossed or Multiple ATRs for band and atr
+	Window     string
+	Price      alertPrice
+	Move       float64 // percent move that fired a change rule, or the candle's range in ATRs for atr
+	Pattern    string  // candle pattern that fired a pattern rule
+	Band       string  // upper or lower, the band a band rule's candle closed outside
+	Expression string  // condition of an expr rule
+	Interval   string
+	Time       time.Time
+}
+
+func templateData(rule AlertRule, price, move float64, ts time.Time) AlertTemplateData {
+	threshold := rule.Price
+	if rule.Condition == alertChange {
+		threshold = rule.Percent
+	}
+	return AlertTemplateData{
+		ID:         rule.ID,
+		Symbol:     strings.ToUpper(rule.Symbol),
+		Condition:  rule.Condition,
+		Threshold:  alertPrice(threshold),
+		Window:     rule.Window,
+		Price:      alertPrice(price),
+		Move:       move,
+		Pattern:    rule.Pattern,
+		Band:       rule.Band,
+		Expression: rule.Expression,
+		Interval:   rule.Interval,
+		Time:       ts,
+	}
+}
+
+// compileAlertTemplates builds the template for every notifier: the rule's
+// per-notifier template, else its shared template, else the default for its
+// condition. Templates are test-rendered so mistakes surface when the rule is
+// created rather than when it fires.
+func compileAlertTemplates(rule AlertRule) (map[string]*template.Template, error) {
+	for name := range rule.Templates {
+		if !isNotifier(name) {
+			return nil, fmt.Errorf("unknown notifier %q in templates (want ws, webhook or telegram)", name)
+		}
+	}
+
+	out := make(map[string]*template.Template, len(notifiers))
+	for _, name := range notifiers {
+		text := rule.Templates[name]
+		if text == "" {
+			text = rule.Template
+		}
+		if text == "" {
+			text = defaultAlertTemplates[rule.Condition]
+		}
+		t, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s template: %v", name, err)
+		}
+		if err := t.Execute(new(strings.Builder), templateData(rule, rule.Price, 0, time.Now())); err != nil {
+			return nil, fmt.Errorf("%s template: %v", name, err)
+		}
+		out[name] = t
+	}
+	return out, nil
+}
+
+func isNotifier(name string) bool {
+	for _, n := range notifiers {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renderAlert renders one notifier's message
+func renderAlert(t *template.Template, data AlertTemplateData) (string, error) {
+	if t == nil {
+		return "", errors.New("no template")
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,725 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Alert conditions
+const (
+	alertAbove   = "above"   // price crosses above Price
+	alertBelow   = "below"   // price crosses below Price
+	alertChange  = "change"  // price moves more than Percent within Window
+	alertPattern = "pattern" // a closed Interval candle forms Pattern
+	alertBand    = "band"    // a closed Interval candle closes outside a Bollinger Band
+	alertATR     = "atr"     // a closed Interval candle's true range reaches Multiple ATRs
+	alertExpr    = "expr"    // Expression turns true as an Interval candle closes
+)
+
+// Bollinger Bands a band rule watches
+const (
+	bandUpper   = "upper"
+	bandLower   = "lower"
+	bandOutside = "outside" // either
+)
+
+// Default ATR multiple of atr rules
+const defaultATRMultiple = 2
+
+const (
+	// Default re-arm band in percent of the threshold for crossing rules,
+	// and as a fraction of Percent for change rules
+	defaultHysteresis       = 0.1
+	defaultChangeHysteresis = 0.25
+
+	// Longest window a change alert can look back over
+	maxAlertWindow = 24 * time.Hour
+
+	webhookTimeout = 5 * time.Second
+)
+
+// telegramAPI is the Bot API alerts are sent to Telegram through
+var telegramAPI = "https://api.telegram.org"
+
+// AlertRule is a user-defined alert. Each rule fires once when its condition
+// becomes true and re-arms only after the price has moved back past the
+// hysteresis band, so a price hovering at the threshold doesn't spam.
+type AlertRule struct {
+	ID         string            `json:"id"`
+	Symbol     string            `json:"symbol"`
+	Condition  string            `json:"condition"`
+	Price      float64           `json:"price,omitempty"`
+	Percent    float64           `json:"percent,omitempty"`
+	Window     string            `json:"window,omitempty"`
+	Pattern    string            `json:"pattern,omitempty"`
+	Interval   string            `json:"interval,omitempty"`
+	Band       string            `json:"band,omitempty"`
+	Multiple   float64           `json:"multiple,omitempty"`
+	Expression string            `json:"expr,omitempty"`
+	Hysteresis float64           `json:"hysteresis_percent"`
+	Webhook    string            `json:"webhook,omitempty"`
+	Template   string            `json:"template,omitempty"`  // message for every notifier
+	Templates  map[string]string `json:"templates,omitempty"` // per notifier ("ws", "webhook", "telegram")
+	CreatedAt  time.Time         `json:"created_at"`
+	Armed      bool              `json:"armed"`
+	LastFired  *time.Time        `json:"last_fired,omitempty"`
+}
+
+// AlertNotification is sent to subscribers of the alerts channel, to the
+// rule's webhook and to the Telegram chat, when there is one
+type AlertNotification struct {
+	Channel string    `json:"channel"`
+	Type    string    `json:"type"`
+	Symbol  string    `json:"symbol"`
+	Price   float64   `json:"price"`
+	Message string    `json:"message"`
+	Text    string    `json:"text,omitempty"` // webhooks only, for Slack-style receivers
+	Time    time.Time `json:"time"`
+	Rule    AlertRule `json:"rule"`
+
+	webhookMessage  string
+	telegramMessage string
+}
+
+// alertSample is the price range seen during one second
+type alertSample struct {
+	sec       int64
+	low, high float64
+}
+
+type alertState struct {
+	rule      AlertRule
+	templates map[string]*template.Template
+	window    time.Duration
+	samples   []alertSample // change rules only, oldest first
+	expr      exprNode      // expr rules only
+}
+
+// AlertEngine holds the alert rules and evaluates them against the trade stream
+type AlertEngine struct {
+	mu     sync.Mutex
+	nextID int
+	rules  map[string]*alertState
+}
+
+func NewAlertEngine() *AlertEngine {
+	return &AlertEngine{rules: make(map[string]*alertState)}
+}
+
+// Add validates and stores a rule, filling in defaults
+func (e *AlertEngine) Add(rule AlertRule, now time.Time) (AlertRule, error) {
+	rule.Symbol = strings.ToLower(rule.Symbol)
+	if rule.Symbol == "" {
+		return AlertRule{}, errors.New("symbol is required")
+	}
+	if rule.Hysteresis < 0 {
+		return AlertRule{}, errors.New("hysteresis_percent must not be negative")
+	}
+
+	state := &alertState{}
+	switch rule.Condition {
+	case alertAbove, alertBelow:
+		if rule.Price <= 0 {
+			return AlertRule{}, errors.New("price must be positive")
+		}
+		rule.Percent, rule.Window = 0, ""
+		rule.Armed = false
+		if rule.Hysteresis == 0 {
+			rule.Hysteresis = defaultHysteresis
+		}
+	case alertChange:
+		if rule.Percent <= 0 {
+			return AlertRule{}, errors.New("percent must be positive")
+		}
+		if rule.Window == "" {
+			rule.Window = "5m"
+		}
+		window, err := time.ParseDuration(rule.Window)
+		if err != nil || window < time.Second || window > maxAlertWindow {
+			return AlertRule{}, errors.New("window must be a duration between 1s and 24h")
+		}
+		if rule.Hysteresis == 0 {
+			rule.Hysteresis = rule.Percent * defaultChangeHysteresis
+		}
+		if rule.Hysteresis >= rule.Percent {
+			return AlertRule{}, errors.New("hysteresis_percent must be below percent")
+		}
+		state.window = window
+		rule.Price = 0
+		rule.Armed = true
+	case alertPattern:
+		if !alertPatterns[rule.Pattern] {
+			return AlertRule{}, errors.New("pattern must be doji, hammer, engulfing, bullish_engulfing or bearish_engulfing")
+		}
+		if rule.Interval == "" {
+			rule.Interval = "1m"
+		}
+		if _, ok := candleIntervals[rule.Interval]; !ok {
+			return AlertRule{}, errors.New("unknown interval")
+		}
+		// Each matching candle fires once as it closes, so there is
+		// nothing to re-arm
+		rule.Price, rule.Percent, rule.Window, rule.Hysteresis = 0, 0, "", 0
+		rule.Armed = true
+	case alertBand, alertATR:
+		if rule.Interval == "" {
+			rule.Interval = "1m"
+		}
+		if _, ok := candleIntervals[rule.Interval]; !ok {
+			return AlertRule{}, errors.New("unknown interval")
+		}
+		if rule.Condition == alertBand {
+			if rule.Band == "" {
+				rule.Band = bandOutside
+			}
+			if rule.Band != bandUpper && rule.Band != bandLower && rule.Band != bandOutside {
+				return AlertRule{}, errors.New("band must be upper, lower or outside")
+			}
+		} else {
+			if rule.Multiple == 0 {
+				rule.Multiple = defaultATRMultiple
+			}
+			if rule.Multiple < 0 {
+				return AlertRule{}, errors.New("multiple must be positive")
+			}
+		}
+		// A band rule re-arms once a candle closes back inside the bands;
+		// an atr rule fires on every candle that ranges that far
+		rule.Price, rule.Percent, rule.Window, rule.Hysteresis = 0, 0, "", 0
+		rule.Armed = true
+	case alertExpr:
+		expr, err := parseAlertExpr(rule.Expression)
+		if err != nil {
+			return AlertRule{}, err
+		}
+		if rule.Interval == "" {
+			rule.Interval = "1m"
+		}
+		if _, ok := candleIntervals[rule.Interval]; !ok {
+			return AlertRule{}, errors.New("unknown interval")
+		}
+		// Fires as the expression turns true, re-arms once a candle closes
+		// with it false
+		state.expr = expr
+		rule.Expression = strings.TrimSpace(rule.Expression)
+		rule.Price, rule.Percent, rule.Window, rule.Hysteresis = 0, 0, "", 0
+		rule.Armed = true
+	default:
+		return AlertRule{}, errors.New("condition must be above, below, change, pattern, band, atr or expr")
+	}
+	if rule.Webhook != "" {
+		u, err := url.Parse(rule.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return AlertRule{}, errors.New("webhook must be an http(s) URL")
+		}
+	}
+
+	templates, err := compileAlertTemplates(rule)
+	if err != nil {
+		return AlertRule{}, err
+	}
+	state.templates = templates
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.nextID++
+	rule.ID = strconv.Itoa(e.nextID)
+	rule.CreatedAt = now
+	rule.LastFired = nil
+	state.rule = rule
+	e.rules[rule.ID] = state
+	return rule, nil
+}
+
+// List returns every rule ordered by creation
+func (e *AlertEngine) List() []AlertRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	out := make([]AlertRule, 0, len(e.rules))
+	for _, state := range e.rules {
+		out = append(out, state.rule)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := strconv.Atoi(out[i].ID)
+		b, _ := strconv.Atoi(out[j].ID)
+		return a < b
+	})
+	return out
+}
+
+// Test builds a test notification for one rule, rendered with the rule's
+// templates at the current price when current is for the rule's symbol
+func (e *AlertEngine) Test(id string, current ProcessedMessage, now time.Time) (AlertNotification, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	state, ok := e.rules[id]
+	if !ok {
+		return AlertNotification{}, false
+	}
+	price := state.rule.Price
+	if current.Symbol == state.rule.Symbol {
+		price = current.Price
+	}
+	n := state.notification("alert_test", templateData(state.rule, price, state.rule.Percent, now))
+	n.Message = "[test] " + n.Message
+	n.webhookMessage = "[test] " + n.webhookMessage
+	n.telegramMessage = "[test] " + n.telegramMessage
+	return n, true
+}
+
+// Remove deletes a rule, reporting whether it existed
+func (e *AlertEngine) Remove(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.rules[id]
+	delete(e.rules, id)
+	return ok
+}
+
+// Evaluate runs every rule for symbol against a trade and returns the alerts
+// that fired
+func (e *AlertEngine) Evaluate(symbol string, price float64, ts time.Time) []AlertNotification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var fired []AlertNotification
+	for _, state := range e.rules {
+		if state.rule.Symbol != symbol || candleCondition(state.rule.Condition) {
+			continue
+		}
+		if move, ok := state.evaluate(price, ts); ok {
+			t := ts
+			state.rule.LastFired = &t
+			fired = append(fired, state.notification("alert", templateData(state.rule, price, move, ts)))
+		}
+	}
+	return fired
+}
+
+// candleCondition reports whether rules of condition are evaluated on
+// closed candles rather than trades
+func candleCondition(condition string) bool {
+	return condition == alertPattern || condition == alertBand || condition == alertATR || condition == alertExpr
+}
+
+// latestCandle is an interval's latest closed candle and the indicators
+// over the closed candles up to it
+type latestCandle struct {
+	candle     Candle
+	indicators Indicators
+	ok         bool // false until a candle of the interval has closed
+}
+
+// EvaluateCandles runs the candle rules for symbol against candles that
+// just closed and returns the alerts that fired. latest returns an
+// interval's latest closed candle, the new ones included, and its
+// indicators; it is only called for intervals band, atr and expr rules read.
+func (e *AlertEngine) EvaluateCandles(symbol string, closed []ClosedCandle, latest func(interval string) (Candle, Indicators, bool)) []AlertNotification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	computed := make(map[string]latestCandle)
+	latestOf := func(interval string) latestCandle {
+		l, ok := computed[interval]
+		if !ok {
+			l.candle, l.indicators, l.ok = latest(interval)
+			computed[interval] = l
+		}
+		return l
+	}
+
+	var fired []AlertNotification
+	for _, state := range e.rules {
+		r := &state.rule
+		if r.Symbol != symbol || !candleCondition(r.Condition) {
+			continue
+		}
+		for _, cc := range closed {
+			if cc.Interval != r.Interval {
+				continue
+			}
+			if r.Condition == alertExpr {
+				if state.evaluateExpr(cc, latestOf) {
+					t := cc.Candle.Time
+					r.LastFired = &t
+					fired = append(fired, state.notification("alert", templateData(*r, cc.Candle.Close, 0, t)))
+				}
+				continue
+			}
+			if r.Condition != alertPattern {
+				if data, ok := state.evaluateCandle(cc, latestOf(cc.Interval).indicators); ok {
+					t := cc.Candle.Time
+					r.LastFired = &t
+					fired = append(fired, state.notification("alert", data))
+				}
+				continue
+			}
+			for _, p := range detectPatterns(cc.Prev, cc.Candle) {
+				if !matchesPattern(r.Pattern, p) {
+					continue
+				}
+				t := cc.Candle.Time
+				r.LastFired = &t
+				data := templateData(*r, cc.Candle.Close, 0, t)
+				data.Pattern = p
+				fired = append(fired, state.notification("alert", data))
+			}
+		}
+	}
+	return fired
+}
+
+// evaluateCandle checks a band or atr rule against a closed candle and the
+// indicators it completed, returning the template data when it fires
+func (st *alertState) evaluateCandle(cc ClosedCandle, ind Indicators) (AlertTemplateData, bool) {
+	r := &st.rule
+	c := cc.Candle
+	switch r.Condition {
+	case alertBand:
+		b := ind.Bollinger
+		if b == nil {
+			return AlertTemplateData{}, false
+		}
+		var crossed string
+		var edge float64
+		switch {
+		case c.Close > b.Upper && r.Band != bandLower:
+			crossed, edge = bandUpper, b.Upper
+		case c.Close < b.Lower && r.Band != bandUpper:
+			crossed, edge = bandLower, b.Lower
+		}
+		if crossed == "" {
+			r.Armed = r.Armed || (c.Close <= b.Upper && c.Close >= b.Lower)
+			return AlertTemplateData{}, false
+		}
+		if !r.Armed {
+			return AlertTemplateData{}, false
+		}
+		r.Armed = false
+		data := templateData(*r, c.Close, 0, c.Time)
+		data.Threshold = alertPrice(edge)
+		data.Band = crossed
+		return data, true
+	case alertATR:
+		a := ind.ATR
+		if a == nil || a.Value <= 0 {
+			return AlertTemplateData{}, false
+		}
+		ratio := trueRange(cc.Prev, c) / a.Value
+		if ratio < r.Multiple {
+			return AlertTemplateData{}, false
+		}
+		data := templateData(*r, c.Close, ratio, c.Time)
+		data.Threshold = alertPrice(a.Value * r.Multiple)
+		return data, true
+	}
+	return AlertTemplateData{}, false
+}
+
+// evaluateExpr checks an expr rule against a closed candle of its interval
+// and reports whether the expression just turned true. Variables with an
+// interval of their own read latestOf that interval.
+func (st *alertState) evaluateExpr(cc ClosedCandle, latestOf func(interval string) latestCandle) bool {
+	r := &st.rule
+	ind := latestOf(cc.Interval).indicators
+	match := st.expr.eval(func(name, interval string) (float64, bool) {
+		if interval == "" || interval == cc.Interval {
+			return alertVariables[name](cc.Candle, ind)
+		}
+		l := latestOf(interval)
+		if !l.ok {
+			return 0, false
+		}
+		return alertVariables[name](l.candle, l.indicators)
+	})
+	if !match {
+		r.Armed = true
+		return false
+	}
+	if !r.Armed {
+		return false
+	}
+	r.Armed = false
+	return true
+}
+
+// notification renders the rule's messages for a firing
+func (st *alertState) notification(kind string, data AlertTemplateData) AlertNotification {
+	render := func(notifier string) string {
+		msg, err := renderAlert(st.templates[notifier], data)
+		if err != nil {
+			log.Printf("Alert %s %s template error: %v", st.rule.ID, notifier, err)
+			return fmt.Sprintf("%s alert %s fired at %s", data.Symbol, st.rule.ID, data.Price)
+		}
+		return msg
+	}
+	return AlertNotification{
+		Channel:         channelAlerts,
+		Type:            kind,
+		Symbol:          st.rule.Symbol,
+		Price:           float64(data.Price),
+		Message:         render(notifierWS),
+		Time:            data.Time,
+		Rule:            st.rule,
+		webhookMessage:  render(notifierWebhook),
+		telegramMessage: render(notifierTelegram),
+	}
+}
+
+// evaluate updates the rule's state with a trade and reports whether it
+// fired, along with the move in percent for change rules
+func (st *alertState) evaluate(price float64, ts time.Time) (float64, bool) {
+	r := &st.rule
+	band := r.Hysteresis / 100
+
+	switch r.Condition {
+	case alertAbove:
+		if !r.Armed {
+			// Crossing means the price has to be seen below the line first
+			r.Armed = price < r.Price*(1-band)
+			return 0, false
+		}
+		if price >= r.Price {
+			r.Armed = false
+			return 0, true
+		}
+	case alertBelow:
+		if !r.Armed {
+			r.Armed = price > r.Price*(1+band)
+			return 0, false
+		}
+		if price <= r.Price {
+			r.Armed = false
+			return 0, true
+		}
+	case alertChange:
+		move := st.addSample(price, ts)
+		if !r.Armed {
+			r.Armed = math.Abs(move) < r.Percent-r.Hysteresis
+			return 0, false
+		}
+		if math.Abs(move) > r.Percent {
+			r.Armed = false
+			return move, true
+		}
+	}
+	return 0, false
+}
+
+// addSample records a trade and returns the largest move, in percent, from
+// the window's low (positive) or high (negative) to price
+func (st *alertState) addSample(price float64, ts time.Time) float64 {
+	sec := ts.Unix()
+	n := len(st.samples)
+	if n > 0 && st.samples[n-1].sec == sec {
+		s := &st.samples[n-1]
+		s.low = min(s.low, price)
+		s.high = max(s.high, price)
+	} else {
+		st.samples = append(st.samples, alertSample{sec: sec, low: price, high: price})
+	}
+
+	cutoff := sec - int64(st.window.Seconds())
+	drop := 0
+	for drop < len(st.samples) && st.samples[drop].sec <= cutoff {
+		drop++
+	}
+	st.samples = st.samples[drop:]
+
+	low, high := price, price
+	for _, s := range st.samples {
+		low = min(low, s.low)
+		high = max(high, s.high)
+	}
+	up := (price - low) / low * 100
+	down := (price - high) / high * 100
+	if up >= -down {
+		return up
+	}
+	return down
+}
+
+func formatAlertPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// evaluateAlerts checks a processed trade, and any candles it closed,
+// against the alert rules and delivers whatever fired
+func (s *Server) evaluateAlerts(symbol string, price float64, ts time.Time, closed []ClosedCandle) {
+	fired := s.alerts.Evaluate(symbol, price, ts)
+	if len(closed) > 0 {
+		fired = append(fired, s.alerts.EvaluateCandles(symbol, closed, func(interval string) (Candle, Indicators, bool) {
+			// The newest candle is still open
+			candles := s.candles.Candles(symbol, interval, 2)
+			if len(candles) < 2 {
+				return Candle{}, Indicators{}, false
+			}
+			return candles[0], s.indicators(symbol, interval, indicatorNames()), true
+		})...)
+	}
+	for _, n := range fired {
+		log.Printf("Alert %s: %s", n.Rule.ID, n.Message)
+		s.reports.AddAlert(n)
+		s.broadcastAlert(n)
+		if n.Rule.Webhook != "" {
+			go func() {
+				if err := postWebhook(n.Rule.Webhook, webhookBody(n)); err != nil {
+					log.Printf("Alert webhook error: %v", err)
+				}
+			}()
+		}
+		if s.cfg.TelegramToken != "" {
+			go func() {
+				if err := postTelegram(s.cfg.TelegramToken, s.cfg.TelegramChat, n.telegramMessage); err != nil {
+					log.Printf("Alert Telegram error: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// webhookBody encodes a notification with its webhook message, repeated as
+// "text" so Slack-compatible receivers display it as is
+func webhookBody(n AlertNotification) []byte {
+	n.Message = n.webhookMessage
+	n.Text = n.webhookMessage
+	data, _ := json.Marshal(n)
+	return data
+}
+
+// broadcastAlert sends a notification to alerts subscribers
+func (s *Server) broadcastAlert(n AlertNotification) {
+	data, _ := json.Marshal(n)
+	s.hub.Broadcast(event{
+		symbol: n.Symbol,
+		build: func(sub subscription) []byte {
+			if sub.Channel != channelAlerts {
+				return nil
+			}
+			return data
+		},
+	})
+}
+
+// postWebhook delivers an alert to a webhook
+func postWebhook(url string, data []byte) error {
+	client := http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// postTelegram sends an alert message to a Telegram chat through a bot
+func postTelegram(token, chatID, text string) error {
+	data, _ := json.Marshal(map[string]string{"chat_id": chatID, "text": text})
+	client := http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(telegramAPI+"/bot"+token+"/sendMessage", "application/json", bytes.NewReader(data))
+	if err != nil {
+		// The URL holds the bot token, so it stays out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var body struct {
+			Description string `json:"description"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+		return fmt.Errorf("telegram returned %s: %s", resp.Status, body.Description)
+	}
+	return nil
+}
+
+// handleAlertTest fires a test notification for one rule through every
+// delivery channel. The webhook and Telegram are called synchronously so the
+// response can report whether they worked.
+func (s *Server) handleAlertTest(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	current := s.current
+	s.mu.RUnlock()
+
+	n, ok := s.alerts.Test(r.PathValue("id"), current, s.clock.Now())
+	if !ok {
+		http.Error(w, "Unknown alert", http.StatusNotFound)
+		return
+	}
+	s.broadcastAlert(n)
+
+	result := struct {
+		Notification AlertNotification `json:"notification"`
+		Webhook      string            `json:"webhook"`
+		Telegram     string            `json:"telegram"`
+	}{Notification: n, Webhook: "not configured", Telegram: "not configured"}
+	if n.Rule.Webhook != "" {
+		result.Webhook = "delivered"
+		if err := postWebhook(n.Rule.Webhook, webhookBody(n)); err != nil {
+			result.Webhook = err.Error()
+		}
+	}
+	if s.cfg.TelegramToken != "" {
+		result.Telegram = "delivered"
+		if err := postTelegram(s.cfg.TelegramToken, s.cfg.TelegramChat, n.telegramMessage); err != nil {
+			result.Telegram = err.Error()
+		}
+	}
+
+	s.writeJSON(w, r, result)
+}
+
+// handleAlerts lists (GET), creates (POST) or deletes (DELETE ?id=) alert rules
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, r, s.alerts.List())
+	case http.MethodPost:
+		var rule AlertRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if rule.Symbol == "" {
+			s.mu.RLock()
+			rule.Symbol = s.symbol
+			s.mu.RUnlock()
+		}
+		rule, err := s.alerts.Add(rule, s.clock.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		s.writeJSON(w, r, rule)
+	case http.MethodDelete:
+		if !s.alerts.Remove(r.URL.Query().Get("id")) {
+			http.Error(w, "Unknown alert", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
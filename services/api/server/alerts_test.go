@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostTelegram(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:secret/sendMessage" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+	defer func(api string) { telegramAPI = api }(telegramAPI)
+	telegramAPI = srv.URL
+
+	if err := postTelegram("123:secret", "-10042", "BTCUSDT crossed above 100000"); err != nil {
+		t.Fatal(err)
+	}
+	if got["chat_id"] != "-10042" || got["text"] != "BTCUSDT crossed above 100000" {
+		t.Errorf("sent %v", got)
+	}
+
+	err := postTelegram("123:wrong", "-10042", "hi")
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("got error %v, want Telegram's description", err)
+	}
+
+	// Errors reach logs and the test endpoint, so the token stays out of them
+	srv.Close()
+	err = postTelegram("123:secret", "-10042", "hi")
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("got error %v, want one without the token", err)
+	}
+}
+
+func TestTelegramTemplate(t *testing.T) {
+	templates, err := compileAlertTemplates(AlertRule{
+		Symbol:    "btcusdt",
+		Condition: alertAbove,
+		Price:     100000,
+		Template:  "{{.Symbol}} above {{.Threshold}}",
+		Templates: map[string]string{notifierTelegram: "🚨 {{.Symbol}} at {{.Price}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &alertState{rule: AlertRule{ID: "a1", Symbol: "btcusdt"}, templates: templates}
+	n := st.notification("alert", AlertTemplateData{Symbol: "BTCUSDT", Threshold: 100000, Price: 100001.5})
+	if n.telegramMessage != "🚨 BTCUSDT at 100001.5" || n.webhookMessage != "BTCUSDT above 100000" {
+		t.Errorf("telegram %q, webhook %q", n.telegramMessage, n.webhookMessage)
+	}
+}
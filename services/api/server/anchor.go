@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Anchor is a reference price that changes are measured against
+type Anchor struct {
+	Price float64   `json:"price"`
+	SetAt time.Time `json:"set_at"`
+}
+
+// AnchorDelta reports the current price relative to an anchor
+type AnchorDelta struct {
+	Anchor
+	Symbol        string  `json:"symbol"`
+	Current       float64 `json:"current"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+}
+
+// anchorDelta returns the selected symbol's anchor, or nil when none is set.
+// Caller must hold s.mu.
+func (s *Server) anchorDelta() *AnchorDelta {
+	anchor, ok := s.anchors[s.symbol]
+	if !ok {
+		return nil
+	}
+
+	d := &AnchorDelta{Anchor: anchor, Symbol: s.symbol, Current: s.current.Price}
+	if anchor.Price > 0 && s.current.Price > 0 {
+		d.Change = s.current.Price - anchor.Price
+		d.ChangePercent = d.Change / anchor.Price * 100
+	}
+	return d
+}
+
+// handleAnchor reads (GET), sets (POST) or clears (DELETE) the anchor for the
+// selected symbol. POST anchors the current price unless a price is given.
+func (s *Server) handleAnchor(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Price float64 `json:"price"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Price < 0 {
+				http.Error(w, "Invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+
+		s.mu.Lock()
+		price := req.Price
+		if price == 0 {
+			price = s.current.Price
+		}
+		if price == 0 {
+			s.mu.Unlock()
+			http.Error(w, "No price to anchor yet", http.StatusConflict)
+			return
+		}
+		s.anchors[s.symbol] = Anchor{Price: price, SetAt: s.clock.Now()}
+		s.mu.Unlock()
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.anchors, s.symbol)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	delta := s.anchorDelta()
+	s.mu.RUnlock()
+
+	if delta == nil {
+		http.Error(w, "No anchor set", http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, r, delta)
+}
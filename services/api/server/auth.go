@@ -0,0 +1,70 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// tokenMatches compares token to want in constant time; an empty want
+// matches nothing
+func tokenMatches(token, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// bearerToken is the token of the request's Authorization header
+func bearerToken(r *http.Request) string {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token
+}
+
+// unauthorized rejects a request that lacks the token it needs
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// authorized reports whether r carries API_TOKEN, or ADMIN_TOKEN, which
+// grants everything the API token does. Browsers can't set headers on
+// WebSocket and EventSource connections, so the streaming endpoints also
+// take it as ?token=. Without an API token every request is authorized.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.cfg.APIToken == "" {
+		return true
+	}
+	token := bearerToken(r)
+	if token == "" && isStreamPath(r.URL.Path) {
+		token = r.URL.Query().Get("token")
+	}
+	return tokenMatches(token, s.cfg.APIToken) || tokenMatches(token, s.adminToken)
+}
+
+func isStreamPath(path string) bool {
+	return path == "/ws" || path == "/api/stream"
+}
+
+// requireToken enforces API_TOKEN on every request that changes state,
+// i.e. anything but GET, HEAD and OPTIONS, such as POST /api/symbol, alerts
+// and paper orders. Streaming connections need it too with WS_AUTH;
+// otherwise they connect freely but only those that sent it may change the
+// symbol over JSON-RPC. Admin endpoints check ADMIN_TOKEN on top.
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	if s.cfg.APIToken == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var needed bool
+		switch {
+		case isStreamPath(r.URL.Path):
+			needed = s.cfg.WSAuth
+		case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+		default:
+			needed = true
+		}
+		if needed && !s.authorized(r) {
+			unauthorized(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
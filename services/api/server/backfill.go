@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StoreWatermark tracks the newest stored trade per symbol, so history that
+// ingestion backfills after a restart isn't stored a second time
+type StoreWatermark struct {
+	mu     sync.Mutex
+	newest map[string]time.Time
+}
+
+func NewStoreWatermark() *StoreWatermark {
+	return &StoreWatermark{newest: make(map[string]time.Time)}
+}
+
+// Admit reports whether a trade at ts should be stored. Live trades always
+// are; backfilled ones only when newer than anything stored for the symbol,
+// which is looked up in store the first time the symbol is seen.
+func (w *StoreWatermark) Admit(store Store, symbol string, ts time.Time, backfill bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	newest, ok := w.newest[symbol]
+	if !ok && backfill {
+		trades, err := store.History(context.Background(), HistoryQuery{Symbol: symbol, Limit: 1})
+		if err == nil && len(trades) > 0 {
+			newest = trades[0].Timestamp
+		}
+	}
+	if backfill && !ts.After(newest) {
+		w.newest[symbol] = newest
+		return false
+	}
+	if ts.After(newest) {
+		newest = ts
+	}
+	w.newest[symbol] = newest
+	return true
+}
+
+// TradeWatermark tracks the newest trade the in-memory trackers have counted
+// per symbol. Backfilled history older than that overlaps trades they
+// already counted, such as the period ingestion publishes again when a
+// paused symbol resumes, and would count them twice.
+type TradeWatermark struct {
+	mu     sync.Mutex
+	newest map[string]int64 // unix ms
+}
+
+func NewTradeWatermark() *TradeWatermark {
+	return &TradeWatermark{newest: make(map[string]int64)}
+}
+
+// Admit reports whether a trade at ts (unix ms) should be counted. Live
+// trades always are; backfilled ones unless older than the newest counted.
+func (w *TradeWatermark) Admit(symbol string, ts int64, backfill bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	newest := w.newest[symbol]
+	if backfill && ts < newest {
+		return false
+	}
+	if ts > newest {
+		w.newest[symbol] = ts
+	}
+	return true
+}
@@ -0,0 +1,26 @@
+package server
+
+import "testing"
+
+func TestTradeWatermark(t *testing.T) {
+	w := NewTradeWatermark()
+	for _, tc := range []struct {
+		ts       int64
+		backfill bool
+		want     bool
+	}{
+		{1000, true, true},  // history before any live trade
+		{2000, false, true}, // live
+		{1500, true, false}, // resumed backfill overlapping it
+		{2000, true, true},  // same millisecond
+		{1500, false, true}, // live trades always count
+		{2500, true, true},
+	} {
+		if got := w.Admit("btcusdt", tc.ts, tc.backfill); got != tc.want {
+			t.Errorf("Admit(%d, backfill %v) = %v, want %v", tc.ts, tc.backfill, got, tc.want)
+		}
+	}
+	if !w.Admit("ethusdt", 1000, true) {
+		t.Error("symbols share a watermark")
+	}
+}
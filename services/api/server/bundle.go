@@ -0,0 +1,303 @@
+package server
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Version of the bundle layout, recorded in its manifest
+const bundleVersion = 1
+
+// Anomaly thresholds for the bundle report: a pause between trades longer
+// than bundleGap, or a move between consecutive trades beyond
+// bundleJumpPercent
+const (
+	bundleGap         = time.Minute
+	bundleJumpPercent = 5.0
+)
+
+// Anomalies listed per kind; the counts cover them all
+const maxBundleAnomalies = 1000
+
+// Candle intervals written to a bundle
+var bundleIntervals = []string{"1m", "5m", "1h"}
+
+// BundleOptions selects what ExportBundle writes
+type BundleOptions struct {
+	Symbols  []string
+	From, To time.Time // To is exclusive
+	// Config is recorded as is in config.json; leave out anything private
+	Config map[string]any
+}
+
+// BundleManifest describes a bundle. It is the first file in the archive.
+type BundleManifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	From      time.Time      `json:"from"`
+	To        time.Time      `json:"to"`
+	Trades    map[string]int `json:"trades"` // by symbol
+}
+
+// BundleAnomalies is anomalies.json: irregularities in the bundled trades
+// worth a look before trusting them
+type BundleAnomalies struct {
+	Gaps         []TradeGap  `json:"gaps"`
+	GapCount     int         `json:"gap_count"`
+	Jumps        []PriceJump `json:"jumps"`
+	JumpCount    int         `json:"jump_count"`
+	InvalidCount int         `json:"invalid_count"` // zero, negative or non-finite prices
+}
+
+// TradeGap is a pause between two trades of a symbol
+type TradeGap struct {
+	Symbol string    `json:"symbol"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+}
+
+// PriceJump is a large move between two consecutive trades
+type PriceJump struct {
+	Symbol  string    `json:"symbol"`
+	Time    time.Time `json:"time"`
+	From    float64   `json:"from"`
+	To      float64   `json:"to"`
+	Percent float64   `json:"percent"`
+}
+
+// ExportBundle writes a gzipped tar of the selected trades for a bug report
+// or a shared dataset: manifest.json, config.json, anomalies.json,
+// candles.json (the latest candles per symbol and interval) and
+// trades.ndjson, oldest first per symbol. Trades are staged in a temporary
+// file, since tar needs each file's size up front.
+func ExportBundle(ctx context.Context, store Store, opts BundleOptions, w io.Writer) (BundleManifest, error) {
+	manifest := BundleManifest{
+		Version:   bundleVersion,
+		CreatedAt: time.Now().UTC(),
+		From:      opts.From.UTC(),
+		To:        opts.To.UTC(),
+		Trades:    make(map[string]int),
+	}
+
+	staged, err := os.CreateTemp("", "bundle-trades-*.ndjson")
+	if err != nil {
+		return manifest, err
+	}
+	defer os.Remove(staged.Name())
+	defer staged.Close()
+
+	buf := bufio.NewWriter(staged)
+	enc := json.NewEncoder(buf)
+	candles := NewCandleAggregator()
+	anomalies := BundleAnomalies{Gaps: []TradeGap{}, Jumps: []PriceJump{}}
+	for _, symbol := range opts.Symbols {
+		q := HistoryQuery{Symbol: symbol, From: opts.From, To: opts.To, Limit: exportChunkSize, Ascending: true}
+		var prev *Trade
+		for {
+			trades, err := store.History(ctx, q)
+			if err != nil {
+				return manifest, fmt.Errorf("reading %s: %w", symbol, err)
+			}
+			for i := range trades {
+				t := trades[i]
+				if err := enc.Encode(t); err != nil {
+					return manifest, err
+				}
+				candles.Add(symbol, t.Price.Float64(), t.Qty, t.Side, t.Timestamp.UnixMilli())
+				anomalies.check(prev, t)
+				prev = &trades[i]
+			}
+			manifest.Trades[symbol] += len(trades)
+			if len(trades) < q.Limit {
+				break
+			}
+			next := nextCursor(q.Cursor, trades)
+			q.Cursor = &next
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return manifest, err
+	}
+
+	series := make(map[string]map[string][]Candle)
+	for _, symbol := range opts.Symbols {
+		series[symbol] = make(map[string][]Candle)
+		for _, interval := range bundleIntervals {
+			series[symbol][interval] = candles.Candles(symbol, interval, maxCandles)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct {
+		name string
+		v    any
+	}{
+		{"manifest.json", manifest},
+		{"config.json", opts.Config},
+		{"anomalies.json", anomalies},
+		{"candles.json", series},
+	} {
+		data, _ := json.MarshalIndent(f.v, "", "  ")
+		if err := writeTarFile(tw, f.name, int64(len(data)), bytes.NewReader(data), manifest.CreatedAt); err != nil {
+			return manifest, err
+		}
+	}
+	size, err := staged.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return manifest, err
+	}
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return manifest, err
+	}
+	if err := writeTarFile(tw, "trades.ndjson", size, staged, manifest.CreatedAt); err != nil {
+		return manifest, err
+	}
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gz.Close()
+}
+
+// check records what is irregular about t given the symbol's previous trade
+func (a *BundleAnomalies) check(prev *Trade, t Trade) {
+	if t.Price <= 0 {
+		a.InvalidCount++
+		return
+	}
+	if prev == nil || prev.Price <= 0 {
+		return
+	}
+	if t.Timestamp.Sub(prev.Timestamp) > bundleGap {
+		a.GapCount++
+		if len(a.Gaps) < maxBundleAnomalies {
+			a.Gaps = append(a.Gaps, TradeGap{Symbol: t.Symbol, From: prev.Timestamp, To: t.Timestamp})
+		}
+	}
+	from, to := prev.Price.Float64(), t.Price.Float64()
+	if move := (to - from) / from * 100; math.Abs(move) > bundleJumpPercent {
+		a.JumpCount++
+		if len(a.Jumps) < maxBundleAnomalies {
+			a.Jumps = append(a.Jumps, PriceJump{Symbol: t.Symbol, Time: t.Timestamp, From: from, To: to, Percent: move})
+		}
+	}
+}
+
+// ImportBundle stores the trades of a bundle written by ExportBundle. A
+// symbol that already has trades in the bundle's range is skipped rather
+// than stored twice. It returns the manifest and the trades stored per
+// symbol.
+func ImportBundle(ctx context.Context, store Store, r io.Reader) (BundleManifest, map[string]int, error) {
+	var manifest BundleManifest
+	imported := make(map[string]int)
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, imported, fmt.Errorf("not a bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	skip := make(map[string]bool)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return manifest, imported, err
+		}
+
+		switch hdr.Name {
+		case "manifest.json":
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return manifest, imported, fmt.Errorf("manifest.json: %w", err)
+			}
+			if manifest.Version != bundleVersion {
+				return manifest, imported, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+			}
+			for symbol := range manifest.Trades {
+				existing, err := store.History(ctx, HistoryQuery{Symbol: symbol, From: manifest.From, To: manifest.To, Limit: 1})
+				if err != nil {
+					return manifest, imported, err
+				}
+				skip[symbol] = len(existing) > 0
+			}
+		case "trades.ndjson":
+			if manifest.Version == 0 {
+				return manifest, imported, errors.New("trades.ndjson before manifest.json")
+			}
+			if err := importTrades(ctx, store, tr, skip, imported); err != nil {
+				return manifest, imported, err
+			}
+		}
+	}
+	if manifest.Version == 0 {
+		return manifest, imported, errors.New("bundle has no manifest.json")
+	}
+	return manifest, imported, nil
+}
+
+// importTrades stores NDJSON trades in batches where the store supports it
+func importTrades(ctx context.Context, store Store, r io.Reader, skip map[string]bool, imported map[string]int) error {
+	batcher, _ := store.(interface {
+		InsertBatch(ctx context.Context, trades []Trade) error
+	})
+	batch := make([]Trade, 0, exportChunkSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if batcher != nil {
+			if err := batcher.InsertBatch(ctx, batch); err != nil {
+				return err
+			}
+		} else {
+			for _, t := range batch {
+				if err := store.Insert(ctx, t); err != nil {
+					return err
+				}
+			}
+		}
+		for _, t := range batch {
+			imported[t.Symbol]++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var t Trade
+		if err := dec.Decode(&t); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("trades.ndjson: %w", err)
+		}
+		if skip[t.Symbol] || t.Symbol == "" {
+			continue
+		}
+		batch = append(batch, t)
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+func writeTarFile(tw *tar.Writer, name string, size int64, r io.Reader, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"api/store"
+)
+
+// Candles kept in memory per symbol and interval
+const maxCandles = 1000
+
+// Supported candle intervals
+var candleIntervals = map[string]time.Duration{
+	"1s": time.Second,
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// addVolume counts a trade's size toward a candle
+func addVolume(c *Candle, qty float64, side string) {
+	c.Volume = roundQuantity(c.Volume + qty)
+	switch side {
+	case sideBuy:
+		c.BuyVolume = roundQuantity(c.BuyVolume + qty)
+	case sideSell:
+		c.SellVolume = roundQuantity(c.SellVolume + qty)
+	}
+}
+
+// ClosedCandle is a candle that ended because a trade opened the next one
+type ClosedCandle struct {
+	Interval string
+	Candle   Candle
+	Prev     *Candle // the candle before it, nil if none is kept
+}
+
+// CandleAggregator builds candles from the processed trade stream
+type CandleAggregator struct {
+	mu     sync.RWMutex
+	series map[string]map[string][]Candle // symbol -> interval -> candles, oldest first
+}
+
+func NewCandleAggregator() *CandleAggregator {
+	return &CandleAggregator{series: make(map[string]map[string][]Candle)}
+}
+
+// Add folds a trade at ts (unix ms) into every interval and returns the
+// candles it closed. qty is 0 and side empty when the exchange doesn't
+// report them.
+func (a *CandleAggregator) Add(symbol string, price, qty float64, side string, ts int64) []ClosedCandle {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var closed []ClosedCandle
+	bySymbol, ok := a.series[symbol]
+	if !ok {
+		bySymbol = make(map[string][]Candle)
+		a.series[symbol] = bySymbol
+	}
+
+	for name, interval := range candleIntervals {
+		width := interval.Milliseconds()
+		start := time.UnixMilli(ts - ts%width).UTC()
+
+		candles := bySymbol[name]
+		n := len(candles)
+		if n > 0 && candles[n-1].Time.Equal(start) {
+			c := &candles[n-1]
+			if price > c.High {
+				c.High = price
+			}
+			if price < c.Low {
+				c.Low = price
+			}
+			c.Close = price
+			c.Trades++
+			addVolume(c, qty, side)
+			continue
+		}
+		if n > 0 && start.Before(candles[n-1].Time) {
+			// Late trade for an already closed candle
+			continue
+		}
+		if n > 0 {
+			cc := ClosedCandle{Interval: name, Candle: candles[n-1]}
+			if n > 1 {
+				prev := candles[n-2]
+				cc.Prev = &prev
+			}
+			closed = append(closed, cc)
+		}
+
+		c := Candle{
+			Time:   start,
+			Open:   price,
+			High:   price,
+			Low:    price,
+			Close:  price,
+			Trades: 1,
+		}
+		addVolume(&c, qty, side)
+		candles = append(candles, c)
+		if len(candles) > maxCandles {
+			candles = candles[len(candles)-maxCandles:]
+		}
+		bySymbol[name] = candles
+	}
+	return closed
+}
+
+// Seed merges a symbol's candles from older, built from trades that all
+// precede the ones already aggregated, underneath its series. A candle
+// spanning both is combined.
+func (a *CandleAggregator) Seed(symbol string, older *CandleAggregator) {
+	older.mu.RLock()
+	defer older.mu.RUnlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	bySymbol, ok := a.series[symbol]
+	if !ok {
+		bySymbol = make(map[string][]Candle)
+		a.series[symbol] = bySymbol
+	}
+	for name := range candleIntervals {
+		seed := older.series[symbol][name]
+		live := bySymbol[name]
+		if n := len(seed); n > 0 && len(live) > 0 && seed[n-1].Time.Equal(live[0].Time) {
+			c := &live[0]
+			c.Open = seed[n-1].Open
+			c.High = max(c.High, seed[n-1].High)
+			c.Low = min(c.Low, seed[n-1].Low)
+			c.Trades += seed[n-1].Trades
+			c.Volume = roundQuantity(c.Volume + seed[n-1].Volume)
+			c.BuyVolume = roundQuantity(c.BuyVolume + seed[n-1].BuyVolume)
+			c.SellVolume = roundQuantity(c.SellVolume + seed[n-1].SellVolume)
+			seed = seed[:n-1]
+		}
+		candles := append(slices.Clone(seed), live...)
+		if len(candles) > maxCandles {
+			candles = candles[len(candles)-maxCandles:]
+		}
+		bySymbol[name] = candles
+	}
+}
+
+// Candles returns up to limit most recent candles, oldest first
+func (a *CandleAggregator) Candles(symbol, interval string, limit int) []Candle {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	candles := a.series[symbol][interval]
+	if len(candles) > limit {
+		candles = candles[len(candles)-limit:]
+	}
+	out := make([]Candle, len(candles))
+	copy(out, candles)
+	return out
+}
+
+// candleRange returns up to limit of the most recent candles starting in
+// [from, to), where a zero bound is open. Candles before the in-memory
+// series come from the store when it aggregates the interval; the store's
+// version of the bucket where both meet wins, since memory may have only
+// part of it.
+func (s *Server) candleRange(ctx context.Context, symbol, interval string, from, to time.Time, limit int) []Candle {
+	var recent []Candle
+	for _, c := range s.candles.Candles(symbol, interval, maxCandles) {
+		if (from.IsZero() || !c.Time.Before(from)) && (to.IsZero() || c.Time.Before(to)) {
+			recent = append(recent, c)
+		}
+	}
+	// Memory is enough when it has limit candles or reaches back to from
+	store, ok := s.store.(store.CandleStore)
+	if !ok || len(recent) >= limit || (len(recent) > 0 && !from.IsZero() && !recent[0].Time.After(from)) {
+		return recent[max(0, len(recent)-limit):]
+	}
+
+	before := to
+	if len(recent) > 0 {
+		before = recent[0].Time.Add(candleIntervals[interval])
+	} else if before.IsZero() {
+		before = s.clock.Now()
+	}
+	stored, ok, err := store.StoredCandles(ctx, symbol, interval, from, before, limit)
+	if err != nil {
+		log.Printf("Stored candles error: %v", err)
+	}
+	if !ok || err != nil {
+		return recent[max(0, len(recent)-limit):]
+	}
+
+	merged := stored
+	for _, c := range recent {
+		if n := len(merged); n == 0 || c.Time.After(merged[n-1].Time) {
+			merged = append(merged, c)
+		}
+	}
+	return merged[max(0, len(merged)-limit):]
+}
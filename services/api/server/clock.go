@@ -0,0 +1,39 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A symbol is stale when no trade has arrived for this long
+const staleAfter = 10 * time.Second
+
+// Clock corrects the local time by the exchange clock skew reported by the
+// ingestion service, so bucketing and staleness follow exchange time
+type Clock struct {
+	skewMs atomic.Int64
+}
+
+// SetSkew records exchange time minus local time
+func (c *Clock) SetSkew(skew time.Duration) {
+	c.skewMs.Store(skew.Milliseconds())
+}
+
+// Skew returns the last reported exchange clock offset
+func (c *Clock) Skew() time.Duration {
+	return time.Duration(c.skewMs.Load()) * time.Millisecond
+}
+
+// Now returns the current time on the exchange clock
+func (c *Clock) Now() time.Time {
+	return time.Now().Add(c.Skew())
+}
+
+// TradeTime converts a millisecond exchange timestamp, falling back to the
+// corrected current time when the trade has none
+func (c *Clock) TradeTime(ms int64) time.Time {
+	if ms == 0 {
+		return c.Now()
+	}
+	return time.UnixMilli(ms)
+}
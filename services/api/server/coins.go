@@ -0,0 +1,131 @@
+package server
+
+import (
+	"math"
+	"strings"
+)
+
+// Decimals used for symbols outside the coin list and exchangeInfo
+const defaultPrecision = 8
+
+// Market is one trading pair of a coin
+type Market struct {
+	Symbol    string    `json:"symbol"`
+	Quote     string    `json:"quote"`
+	Name      string    `json:"name"`
+	Precision int       `json:"precision"` // decimals in a price, from the tick size
+	Sparkline []float64 `json:"sparkline,omitempty"`
+}
+
+// CoinInfo is a selectable coin and the markets it can be tracked in. Symbol
+// and Name describe the default market, the first in Markets.
+type CoinInfo struct {
+	Symbol    string    `json:"symbol"`
+	Name      string    `json:"name"`
+	Sparkline []float64 `json:"sparkline,omitempty"` // default market, with ?with_sparkline=true
+	Markets   []Market  `json:"markets"`
+}
+
+// Each coin trades against one or more quote currencies; the first is the
+// default market picked when a client selects just the coin. Precision
+// follows the exchange tick size, e.g. 0.01 for BTC and 0.00001 for DOGE.
+var coins = []struct {
+	name      string
+	ticker    string
+	precision int
+	quotes    []string
+}{
+	{"Bitcoin", "BTC", 2, []string{"USDT", "FDUSD", "EUR"}},
+	{"Ethereum", "ETH", 2, []string{"USDT", "FDUSD", "EUR"}},
+	{"Solana", "SOL", 2, []string{"USDT", "FDUSD", "EUR"}},
+	{"Binance Coin", "BNB", 2, []string{"USDT", "FDUSD"}},
+	{"Ripple", "XRP", 4, []string{"USDT", "FDUSD", "EUR"}},
+	{"Dogecoin", "DOGE", 5, []string{"USDT", "FDUSD"}},
+}
+
+// marketSymbol is the lowercase exchange symbol of a pair, e.g. "btceur"
+func marketSymbol(ticker, quote string) string {
+	return strings.ToLower(ticker + quote)
+}
+
+// marketName labels a market. The default market keeps the plain coin name
+// so existing symbols read as before, e.g. "Bitcoin (BTC)" and
+// "Bitcoin (BTC/EUR)".
+func marketName(name, ticker, quote string, primary bool) string {
+	if primary {
+		return name + " (" + ticker + ")"
+	}
+	return name + " (" + ticker + "/" + quote + ")"
+}
+
+// lookupMarket returns the metadata for a symbol in the coin list, or
+// failing that any pair Binance trades once exchangeInfo has loaded, named
+// e.g. "PEPE/USDT"
+func lookupMarket(symbol string) (Market, bool) {
+	for _, c := range coins {
+		for i, quote := range c.quotes {
+			if marketSymbol(c.ticker, quote) == symbol {
+				name, ticker := coinLabel(c.name, c.ticker)
+				m := Market{
+					Symbol:    symbol,
+					Quote:     quote,
+					Name:      marketName(name, ticker, quote, i == 0),
+					Precision: c.precision,
+				}
+				if name, ok := names.Markets[symbol]; ok {
+					m.Name = name
+				}
+				return m, true
+			}
+		}
+	}
+	if info, ok := catalog.lookup(symbol); ok {
+		m := Market{
+			Symbol:    symbol,
+			Quote:     info.Quote,
+			Name:      info.Base + "/" + info.Quote,
+			Precision: info.Precision,
+		}
+		if name, ok := names.Markets[symbol]; ok {
+			m.Name = name
+		}
+		return m, true
+	}
+	return Market{}, false
+}
+
+// marketInfo is lookupMarket with a fallback for symbols it doesn't know,
+// such as watchlist extras while exchangeInfo is unavailable
+func marketInfo(symbol string) Market {
+	if m, ok := lookupMarket(symbol); ok {
+		return m
+	}
+	name, ok := names.Markets[symbol]
+	if !ok {
+		name = symbol
+	}
+	return Market{Symbol: symbol, Name: name, Precision: defaultPrecision}
+}
+
+// roundPrice rounds a price to the symbol's precision, so every message and
+// stored trade shows the same value without float noise
+func roundPrice(symbol string, price float64) float64 {
+	scale := math.Pow10(marketInfo(symbol).Precision)
+	return math.Round(price*scale) / scale
+}
+
+// coinList returns the selectable coins and their markets in display order
+func coinList() []CoinInfo {
+	list := make([]CoinInfo, 0, len(coins))
+	for _, c := range coins {
+		info := CoinInfo{Markets: make([]Market, 0, len(c.quotes))}
+		for _, quote := range c.quotes {
+			m, _ := lookupMarket(marketSymbol(c.ticker, quote))
+			info.Markets = append(info.Markets, m)
+		}
+		info.Symbol = info.Markets[0].Symbol
+		info.Name = info.Markets[0].Name
+		list = append(list, info)
+	}
+	return list
+}
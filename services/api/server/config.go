@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// How long to wait for the processing service to answer a config request
+const configTimeout = 2 * time.Second
+
+// handleConfig reads (GET) or updates (POST) the processor parameters. The
+// processing service owns the config, so both are forwarded over NATS.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var update struct {
+			MAWindow   *int   `json:"ma_window,omitempty"`
+			MaxCgoRate *int   `json:"max_cgo_rate,omitempty"`
+			EMASpans   *[]int `json:"ema_spans,omitempty"`
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil || json.Unmarshal(data, &update) != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		body, _ = json.Marshal(update)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reply, err := s.nc.Request("control.config", body, configTimeout)
+	if err == nats.ErrNoResponders || err == nats.ErrTimeout {
+		http.Error(w, "Processing service not available", http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to reach processing service", http.StatusInternalServerError)
+		return
+	}
+
+	var result struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(reply.Data, &result) == nil && result.Error != "" {
+		http.Error(w, result.Error, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reply.Data)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Headers browsers may send to and read from /api/* cross-origin, beyond
+// the ones CORS always allows
+const (
+	corsAllowHeaders  = "Authorization, Content-Type, If-Modified-Since"
+	corsExposeHeaders = "Content-Disposition, Last-Modified, Link, Retry-After, X-History-Reset, X-Next-Cursor, X-Since-Id"
+	corsAllowMethods  = "GET, HEAD, POST, DELETE"
+	corsMaxAge        = "600" // seconds browsers may cache a preflight
+)
+
+// parseOrigins reads a comma separated list of origins such as
+// https://example.com or http://localhost:3000, or "*" for any
+func parseOrigins(list string) ([]string, error) {
+	var origins []string
+	for _, o := range strings.Split(list, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		if o != "*" {
+			u, err := url.Parse(o)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+				return nil, fmt.Errorf("invalid CORS origin %q, want e.g. https://example.com", o)
+			}
+			o = u.Scheme + "://" + u.Host
+		}
+		origins = append(origins, strings.ToLower(o))
+	}
+	return origins, nil
+}
+
+// originAllowed reports whether --cors-origins lets pages on origin use the
+// API
+func (s *Server) originAllowed(origin string) bool {
+	return slices.Contains(s.origins, "*") || slices.Contains(s.origins, strings.ToLower(origin))
+}
+
+// checkOrigin decides which pages may open a WebSocket: those on the API's
+// own origin, like the dashboard, and those --cors-origins allows. Clients
+// other than browsers send no Origin and are always let in.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || s.originAllowed(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// cors lets browsers call /api/* from the origins --cors-origins lists,
+// answering their preflight requests itself. Other origins get no CORS
+// headers, so browsers keep their pages from reading the responses; pages
+// on the API's own origin don't need any.
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if !s.originAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if slices.Contains(s.origins, "*") {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
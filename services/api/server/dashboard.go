@@ -0,0 +1,18 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// The browser dashboard, a single page that talks to /ws and the JSON API
+//
+//go:embed web
+var webFiles embed.FS
+
+// dashboardHandler serves the embedded dashboard at /
+func dashboardHandler() http.Handler {
+	root, _ := fs.Sub(webFiles, "web")
+	return http.FileServer(http.FS(root))
+}
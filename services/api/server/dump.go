@@ -0,0 +1,41 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/nats-io/nats.go"
+)
+
+// handleAdminDump asks ingestion to write the raw exchange messages it
+// recorded over the last --record-window to a file on its host, for
+// tracing odd prices back to what the exchange sent
+func (s *Server) handleAdminDump(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	reply, err := s.nc.Request("control.dump", nil, configTimeout)
+	if err == nats.ErrNoResponders || err == nats.ErrTimeout {
+		http.Error(w, "Ingestion service not available", http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to reach ingestion service", http.StatusInternalServerError)
+		return
+	}
+	var result struct {
+		Dump  json.RawMessage `json:"dump"`
+		Error string          `json:"error"`
+	}
+	if err := json.Unmarshal(reply.Data, &result); err != nil {
+		http.Error(w, "Invalid reply from ingestion service", http.StatusBadGateway)
+		return
+	}
+	if result.Error != "" {
+		http.Error(w, result.Error, http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(result.Dump)
+}
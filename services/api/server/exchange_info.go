@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	exchangeInfoRefresh = 24 * time.Hour   // pairs are listed and delisted rarely
+	exchangeInfoRetry   = time.Minute      // after a failed fetch
+	exchangeInfoTimeout = 30 * time.Second // the full response is a few MB
+	maxCoinResults      = 50               // default and cap of ?limit= on /api/coins?q=
+)
+
+// SymbolInfo is a trading pair listed by the exchange, as returned by
+// /api/coins?q= and /api/symbol/meta
+type SymbolInfo struct {
+	Symbol    string  `json:"symbol"`
+	Name      string  `json:"name"`
+	Base      string  `json:"base_asset"`
+	Quote     string  `json:"quote_asset"`
+	TickSize  float64 `json:"tick_size"`
+	Precision int     `json:"precision"`
+}
+
+// symbolCatalog holds the pairs Binance trades, keyed by lowercase symbol.
+// It stays empty until the first exchangeInfo fetch succeeds; until then
+// only the coin list is selectable.
+type symbolCatalog struct {
+	mu      sync.RWMutex
+	symbols map[string]SymbolInfo
+	sorted  []SymbolInfo // by symbol
+}
+
+// Pairs from the last exchangeInfo fetch, shared like names
+var catalog symbolCatalog
+
+func (c *symbolCatalog) lookup(symbol string) (SymbolInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	info, ok := c.symbols[symbol]
+	return info, ok
+}
+
+func (c *symbolCatalog) set(list []SymbolInfo) {
+	symbols := make(map[string]SymbolInfo, len(list))
+	for _, info := range list {
+		symbols[info.Symbol] = info
+	}
+	slices.SortFunc(list, func(a, b SymbolInfo) int { return strings.Compare(a.Symbol, b.Symbol) })
+	c.mu.Lock()
+	c.symbols = symbols
+	c.sorted = list
+	c.mu.Unlock()
+}
+
+func (c *symbolCatalog) list() []SymbolInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sorted
+}
+
+// fetchExchangeInfo reads the pairs currently trading on Binance spot from
+// its exchangeInfo endpoint under baseURL
+func fetchExchangeInfo(ctx context.Context, baseURL string) ([]SymbolInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, exchangeInfoTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/api/v3/exchangeInfo", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchangeInfo: %s", resp.Status)
+	}
+
+	var body struct {
+		Symbols []struct {
+			Symbol     string `json:"symbol"`
+			Status     string `json:"status"`
+			BaseAsset  string `json:"baseAsset"`
+			QuoteAsset string `json:"quoteAsset"`
+			Filters    []struct {
+				FilterType string `json:"filterType"`
+				TickSize   string `json:"tickSize"`
+			} `json:"filters"`
+		} `json:"symbols"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("exchangeInfo: %w", err)
+	}
+
+	list := make([]SymbolInfo, 0, len(body.Symbols))
+	for _, s := range body.Symbols {
+		if s.Status != "TRADING" {
+			continue
+		}
+		info := SymbolInfo{
+			Symbol:    strings.ToLower(s.Symbol),
+			Base:      s.BaseAsset,
+			Quote:     s.QuoteAsset,
+			Precision: defaultPrecision,
+		}
+		for _, f := range s.Filters {
+			if f.FilterType != "PRICE_FILTER" {
+				continue
+			}
+			if tick, err := strconv.ParseFloat(f.TickSize, 64); err == nil && tick > 0 {
+				info.TickSize = tick
+				info.Precision = tickPrecision(f.TickSize)
+			}
+		}
+		list = append(list, info)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("exchangeInfo: no trading symbols")
+	}
+	return list, nil
+}
+
+// tickPrecision counts the decimals of a tick size such as "0.00100000"
+func tickPrecision(tick string) int {
+	_, frac, _ := strings.Cut(tick, ".")
+	return len(strings.TrimRight(frac, "0"))
+}
+
+// loadExchangeInfo fetches exchangeInfo from baseURL into the catalog once
+func loadExchangeInfo(ctx context.Context, baseURL string) error {
+	list, err := fetchExchangeInfo(ctx, baseURL)
+	if err != nil {
+		return err
+	}
+	catalog.set(list)
+	log.Printf("Loaded %d trading pairs from %s", len(list), baseURL)
+	return nil
+}
+
+// refreshExchangeInfo keeps the catalog current until ctx is done, retrying
+// sooner while the exchange can't be reached. Lookups fall back to the coin
+// list meanwhile.
+func (s *Server) refreshExchangeInfo(ctx context.Context) {
+	var wait time.Duration
+	if len(catalog.list()) > 0 {
+		wait = exchangeInfoRefresh // New loaded it for the startup symbol
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = exchangeInfoRefresh
+		if err := loadExchangeInfo(ctx, s.cfg.BinanceAPIURL); err != nil {
+			log.Printf("Warning: exchangeInfo fetch failed, retrying in %s: %v", exchangeInfoRetry, err)
+			wait = exchangeInfoRetry
+		}
+	}
+}
+
+// searchSymbols returns up to limit pairs matching q: those whose base
+// asset is q first, then those it starts, then any symbol containing it.
+// Before the catalog has loaded it searches the coin list.
+func searchSymbols(q string, limit int) []SymbolInfo {
+	q = strings.ToLower(strings.TrimSpace(q))
+	list := catalog.list()
+	if len(list) == 0 {
+		list = coinListSymbols()
+	}
+
+	var exact, prefix, contains []SymbolInfo
+	for _, info := range list {
+		base := strings.ToLower(info.Base)
+		switch {
+		case base == q:
+			exact = append(exact, info)
+		case strings.HasPrefix(base, q):
+			prefix = append(prefix, info)
+		case strings.Contains(info.Symbol, q):
+			contains = append(contains, info)
+		}
+	}
+	matches := append(append(exact, prefix...), contains...)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	for i := range matches {
+		matches[i].Name = marketInfo(matches[i].Symbol).Name
+	}
+	return matches
+}
+
+// coinListSymbols describes the coin list's markets as SymbolInfo
+func coinListSymbols() []SymbolInfo {
+	var list []SymbolInfo
+	for _, c := range coins {
+		for _, quote := range c.quotes {
+			info, _ := symbolMeta(marketSymbol(c.ticker, quote))
+			list = append(list, info)
+		}
+	}
+	return list
+}
+
+// symbolMeta describes a market lookupMarket knows. Precision is the one
+// prices are rounded to; the exchange's tick size is used when it has as
+// many decimals, e.g. 0.5, and a step of one in the last decimal otherwise.
+func symbolMeta(symbol string) (SymbolInfo, bool) {
+	m, ok := lookupMarket(symbol)
+	if !ok {
+		return SymbolInfo{}, false
+	}
+	info := SymbolInfo{
+		Symbol:    symbol,
+		Name:      m.Name,
+		Base:      strings.ToUpper(strings.TrimSuffix(symbol, strings.ToLower(m.Quote))),
+		Quote:     m.Quote,
+		TickSize:  math.Pow10(-m.Precision),
+		Precision: m.Precision,
+	}
+	if listed, ok := catalog.lookup(symbol); ok {
+		info.Base = listed.Base
+		if listed.Precision == m.Precision && listed.TickSize > 0 {
+			info.TickSize = listed.TickSize
+		}
+	}
+	return info, true
+}
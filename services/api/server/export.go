@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// How many trades the export reads from the store at a time. Each chunk is
+// written and flushed before the next is read, so memory stays flat however
+// large the range is.
+const exportChunkSize = 5000
+
+// handleHistoryExport streams a symbol's trades as CSV or NDJSON, oldest
+// first unless ?order=desc. The response is chunked and has no size limit.
+func (s *Server) handleHistoryExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.store == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	params := r.URL.Query()
+	format := params.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+	q, err := s.historyQuery(params, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Limit = exportChunkSize
+
+	// Read the first chunk before writing anything so a store error can
+	// still be reported with a proper status
+	trades, err := s.store.History(r.Context(), q)
+	if err != nil {
+		http.Error(w, "Failed to fetch history", http.StatusInternalServerError)
+		return
+	}
+
+	var write func(Trade) error
+	var flush func() error
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"time", "symbol", "price", "qty", "side"})
+		write = func(t Trade) error {
+			// Size and side stay empty where they weren't recorded
+			qty := ""
+			if t.Qty > 0 {
+				qty = strconv.FormatFloat(t.Qty, 'f', -1, 64)
+			}
+			return cw.Write([]string{
+				t.Timestamp.UTC().Format(time.RFC3339Nano),
+				t.Symbol,
+				t.Price.String(),
+				qty,
+				t.Side,
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(t Trade) error { return enc.Encode(t) }
+		flush = func() error { return nil }
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+q.Symbol+"-trades."+format+`"`)
+
+	rc := http.NewResponseController(w)
+	for {
+		for _, t := range trades {
+			if err := write(t); err != nil {
+				return
+			}
+		}
+		if err := flush(); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		if len(trades) < q.Limit {
+			return
+		}
+
+		next := nextCursor(q.Cursor, trades)
+		q.Cursor = &next
+		trades, err = s.store.History(r.Context(), q)
+		if err != nil {
+			// The status is already sent; cutting the stream short is the
+			// only way left to tell the client the export is incomplete
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
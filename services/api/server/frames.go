@@ -0,0 +1,73 @@
+package server
+
+import (
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"api/marketpb"
+)
+
+// WebSocket message formats, picked with /ws?format=. Protobuf clients get
+// every message as a binary StreamFrame of proto/market.proto, which spares
+// the server encoding JSON for each trade.
+const (
+	formatJSON     = "json"
+	formatProtobuf = "protobuf"
+)
+
+// StreamFrame field numbers, for the frames put together without proto
+const (
+	frameSeqField  = 1
+	frameJSONField = 4
+)
+
+// tradeFrame is the binary form of a v2 trade event or price channel update
+func tradeFrame(p ProcessedMessage) []byte {
+	market := marketInfo(p.Symbol)
+	data, _ := proto.Marshal(&marketpb.StreamFrame{
+		Payload: &marketpb.StreamFrame_Trade{Trade: &marketpb.Price{
+			Symbol:    p.Symbol,
+			Price:     p.Price,
+			Quote:     market.Quote,
+			Precision: int32(market.Precision),
+			Time:      p.Time,
+			PriceE8:   p.PriceE8,
+		}},
+	})
+	return data
+}
+
+// channelFrame is the binary form of channelMessage, or nil for channels
+// that are only sent as JSON
+func (s *Server) channelFrame(sub subscription, p ProcessedMessage) []byte {
+	switch sub.Channel {
+	case channelPrice:
+		return tradeFrame(p)
+	case channelStats:
+		data, _ := proto.Marshal(&marketpb.StreamFrame{
+			Payload: &marketpb.StreamFrame_Stats{Stats: &marketpb.SymbolStats{
+				Symbol: p.Symbol,
+				Stats:  statsProto(s.statsOf(p.Symbol, p)),
+			}},
+		})
+		return data
+	}
+	return nil
+}
+
+// jsonFrame wraps a JSON message for a protobuf client
+func jsonFrame(msg []byte) []byte {
+	out := make([]byte, 0, len(msg)+8)
+	out = protowire.AppendTag(out, frameJSONField, protowire.BytesType)
+	return protowire.AppendBytes(out, msg)
+}
+
+// frameWithSeq returns a copy of a frame numbered seq, the binary
+// counterpart of withSeq. Protobuf decoders accept fields in any order, so
+// it is appended.
+func frameWithSeq(frame []byte, seq uint64) []byte {
+	out := protowire.AppendTag(slices.Clip(frame), frameSeqField, protowire.VarintType)
+	return protowire.AppendVarint(out, seq)
+}
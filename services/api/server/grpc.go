@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"api/marketpb"
+)
+
+// Trades queued per gRPC stream; a stream further behind misses trades
+const grpcStreamBuffer = 256
+
+// tradeStreams fans live trades out to gRPC StreamTrades calls, without
+// the JSON the hub's clients get
+type tradeStreams struct {
+	mu      sync.Mutex
+	streams map[chan ProcessedMessage]string // to the symbol streamed
+	done    chan struct{}                    // closed when the server shuts down
+	dropped atomic.Int64
+}
+
+func newTradeStreams() *tradeStreams {
+	return &tradeStreams{
+		streams: make(map[chan ProcessedMessage]string),
+		done:    make(chan struct{}),
+	}
+}
+
+func (t *tradeStreams) add(symbol string) chan ProcessedMessage {
+	ch := make(chan ProcessedMessage, grpcStreamBuffer)
+	t.mu.Lock()
+	t.streams[ch] = symbol
+	t.mu.Unlock()
+	return ch
+}
+
+func (t *tradeStreams) remove(ch chan ProcessedMessage) {
+	t.mu.Lock()
+	delete(t.streams, ch)
+	t.mu.Unlock()
+}
+
+// publish queues a trade for the streams of its symbol, skipping those
+// that are full
+func (t *tradeStreams) publish(p ProcessedMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch, symbol := range t.streams {
+		if symbol != p.Symbol {
+			continue
+		}
+		select {
+		case ch <- p:
+		default:
+			t.dropped.Add(1)
+		}
+	}
+}
+
+// shutdown ends every stream, so a graceful stop doesn't wait for them
+func (t *tradeStreams) shutdown() {
+	close(t.done)
+}
+
+// grpcService is the MarketData service of proto/market.proto
+type grpcService struct {
+	marketpb.UnimplementedMarketDataServer
+	s *Server
+}
+
+// newGRPCServer serves MarketData with the HTTP server's certificate, if it
+// has one
+func (s *Server) newGRPCServer() *grpc.Server {
+	var opts []grpc.ServerOption
+	switch {
+	case s.tlsConfig != nil:
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	case s.acme != nil:
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.acme.TLSConfig())))
+	}
+	srv := grpc.NewServer(opts...)
+	marketpb.RegisterMarketDataServer(srv, &grpcService{s: s})
+	return srv
+}
+
+// authorizedCall checks a call's authorization metadata the way authorized
+// checks a request's header
+func (s *Server) authorizedCall(ctx context.Context) bool {
+	if s.cfg.APIToken == "" {
+		return true
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token, _ := strings.CutPrefix(v, "Bearer ")
+		if tokenMatches(token, s.cfg.APIToken) || tokenMatches(token, s.adminToken) {
+			return true
+		}
+	}
+	return false
+}
+
+var errCallUnauthenticated = status.Error(codes.Unauthenticated, "API token required")
+
+func (g *grpcService) GetPrice(ctx context.Context, _ *marketpb.GetPriceRequest) (*marketpb.Price, error) {
+	g.s.mu.RLock()
+	symbol, current := g.s.symbol, g.s.current
+	g.s.mu.RUnlock()
+	market := marketInfo(symbol)
+	return &marketpb.Price{
+		Symbol:    symbol,
+		Price:     current.Price,
+		Quote:     market.Quote,
+		Precision: int32(market.Precision),
+		Time:      current.Time,
+		PriceE8:   current.PriceE8,
+	}, nil
+}
+
+func (g *grpcService) GetStats(ctx context.Context, _ *marketpb.GetStatsRequest) (*marketpb.Stats, error) {
+	return statsProto(g.s.stats()), nil
+}
+
+func (g *grpcService) StreamTrades(req *marketpb.StreamTradesRequest, stream grpc.ServerStreamingServer[marketpb.TradeUpdate]) error {
+	s := g.s
+	ctx := stream.Context()
+	if s.cfg.WSAuth && !s.authorizedCall(ctx) {
+		return errCallUnauthenticated
+	}
+
+	// Streams are held open like WebSocket and SSE clients, and count
+	// against the same limits
+	if !s.clientLimit.Acquire() {
+		return status.Error(codes.ResourceExhausted, "server busy, try again later")
+	}
+	defer s.clientLimit.Release()
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok {
+		addr, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+	if !s.addrClients.Acquire(addr) {
+		return status.Error(codes.ResourceExhausted, "too many streams from this address")
+	}
+	defer s.addrClients.Release(addr)
+
+	symbol := strings.ToLower(req.GetSymbol())
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+	ch := s.trades.add(symbol)
+	defer s.trades.remove(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.trades.done:
+			return status.Error(codes.Unavailable, "server shutting down")
+		case p := <-ch:
+			update := &marketpb.TradeUpdate{
+				Trade: &marketpb.Trade{
+					Symbol:  p.Symbol,
+					Price:   p.Price,
+					Qty:     p.Qty,
+					Side:    p.Side,
+					Time:    p.Time,
+					PriceE8: p.PriceE8,
+				},
+				Stats: statsProto(s.statsOf(p.Symbol, p)),
+			}
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (g *grpcService) SetSymbol(ctx context.Context, req *marketpb.SetSymbolRequest) (*marketpb.SetSymbolResponse, error) {
+	if !g.s.authorizedCall(ctx) {
+		return nil, errCallUnauthenticated
+	}
+	name, changed, err := g.s.changeSymbol(req.GetSymbol())
+	if errors.Is(err, errUnknownSymbol) {
+		return nil, status.Error(codes.InvalidArgument, "unknown symbol")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &marketpb.SetSymbolResponse{Symbol: req.GetSymbol(), Name: name, Changed: changed}, nil
+}
+
+func statsProto(st Stats) *marketpb.Stats {
+	return &marketpb.Stats{
+		MovingAverage: st.MovingAverage,
+		High:          st.High,
+		Low:           st.Low,
+		Vwap:          st.VWAP,
+		StdDev:        st.StdDev,
+		Ema:           st.EMA,
+		Volume:        st.Volume,
+		BuyVolume:     st.BuyVolume,
+		SellVolume:    st.SellVolume,
+		BuyRatio:      st.BuyRatio,
+		TradesPerSec:  st.TradesPerSec,
+		Samples:       int32(st.Samples),
+		WindowFull:    st.WindowFull,
+	}
+}
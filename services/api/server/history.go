@@ -0,0 +1,247 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"api/store"
+)
+
+func decodeHistoryCursor(s string) (*HistoryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c HistoryCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Time.IsZero() || c.Skip < 1 {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+// nextCursor returns the cursor following a page
+func nextCursor(prev *HistoryCursor, page []Trade) HistoryCursor {
+	last := page[len(page)-1].Timestamp
+	next := HistoryCursor{Time: last, ID: page[len(page)-1].ID}
+	for i := len(page) - 1; i >= 0 && page[i].Timestamp.Equal(last); i-- {
+		next.Skip++
+	}
+	if prev != nil && prev.Time.Equal(last) {
+		next.Skip += prev.Skip
+	}
+	return next
+}
+
+// latestCursor is the since_id of a newest-first page: the position just
+// after its newest trade
+func latestCursor(page []Trade) HistoryCursor {
+	newest := page[0].Timestamp
+	latest := HistoryCursor{Time: newest, ID: page[0].ID}
+	for i := 0; i < len(page) && page[i].Timestamp.Equal(newest); i++ {
+		latest.Skip++
+	}
+	return latest
+}
+
+// parseHistoryTime accepts RFC 3339 or unix milliseconds
+func parseHistoryTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// historyQuery reads the symbol, from, to, order and cursor parameters
+// shared by the history endpoints. The limit is left to the caller.
+func (s *Server) historyQuery(params url.Values, ascending bool) (HistoryQuery, error) {
+	q := HistoryQuery{Symbol: params.Get("symbol"), Ascending: ascending}
+	if q.Symbol == "" {
+		s.mu.RLock()
+		q.Symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	if v := params.Get("from"); v != "" {
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			return q, errors.New("Invalid from")
+		}
+		q.From = t
+	}
+	if v := params.Get("to"); v != "" {
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			return q, errors.New("Invalid to")
+		}
+		q.To = t
+	}
+	switch params.Get("order") {
+	case "":
+	case "desc":
+		q.Ascending = false
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, errors.New("Invalid order")
+	}
+	if v := params.Get("cursor"); v != "" {
+		cursor, err := decodeHistoryCursor(v)
+		if err != nil {
+			return q, errors.New("Invalid cursor")
+		}
+		q.Cursor = cursor
+	}
+	return q, nil
+}
+
+// handleHistory serves stored trades a page at a time. The body is a JSON
+// array; when more trades match, the X-Next-Cursor header (and a Link header
+// with rel="next") tells the client how to fetch the next page.
+//
+// The newest page also carries X-Since-Id and Last-Modified. Passing the id
+// back as ?since_id= returns only the trades stored since, newest first, so
+// a client can prepend them instead of reloading; if more than a page of
+// trades arrived in between, the regular newest page is served instead with
+// X-History-Reset set. Either way nothing new answers 304 Not Modified, as
+// does an If-Modified-Since no older than the newest trade.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		s.deleteHistory(w, r)
+		return
+	}
+
+	params := r.URL.Query()
+	q, err := s.historyQuery(params, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Limit = defaultHistoryLimit
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		q.Limit = min(n, maxHistoryLimit)
+	}
+
+	if v := params.Get("since_id"); v != "" {
+		since, err := decodeHistoryCursor(v)
+		if err != nil {
+			http.Error(w, "Invalid since_id", http.StatusBadRequest)
+			return
+		}
+		if s.serveHistorySince(w, r, q, since) {
+			return
+		}
+		w.Header().Set("X-History-Reset", "true")
+	}
+
+	// Ask for one extra trade to learn whether there is a next page
+	limit := q.Limit
+	q.Limit++
+	trades, err := s.store.History(r.Context(), q)
+	if err != nil {
+		http.Error(w, "Failed to fetch history", http.StatusInternalServerError)
+		return
+	}
+	if len(trades) > limit {
+		trades = trades[:limit]
+		next := nextCursor(q.Cursor, trades).Encode()
+		params.Set("cursor", next)
+		params.Del("since_id")
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", "<"+(&url.URL{Path: r.URL.Path, RawQuery: params.Encode()}).String()+`>; rel="next"`)
+	}
+	if q.Cursor == nil && !q.Ascending && len(trades) > 0 {
+		newest := trades[0].Timestamp
+		w.Header().Set("X-Since-Id", latestCursor(trades).Encode())
+		w.Header().Set("Last-Modified", newest.UTC().Format(http.TimeFormat))
+		if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !newest.Truncate(time.Second).After(ims) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	if trades == nil {
+		trades = []Trade{}
+	}
+
+	s.writeJSON(w, r, trades)
+}
+
+// serveHistorySince answers a since_id request with the trades stored after
+// since, newest first. It reports false without writing anything when more
+// than q.Limit trades arrived, leaving the caller to serve the newest page.
+func (s *Server) serveHistorySince(w http.ResponseWriter, r *http.Request, q HistoryQuery, since *HistoryCursor) bool {
+	q.Ascending = true
+	q.Cursor = since
+	q.Limit++
+	trades, err := s.store.History(r.Context(), q)
+	if err != nil {
+		http.Error(w, "Failed to fetch history", http.StatusInternalServerError)
+		return true
+	}
+	if len(trades) >= q.Limit {
+		return false
+	}
+	if len(trades) == 0 {
+		w.Header().Set("X-Since-Id", since.Encode())
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	w.Header().Set("X-Since-Id", nextCursor(since, trades).Encode())
+	w.Header().Set("Last-Modified", trades[len(trades)-1].Timestamp.UTC().Format(http.TimeFormat))
+	slices.Reverse(trades)
+	s.writeJSON(w, r, trades)
+	return true
+}
+
+// deleteHistory deletes every symbol's trades older than ?before= (admin),
+// to reclaim disk space by hand between retention runs
+func (s *Server) deleteHistory(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	deleter, ok := s.store.(store.TradeDeleter)
+	if !ok {
+		http.Error(w, "Store can't delete trades", http.StatusNotImplemented)
+		return
+	}
+	v := r.URL.Query().Get("before")
+	if v == "" {
+		http.Error(w, "before is required", http.StatusBadRequest)
+		return
+	}
+	before, err := parseHistoryTime(v)
+	if err != nil {
+		http.Error(w, "Invalid before", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := deleter.DeleteTradesBefore(r.Context(), before)
+	if err != nil {
+		log.Printf("Delete history error: %v", err)
+		http.Error(w, "Failed to delete history", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Deleted %d trades before %s", deleted, before.UTC().Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"deleted": deleted,
+		"before":  before.UTC(),
+	})
+}
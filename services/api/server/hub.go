@@ -0,0 +1,532 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Outbound messages buffered per client before the oldest is dropped
+	clientQueueSize = 64
+
+	// Events buffered between the NATS callback and the hub goroutine
+	hubQueueSize = 256
+
+	// WebSocket clients are pinged every wsPingInterval and dropped when
+	// nothing, not even a pong, arrives for wsPongWait
+	wsPingInterval = 5 * time.Second
+	wsPongWait     = 15 * time.Second
+
+	// Longest a single write to a client may take before the client is
+	// dropped, so a stalled connection can't hold its write pump forever
+	clientWriteWait = 10 * time.Second
+
+	// WebSocket protocol versions. Version 1 sends {"price": ...} to clients
+	// without subscriptions; version 2 (/ws?v=2) sends typed trade events
+	// instead and numbers every message with "seq".
+	protocolV1     = 1
+	protocolV2     = 2
+	latestProtocol = protocolV2
+)
+
+// transport is the connection behind a client, a WebSocket or an SSE stream
+type transport interface {
+	WriteMessage(msg []byte) error
+	WriteKeepalive() error
+	WriteClose(reason string) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+// wsTransport sends messages as WebSocket text frames, or binary ones to
+// protobuf clients
+type wsTransport struct {
+	conn   *websocket.Conn
+	binary bool
+}
+
+func (t wsTransport) WriteMessage(msg []byte) error {
+	if t.binary {
+		return t.conn.WriteMessage(websocket.BinaryMessage, msg)
+	}
+	return t.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+func (t wsTransport) WriteKeepalive() error {
+	return t.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (t wsTransport) WriteClose(reason string) error {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, reason)
+	return t.conn.WriteMessage(websocket.CloseMessage, msg)
+}
+
+func (t wsTransport) SetWriteDeadline(d time.Time) error {
+	return t.conn.SetWriteDeadline(d)
+}
+
+func (t wsTransport) Close() error {
+	return t.conn.Close()
+}
+
+// Client is a connection fed by the hub through a buffered queue. Only
+// writePump writes to the connection.
+type Client struct {
+	conn      transport
+	keepalive time.Duration // 0 disables keepalives
+	writeWait time.Duration // deadline of each write
+	send      chan []byte
+	done      chan struct{} // closed when the client is unregistered
+	stopped   chan struct{} // closed when writePump has returned
+	once      sync.Once
+	subs      subscriptionSet
+	dropped   atomic.Int64
+	paused    atomic.Bool // market data held back until the client resumes
+	rate      throttle    // coalesces market data, see set_rate
+	hub       *Hub
+
+	version int
+	seqMu   sync.Mutex // keeps seq in queue order
+	seq     uint64
+
+	// Sent the API token on connecting, so may call mutating JSON-RPC methods
+	authorized bool
+
+	// Opened with ?format=protobuf, so gets StreamFrames instead of JSON
+	binary bool
+
+	// Price format of its JSON messages, from ?prices= or the server's
+	// default; priceString sends trade prices as decimal strings
+	prices string
+}
+
+// isSSE reports whether the client is an SSE stream rather than a WebSocket
+func (c *Client) isSSE() bool {
+	_, ok := c.conn.(sseTransport)
+	return ok
+}
+
+// enqueue queues a JSON message without blocking, dropping the oldest
+// queued message when the client has fallen behind. Version 2 clients see a
+// dropped message as a gap in seq.
+func (c *Client) enqueue(msg []byte) {
+	if c.binary {
+		c.enqueueFrame(jsonFrame(msg))
+		return
+	}
+	c.queue(msg, withSeq)
+}
+
+// enqueueFrame queues a StreamFrame for a protobuf client like enqueue
+func (c *Client) enqueueFrame(frame []byte) {
+	c.queue(frame, frameWithSeq)
+}
+
+func (c *Client) queue(msg []byte, numbered func([]byte, uint64) []byte) {
+	if c.version >= protocolV2 {
+		c.seqMu.Lock()
+		defer c.seqMu.Unlock()
+		c.seq++
+		msg = numbered(msg, c.seq)
+	}
+	for {
+		select {
+		case c.send <- msg:
+			return
+		default:
+		}
+		select {
+		case <-c.send:
+			c.dropped.Add(1)
+			c.hub.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// withSeq returns a copy of a JSON object with "seq" as its first field.
+// Messages are shared between clients, so msg itself is left alone.
+func withSeq(msg []byte, seq uint64) []byte {
+	if len(msg) < 2 || msg[0] != '{' {
+		return msg
+	}
+	out := make([]byte, 0, len(msg)+24)
+	out = append(out, `{"seq":`...)
+	out = strconv.AppendUint(out, seq, 10)
+	if msg[1] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, msg[1:]...)
+}
+
+// writePump writes queued messages until the client is unregistered or a
+// write fails. On shutdown it flushes the queue, which ends with the
+// shutdown notice, and sends a close frame. A panic in the connection only
+// drops this client.
+func (c *Client) writePump() {
+	defer c.hub.pumps.Done()
+	defer close(c.stopped)
+	defer c.conn.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Client write panic, dropping client: %v", r)
+			c.hub.Unregister(c)
+		}
+	}()
+
+	var keepalive <-chan time.Time
+	if c.keepalive > 0 {
+		ticker := time.NewTicker(c.keepalive)
+		defer ticker.Stop()
+		keepalive = ticker.C
+	}
+	// Ticks while the client is throttled, releasing its held market data
+	var release <-chan time.Time
+	var releaseTicker *time.Ticker
+	defer func() {
+		if releaseTicker != nil {
+			releaseTicker.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-c.rate.changed:
+			if releaseTicker != nil {
+				releaseTicker.Stop()
+				releaseTicker, release = nil, nil
+			}
+			if d := c.rate.Interval(); d > 0 {
+				releaseTicker = time.NewTicker(d)
+				release = releaseTicker.C
+			}
+			c.release()
+		case <-release:
+			c.release()
+		case <-c.done:
+			if c.hub.closing.Load() {
+				c.flush()
+			}
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
+			if err := c.conn.WriteMessage(msg); err != nil {
+				c.hub.Unregister(c)
+				return
+			}
+		case <-keepalive:
+			c.conn.SetWriteDeadline(time.Now().Add(c.writeWait))
+			if err := c.conn.WriteKeepalive(); err != nil {
+				c.hub.Unregister(c)
+				return
+			}
+		}
+	}
+}
+
+// flush writes whatever is still queued and a close frame, bounded by a
+// short deadline so a dead client can't hold up shutdown
+func (c *Client) flush() {
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.conn.WriteMessage(msg); err != nil {
+				return
+			}
+		default:
+			c.conn.WriteClose("server shutting down")
+			return
+		}
+	}
+}
+
+// event is a message fanned out by the hub
+type event struct {
+	symbol  string
+	legacy  []byte                        // for v1 clients without subscriptions, may be nil
+	trade   []byte                        // for v2 clients without subscriptions, may be nil
+	control []byte                        // for every v2 client, e.g. a market change; the event carries nothing else
+	build   func(sub subscription) []byte // payload for a subscription, nil to skip
+	from    *Client                       // left out of delivery, e.g. who published session state
+
+	// Binary forms of trade and build's payloads for protobuf clients, built
+	// only once one needs them; nil, or returning nil, to send them the JSON
+	tradeFrame func() []byte
+	buildFrame func(sub subscription) []byte
+
+	// Forms of legacy, trade and build's payloads with string prices, for
+	// clients that asked for them, built only once one needs them; nil, or
+	// returning nil, to send them the same as everyone else
+	stringLegacy func() []byte
+	stringTrade  func() []byte
+	stringBuild  func(sub subscription) []byte
+}
+
+// Hub owns the set of clients and routes events to their queues, so a slow
+// client can never stall the pipeline
+type Hub struct {
+	register   chan *Client
+	unregister chan *Client
+	events     chan event
+	quit       chan chan struct{}
+	stopped    chan struct{} // closed once Run has returned
+	stopOnce   sync.Once
+	clients    map[*Client]bool
+
+	pumps   sync.WaitGroup
+	closing atomic.Bool
+	count   atomic.Int64
+	sse     atomic.Int64 // of count, the SSE streams
+	dropped atomic.Int64
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		events:     make(chan event, hubQueueSize),
+		quit:       make(chan chan struct{}),
+		stopped:    make(chan struct{}),
+		clients:    make(map[*Client]bool),
+	}
+}
+
+// NewClient wraps a WebSocket connection for use with the hub, sending it
+// binary StreamFrames instead of JSON when binary is set
+func (h *Hub) NewClient(conn *websocket.Conn, binary bool) *Client {
+	c := h.newClient(wsTransport{conn, binary}, wsPingInterval)
+	c.binary = binary
+	return c
+}
+
+func (h *Hub) newClient(conn transport, keepalive time.Duration) *Client {
+	return &Client{
+		conn:      conn,
+		keepalive: keepalive,
+		writeWait: clientWriteWait,
+		send:      make(chan []byte, clientQueueSize),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		rate:      newThrottle(),
+		hub:       h,
+	}
+}
+
+// Register adds a client and starts its write pump. A client registered
+// after shutdown is closed straight away, without one: Shutdown may already
+// be waiting for the pumps.
+func (h *Hub) Register(c *Client) {
+	select {
+	case h.register <- c:
+		h.count.Add(1)
+		if c.isSSE() {
+			h.sse.Add(1)
+		}
+		go c.writePump()
+	case <-h.stopped:
+		c.once.Do(func() { close(c.done) })
+		c.conn.Close()
+		close(c.stopped)
+	}
+}
+
+// Unregister removes a client and stops its write pump; safe to call twice
+func (h *Hub) Unregister(c *Client) {
+	c.once.Do(func() {
+		select {
+		case h.unregister <- c:
+			h.count.Add(-1)
+			if c.isSSE() {
+				h.sse.Add(-1)
+			}
+		case <-h.stopped:
+		}
+		close(c.done)
+	})
+}
+
+// Broadcast hands an event to the hub goroutine; events after shutdown are
+// discarded
+func (h *Hub) Broadcast(e event) {
+	select {
+	case h.events <- e:
+	case <-h.stopped:
+	}
+}
+
+// Clients returns the number of connected clients
+func (h *Hub) Clients() int {
+	return int(h.count.Load())
+}
+
+// SSEClients returns how many of the connected clients are SSE streams;
+// the rest are WebSockets
+func (h *Hub) SSEClients() int {
+	return int(h.sse.Load())
+}
+
+// Dropped returns the total number of messages dropped for slow clients
+func (h *Hub) Dropped() int64 {
+	return h.dropped.Load()
+}
+
+// Shutdown notifies every client that the server is going away, closes
+// their connections and stops the hub; safe to call more than once
+func (h *Hub) Shutdown() {
+	h.stopOnce.Do(func() {
+		done := make(chan struct{})
+		h.quit <- done
+		<-done
+	})
+	h.pumps.Wait()
+}
+
+func (h *Hub) Run() {
+	for {
+		select {
+		case done := <-h.quit:
+			h.closing.Store(true)
+			notice, _ := json.Marshal(map[string]string{
+				"type":   "server_shutdown",
+				"reason": "server shutting down",
+			})
+			for c := range h.clients {
+				c.enqueue(notice)
+				c.once.Do(func() { close(c.done) })
+				delete(h.clients, c)
+			}
+			h.count.Store(0)
+			h.sse.Store(0)
+			close(h.stopped)
+			close(done)
+			return
+
+		case c := <-h.register:
+			// Counted here rather than in Register, so it happens before
+			// shutdown closes stopped and Shutdown waits
+			h.pumps.Add(1)
+			h.clients[c] = true
+
+		case c := <-h.unregister:
+			delete(h.clients, c)
+
+		case e := <-h.events:
+			built := make(map[subscription][]byte)
+			strs := make(map[subscription][]byte)
+			frames := make(map[subscription][]byte)
+			for c := range h.clients {
+				h.deliver(c, e, built, strs, frames)
+			}
+		}
+	}
+}
+
+// deliver queues an event for one client. Payloads are built once per event
+// and kept in built, strs and frames for the next client. A panic while doing
+// so drops that client instead of the hub goroutine, so the remaining clients
+// still get the event.
+func (h *Hub) deliver(c *Client, e event, built, strs, frames map[subscription][]byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Client delivery panic, dropping client: %v", r)
+			// Unregister hands the client back to this goroutine, so it
+			// can't be called from here
+			delete(h.clients, c)
+			go h.Unregister(c)
+		}
+	}()
+
+	if c == e.from {
+		return
+	}
+	if e.control != nil {
+		if c.version >= protocolV2 {
+			c.enqueue(e.control)
+		}
+		return
+	}
+
+	paused := c.paused.Load()
+	subs, active := c.subs.forSymbol(e.symbol)
+	if !active && !paused {
+		if c.version >= protocolV2 && e.trade != nil {
+			msg := e.trade
+			if c.prices == priceString && e.stringTrade != nil {
+				if str := e.stringTrade(); str != nil {
+					msg = str
+				}
+			}
+			var frame []byte
+			if c.binary && e.tradeFrame != nil {
+				frame = e.tradeFrame()
+			}
+			c.offer(subscription{}, msg, frame)
+		} else if c.version < protocolV2 && e.legacy != nil {
+			msg := e.legacy
+			if c.prices == priceString && e.stringLegacy != nil {
+				if str := e.stringLegacy(); str != nil {
+					msg = str
+				}
+			}
+			c.offer(subscription{}, msg, nil)
+		}
+	}
+	for _, sub := range subs {
+		if paused && sub.Channel != channelAlerts && sub.Channel != channelSignals && sub.Channel != channelMeta {
+			continue
+		}
+		if c.binary && e.buildFrame != nil {
+			if frame := e.payload(e.buildFrame, sub, frames); frame != nil {
+				if coalesced(sub.Channel) {
+					c.offer(sub, nil, frame)
+				} else {
+					c.enqueueFrame(frame)
+				}
+				continue
+			}
+		}
+		var msg []byte
+		if c.prices == priceString && e.stringBuild != nil {
+			msg = e.payload(e.stringBuild, sub, strs)
+		}
+		if msg == nil {
+			msg = e.payload(e.build, sub, built)
+		}
+		if msg != nil {
+			if coalesced(sub.Channel) {
+				c.offer(sub, msg, nil)
+			} else {
+				c.enqueue(msg)
+			}
+		}
+	}
+}
+
+// payload returns build's payload for sub from cache, building it the first
+// time a client needs it
+func (e event) payload(build func(sub subscription) []byte, sub subscription, cache map[subscription][]byte) []byte {
+	msg, ok := cache[sub]
+	if !ok {
+		msg = e.safeBuild(build, sub)
+		cache[sub] = msg
+	}
+	return msg
+}
+
+// safeBuild builds a subscription payload, treating a panic as nothing to
+// send; the fault is in the payload, not in any one client
+func (e event) safeBuild(build func(sub subscription) []byte, sub subscription) (msg []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Building %s message for %s panicked: %v", sub.Channel, e.symbol, r)
+			msg = nil
+		}
+	}()
+	return build(sub)
+}
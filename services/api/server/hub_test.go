@@ -0,0 +1,264 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// How long tests wait for something the hub should do at once
+const hubTestWait = 2 * time.Second
+
+// fakeConn is a transport that records the messages it is sent, or
+// misbehaves in WriteMessage when misbehave is set
+type fakeConn struct {
+	misbehave func(f *fakeConn) error
+	received  chan []byte
+
+	mu        sync.Mutex
+	deadline  time.Time
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// newFakeConn returns a connection that is closed when the test ends,
+// before the hub shuts down, so a stalled write can't hold up shutdown
+func newFakeConn(t *testing.T, misbehave func(f *fakeConn) error) *fakeConn {
+	f := &fakeConn{
+		misbehave: misbehave,
+		received:  make(chan []byte, clientQueueSize),
+		closed:    make(chan struct{}),
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func (f *fakeConn) WriteMessage(msg []byte) error {
+	if f.misbehave != nil {
+		return f.misbehave(f)
+	}
+	f.received <- msg
+	return nil
+}
+
+func (f *fakeConn) WriteKeepalive() error          { return nil }
+func (f *fakeConn) WriteClose(reason string) error { return nil }
+
+func (f *fakeConn) SetWriteDeadline(t time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deadline = t
+	return nil
+}
+
+func (f *fakeConn) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return nil
+}
+
+// Ways a connection misbehaves
+func panics(f *fakeConn) error {
+	panic("concurrent write to websocket connection")
+}
+
+func fails(f *fakeConn) error {
+	return errors.New("broken pipe")
+}
+
+// stalls blocks until the write deadline passes, like a peer that stopped
+// reading
+func stalls(f *fakeConn) error {
+	f.mu.Lock()
+	deadline := f.deadline
+	f.mu.Unlock()
+	if deadline.IsZero() {
+		<-f.closed
+		return net.ErrClosed
+	}
+	select {
+	case <-time.After(time.Until(deadline)):
+		return os.ErrDeadlineExceeded
+	case <-f.closed:
+		return net.ErrClosed
+	}
+}
+
+// startHub runs a hub until the test ends
+func startHub(t *testing.T) *Hub {
+	t.Helper()
+	h := NewHub()
+	go h.Run()
+	t.Cleanup(h.Shutdown)
+	return h
+}
+
+// addClient registers a client without subscriptions on conn
+func addClient(h *Hub, conn *fakeConn, version int, binary bool) *Client {
+	c := h.newClient(conn, 0)
+	c.version, c.binary = version, binary
+	c.writeWait = 50 * time.Millisecond
+	h.Register(c)
+	return c
+}
+
+func expectMessage(t *testing.T, conn *fakeConn, want string) {
+	t.Helper()
+	select {
+	case msg := <-conn.received:
+		if string(msg) != want {
+			t.Fatalf("got %s, want %s", msg, want)
+		}
+	case <-time.After(hubTestWait):
+		t.Fatalf("no message, want %s", want)
+	}
+}
+
+// expectDropped checks that c was unregistered and its connection closed
+func expectDropped(t *testing.T, c *Client, conn *fakeConn) {
+	t.Helper()
+	select {
+	case <-c.stopped:
+	case <-time.After(hubTestWait):
+		t.Fatal("misbehaving client's write pump still running")
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("misbehaving client not unregistered")
+	}
+	select {
+	case <-conn.closed:
+	default:
+		t.Fatal("misbehaving client's connection not closed")
+	}
+}
+
+func TestBroadcastSurvivesMisbehavingConnection(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		misbehave func(*fakeConn) error
+	}{
+		{"panic", panics},
+		{"error", fails},
+		{"stall", stalls},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := startHub(t)
+			before, after := newFakeConn(t, nil), newFakeConn(t, nil)
+			bad := newFakeConn(t, tc.misbehave)
+			addClient(h, before, protocolV1, false)
+			badClient := addClient(h, bad, protocolV1, false)
+			addClient(h, after, protocolV1, false)
+
+			h.Broadcast(event{legacy: []byte(`{"price":1}`)})
+			expectMessage(t, before, `{"price":1}`)
+			expectMessage(t, after, `{"price":1}`)
+			expectDropped(t, badClient, bad)
+			if n := h.Clients(); n != 2 {
+				t.Fatalf("%d clients registered, want 2", n)
+			}
+
+			// The hub carries on without it
+			h.Broadcast(event{legacy: []byte(`{"price":2}`)})
+			expectMessage(t, before, `{"price":2}`)
+			expectMessage(t, after, `{"price":2}`)
+		})
+	}
+}
+
+func TestDeliveryPanicDropsOnlyThatClient(t *testing.T) {
+	h := startHub(t)
+	jsonConn, binaryConn := newFakeConn(t, nil), newFakeConn(t, nil)
+	addClient(h, jsonConn, protocolV2, false)
+	binaryClient := addClient(h, binaryConn, protocolV2, true)
+
+	// Only protobuf clients build the frame
+	h.Broadcast(event{
+		trade:      []byte(`{"type":"trade"}`),
+		tradeFrame: func() []byte { panic("bad frame") },
+	})
+	expectMessage(t, jsonConn, `{"seq":1,"type":"trade"}`)
+	expectDropped(t, binaryClient, binaryConn)
+	if n := h.Clients(); n != 1 {
+		t.Fatalf("%d clients registered, want 1", n)
+	}
+}
+
+func TestPanickingBuildSkipsOnlyThatPayload(t *testing.T) {
+	h := startHub(t)
+	conn := newFakeConn(t, nil)
+	c := h.newClient(conn, 0)
+	c.version = protocolV2
+	c.subs.add(subscription{Channel: channelPrice, Symbol: "btcusdt"})
+	c.subs.add(subscription{Channel: channelAlerts, Symbol: "btcusdt"})
+	h.Register(c)
+
+	h.Broadcast(event{symbol: "btcusdt", build: func(sub subscription) []byte {
+		if sub.Channel == channelPrice {
+			panic("bad price")
+		}
+		return []byte(`{"channel":"alerts"}`)
+	}})
+	expectMessage(t, conn, `{"seq":1,"channel":"alerts"}`)
+	select {
+	case <-c.done:
+		t.Fatal("client dropped for a payload that failed to build")
+	default:
+	}
+}
+
+func TestRegisterAfterShutdown(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	h.Shutdown()
+
+	conn := newFakeConn(t, nil)
+	c := h.newClient(conn, 0)
+	h.Register(c)
+	for name, ch := range map[string]chan struct{}{"unregistered": c.done, "stopped": c.stopped, "closed": conn.closed} {
+		select {
+		case <-ch:
+		default:
+			t.Fatalf("client registered after shutdown not %s", name)
+		}
+	}
+	h.Shutdown() // returns at once with no pump to wait for
+}
+
+func TestStringPrices(t *testing.T) {
+	h := startHub(t)
+	s := &Server{hub: h}
+	conns := map[string]*fakeConn{}
+	for _, name := range []string{"v1", "v1 string", "v2", "v2 string", "sub", "sub string"} {
+		conn := newFakeConn(t, nil)
+		c := h.newClient(conn, 0)
+		c.version = protocolV2
+		if strings.HasPrefix(name, "v1") {
+			c.version = protocolV1
+		}
+		c.prices = priceNumber
+		if strings.HasSuffix(name, "string") {
+			c.prices = priceString
+		}
+		if strings.HasPrefix(name, "sub") {
+			c.subs.add(subscription{Channel: channelPrice, Symbol: "pepeusdt"})
+		}
+		h.Register(c)
+		conns[name] = conn
+	}
+
+	s.broadcast(ProcessedMessage{Symbol: "pepeusdt", Price: 0.00001234, PriceE8: 1234, Time: 1}, true)
+	trade := `"precision":8,"price":%s,"quote":"","symbol":"pepeusdt","ts":1,"type":"trade"}`
+	channel := `{"seq":1,"channel":"price","precision":8,"price":%s,"quote":"","symbol":"pepeusdt","time":1,"ts":1,"type":"trade"}`
+	expectMessage(t, conns["v1"], `{"price":0.00001234}`)
+	expectMessage(t, conns["v1 string"], `{"price":"0.00001234"}`)
+	expectMessage(t, conns["v2"], fmt.Sprintf(`{"seq":1,`+trade, `0.00001234`))
+	expectMessage(t, conns["v2 string"], fmt.Sprintf(`{"seq":1,`+trade, `"0.00001234"`))
+	expectMessage(t, conns["sub"], fmt.Sprintf(channel, `0.00001234`))
+	expectMessage(t, conns["sub string"], fmt.Sprintf(channel, `"0.00001234"`))
+}
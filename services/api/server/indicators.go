@@ -0,0 +1,320 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Standard indicator parameters, in candles
+const (
+	rsiPeriod  = 14
+	macdFast   = 12
+	macdSlow   = 26
+	macdSignal = 9
+	bandPeriod = 20
+	bandWidth  = 2 // standard deviations either side of the middle band
+	atrPeriod  = 14
+)
+
+// Indicators are technical indicators computed over a symbol's closed
+// candles of one interval. An indicator is left out until enough candles
+// have closed for it.
+type Indicators struct {
+	Symbol    string          `json:"symbol"`
+	Interval  string          `json:"interval"`
+	Time      time.Time       `json:"time"`    // open time of the last closed candle
+	Candles   int             `json:"candles"` // closed candles the indicators cover
+	RSI       *RSI            `json:"rsi,omitempty"`
+	MACD      *MACD           `json:"macd,omitempty"`
+	Bollinger *BollingerBands `json:"bollinger,omitempty"`
+	ATR       *ATR            `json:"atr,omitempty"`
+}
+
+// RSI is the relative strength index with Wilder's smoothing
+type RSI struct {
+	Period int     `json:"period"`
+	Value  float64 `json:"value"`
+}
+
+// MACD is the moving average convergence/divergence of candle closes
+type MACD struct {
+	Fast         int     `json:"fast"`
+	Slow         int     `json:"slow"`
+	SignalPeriod int     `json:"signal_period"`
+	MACD         float64 `json:"macd"`
+	Signal       float64 `json:"signal"`
+	Histogram    float64 `json:"histogram"`
+}
+
+// BollingerBands are a simple moving average of closes with bands K
+// population standard deviations above and below it
+type BollingerBands struct {
+	Period    int     `json:"period"`
+	K         float64 `json:"k"`
+	Middle    float64 `json:"middle"`
+	Upper     float64 `json:"upper"`
+	Lower     float64 `json:"lower"`
+	Bandwidth float64 `json:"bandwidth"` // upper minus lower, in percent of middle
+}
+
+// ATR is the average true range with Wilder's smoothing
+type ATR struct {
+	Period int     `json:"period"`
+	Value  float64 `json:"value"`
+}
+
+// indicatorFuncs fills in each indicator by name from closed candles,
+// oldest first
+var indicatorFuncs = map[string]func(ind *Indicators, candles []Candle){
+	"rsi": func(ind *Indicators, candles []Candle) {
+		if v, ok := rsi(closes(candles), rsiPeriod); ok {
+			ind.RSI = &RSI{Period: rsiPeriod, Value: math.Round(v*100) / 100}
+		}
+	},
+	"macd": func(ind *Indicators, candles []Candle) {
+		line, signal, ok := macd(closes(candles), macdFast, macdSlow, macdSignal)
+		if !ok {
+			return
+		}
+		ind.MACD = &MACD{
+			Fast:         macdFast,
+			Slow:         macdSlow,
+			SignalPeriod: macdSignal,
+			MACD:         roundPrice(ind.Symbol, line),
+			Signal:       roundPrice(ind.Symbol, signal),
+			Histogram:    roundPrice(ind.Symbol, line-signal),
+		}
+	},
+	"bollinger": func(ind *Indicators, candles []Candle) {
+		middle, upper, lower, ok := bollinger(closes(candles), bandPeriod, bandWidth)
+		if !ok {
+			return
+		}
+		ind.Bollinger = &BollingerBands{
+			Period:    bandPeriod,
+			K:         bandWidth,
+			Middle:    roundPrice(ind.Symbol, middle),
+			Upper:     roundPrice(ind.Symbol, upper),
+			Lower:     roundPrice(ind.Symbol, lower),
+			Bandwidth: math.Round((upper-lower)/middle*1e6) / 1e4,
+		}
+	},
+	"atr": func(ind *Indicators, candles []Candle) {
+		if v, ok := atr(candles, atrPeriod); ok {
+			ind.ATR = &ATR{Period: atrPeriod, Value: roundPrice(ind.Symbol, v)}
+		}
+	},
+}
+
+// indicatorNames lists the known indicators, sorted
+func indicatorNames() []string {
+	names := make([]string, 0, len(indicatorFuncs))
+	for name := range indicatorFuncs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// parseIndicatorSet reads a comma separated list of indicator names, every
+// indicator when empty, and returns it sorted without duplicates
+func parseIndicatorSet(list string) ([]string, bool) {
+	if strings.TrimSpace(list) == "" {
+		return indicatorNames(), true
+	}
+	var set []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := indicatorFuncs[name]; !ok {
+			return nil, false
+		}
+		if !slices.Contains(set, name) {
+			set = append(set, name)
+		}
+	}
+	slices.Sort(set)
+	return set, true
+}
+
+// indicators computes set over the symbol's closed candles of interval
+func (s *Server) indicators(symbol, interval string, set []string) Indicators {
+	// The newest candle is still open
+	candles := s.candles.Candles(symbol, interval, maxCandles)
+	if len(candles) > 0 {
+		candles = candles[:len(candles)-1]
+	}
+	ind := Indicators{Symbol: symbol, Interval: interval, Candles: len(candles)}
+	if len(candles) == 0 {
+		return ind
+	}
+	ind.Time = candles[len(candles)-1].Time
+	for _, name := range set {
+		indicatorFuncs[name](&ind, candles)
+	}
+	return ind
+}
+
+func closes(candles []Candle) []float64 {
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = c.Close
+	}
+	return out
+}
+
+// rsi is the relative strength index of the last value: average gains and
+// losses start as the mean of the first period changes, then are smoothed
+// by 1/period. It needs period+1 values.
+func rsi(values []float64, period int) (float64, bool) {
+	if len(values) <= period {
+		return 0, false
+	}
+	var gain, loss float64
+	for i := 1; i < len(values); i++ {
+		up := max(values[i]-values[i-1], 0)
+		down := max(values[i-1]-values[i], 0)
+		if i <= period {
+			gain += up / float64(period)
+			loss += down / float64(period)
+			continue
+		}
+		gain = (gain*float64(period-1) + up) / float64(period)
+		loss = (loss*float64(period-1) + down) / float64(period)
+	}
+	switch {
+	case gain == 0 && loss == 0:
+		return 50, true
+	case loss == 0:
+		return 100, true
+	}
+	return 100 - 100/(1+gain/loss), true
+}
+
+// macd returns the fast minus slow EMA of values and the signal EMA of that
+// line. Like the strategies' averages, each EMA starts at its first value;
+// it needs slow+signal values before either has settled.
+func macd(values []float64, fast, slow, signal int) (line, sig float64, ok bool) {
+	if len(values) < slow+signal {
+		return 0, 0, false
+	}
+	fastEMA, slowEMA := values[0], values[0]
+	for i, v := range values {
+		fastEMA = ema(fastEMA, v, fast)
+		slowEMA = ema(slowEMA, v, slow)
+		line = fastEMA - slowEMA
+		if i == 0 {
+			sig = line
+		}
+		sig = ema(sig, line, signal)
+	}
+	return line, sig, true
+}
+
+// bollinger returns the mean of the last period values and the bands k
+// standard deviations either side of it
+func bollinger(values []float64, period int, k float64) (middle, upper, lower float64, ok bool) {
+	if len(values) < period {
+		return 0, 0, 0, false
+	}
+	window := values[len(values)-period:]
+	for _, v := range window {
+		middle += v
+	}
+	middle /= float64(period)
+	var variance float64
+	for _, v := range window {
+		variance += (v - middle) * (v - middle)
+	}
+	dev := math.Sqrt(variance / float64(period))
+	return middle, middle + k*dev, middle - k*dev, true
+}
+
+// trueRange is a candle's range extended to the previous close, so gaps
+// count as movement
+func trueRange(prev *Candle, c Candle) float64 {
+	if prev == nil {
+		return c.High - c.Low
+	}
+	return max(c.High-c.Low, math.Abs(c.High-prev.Close), math.Abs(c.Low-prev.Close))
+}
+
+// atr is the average true range of the last candle: the mean of the first
+// period true ranges, smoothed by 1/period from there. It needs period+1
+// candles, as the first has no previous close.
+func atr(candles []Candle, period int) (float64, bool) {
+	if len(candles) <= period {
+		return 0, false
+	}
+	var avg float64
+	for i := 1; i < len(candles); i++ {
+		tr := trueRange(&candles[i-1], candles[i])
+		if i <= period {
+			avg += tr / float64(period)
+			continue
+		}
+		avg = (avg*float64(period-1) + tr) / float64(period)
+	}
+	return avg, true
+}
+
+// handleIndicators computes indicators over a symbol's recent closed candles
+func (s *Server) handleIndicators(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "1m"
+	}
+	if _, ok := candleIntervals[interval]; !ok {
+		http.Error(w, "Unknown interval", http.StatusBadRequest)
+		return
+	}
+
+	set, ok := parseIndicatorSet(q.Get("set"))
+	if !ok {
+		http.Error(w, "Unknown indicator, want "+strings.Join(indicatorNames(), ","), http.StatusBadRequest)
+		return
+	}
+
+	s.writeJSON(w, r, s.indicators(symbol, interval, set))
+}
+
+// broadcastIndicators sends indicators subscribers fresh values for every
+// interval whose candle just closed. They are only computed for
+// subscriptions someone holds.
+func (s *Server) broadcastIndicators(symbol string, closed []ClosedCandle) {
+	if len(closed) == 0 {
+		return
+	}
+	s.hub.Broadcast(event{
+		symbol: symbol,
+		build: func(sub subscription) []byte {
+			if sub.Channel != channelIndicators || !slices.ContainsFunc(closed, func(cc ClosedCandle) bool {
+				return cc.Interval == sub.Interval
+			}) {
+				return nil
+			}
+			data, _ := json.Marshal(struct {
+				Type    string `json:"type"`
+				Channel string `json:"channel"`
+				Indicators
+			}{"indicators", channelIndicators, s.indicators(symbol, sub.Interval, strings.Split(sub.Set, ","))})
+			return data
+		},
+	})
+}
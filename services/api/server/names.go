@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NameOverrides relabels coins and markets, e.g. in a local language. It
+// changes display names only; symbols, and so what is streamed and stored,
+// stay the exchange's.
+//
+//	{
+//	  "coins":   {"BTC": {"name": "Bitcoin", "ticker": "XBT"}},
+//	  "markets": {"btceur": "Bitcoin in Euro", "pepeusdt": "Pepe (PEPE)"}
+//	}
+type NameOverrides struct {
+	// By exchange ticker. An empty field keeps the coin's own.
+	Coins map[string]CoinOverride `json:"coins"`
+	// Display name by symbol, replacing the one built from the coin's. A
+	// symbol outside the coin list, such as a watchlist extra, gets a name
+	// this way too.
+	Markets map[string]string `json:"markets"`
+}
+
+// CoinOverride is the display name and ticker of a coin, used in the names
+// of all its markets
+type CoinOverride struct {
+	Name   string `json:"name"`
+	Ticker string `json:"ticker"`
+}
+
+// Overrides in effect, set by New before anything is served
+var names NameOverrides
+
+// LoadNameOverrides reads a NameOverrides JSON file, rejecting coins that
+// aren't in the coin list
+func LoadNameOverrides(path string) (NameOverrides, error) {
+	var o NameOverrides
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return o, err
+	}
+	if err := json.Unmarshal(data, &o); err != nil {
+		return o, fmt.Errorf("%s: %w", path, err)
+	}
+
+	coinsByTicker := make(map[string]CoinOverride, len(o.Coins))
+	for ticker, override := range o.Coins {
+		ticker = strings.ToUpper(ticker)
+		if !knownTicker(ticker) {
+			return o, fmt.Errorf("%s: unknown coin %q", path, ticker)
+		}
+		coinsByTicker[ticker] = override
+	}
+	o.Coins = coinsByTicker
+
+	marketsBySymbol := make(map[string]string, len(o.Markets))
+	for symbol, name := range o.Markets {
+		if name == "" {
+			return o, fmt.Errorf("%s: empty name for %s", path, symbol)
+		}
+		marketsBySymbol[strings.ToLower(symbol)] = name
+	}
+	o.Markets = marketsBySymbol
+	return o, nil
+}
+
+func knownTicker(ticker string) bool {
+	for _, c := range coins {
+		if c.ticker == ticker {
+			return true
+		}
+	}
+	return false
+}
+
+// coinLabel is a coin's display name and ticker, with overrides applied
+func coinLabel(name, ticker string) (string, string) {
+	override := names.Coins[ticker]
+	if override.Name != "" {
+		name = override.Name
+	}
+	if override.Ticker != "" {
+		ticker = override.Ticker
+	}
+	return name, ticker
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// PaperPosition is the holding in one symbol, valued at the last price in
+// its quote currency
+type PaperPosition struct {
+	Symbol        string  `json:"symbol"`
+	Quote         string  `json:"quote"`
+	Quantity      float64 `json:"quantity"`
+	AvgPrice      float64 `json:"avg_price"`
+	Price         float64 `json:"price"`
+	MarketValue   float64 `json:"market_value"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+}
+
+// PaperPnL totals realized and unrealized profit across every symbol
+// traded. The totals add up quote currencies as they are, which only means
+// something when every market traded shares a quote (or a peg, like USDT
+// and FDUSD).
+type PaperPnL struct {
+	Realized   float64                  `json:"realized"`
+	Unrealized float64                  `json:"unrealized"`
+	Total      float64                  `json:"total"`
+	Symbols    map[string]PaperPosition `json:"symbols"`
+}
+
+var errInsufficientPosition = errors.New("Insufficient position")
+
+// Quantities are kept to the exchanges' smallest lot step, so selling what
+// was bought in several fills closes the position despite float rounding
+const paperQuantityDecimals = 8
+
+func roundQuantity(q float64) float64 {
+	scale := math.Pow10(paperQuantityDecimals)
+	return math.Round(q*scale) / scale
+}
+
+// paperHolding is the running state of one symbol. Positions are long only:
+// a sell can close at most what is held.
+type paperHolding struct {
+	quantity float64
+	avgPrice float64
+	realized float64
+}
+
+// apply fills an order, averaging the entry price on buys and realizing
+// the difference to it on sells
+func (h *paperHolding) apply(o PaperOrder) error {
+	switch o.Side {
+	case "buy":
+		cost := h.quantity*h.avgPrice + o.Quantity*o.Price
+		h.quantity = roundQuantity(h.quantity + o.Quantity)
+		h.avgPrice = cost / h.quantity
+	case "sell":
+		if o.Quantity > h.quantity {
+			return errInsufficientPosition
+		}
+		h.realized += o.Quantity * (o.Price - h.avgPrice)
+		h.quantity = roundQuantity(h.quantity - o.Quantity)
+		if h.quantity == 0 {
+			h.avgPrice = 0
+		}
+	}
+	return nil
+}
+
+// Portfolio is the simulated account. The order ledger is the source of
+// truth: it is saved to the store and replayed on startup, and positions
+// are derived from it.
+type Portfolio struct {
+	mu       sync.Mutex
+	store    Store // nil keeps the portfolio in memory only
+	holdings map[string]*paperHolding
+	orders   []PaperOrder
+}
+
+// NewPortfolio rebuilds the portfolio from the orders saved in store
+func NewPortfolio(ctx context.Context, store Store) (*Portfolio, error) {
+	p := &Portfolio{store: store, holdings: make(map[string]*paperHolding)}
+	if store == nil {
+		return p, nil
+	}
+	orders, err := store.Orders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		p.holding(o.Symbol).apply(o)
+		p.orders = append(p.orders, o)
+	}
+	return p, nil
+}
+
+func (p *Portfolio) holding(symbol string) *paperHolding {
+	h, ok := p.holdings[symbol]
+	if !ok {
+		h = &paperHolding{}
+		p.holdings[symbol] = h
+	}
+	return h
+}
+
+// Place fills an order and saves it before the portfolio changes, so a
+// failed save leaves nothing half applied
+func (p *Portfolio) Place(ctx context.Context, o PaperOrder) (PaperOrder, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o.ID = int64(len(p.orders) + 1)
+	next := *p.holding(o.Symbol)
+	if err := next.apply(o); err != nil {
+		return o, err
+	}
+	if p.store != nil {
+		if err := p.store.InsertOrder(ctx, o); err != nil {
+			return o, err
+		}
+	}
+	*p.holdings[o.Symbol] = next
+	p.orders = append(p.orders, o)
+	return o, nil
+}
+
+// Orders returns every filled order, oldest first
+func (p *Portfolio) Orders() []PaperOrder {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PaperOrder{}, p.orders...)
+}
+
+// Positions values every symbol traded with price, which reports the last
+// price of a symbol and false when there is none
+func (p *Portfolio) Positions(price func(symbol string) (float64, bool)) []PaperPosition {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]PaperPosition, 0, len(p.holdings))
+	for symbol, h := range p.holdings {
+		// Without a live price the position is marked at its entry
+		mark := h.avgPrice
+		if last, ok := price(symbol); ok {
+			mark = last
+		}
+		pos := PaperPosition{
+			Symbol:        symbol,
+			Quote:         marketInfo(symbol).Quote,
+			Quantity:      h.quantity,
+			AvgPrice:      roundPrice(symbol, h.avgPrice),
+			Price:         mark,
+			MarketValue:   roundPrice(symbol, h.quantity*mark),
+			UnrealizedPnL: roundPrice(symbol, h.quantity*(mark-h.avgPrice)),
+			RealizedPnL:   roundPrice(symbol, h.realized),
+		}
+		out = append(out, pos)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+// lastPrice is the latest traded price of any streamed symbol
+func (s *Server) lastPrice(symbol string) (float64, bool) {
+	candles := s.candles.Candles(symbol, "1s", 1)
+	if len(candles) == 0 {
+		return 0, false
+	}
+	return candles[0].Close, true
+}
+
+// handlePaperOrders lists the paper orders or places a market order at the
+// last price
+func (s *Server) handlePaperOrders(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, r, s.paper.Orders())
+	case http.MethodPost:
+		var req struct {
+			Symbol   string  `json:"symbol"`
+			Side     string  `json:"side"`
+			Quantity float64 `json:"quantity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.Symbol == "" {
+			s.mu.RLock()
+			req.Symbol = s.symbol
+			s.mu.RUnlock()
+		}
+		if _, ok := lookupMarket(req.Symbol); !ok {
+			http.Error(w, "Invalid symbol", http.StatusBadRequest)
+			return
+		}
+		if req.Side != "buy" && req.Side != "sell" {
+			http.Error(w, "Invalid side", http.StatusBadRequest)
+			return
+		}
+		req.Quantity = roundQuantity(req.Quantity)
+		if req.Quantity <= 0 {
+			http.Error(w, "Invalid quantity", http.StatusBadRequest)
+			return
+		}
+		price, ok := s.lastPrice(req.Symbol)
+		if !ok {
+			http.Error(w, "No price for symbol yet", http.StatusConflict)
+			return
+		}
+
+		order, err := s.paper.Place(r.Context(), PaperOrder{
+			Symbol:   req.Symbol,
+			Side:     req.Side,
+			Quantity: req.Quantity,
+			Price:    price,
+			Time:     s.clock.Now(),
+		})
+		if errors.Is(err, errInsufficientPosition) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save order", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		s.writeJSON(w, r, order)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePaperPositions lists open paper positions
+func (s *Server) handlePaperPositions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	open := []PaperPosition{}
+	for _, pos := range s.paper.Positions(s.lastPrice) {
+		if pos.Quantity > 0 {
+			open = append(open, pos)
+		}
+	}
+	s.writeJSON(w, r, open)
+}
+
+// handlePaperPnL reports realized and unrealized PnL, in total and per symbol
+func (s *Server) handlePaperPnL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pnl := PaperPnL{Symbols: make(map[string]PaperPosition)}
+	for _, pos := range s.paper.Positions(s.lastPrice) {
+		pnl.Realized += pos.RealizedPnL
+		pnl.Unrealized += pos.UnrealizedPnL
+		pnl.Symbols[pos.Symbol] = pos
+	}
+	pnl.Total = pnl.Realized + pnl.Unrealized
+	s.writeJSON(w, r, pnl)
+}
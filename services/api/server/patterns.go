@@ -0,0 +1,147 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Candle patterns recognised on closed candles
+const (
+	patternDoji             = "doji"
+	patternHammer           = "hammer"
+	patternBullishEngulfing = "bullish_engulfing"
+	patternBearishEngulfing = "bearish_engulfing"
+)
+
+// Pattern names alert rules accept; "engulfing" matches either direction
+var alertPatterns = map[string]bool{
+	patternDoji:             true,
+	patternHammer:           true,
+	patternBullishEngulfing: true,
+	patternBearishEngulfing: true,
+	"engulfing":             true,
+}
+
+const (
+	// A doji's body is at most this fraction of its range
+	dojiBodyRatio = 0.1
+	// A hammer's lower shadow is at least this many times its body
+	hammerShadowRatio = 2.0
+)
+
+// CandlePattern is a pattern found on a closed candle
+type CandlePattern struct {
+	Time    time.Time `json:"time"`
+	Pattern string    `json:"pattern"`
+	Signal  string    `json:"signal"` // bullish, bearish or neutral
+	Candle  Candle    `json:"candle"`
+}
+
+func patternSignal(pattern string) string {
+	switch pattern {
+	case patternHammer, patternBullishEngulfing:
+		return "bullish"
+	case patternBearishEngulfing:
+		return "bearish"
+	}
+	return "neutral"
+}
+
+// detectPatterns returns the patterns c forms, given the candle before it
+// (nil for the first). Shapes are judged on the candles alone, without the
+// trend context a chartist would also weigh.
+func detectPatterns(prev *Candle, c Candle) []string {
+	span := c.High - c.Low
+	if span <= 0 {
+		// A flat candle, usually a single trade, says nothing
+		return nil
+	}
+	body := math.Abs(c.Close - c.Open)
+	upper := c.High - max(c.Open, c.Close)
+	lower := min(c.Open, c.Close) - c.Low
+
+	var found []string
+	if body <= span*dojiBodyRatio {
+		found = append(found, patternDoji)
+	} else if lower >= body*hammerShadowRatio && upper <= body {
+		found = append(found, patternHammer)
+	}
+
+	if prev != nil {
+		prevBody := math.Abs(prev.Close - prev.Open)
+		if prevBody > 0 && body > prevBody {
+			switch {
+			case prev.Close < prev.Open && c.Close > c.Open && c.Open <= prev.Close && c.Close >= prev.Open:
+				found = append(found, patternBullishEngulfing)
+			case prev.Close > prev.Open && c.Close < c.Open && c.Open >= prev.Close && c.Close <= prev.Open:
+				found = append(found, patternBearishEngulfing)
+			}
+		}
+	}
+	return found
+}
+
+// matchesPattern reports whether a detected pattern satisfies a rule's
+func matchesPattern(want, got string) bool {
+	if want == "engulfing" {
+		return got == patternBullishEngulfing || got == patternBearishEngulfing
+	}
+	return want == got
+}
+
+// findPatterns scans closed candles, oldest first, and returns every pattern
+// in the same order
+func findPatterns(candles []Candle) []CandlePattern {
+	out := []CandlePattern{}
+	for i, c := range candles {
+		var prev *Candle
+		if i > 0 {
+			prev = &candles[i-1]
+		}
+		for _, p := range detectPatterns(prev, c) {
+			out = append(out, CandlePattern{Time: c.Time, Pattern: p, Signal: patternSignal(p), Candle: c})
+		}
+	}
+	return out
+}
+
+// handlePatterns lists the patterns found on a symbol's recent closed candles
+func (s *Server) handlePatterns(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "1m"
+	}
+	if _, ok := candleIntervals[interval]; !ok {
+		http.Error(w, "Unknown interval", http.StatusBadRequest)
+		return
+	}
+
+	limit := 200
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxCandles)
+	}
+
+	// The newest candle is still open and may change shape
+	candles := s.candles.Candles(symbol, interval, limit+1)
+	if len(candles) > 0 {
+		candles = candles[:len(candles)-1]
+	}
+
+	s.writeJSON(w, r, findPatterns(candles))
+}
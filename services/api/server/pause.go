@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// handleSymbolPause mutes a symbol in ingestion: its exchange stream is
+// released and nothing is processed or stored for it until it is resumed,
+// but it stays in the watchlist
+func (s *Server) handleSymbolPause(w http.ResponseWriter, r *http.Request) {
+	s.setSymbolPaused(w, r, true)
+}
+
+// handleSymbolResume streams a paused symbol again, after backfilling the gap
+func (s *Server) handleSymbolResume(w http.ResponseWriter, r *http.Request) {
+	s.setSymbolPaused(w, r, false)
+}
+
+func (s *Server) setSymbolPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	symbol := strings.ToLower(r.PathValue("symbol"))
+	if _, ok := lookupMarket(symbol); !ok {
+		http.Error(w, "Unknown symbol", http.StatusNotFound)
+		return
+	}
+
+	body, _ := json.Marshal(map[string]any{"symbol": symbol, "paused": paused})
+	reply, err := s.nc.Request("control.pause", body, configTimeout)
+	if err == nats.ErrNoResponders || err == nats.ErrTimeout {
+		http.Error(w, "Ingestion service not available", http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to reach ingestion service", http.StatusInternalServerError)
+		return
+	}
+	var result struct {
+		Paused []string `json:"paused"`
+		Error  string   `json:"error"`
+	}
+	if err := json.Unmarshal(reply.Data, &result); err != nil {
+		http.Error(w, "Invalid reply from ingestion service", http.StatusBadGateway)
+		return
+	}
+	if result.Error != "" {
+		http.Error(w, result.Error, http.StatusConflict)
+		return
+	}
+
+	// Show the change in /api/status before the next report
+	if status := s.ingestion.Load(); status != nil {
+		updated := *status
+		updated.Paused = result.Paused
+		s.ingestion.CompareAndSwap(status, &updated)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"symbol": symbol,
+		"paused": paused,
+		"all":    result.Paused,
+	})
+}
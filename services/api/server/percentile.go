@@ -0,0 +1,63 @@
+package server
+
+import "time"
+
+// Ranges the current price is placed in, with the candles each is read from
+var bandDefs = []struct {
+	name     string
+	window   time.Duration
+	interval string
+}{
+	{"24h", 24 * time.Hour, "5m"},
+	{"7d", 7 * 24 * time.Hour, "1h"},
+}
+
+// PercentileBand places a price within the candles of a trailing range
+type PercentileBand struct {
+	Percentile float64 `json:"percentile"`
+	Low        float64 `json:"low"`
+	High       float64 `json:"high"`
+	Candles    int     `json:"candles"`
+	Complete   bool    `json:"complete"` // the candles span the whole range
+}
+
+// bands places price within each range of the symbol's candles, leaving out
+// ranges without any
+func (s *Server) bands(symbol string, price float64, now time.Time) map[string]PercentileBand {
+	out := make(map[string]PercentileBand)
+	if price == 0 {
+		return out
+	}
+	for _, def := range bandDefs {
+		width := candleIntervals[def.interval]
+		limit := int(def.window / width)
+		candles := s.candles.Candles(symbol, def.interval, limit)
+		cutoff := now.Add(-def.window)
+		for len(candles) > 0 && !candles[0].Time.Add(width).After(cutoff) {
+			candles = candles[1:]
+		}
+		if len(candles) == 0 {
+			continue
+		}
+		out[def.name] = percentileBand(candles, price, !candles[0].Time.After(cutoff.Add(width)))
+	}
+	return out
+}
+
+// percentileBand ranks price among the candles' closes, counting equal
+// closes as half below
+func percentileBand(candles []Candle, price float64, complete bool) PercentileBand {
+	band := PercentileBand{Low: candles[0].Low, High: candles[0].High, Candles: len(candles), Complete: complete}
+	var below float64
+	for _, c := range candles {
+		band.Low = min(band.Low, c.Low)
+		band.High = max(band.High, c.High)
+		if c.Close < price {
+			below++
+		} else if c.Close == price {
+			below += 0.5
+		}
+	}
+	band.Percentile = below / float64(len(candles)) * 100
+	return band
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Price formats of JSON responses
+const (
+	priceNumber = "number"
+	priceString = "string" // decimal strings, e.g. "0.00001234"
+)
+
+// priceFormat is the price format a request asks for with ?prices=, or the
+// configured default. It isn't ?format=, which already picks /ws's message
+// encoding and the file format of exports and reports.
+func (s *Server) priceFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("prices"); f == priceNumber || f == priceString {
+		return f
+	}
+	return s.cfg.PriceFormat
+}
+
+// writeJSON encodes v as the response body. With the string price format,
+// Decimal prices are sent as decimal strings, so clients can read them into a
+// decimal type without going through a float.
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.priceFormat(r) != priceString {
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+	data, err := stringPrices(v)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+// streamPrice is a trade price as streamed JSON messages carry it: the
+// exact number, or its decimal string in the string format
+func streamPrice(d Decimal, format string) any {
+	if format == priceString {
+		return d.String()
+	}
+	return d
+}
+
+var decimalType = reflect.TypeFor[Decimal]()
+
+// stringPrices encodes v as JSON with its Decimal values as strings. A
+// Decimal encodes as its exact digits, so quoting the number is all it takes.
+func stringPrices(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out bytes.Buffer
+	if err := (&priceRewriter{dec: dec, out: &out}).value(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// priceRewriter copies the JSON encoding of a value token by token,
+// following the value alongside to know which numbers are Decimals
+type priceRewriter struct {
+	dec *json.Decoder
+	out *bytes.Buffer
+}
+
+// value copies the next JSON value, which encodes v. v is invalid where the
+// Go value is unknown, and nothing below it is rewritten.
+func (p *priceRewriter) value(v reflect.Value) error {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+	tok, err := p.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			return p.array(v)
+		}
+		return p.object(v)
+	case json.Number:
+		if v.IsValid() && v.Type() == decimalType {
+			p.out.WriteString(strconv.Quote(tok.String()))
+		} else {
+			p.out.WriteString(tok.String())
+		}
+	default:
+		data, _ := json.Marshal(tok)
+		p.out.Write(data)
+	}
+	return nil
+}
+
+func (p *priceRewriter) array(v reflect.Value) error {
+	if v.IsValid() && v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		v = reflect.Value{}
+	}
+	p.out.WriteByte('[')
+	for i := 0; p.dec.More(); i++ {
+		if i > 0 {
+			p.out.WriteByte(',')
+		}
+		var elem reflect.Value
+		if v.IsValid() && i < v.Len() {
+			elem = v.Index(i)
+		}
+		if err := p.value(elem); err != nil {
+			return err
+		}
+	}
+	p.dec.Token() // ]
+	p.out.WriteByte(']')
+	return nil
+}
+
+func (p *priceRewriter) object(v reflect.Value) error {
+	p.out.WriteByte('{')
+	for i := 0; p.dec.More(); i++ {
+		tok, err := p.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if i > 0 {
+			p.out.WriteByte(',')
+		}
+		data, _ := json.Marshal(key)
+		p.out.Write(data)
+		p.out.WriteByte(':')
+
+		var field reflect.Value
+		switch {
+		case !v.IsValid():
+		case v.Kind() == reflect.Struct:
+			field = jsonField(v, key)
+		case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			field = v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		case v.Kind() == reflect.Map:
+			// Keyed by number; every value has the same type
+			field = reflect.Zero(v.Type().Elem())
+		}
+		if err := p.value(field); err != nil {
+			return err
+		}
+	}
+	p.dec.Token() // }
+	p.out.WriteByte('}')
+	return nil
+}
+
+// jsonField finds the struct field encoded under key, looking into
+// embedded structs the way encoding/json does
+func jsonField(v reflect.Value, key string) reflect.Value {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if field := jsonField(v.Field(i), key); field.IsValid() {
+				return field
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Window over which the trade rate is averaged, in one-second buckets
+const rateWindow = 10
+
+type rateBucket struct {
+	sec   int64
+	count int64
+}
+
+// RateTracker measures trades per second per symbol over the last
+// rateWindow seconds
+type RateTracker struct {
+	mu      sync.Mutex
+	symbols map[string]*[rateWindow]rateBucket
+}
+
+func NewRateTracker() *RateTracker {
+	return &RateTracker{symbols: make(map[string]*[rateWindow]rateBucket)}
+}
+
+// Add counts one trade received at now
+func (r *RateTracker) Add(symbol string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buckets, ok := r.symbols[symbol]
+	if !ok {
+		buckets = &[rateWindow]rateBucket{}
+		r.symbols[symbol] = buckets
+	}
+
+	sec := now.Unix()
+	b := &buckets[sec%rateWindow]
+	if b.sec != sec {
+		*b = rateBucket{sec: sec}
+	}
+	b.count++
+}
+
+// Rate returns the average trades per second over the window ending at now
+func (r *RateTracker) Rate(symbol string, now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buckets, ok := r.symbols[symbol]
+	if !ok {
+		return 0
+	}
+
+	sec := now.Unix()
+	var total int64
+	for _, b := range buckets {
+		if b.sec > sec-rateWindow && b.sec <= sec {
+			total += b.count
+		}
+	}
+	return float64(total) / rateWindow
+}
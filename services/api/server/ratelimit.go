@@ -0,0 +1,204 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// How often idle buckets are dropped, so addresses seen once don't pile up
+const rateSweepInterval = time.Minute
+
+// RateLimiter gives each client address a token bucket holding up to burst
+// requests and refilled at rate per second. A rate of 0 or less admits
+// everyone.
+type RateLimiter struct {
+	rate     float64
+	burst    float64
+	rejected atomic.Int64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes a token from addr's bucket, or reports how long until the
+// next one when it is empty
+func (l *RateLimiter) Allow(addr string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) >= rateSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[addr]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[addr] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		l.rejected.Add(1)
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets that have refilled, which are the same as new
+// ones. Called with mu held.
+func (l *RateLimiter) sweep(now time.Time) {
+	for addr, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, addr)
+		}
+	}
+	l.swept = now
+}
+
+// RateLimitStatus is the JSON view of a RateLimiter
+type RateLimitStatus struct {
+	Rate      float64 `json:"rate"` // requests per second per address, 0 when unlimited
+	Burst     int     `json:"burst"`
+	Addresses int     `json:"addresses"` // tracked until their bucket refills
+	Rejected  int64   `json:"rejected"`
+}
+
+func (l *RateLimiter) Status() RateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return RateLimitStatus{
+		Rate:      max(l.rate, 0),
+		Burst:     int(l.burst),
+		Addresses: len(l.buckets),
+		Rejected:  l.rejected.Load(),
+	}
+}
+
+// AddrLimiter caps how many holders each client address may have at once,
+// like Limiter does for everyone together. A max of 0 or less admits
+// everyone.
+type AddrLimiter struct {
+	max      int
+	rejected atomic.Int64
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewAddrLimiter(max int) *AddrLimiter {
+	return &AddrLimiter{max: max, counts: make(map[string]int)}
+}
+
+// Acquire admits a holder for addr if it has room; every successful Acquire
+// must be paired with a Release of the same addr
+func (l *AddrLimiter) Acquire(addr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.counts[addr] >= l.max {
+		l.rejected.Add(1)
+		return false
+	}
+	l.counts[addr]++
+	return true
+}
+
+func (l *AddrLimiter) Release(addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[addr]--; l.counts[addr] <= 0 {
+		delete(l.counts, addr)
+	}
+}
+
+// AddrLimiterStatus is the JSON view of an AddrLimiter
+type AddrLimiterStatus struct {
+	Max       int   `json:"max"` // per address, 0 for no limit
+	Addresses int   `json:"addresses"`
+	Rejected  int64 `json:"rejected"`
+}
+
+func (l *AddrLimiter) Status() AddrLimiterStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return AddrLimiterStatus{
+		Max:       max(l.max, 0),
+		Addresses: len(l.counts),
+		Rejected:  l.rejected.Load(),
+	}
+}
+
+// clientAddr is the IP address a request came from. Behind a reverse proxy
+// every request comes from the proxy, so with TrustProxy it is the address
+// the proxy appended to X-Forwarded-For instead.
+func (s *Server) clientAddr(r *http.Request) string {
+	if s.cfg.TrustProxy {
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			list := forwarded[len(forwarded)-1]
+			if addr := strings.TrimSpace(list[strings.LastIndex(list, ",")+1:]); addr != "" {
+				return addr
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rejectTooMany tells a client it has sent too much, and when to retry
+func rejectTooMany(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(wait, time.Second).Seconds()))))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
+
+// closeTooMany ends a WebSocket connection over its address's limit with
+// close code 1013, try again later. Browsers don't expose the status of a
+// refused handshake, but they do report close codes.
+func closeTooMany(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many connections from this address")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// rateLimit applies the per address rate limit to every request but
+// /api/status, which monitoring polls. WebSocket and SSE connections count
+// once, when they open.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/status" {
+			if ok, wait := s.rateLimiter.Allow(s.clientAddr(r)); !ok {
+				rejectTooMany(w, wait)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys and channels shared by the instances, all under redisPrefix
+const (
+	redisPrefix    = "sign:"
+	redisFeederKey = redisPrefix + "feeder"  // id of the instance feeding trades, with a TTL
+	redisTrades    = redisPrefix + "trades"  // channel: processed trades, from the feeder
+	redisSymbol    = redisPrefix + "symbol"  // key and channel: the selected symbol
+	redisLatest    = redisPrefix + "latest:" // key per symbol: its last processed trade
+)
+
+// The feeder renews its lease every redisLeaseRenew; when it dies another
+// instance takes over within redisLeaseTTL
+const (
+	redisLeaseTTL   = 15 * time.Second
+	redisLeaseRenew = 5 * time.Second
+)
+
+// How long a symbol's latest trade is kept once it stops streaming
+const redisLatestTTL = 24 * time.Hour
+
+// Extends the lease only while this instance still holds it
+var redisRenew = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// Gives the lease up on shutdown, unless it already passed to another
+var redisRelease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// cluster lets several API instances behind a load balancer act as one.
+// One instance, the feeder, holds a lease in Redis: it alone subscribes to
+// trades.processed, stores the trades and republishes them on a Redis
+// channel. Every instance, the feeder included, handles the trades from
+// that channel and serves its own WebSocket clients, so clients scale with
+// the number of instances while each trade is stored once. The selected
+// symbol and each symbol's latest trade live in Redis keys, so a new
+// instance starts where the others are.
+type cluster struct {
+	rdb    *redis.Client
+	id     string
+	feeder atomic.Bool
+	feed   *nats.Subscription // while feeder
+	pubsub *redis.PubSub
+	leased chan struct{} // closed when holdFeederLease returns
+}
+
+// connectCluster connects to Redis at url
+func connectCluster(ctx context.Context, url string) (*cluster, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	rdb := redis.NewClient(opts)
+	for i := 0; ; i++ {
+		if err = rdb.Ping(ctx).Err(); err == nil {
+			break
+		}
+		if i == 9 {
+			rdb.Close()
+			return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		log.Printf("Redis connection failed, retrying in 2s... (%v)", err)
+		time.Sleep(2 * time.Second)
+	}
+
+	host, _ := os.Hostname()
+	id := host + "-" + strconv.FormatInt(time.Now().UnixNano()%1e6, 36)
+	return &cluster{rdb: rdb, id: id}, nil
+}
+
+// Status reports this instance's role
+func (c *cluster) Status() *ClusterStatus {
+	return &ClusterStatus{Instance: c.id, Feeder: c.feeder.Load()}
+}
+
+// joinCluster adopts the cluster's selected symbol and latest trade, then
+// starts handling fanned out trades and competing for the feeder lease
+func (s *Server) joinCluster(ctx context.Context) {
+	c := s.cluster
+	if symbol, err := c.rdb.Get(ctx, redisSymbol).Result(); err == nil {
+		if market, ok := lookupMarket(symbol); ok {
+			s.mu.Lock()
+			s.symbol, s.coinName = market.Symbol, market.Name
+			s.mu.Unlock()
+		}
+	}
+	s.mu.RLock()
+	symbol := s.symbol
+	s.mu.RUnlock()
+	if data, err := c.rdb.Get(ctx, redisLatest+symbol).Bytes(); err == nil {
+		var latest ProcessedMessage
+		if json.Unmarshal(data, &latest) == nil {
+			s.mu.Lock()
+			s.current = latest
+			s.mu.Unlock()
+		}
+	}
+
+	c.pubsub = c.rdb.Subscribe(ctx, redisTrades, redisSymbol)
+	go func() {
+		for msg := range c.pubsub.Channel() {
+			switch msg.Channel {
+			case redisTrades:
+				var processed ProcessedMessage
+				if json.Unmarshal([]byte(msg.Payload), &processed) == nil {
+					s.onProcessed(processed)
+				}
+			case redisSymbol:
+				if market, ok := lookupMarket(msg.Payload); ok {
+					s.symbolChangeMu.Lock()
+					if s.setSymbol(market.Symbol, market.Name) {
+						log.Printf("Changed to %s by another instance", market.Name)
+					}
+					s.symbolChangeMu.Unlock()
+				}
+			}
+		}
+	}()
+
+	c.leased = make(chan struct{})
+	go s.holdFeederLease(ctx)
+	log.Printf("Joined Redis cluster as %s", c.id)
+}
+
+// holdFeederLease takes the feeder lease whenever it is free and renews it
+// while held, until ctx is done
+func (s *Server) holdFeederLease(ctx context.Context) {
+	c := s.cluster
+	defer close(c.leased)
+	ticker := time.NewTicker(redisLeaseRenew)
+	defer ticker.Stop()
+	for {
+		var held bool
+		if c.feeder.Load() {
+			n, err := redisRenew.Run(ctx, c.rdb, []string{redisFeederKey}, c.id, redisLeaseTTL.Milliseconds()).Int()
+			held = err == nil && n == 1
+		} else {
+			ok, err := c.rdb.SetNX(ctx, redisFeederKey, c.id, redisLeaseTTL).Result()
+			held = err == nil && ok
+		}
+		s.setFeeder(held)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setFeeder starts or stops feeding trades from NATS into Redis
+func (s *Server) setFeeder(feeder bool) {
+	c := s.cluster
+	if c.feeder.Load() == feeder {
+		return
+	}
+	if !feeder {
+		c.feed.Unsubscribe()
+		c.feed = nil
+		c.feeder.Store(false)
+		log.Println("Lost the feeder lease")
+		return
+	}
+
+	sub, err := s.nc.Subscribe("trades.processed", func(msg *nats.Msg) {
+		var processed ProcessedMessage
+		if err := json.Unmarshal(msg.Data, &processed); err != nil {
+			return
+		}
+		pipe := c.rdb.Pipeline()
+		pipe.Publish(context.Background(), redisTrades, msg.Data)
+		if !processed.Backfill {
+			pipe.Set(context.Background(), redisLatest+processed.Symbol, msg.Data, redisLatestTTL)
+		}
+		if _, err := pipe.Exec(context.Background()); err != nil {
+			log.Printf("Redis fan-out error: %v", err)
+		}
+	})
+	if err != nil {
+		log.Printf("Feeder subscribe error: %v", err)
+		return
+	}
+	c.feed = sub
+	c.feeder.Store(true)
+	log.Println("Took the feeder lease, fanning out trades")
+}
+
+// shareSymbol records the selected symbol for the other instances
+func (c *cluster) shareSymbol(symbol string) {
+	ctx := context.Background()
+	pipe := c.rdb.Pipeline()
+	pipe.Set(ctx, redisSymbol, symbol, 0)
+	pipe.Publish(ctx, redisSymbol, symbol)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Redis symbol share error: %v", err)
+	}
+}
+
+// leave stops feeding, hands the lease back so another instance takes
+// over at once, and disconnects. The ctx given to joinCluster must be done.
+func (c *cluster) leave() {
+	if c.leased != nil {
+		<-c.leased
+	}
+	if c.feed != nil {
+		c.feed.Unsubscribe()
+	}
+	if c.feeder.Load() {
+		redisRelease.Run(context.Background(), c.rdb, []string{redisFeederKey}, c.id)
+	}
+	if c.pubsub != nil {
+		c.pubsub.Close()
+	}
+	c.rdb.Close()
+}
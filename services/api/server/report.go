@@ -0,0 +1,337 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// Largest trades listed per symbol in a daily report
+	reportTopTrades = 5
+	// Alert messages listed per symbol; the rest are only counted
+	reportAlertMessages = 20
+	// How often the reporter checks whether the day has ended, for feeds
+	// too quiet to roll it over with a trade
+	reportCheckInterval = time.Minute
+)
+
+// Daily report formats
+const (
+	reportMarkdown = "markdown"
+	reportHTML     = "html"
+)
+
+// DailyReport summarizes one UTC day of the live trade stream
+type DailyReport struct {
+	Date    string         `json:"date"` // 2006-01-02
+	Symbols []SymbolReport `json:"symbols"`
+}
+
+// SymbolReport is one symbol's day
+type SymbolReport struct {
+	Symbol        string        `json:"symbol"`
+	Open          float64       `json:"open"`
+	High          float64       `json:"high"`
+	Low           float64       `json:"low"`
+	Close         float64       `json:"close"`
+	Volume        float64       `json:"volume"` // base asset, from trades that report a size
+	Trades        int64         `json:"trades"`
+	Range         float64       `json:"range"`
+	RangePercent  float64       `json:"range_percent"`
+	ChangePercent float64       `json:"change_percent"`
+	BiggestTrades []ReportTrade `json:"biggest_trades"`
+	Alerts        int           `json:"alerts"`
+	AlertMessages []string      `json:"alert_messages"`
+}
+
+// ReportTrade is a trade listed in a report
+type ReportTrade struct {
+	Price float64   `json:"price"`
+	Qty   float64   `json:"qty"`
+	Time  time.Time `json:"time"`
+}
+
+// ReportTracker accumulates the current day's report from processed trades
+// and fired alerts
+type ReportTracker struct {
+	mu      sync.Mutex
+	day     string
+	symbols map[string]*SymbolReport
+}
+
+func NewReportTracker() *ReportTracker {
+	return &ReportTracker{symbols: make(map[string]*SymbolReport)}
+}
+
+func reportDay(t time.Time) string { return t.UTC().Format(time.DateOnly) }
+
+// Roll starts a new day when now is past the current one and returns the
+// finished day's report, or nil when the day hasn't changed or saw no trades
+func (rt *ReportTracker) Roll(now time.Time) *DailyReport {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.roll(reportDay(now))
+}
+
+func (rt *ReportTracker) roll(day string) *DailyReport {
+	if day <= rt.day {
+		return nil
+	}
+	var done *DailyReport
+	if rt.day != "" && len(rt.symbols) > 0 {
+		report := rt.reportLocked()
+		done = &report
+	}
+	rt.day = day
+	rt.symbols = make(map[string]*SymbolReport)
+	return done
+}
+
+// AddTrade folds a trade into its day, first rolling over to that day if
+// it is a new one, whose finished report is returned
+func (rt *ReportTracker) AddTrade(symbol string, price, qty float64, ts time.Time) *DailyReport {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	day := reportDay(ts)
+	done := rt.roll(day)
+	if day != rt.day {
+		// Late trade from a day already reported
+		return done
+	}
+
+	sr, ok := rt.symbols[symbol]
+	if !ok {
+		sr = &SymbolReport{Symbol: symbol, Open: price, High: price, Low: price}
+		rt.symbols[symbol] = sr
+	}
+	sr.High = max(sr.High, price)
+	sr.Low = min(sr.Low, price)
+	sr.Close = price
+	sr.Volume += qty
+	sr.Trades++
+
+	// Keep the largest trades by notional, biggest first
+	if qty > 0 {
+		sr.BiggestTrades = append(sr.BiggestTrades, ReportTrade{Price: price, Qty: qty, Time: ts})
+		slices.SortStableFunc(sr.BiggestTrades, func(a, b ReportTrade) int {
+			switch na, nb := a.Price*a.Qty, b.Price*b.Qty; {
+			case na > nb:
+				return -1
+			case na < nb:
+				return 1
+			}
+			return 0
+		})
+		sr.BiggestTrades = sr.BiggestTrades[:min(len(sr.BiggestTrades), reportTopTrades)]
+	}
+	return done
+}
+
+// AddAlert counts a fired alert toward its symbol's day
+func (rt *ReportTracker) AddAlert(n AlertNotification) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	sr, ok := rt.symbols[n.Symbol]
+	if !ok || reportDay(n.Time) != rt.day {
+		// No trades for it today, or fired on a candle from yesterday
+		return
+	}
+	sr.Alerts++
+	if len(sr.AlertMessages) < reportAlertMessages {
+		sr.AlertMessages = append(sr.AlertMessages, n.Message)
+	}
+}
+
+// Current returns the report of the day so far
+func (rt *ReportTracker) Current() DailyReport {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.reportLocked()
+}
+
+func (rt *ReportTracker) reportLocked() DailyReport {
+	report := DailyReport{Date: rt.day, Symbols: []SymbolReport{}}
+	for _, sr := range rt.symbols {
+		out := *sr
+		out.Range = roundPrice(sr.Symbol, sr.High-sr.Low)
+		if sr.Low > 0 {
+			out.RangePercent = out.Range / sr.Low * 100
+		}
+		if sr.Open > 0 {
+			out.ChangePercent = (sr.Close - sr.Open) / sr.Open * 100
+		}
+		out.BiggestTrades = append([]ReportTrade{}, sr.BiggestTrades...)
+		out.AlertMessages = append([]string{}, sr.AlertMessages...)
+		report.Symbols = append(report.Symbols, out)
+	}
+	slices.SortFunc(report.Symbols, func(a, b SymbolReport) int { return strings.Compare(a.Symbol, b.Symbol) })
+	return report
+}
+
+var reportFuncs = template.FuncMap{
+	"upper":   strings.ToUpper,
+	"price":   formatAlertPrice,
+	"percent": func(v float64) string { return fmt.Sprintf("%+.2f%%", v) },
+	"clock":   func(t time.Time) string { return t.UTC().Format("15:04:05") },
+}
+
+var markdownReport = template.Must(template.New("markdown").Funcs(reportFuncs).Parse(`# Daily summary {{.Date}}
+{{range .Symbols}}
+## {{upper .Symbol}}
+
+| Open | High | Low | Close | Change | Range | Volume | Trades |
+|------|------|-----|-------|--------|-------|--------|--------|
+| {{price .Open}} | {{price .High}} | {{price .Low}} | {{price .Close}} | {{percent .ChangePercent}} | {{price .Range}} ({{printf "%.2f" .RangePercent}}%) | {{price .Volume}} | {{.Trades}} |
+{{if .BiggestTrades}}
+Biggest trades:
+{{range .BiggestTrades}}
+- {{price .Qty}} at {{price .Price}} ({{clock .Time}} UTC)
+{{- end}}
+{{end}}
+Alerts fired: {{.Alerts}}
+{{- range .AlertMessages}}
+- {{.}}
+{{- end}}
+{{else}}
+No trades.
+{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap(reportFuncs)).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Daily summary {{.Date}}</title></head>
+<body>
+<h1>Daily summary {{.Date}}</h1>
+{{range .Symbols}}
+<h2>{{upper .Symbol}}</h2>
+<table>
+<tr><th>Open</th><th>High</th><th>Low</th><th>Close</th><th>Change</th><th>Range</th><th>Volume</th><th>Trades</th></tr>
+<tr><td>{{price .Open}}</td><td>{{price .High}}</td><td>{{price .Low}}</td><td>{{price .Close}}</td><td>{{percent .ChangePercent}}</td><td>{{price .Range}} ({{printf "%.2f" .RangePercent}}%)</td><td>{{price .Volume}}</td><td>{{.Trades}}</td></tr>
+</table>
+{{if .BiggestTrades}}<p>Biggest trades:</p>
+<ul>{{range .BiggestTrades}}<li>{{price .Qty}} at {{price .Price}} ({{clock .Time}} UTC)</li>{{end}}</ul>{{end}}
+<p>Alerts fired: {{.Alerts}}</p>
+{{if .AlertMessages}}<ul>{{range .AlertMessages}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{else}}
+<p>No trades.</p>
+{{end}}
+</body></html>
+`))
+
+// renderReport renders a report as markdown or html
+func renderReport(report DailyReport, format string) (string, error) {
+	var b strings.Builder
+	var err error
+	if format == reportHTML {
+		err = htmlReport.Execute(&b, report)
+	} else {
+		err = markdownReport.Execute(&b, report)
+	}
+	return b.String(), err
+}
+
+// reportExtension is the file extension of a report format
+func reportExtension(format string) string {
+	if format == reportHTML {
+		return ".html"
+	}
+	return ".md"
+}
+
+// deliverReport writes a finished day's report to the reports directory
+// and posts it to the report webhook, whichever are configured. Only one
+// instance of a cluster delivers, the one storing trades.
+func (s *Server) deliverReport(report *DailyReport) {
+	if report == nil || (s.cfg.ReportDir == "" && s.cfg.ReportWebhook == "") {
+		return
+	}
+	if s.cluster != nil && !s.cluster.feeder.Load() {
+		return
+	}
+	text, err := renderReport(*report, s.cfg.ReportFormat)
+	if err != nil {
+		log.Printf("Daily report error: %v", err)
+		return
+	}
+
+	if s.cfg.ReportDir != "" {
+		path := filepath.Join(s.cfg.ReportDir, report.Date+reportExtension(s.cfg.ReportFormat))
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			log.Printf("Daily report write error: %v", err)
+		} else {
+			log.Printf("Daily report for %s written to %s", report.Date, path)
+		}
+	}
+	if s.cfg.ReportWebhook != "" {
+		// Like alert webhooks, text carries the rendered message for
+		// Slack-style receivers
+		data, _ := json.Marshal(struct {
+			Type   string      `json:"type"`
+			Format string      `json:"format"`
+			Text   string      `json:"text"`
+			Report DailyReport `json:"report"`
+		}{"daily_report", s.cfg.ReportFormat, text, *report})
+		if err := postWebhook(s.cfg.ReportWebhook, data); err != nil {
+			log.Printf("Daily report webhook error: %v", err)
+		} else {
+			log.Printf("Daily report for %s delivered", report.Date)
+		}
+	}
+}
+
+// runReports rolls the report over at midnight UTC when no trade does it
+// first
+func (s *Server) runReports(ctx context.Context) {
+	s.reports.Roll(s.clock.Now())
+	ticker := time.NewTicker(reportCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.deliverReport(s.reports.Roll(s.clock.Now()))
+		}
+	}
+}
+
+// handleDailyReport renders the report of the day so far, as JSON or in a
+// report format (?format=markdown or html)
+func (s *Server) handleDailyReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report := s.reports.Current()
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case reportMarkdown, reportHTML:
+		text, err := renderReport(report, format)
+		if err != nil {
+			http.Error(w, "Failed to render report", http.StatusInternalServerError)
+			return
+		}
+		contentType := "text/markdown; charset=utf-8"
+		if format == reportHTML {
+			contentType = "text/html; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(text))
+	default:
+		http.Error(w, "Unknown format, want json, markdown or html", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// authorizeAdmin checks the request's bearer token against ADMIN_TOKEN.
+// Admin endpoints are disabled while no token is configured.
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		http.Error(w, "Admin endpoints disabled, set ADMIN_TOKEN", http.StatusForbidden)
+		return false
+	}
+	if !tokenMatches(bearerToken(r), s.adminToken) {
+		unauthorized(w)
+		return false
+	}
+	return true
+}
+
+// handleStatsReset starts a new session for one symbol (default the selected
+// one): the processor's moving average, high and low start over
+func (s *Server) handleStatsReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	symbol := strings.ToLower(r.URL.Query().Get("symbol"))
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	body, _ := json.Marshal(map[string]string{"symbol": symbol})
+	reply, err := s.nc.Request("control.reset", body, configTimeout)
+	if err == nats.ErrNoResponders || err == nats.ErrTimeout {
+		http.Error(w, "Processing service not available", http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to reach processing service", http.StatusInternalServerError)
+		return
+	}
+	var result struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(reply.Data, &result) == nil && result.Error != "" {
+		http.Error(w, result.Error, http.StatusBadRequest)
+		return
+	}
+
+	// Show the new session right away instead of after the next trade
+	s.mu.Lock()
+	if s.current.Symbol == symbol {
+		s.current.MovingAverage = s.current.Price
+		s.current.High = s.current.Price
+		s.current.Low = s.current.Price
+		s.current.VWAP = 0
+		s.current.StdDev = 0
+		s.current.Volume, s.current.BuyVolume, s.current.SellVolume = 0, 0, 0
+		s.current.EMA = nil
+		s.current.Samples = 0
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"symbol":   symbol,
+		"reset_at": time.Now().UTC(),
+	})
+}
@@ -0,0 +1,91 @@
+package server
+
+import "encoding/json"
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcUnauthorized   = -32001 // server defined: the method needs the API token
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// handleRPC answers a JSON-RPC request received on a WebSocket connection.
+// Requests without an id are notifications and get no reply.
+func (s *Server) handleRPC(c *Client, data []byte) {
+	var req rpcRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		s.replyRPC(c, nil, nil, &rpcError{rpcParseError, "parse error"})
+		return
+	}
+	if req.Method == "" {
+		s.replyRPC(c, req.ID, nil, &rpcError{rpcInvalidRequest, "missing method"})
+		return
+	}
+
+	var result any
+	var rpcErr *rpcError
+	if req.Method == "change_symbol" && !c.authorized {
+		rpcErr = &rpcError{rpcUnauthorized, "unauthorized, connect with the API token"}
+	} else {
+		result, rpcErr = s.callRPC(req.Method, req.Params)
+	}
+	if req.ID == nil {
+		return
+	}
+	s.replyRPC(c, req.ID, result, rpcErr)
+}
+
+func (s *Server) callRPC(method string, params json.RawMessage) (any, *rpcError) {
+	switch method {
+	case "get_stats":
+		return s.stats(), nil
+
+	case "list_coins":
+		return coinList(), nil
+
+	case "change_symbol":
+		var p struct {
+			Symbol string `json:"symbol"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.Symbol == "" {
+			return nil, &rpcError{rpcInvalidParams, "expected {\"symbol\": string}"}
+		}
+		name, changed, err := s.changeSymbol(p.Symbol)
+		if err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		return map[string]any{"symbol": p.Symbol, "name": name, "changed": changed}, nil
+	}
+
+	return nil, &rpcError{rpcMethodNotFound, "method not found"}
+}
+
+func (s *Server) replyRPC(c *Client, id json.RawMessage, result any, rpcErr *rpcError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr}
+	data, _ := json.Marshal(resp)
+	c.enqueue(data)
+}
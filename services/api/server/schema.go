@@ -0,0 +1,507 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaVersion is bumped whenever a response field is added, changed or
+// removed. Fields record the version they appeared in, so clients can tell
+// what an older server won't send.
+const schemaVersion = 3
+
+// FieldSchema describes one JSON field of a response type
+type FieldSchema struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`             // string, number, integer, boolean, array or object
+	Format      string `json:"format,omitempty"` // date-time for RFC 3339 times, decimal for exact prices
+	Items       string `json:"items,omitempty"`  // element type of an array or map
+	Ref         string `json:"ref,omitempty"`    // type name of a nested object
+	Nullable    bool   `json:"nullable,omitempty"`
+	Optional    bool   `json:"optional,omitempty"` // left out when empty
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description"`
+	Since       int    `json:"since"`
+}
+
+// TypeSchema describes a response type
+type TypeSchema struct {
+	Description string        `json:"description"`
+	Fields      []FieldSchema `json:"fields"`
+}
+
+// fieldDoc is the hand-written part of a field's schema
+type fieldDoc struct {
+	unit  string
+	desc  string
+	since int // 0 for schemaVersion 1
+}
+
+// Units used in field docs
+const (
+	unitQuote   = "quote"   // the market's quote currency, e.g. USDT
+	unitBase    = "base"    // the coin itself, e.g. BTC
+	unitPercent = "percent" // 1.5 means 1.5%
+	unitMs      = "ms"      // unix milliseconds unless the field says otherwise
+)
+
+// schemaTypes lists the documented response types. Field names and types
+// are read from the structs themselves, so they can't drift from what the
+// API sends; schemaDocs adds units and meaning.
+var schemaTypes = []struct {
+	name string
+	desc string
+	typ  reflect.Type
+}{
+	{"Trade", "A stored trade", reflect.TypeFor[Trade]()},
+	{"Stats", "Session statistics of the selected symbol", reflect.TypeFor[Stats]()},
+	{"Snapshot", "Everything a dashboard needs to render the selected symbol", reflect.TypeFor[Snapshot]()},
+	{"TimeframeStats", "Price movement over a rolling timeframe", reflect.TypeFor[TimeframeStats]()},
+	{"WindowStats", "A symbol's stats over one rolling timeframe", reflect.TypeFor[WindowStats]()},
+	{"PercentileBand", "Where the price sits within a trailing range", reflect.TypeFor[PercentileBand]()},
+	{"Summary", "A symbol's last 24 hours, like an exchange's 24h ticker", reflect.TypeFor[Summary]()},
+	{"AnchorDelta", "The current price relative to an anchored one", reflect.TypeFor[AnchorDelta]()},
+	{"Candle", "An OHLC bar", reflect.TypeFor[Candle]()},
+	{"CandlePattern", "A pattern found on a closed candle", reflect.TypeFor[CandlePattern]()},
+	{"Indicators", "Technical indicators over a symbol's closed candles", reflect.TypeFor[Indicators]()},
+	{"RSI", "Relative strength index", reflect.TypeFor[RSI]()},
+	{"MACD", "Moving average convergence/divergence", reflect.TypeFor[MACD]()},
+	{"BollingerBands", "A moving average of closes with bands a multiple of their deviation around it", reflect.TypeFor[BollingerBands]()},
+	{"ATR", "Average true range", reflect.TypeFor[ATR]()},
+	{"CoinInfo", "A coin and the markets it trades in", reflect.TypeFor[CoinInfo]()},
+	{"Market", "A coin traded against one quote currency", reflect.TypeFor[Market]()},
+	{"SymbolInfo", "A pair the exchange trades and how to format its prices", reflect.TypeFor[SymbolInfo]()},
+	{"AlertRule", "An alert rule", reflect.TypeFor[AlertRule]()},
+	{"AlertNotification", "A fired alert", reflect.TypeFor[AlertNotification]()},
+	{"Signal", "A strategy's call to buy or sell", reflect.TypeFor[Signal]()},
+	{"PaperOrder", "A filled paper market order", reflect.TypeFor[PaperOrder]()},
+	{"PaperPosition", "A paper holding valued at the last price", reflect.TypeFor[PaperPosition]()},
+	{"PaperPnL", "Paper profit and loss", reflect.TypeFor[PaperPnL]()},
+	{"IngestionStatus", "Exchange feed health reported by ingestion", reflect.TypeFor[IngestionStatus]()},
+	{"ExchangeStatus", "Ingestion's connection to one exchange", reflect.TypeFor[ExchangeStatus]()},
+	{"OutputStatus", "Trades ingestion copied to an output", reflect.TypeFor[OutputStatus]()},
+	{"ProcessingStatus", "Cost of the processing service's C++ calls", reflect.TypeFor[ProcessingStatus]()},
+	{"CgoCallStatus", "Calls to one C function and their time", reflect.TypeFor[CgoCallStatus]()},
+	{"WriteQueueStatus", "Trades waiting to be written to TimescaleDB", reflect.TypeFor[WriteQueueStatus]()},
+	{"ClusterStatus", "This instance's role when sharing the feed through Redis", reflect.TypeFor[ClusterStatus]()},
+	{"VersionInfo", "The running API's version, build and features", reflect.TypeFor[VersionInfo]()},
+	{"Features", "Optional parts of the pipeline in use", reflect.TypeFor[Features]()},
+	{"Protocols", "Wire protocol versions the API speaks", reflect.TypeFor[Protocols]()},
+	{"DailyReport", "A summary of one UTC day of the live trade stream", reflect.TypeFor[DailyReport]()},
+	{"SymbolReport", "One symbol's day in a daily report", reflect.TypeFor[SymbolReport]()},
+	{"ReportTrade", "A trade listed in a daily report", reflect.TypeFor[ReportTrade]()},
+}
+
+// schemaEndpoints maps each endpoint to the type of its JSON body; [] marks
+// an array
+var schemaEndpoints = map[string]string{
+	"GET /api/snapshot":        "Snapshot",
+	"GET /api/stats":           "Stats",
+	"GET /api/stats?window=":   "WindowStats",
+	"GET /api/history":         "[]Trade",
+	"GET /api/candles":         "[]Candle",
+	"GET /api/patterns":        "[]CandlePattern",
+	"GET /api/indicators":      "Indicators",
+	"GET /api/coins":           "[]CoinInfo",
+	"GET /api/coins?q=":        "[]SymbolInfo",
+	"GET /api/symbol/meta":     "SymbolInfo",
+	"GET /api/summary":         "Summary",
+	"GET /api/alerts":          "[]AlertRule",
+	"POST /api/alerts":         "AlertRule",
+	"GET /api/signals":         "[]Signal",
+	"GET /api/paper/orders":    "[]PaperOrder",
+	"POST /api/paper/orders":   "PaperOrder",
+	"GET /api/paper/positions": "[]PaperPosition",
+	"GET /api/paper/pnl":       "PaperPnL",
+	"WS alerts channel":        "AlertNotification",
+	"GET /api/version":         "VersionInfo",
+	"GET /api/reports/daily":   "DailyReport",
+	"WS signals channel":       "Signal",
+	"WS indicators channel":    "Indicators",
+}
+
+// schemaDocs holds units and descriptions, keyed by "Type.field"
+var schemaDocs = map[string]fieldDoc{
+	"Trade.symbol":    {"", "Market symbol, e.g. btcusdt", 0},
+	"Trade.price":     {unitQuote, "Trade price, rounded to the market's precision", 0},
+	"Trade.qty":       {unitBase, "Trade size; left out when the exchange doesn't report it or the trade was stored before sizes were", 2},
+	"Trade.side":      {"", "Taker side, buy or sell; left out when unknown", 2},
+	"Trade.timestamp": {"", "Exchange time of the trade", 0},
+
+	"Stats.moving_average": {unitQuote, "Moving average over the last window trades", 0},
+	"Stats.high":           {unitQuote, "Session high", 0},
+	"Stats.low":            {unitQuote, "Session low", 0},
+	"Stats.vwap":           {unitQuote, "Volume-weighted average price of the session, 0 until a trade with a size", 2},
+	"Stats.std_dev":        {unitQuote, "Standard deviation of the prices the moving average covers", 2},
+	"Stats.ema":            {unitQuote, "Exponential moving averages keyed by span in trades, e.g. \"12\"; null before the first trade", 2},
+	"Stats.volume":         {unitBase, "Traded this session, from trades with a reported size", 2},
+	"Stats.buy_volume":     {unitBase, "Bought by takers this session", 2},
+	"Stats.sell_volume":    {unitBase, "Sold by takers this session", 2},
+	"Stats.buy_ratio":      {"", "Buy volume over buy plus sell volume, 0 to 1; 0 before any trade with a side", 2},
+	"Stats.trades_per_sec": {"trades/s", "Recent trade rate", 0},
+	"Stats.samples":        {"trades", "Trades the moving average covers", 0},
+	"Stats.window_full":    {"", "Whether the moving average covers a full window; false while warming up", 0},
+
+	"Snapshot.symbol":     {"", "Selected market symbol", 0},
+	"Snapshot.name":       {"", "Display name, e.g. Bitcoin (BTC)", 0},
+	"Snapshot.quote":      {"", "Quote currency of prices", 0},
+	"Snapshot.precision":  {"decimals", "Decimal places prices are rounded to", 0},
+	"Snapshot.price":      {unitQuote, "Last trade price, 0 before the first trade", 0},
+	"Snapshot.anchor":     {"", "Move since the anchored price, null without an anchor", 0},
+	"Snapshot.last_trade": {"", "Time of the last trade, null before the first", 0},
+	"Snapshot.stale":      {"", "No trade for 10s or more", 0},
+	"Snapshot.timeframes": {"", "Movement over 1m, 5m, 1h and 24h, keyed by timeframe", 0},
+	"Snapshot.bands":      {"", "The price within the 24h and 7d ranges, keyed by range; a range is missing until it has candles", 2},
+
+	"TimeframeStats.open":           {unitQuote, "First price in the timeframe", 0},
+	"TimeframeStats.close":          {unitQuote, "Last price in the timeframe", 0},
+	"TimeframeStats.change":         {unitQuote, "Close minus open", 0},
+	"TimeframeStats.change_percent": {unitPercent, "Change relative to open", 0},
+	"TimeframeStats.high":           {unitQuote, "Highest price in the timeframe", 0},
+	"TimeframeStats.low":            {unitQuote, "Lowest price in the timeframe", 0},
+	"TimeframeStats.average":        {unitQuote, "Mean trade price in the timeframe", 2},
+	"TimeframeStats.trades":         {"trades", "Trades in the timeframe", 0},
+	"TimeframeStats.volume":         {unitBase, "Traded in the timeframe, from trades with a reported size", 3},
+	"TimeframeStats.buy_volume":     {unitBase, "Bought by takers in the timeframe", 3},
+	"TimeframeStats.sell_volume":    {unitBase, "Sold by takers in the timeframe", 3},
+
+	"WindowStats.symbol": {"", "Market symbol", 2},
+	"WindowStats.window": {"", "Timeframe: 1m, 5m, 1h or 24h", 2},
+
+	"PercentileBand.percentile": {unitPercent, "Share of the range's candle closes below the price, equal closes counting half", 2},
+	"PercentileBand.low":        {unitQuote, "Lowest price in the range", 2},
+	"PercentileBand.high":       {unitQuote, "Highest price in the range", 2},
+	"PercentileBand.candles":    {"", "Candles ranked against: 5m candles for 24h, 1h candles for 7d", 2},
+	"PercentileBand.complete":   {"", "Whether the candles reach back to the start of the range; after a restart only warmed and live candles count", 2},
+
+	"Summary.symbol":           {"", "Market symbol", 2},
+	"Summary.open":             {unitQuote, "Open of the oldest candle in the last 24h", 2},
+	"Summary.high":             {unitQuote, "Highest price in the last 24h", 2},
+	"Summary.low":              {unitQuote, "Lowest price in the last 24h", 2},
+	"Summary.close":            {unitQuote, "Latest price", 2},
+	"Summary.change":           {unitQuote, "Close minus open", 2},
+	"Summary.change_percent":   {unitPercent, "Change relative to open", 2},
+	"Summary.volume":           {unitBase, "Traded in the last 24h, from trades with a reported size", 2},
+	"Summary.weighted_average": {unitQuote, "Volume-weighted average of the candles' typical price, (high+low+close)/3; the mean close without volume", 2},
+	"Summary.trades":           {"trades", "Trades in the last 24h", 2},
+	"Summary.interval":         {"", "Candles summarized: 5m from memory, or 1m from the store's aggregates when memory doesn't reach back a day", 2},
+	"Summary.from":             {"", "Start of the oldest candle covered", 2},
+	"Summary.complete":         {"", "Whether the candles reach back a full 24h, from memory or the store", 2},
+
+	"AnchorDelta.price":          {unitQuote, "Anchored price", 0},
+	"AnchorDelta.set_at":         {"", "When the anchor was set", 0},
+	"AnchorDelta.symbol":         {"", "Market symbol", 0},
+	"AnchorDelta.current":        {unitQuote, "Current price", 0},
+	"AnchorDelta.change":         {unitQuote, "Current minus anchored price", 0},
+	"AnchorDelta.change_percent": {unitPercent, "Change relative to the anchored price", 0},
+
+	"Candle.time":        {"", "Start of the candle", 0},
+	"Candle.open":        {unitQuote, "First price", 0},
+	"Candle.high":        {unitQuote, "Highest price", 0},
+	"Candle.low":         {unitQuote, "Lowest price", 0},
+	"Candle.close":       {unitQuote, "Last price", 0},
+	"Candle.trades":      {"trades", "Trades in the candle", 0},
+	"Candle.volume":      {unitBase, "Traded in the candle, from trades with a reported size", 2},
+	"Candle.buy_volume":  {unitBase, "Bought by takers", 2},
+	"Candle.sell_volume": {unitBase, "Sold by takers", 2},
+
+	"CandlePattern.time":    {"", "Start of the candle that formed the pattern", 0},
+	"CandlePattern.pattern": {"", "doji, hammer, bullish_engulfing or bearish_engulfing", 0},
+	"CandlePattern.signal":  {"", "bullish, bearish or neutral", 0},
+	"CandlePattern.candle":  {"", "The candle", 0},
+
+	"Indicators.symbol":    {"", "Market symbol", 2},
+	"Indicators.interval":  {"", "Candle interval the indicators are computed over", 2},
+	"Indicators.time":      {"", "Start of the last closed candle, zero before the first", 2},
+	"Indicators.candles":   {"candles", "Closed candles the indicators cover", 2},
+	"Indicators.rsi":       {"", "RSI, missing until 15 candles have closed", 2},
+	"Indicators.macd":      {"", "MACD, missing until 35 candles have closed", 2},
+	"Indicators.bollinger": {"", "Bollinger Bands, missing until 20 candles have closed", 2},
+	"Indicators.atr":       {"", "ATR, missing until 15 candles have closed", 2},
+
+	"RSI.period": {"candles", "Smoothing period", 2},
+	"RSI.value":  {"", "0 to 100; above 70 is commonly read as overbought, below 30 as oversold", 2},
+
+	"MACD.fast":          {"candles", "Span of the fast EMA", 2},
+	"MACD.slow":          {"candles", "Span of the slow EMA", 2},
+	"MACD.signal_period": {"candles", "Span of the signal line's EMA", 2},
+	"MACD.macd":          {unitQuote, "Fast EMA minus slow EMA of closes", 2},
+	"MACD.signal":        {unitQuote, "EMA of the MACD line", 2},
+	"MACD.histogram":     {unitQuote, "MACD minus signal", 2},
+
+	"BollingerBands.period":    {"candles", "Closes the middle band averages", 2},
+	"BollingerBands.k":         {"", "Standard deviations between the middle band and the others", 2},
+	"BollingerBands.middle":    {unitQuote, "Simple moving average of closes", 2},
+	"BollingerBands.upper":     {unitQuote, "Middle plus k standard deviations", 2},
+	"BollingerBands.lower":     {unitQuote, "Middle minus k standard deviations", 2},
+	"BollingerBands.bandwidth": {unitPercent, "Upper minus lower, relative to middle", 2},
+
+	"ATR.period": {"candles", "Smoothing period", 2},
+	"ATR.value":  {unitQuote, "Average true range: each candle's high to low, extended to the previous close", 2},
+
+	"CoinInfo.symbol":    {"", "Symbol of the default market", 0},
+	"CoinInfo.name":      {"", "Display name of the default market", 0},
+	"CoinInfo.sparkline": {unitQuote, "Default market closes over 30 minutes, oldest first, with ?with_sparkline=true", 0},
+	"CoinInfo.markets":   {"", "Every market of the coin, default first", 0},
+
+	"Market.symbol":    {"", "Market symbol, e.g. btceur", 0},
+	"Market.quote":     {"", "Quote currency", 0},
+	"Market.name":      {"", "Display name", 0},
+	"Market.precision": {"decimals", "Decimal places prices are rounded to", 0},
+	"Market.sparkline": {unitQuote, "Closes over 30 minutes, oldest first, with ?with_sparkline=true", 0},
+
+	"SymbolInfo.symbol":      {"", "Market symbol, selectable through /api/symbol, e.g. pepeusdt", 2},
+	"SymbolInfo.name":        {"", "Display name, e.g. PEPE/USDT outside the coin list", 2},
+	"SymbolInfo.base_asset":  {"", "Asset traded, e.g. PEPE", 2},
+	"SymbolInfo.quote_asset": {"", "Asset prices are in, e.g. USDT", 2},
+	"SymbolInfo.tick_size":   {unitQuote, "Smallest price step on the exchange", 2},
+	"SymbolInfo.precision":   {"decimals", "Decimal places prices are rounded to, from the tick size", 2},
+
+	"AlertRule.id":                 {"", "Rule ID", 0},
+	"AlertRule.symbol":             {"", "Market symbol", 0},
+	"AlertRule.condition":          {"", "above, below, change, pattern, band, atr or expr", 0},
+	"AlertRule.price":              {unitQuote, "Threshold of above and below rules", 0},
+	"AlertRule.percent":            {unitPercent, "Move that fires a change rule", 0},
+	"AlertRule.window":             {"duration", "Window of a change rule, e.g. 5m", 0},
+	"AlertRule.pattern":            {"", "Candle pattern of a pattern rule", 0},
+	"AlertRule.interval":           {"", "Candle interval of a pattern, band, atr or expr rule", 0},
+	"AlertRule.band":               {"", "Bollinger Band a band rule fires on closes beyond: upper, lower or outside for either", 2},
+	"AlertRule.multiple":           {"", "True range, in ATRs, at which an atr rule fires", 2},
+	"AlertRule.expr":               {"", "Condition of an expr rule, e.g. price > 70000 and volume(5m) > 12 or rsi < 25", 2},
+	"AlertRule.hysteresis_percent": {unitPercent, "Move back needed before the rule re-arms", 0},
+	"AlertRule.webhook":            {"", "URL notifications are POSTed to", 0},
+	"AlertRule.template":           {"", "Go template for every notifier's message", 0},
+	"AlertRule.templates":          {"", "Go templates per notifier (ws, webhook, telegram)", 0},
+	"AlertRule.created_at":         {"", "When the rule was created", 0},
+	"AlertRule.armed":              {"", "Whether the rule can fire", 0},
+	"AlertRule.last_fired":         {"", "When the rule last fired", 0},
+
+	"AlertNotification.channel": {"", "Always alerts", 0},
+	"AlertNotification.type":    {"", "alert, or alert_test for test notifications", 0},
+	"AlertNotification.symbol":  {"", "Market symbol", 0},
+	"AlertNotification.price":   {unitQuote, "Price that fired the rule", 0},
+	"AlertNotification.message": {"", "Rendered message", 0},
+	"AlertNotification.text":    {"", "The message again for Slack-style webhooks", 0},
+	"AlertNotification.time":    {"", "When the rule fired", 0},
+	"AlertNotification.rule":    {"", "The rule that fired", 0},
+
+	"Signal.time":     {"", "When the signal was emitted", 0},
+	"Signal.strategy": {"", "Strategy name", 0},
+	"Signal.symbol":   {"", "Market symbol", 0},
+	"Signal.side":     {"", "buy or sell", 0},
+	"Signal.price":    {unitQuote, "Price when the signal was emitted", 0},
+	"Signal.reason":   {"", "Why the strategy signalled", 0},
+
+	"PaperOrder.id":       {"", "Order ID", 0},
+	"PaperOrder.symbol":   {"", "Market symbol", 0},
+	"PaperOrder.side":     {"", "buy or sell", 0},
+	"PaperOrder.quantity": {unitBase, "Quantity filled", 0},
+	"PaperOrder.price":    {unitQuote, "Fill price", 0},
+	"PaperOrder.time":     {"", "When the order filled", 0},
+
+	"PaperPosition.symbol":         {"", "Market symbol", 0},
+	"PaperPosition.quote":          {"", "Quote currency of the values", 0},
+	"PaperPosition.quantity":       {unitBase, "Quantity held", 0},
+	"PaperPosition.avg_price":      {unitQuote, "Average entry price", 0},
+	"PaperPosition.price":          {unitQuote, "Last price, or the entry price without one", 0},
+	"PaperPosition.market_value":   {unitQuote, "Quantity times price", 0},
+	"PaperPosition.unrealized_pnl": {unitQuote, "Open profit at the last price", 0},
+	"PaperPosition.realized_pnl":   {unitQuote, "Profit taken by sells", 0},
+
+	"PaperPnL.realized":   {unitQuote, "Realized PnL summed across symbols", 0},
+	"PaperPnL.unrealized": {unitQuote, "Unrealized PnL summed across symbols", 0},
+	"PaperPnL.total":      {unitQuote, "Realized plus unrealized", 0},
+	"PaperPnL.symbols":    {"", "Positions keyed by symbol, including closed ones", 0},
+
+	"IngestionStatus.exchange":           {"", "Exchanges streamed from, comma separated when pairs are routed to several", 0},
+	"IngestionStatus.connected":          {"", "Whether every exchange with pairs to stream is connected", 0},
+	"IngestionStatus.connects":           {"", "Successful connections since start", 0},
+	"IngestionStatus.stalls":             {"", "Connections dropped for sending nothing", 0},
+	"IngestionStatus.trades":             {"trades", "Trades published since start", 0},
+	"IngestionStatus.rejected":           {"", "Exchange messages and trades dropped since start because they didn't parse", 2},
+	"IngestionStatus.last_trade":         {unitMs, "Exchange time of the last trade", 0},
+	"IngestionStatus.reconnects":         {"", "Reconnect attempts since start", 0},
+	"IngestionStatus.reconnect_delay_ms": {"ms", "Last wait before reconnecting (a duration)", 0},
+	"IngestionStatus.paused":             {"", "Symbols muted through POST /api/symbols/{symbol}/pause", 2},
+	"IngestionStatus.outputs":            {"", "Kafka, NATS and UDP outputs keyed by name, only when configured", 2},
+	"IngestionStatus.exchanges":          {"", "Connection state and counters keyed by exchange, e.g. binance", 2},
+	"IngestionStatus.started_at":         {unitMs, "When ingestion started", 2},
+	"IngestionStatus.reported_at":        {unitMs, "When ingestion sent the report", 0},
+
+	"ExchangeStatus.connected":    {"", "Whether a connection is open", 2},
+	"ExchangeStatus.connected_at": {unitMs, "When the current connection opened, 0 while down", 2},
+	"ExchangeStatus.symbols":      {"", "Symbols routed to the exchange", 2},
+	"ExchangeStatus.connects":     {"", "Successful connections since start", 2},
+	"ExchangeStatus.reconnects":   {"", "Reconnect attempts since start", 2},
+	"ExchangeStatus.stalls":       {"", "Connections dropped for sending nothing", 2},
+	"ExchangeStatus.trades":       {"trades", "Trades published since start", 2},
+	"ExchangeStatus.last_trade":   {unitMs, "Exchange time of the last trade, 0 before the first", 2},
+
+	"OutputStatus.published": {"trades", "Trades written to Kafka, handed to the NATS connection or sent as UDP datagrams", 2},
+	"OutputStatus.failed":    {"trades", "Trades lost to write errors", 2},
+
+	"ProcessingStatus.trades":       {"trades", "Trades processed since start", 2},
+	"ProcessingStatus.sampled":      {"trades", "Trades that skipped the C++ processor and reused its last stats", 2},
+	"ProcessingStatus.max_cgo_rate": {"trades/s", "Trades per second and symbol passed to the C++ processor, 0 for no limit", 2},
+	"ProcessingStatus.cgo":          {"", "Cost of each C function, keyed by its name", 2},
+	"ProcessingStatus.reported_at":  {unitMs, "When processing sent the report", 2},
+
+	"CgoCallStatus.calls":    {"", "Calls since processing started", 2},
+	"CgoCallStatus.total_ms": {"ms", "Time spent in the calls, including the cgo transition (a duration)", 2},
+	"CgoCallStatus.avg_ns":   {"ns", "Average time per call (a duration)", 2},
+
+	"WriteQueueStatus.queued":        {"trades", "Trades waiting for the next batch", 2},
+	"WriteQueueStatus.max_queued":    {"trades", "Queue size past which trades are dropped", 2},
+	"WriteQueueStatus.written":       {"trades", "Trades written since start", 2},
+	"WriteQueueStatus.batches":       {"", "Batches written since start", 2},
+	"WriteQueueStatus.failures":      {"", "Batches that failed and stayed queued to retry", 2},
+	"WriteQueueStatus.dropped":       {"trades", "Trades refused while the queue was full", 2},
+	"WriteQueueStatus.last_flush_ms": {"ms", "Time the last batch took (a duration)", 2},
+
+	"ClusterStatus.instance": {"", "Host name and a suffix unique to this process", 2},
+	"ClusterStatus.feeder":   {"", "Whether this instance holds the feeder lease: it reads trades from NATS, fans them out through Redis and stores them", 2},
+
+	"VersionInfo.version":    {"", "Semantic version of the release, 0.0.0-dev for local builds", 2},
+	"VersionInfo.commit":     {"", "Git commit the binary was built from, empty when unknown", 2},
+	"VersionInfo.build_date": {"", "When the binary was built, RFC 3339; the commit time for unstamped builds, empty when unknown", 2},
+	"VersionInfo.go_version": {"", "Go toolchain, e.g. go1.23.4", 2},
+	"VersionInfo.features":   {"", "Optional parts of the pipeline in use", 2},
+	"VersionInfo.protocols":  {"", "Wire protocol versions", 2},
+
+	"Features.database":  {"", "timescaledb, sqlite or bolt; empty without history", 2},
+	"Features.processor": {"", "cgo once the processing service has reported, empty before", 2},
+	"Features.exchanges": {"", "Exchanges ingestion streams from, empty until it has reported", 2},
+	"Features.redis":     {"", "Whether this instance shares its feed and state through Redis", 2},
+
+	"Protocols.websocket": {"", "Values /ws?v= accepts", 2},
+	"Protocols.schema":    {"", "Version of /api/schema", 2},
+	"Protocols.jsonrpc":   {"", "JSON-RPC version of WebSocket method calls", 2},
+
+	"DailyReport.date":    {"", "UTC day, 2006-01-02", 2},
+	"DailyReport.symbols": {"", "Symbols that traded that day, sorted", 2},
+
+	"SymbolReport.symbol":         {"", "Market symbol", 2},
+	"SymbolReport.open":           {unitQuote, "First trade price of the day", 2},
+	"SymbolReport.high":           {unitQuote, "Highest trade price", 2},
+	"SymbolReport.low":            {unitQuote, "Lowest trade price", 2},
+	"SymbolReport.close":          {unitQuote, "Last trade price so far", 2},
+	"SymbolReport.volume":         {unitBase, "Traded size, from exchanges that report it", 2},
+	"SymbolReport.trades":         {"trades", "Trades streamed, backfill excluded", 2},
+	"SymbolReport.range":          {unitQuote, "High minus low", 2},
+	"SymbolReport.range_percent":  {unitPercent, "Range relative to low", 2},
+	"SymbolReport.change_percent": {unitPercent, "Close relative to open", 2},
+	"SymbolReport.biggest_trades": {"", "Largest trades by notional value, biggest first", 2},
+	"SymbolReport.alerts":         {"alerts", "Alerts fired for the symbol", 2},
+	"SymbolReport.alert_messages": {"", "Messages of the first alerts fired", 2},
+
+	"ReportTrade.price": {unitQuote, "Trade price", 2},
+	"ReportTrade.qty":   {unitBase, "Trade size", 2},
+	"ReportTrade.time":  {"", "Trade time", 2},
+}
+
+// describeType lists a struct's JSON fields, flattening embedded structs
+// the way encoding/json does. Docs are looked up under name, then under the
+// struct's own type name, so embedded fields keep their docs.
+func describeType(name string, t reflect.Type) []FieldSchema {
+	var fields []FieldSchema
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		jsonName, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && jsonName == "" && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, describeType(name, f.Type)...)
+			continue
+		}
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+
+		field := FieldSchema{Name: jsonName, Optional: strings.Contains(opts, "omitempty"), Since: 1}
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			field.Nullable = true
+			ft = ft.Elem()
+		}
+		field.Type, field.Format, field.Items, field.Ref = jsonType(ft)
+		if strings.Contains(opts, "string") {
+			field.Type = "string"
+		}
+		doc, ok := schemaDocs[name+"."+jsonName]
+		if !ok {
+			doc, ok = schemaDocs[t.Name()+"."+jsonName]
+		}
+		if ok {
+			field.Unit = doc.unit
+			field.Description = doc.desc
+			if doc.since > 0 {
+				field.Since = doc.since
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// jsonType names the JSON type a Go type encodes to, with the element or
+// referenced type for arrays, maps and structs
+func jsonType(t reflect.Type) (typ, format, items, ref string) {
+	if t == reflect.TypeFor[time.Time]() {
+		return "string", "date-time", "", ""
+	}
+	if t == decimalType {
+		return "number", "decimal", "", ""
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string", "", "", ""
+	case reflect.Bool:
+		return "boolean", "", "", ""
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer", "", "", ""
+	case reflect.Float32, reflect.Float64:
+		return "number", "", "", ""
+	case reflect.Slice, reflect.Array, reflect.Map:
+		elem, _, _, elemRef := jsonType(t.Elem())
+		if elemRef != "" {
+			elem = elemRef
+		}
+		typ = "array"
+		if t.Kind() == reflect.Map {
+			typ = "object"
+		}
+		return typ, "", elem, ""
+	case reflect.Struct:
+		return "object", "", "", t.Name()
+	}
+	return "object", "", "", ""
+}
+
+// handleSchema describes every documented response type and which endpoint
+// returns it, for client code generation
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	types := make(map[string]TypeSchema, len(schemaTypes))
+	for _, st := range schemaTypes {
+		types[st.name] = TypeSchema{Description: st.desc, Fields: describeType(st.name, st.typ)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"version":   schemaVersion,
+		"types":     types,
+		"endpoints": schemaEndpoints,
+	})
+}
@@ -0,0 +1,1173 @@
+// Package server is the API service: it follows processed trades on NATS,
+// stores them and serves prices, history and signals over HTTP, WebSocket
+// and SSE. The api binary is a thin wrapper around it, and other programs
+// can embed the same server:
+//
+//	srv, err := server.New(server.Config{BoltPath: "trades.db"})
+//	...
+//	err = srv.Run(ctx)
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats.go"
+	"golang.org/x/crypto/acme/autocert"
+	"google.golang.org/grpc"
+
+	"api/store"
+)
+
+// ProcessedMessage from processing service
+type ProcessedMessage struct {
+	Symbol        string             `json:"symbol"`
+	Price         float64            `json:"price"`
+	PriceE8       int64              `json:"price_e8,omitempty"` // Price exactly, as a Decimal; 0 from older services
+	MovingAverage float64            `json:"moving_average"`
+	High          float64            `json:"high"`
+	Low           float64            `json:"low"`
+	VWAP          float64            `json:"vwap"`
+	StdDev        float64            `json:"std_dev"`
+	EMA           map[string]float64 `json:"ema,omitempty"`  // by span
+	Qty           float64            `json:"qty,omitempty"`  // trade size, when the exchange reports it
+	Side          string             `json:"side,omitempty"` // taker side, buy or sell, when reported
+	Volume        float64            `json:"volume"`         // base asset this session
+	BuyVolume     float64            `json:"buy_volume"`
+	SellVolume    float64            `json:"sell_volume"`
+	Samples       int                `json:"samples"`
+	Window        int                `json:"window"`
+	Time          int64              `json:"time"`
+	Backfill      bool               `json:"backfill,omitempty"` // seeded from exchange history
+}
+
+// Stats for the stats endpoint. Samples is how many trades the moving
+// average covers; it's below the window size while warming up.
+type Stats struct {
+	MovingAverage float64            `json:"moving_average"`
+	High          float64            `json:"high"`
+	Low           float64            `json:"low"`
+	VWAP          float64            `json:"vwap"`
+	StdDev        float64            `json:"std_dev"`
+	EMA           map[string]float64 `json:"ema"`
+	Volume        float64            `json:"volume"`
+	BuyVolume     float64            `json:"buy_volume"`
+	SellVolume    float64            `json:"sell_volume"`
+	BuyRatio      float64            `json:"buy_ratio"` // share of sided volume bought by takers, 0 to 1
+	TradesPerSec  float64            `json:"trades_per_sec"`
+	Samples       int                `json:"samples"`
+	WindowFull    bool               `json:"window_full"`
+}
+
+// Server holds application state
+type Server struct {
+	cfg Config
+
+	mu       sync.RWMutex
+	current  ProcessedMessage
+	symbol   string
+	coinName string
+	anchors  map[string]Anchor
+
+	// Serializes symbol changes end to end
+	symbolChangeMu sync.Mutex
+
+	hub *Hub
+	// Live trades for gRPC streams
+	trades *tradeStreams
+	// Announces changes to the symbols ingestion streams
+	subsNotifier *subscriptionNotifier
+
+	// Admission control for streaming clients and plain HTTP requests
+	clientLimit  *Limiter
+	requestLimit *Limiter
+	// The same per client address
+	addrClients *AddrLimiter
+	rateLimiter *RateLimiter
+
+	// Bearer token for admin endpoints, empty disables them
+	adminToken string
+	// Origins of the pages allowed to call the API from a browser
+	origins []string
+	// The configured certificate, or Let's Encrypt's; both nil for plain HTTP
+	tlsConfig *tls.Config
+	acme      *autocert.Manager
+
+	alerts     *AlertEngine
+	strategies *StrategyEngine
+	paper      *Portfolio
+	frames     *FrameTracker
+	candles    *CandleAggregator
+	rates      *RateTracker
+	sparks     *SparklineTracker
+	reports    *ReportTracker
+	clock      Clock
+	// Keeps backfills from counting trades twice in frames and sparklines
+	tracked *TradeWatermark
+
+	// Latest feed health from ingestion, nil until the first report
+	ingestion atomic.Pointer[IngestionStatus]
+	// Latest cgo metrics from processing, nil until the first report
+	processing atomic.Pointer[ProcessingStatus]
+
+	store  Store
+	stored *StoreWatermark
+	writes sync.WaitGroup // in-flight signal inserts and warm-ups
+	warmed sync.Map       // symbols whose candles were warmed from the store
+	nc     *nats.Conn
+
+	// Time of each symbol's latest live trade, and when Run started, for
+	// /api/status
+	lastTrades sync.Map
+	started    time.Time
+
+	// Latest meta message per session name, for clients that join later
+	sessions sync.Map
+
+	// Shared state with the other instances, nil when running alone
+	cluster *cluster
+}
+
+// Config configures a Server. Empty NATSURL, Symbol, Addr and BoltRetention
+// fall back to the api binary's defaults.
+type Config struct {
+	NATSURL       string        // default nats://localhost:4222
+	DatabaseURL   string        // TimescaleDB, or sqlite://FILE; used when BoltPath is empty
+	BoltPath      string        // embedded bolt store; with neither, history is disabled
+	BoltRetention time.Duration // how long bolt keeps trades, default 7 days
+	Retention     time.Duration // how long TimescaleDB keeps trades, 0 for ever
+	CompressAfter time.Duration // age at which TimescaleDB compresses trades, 0 never
+	Symbol        string        // tracked until a client selects another, default btcusdt
+	Addr          string        // HTTP listen address, default :8080
+	TLSCert       string        // PEM certificate file; with TLSKey serves HTTPS and WSS
+	TLSKey        string        // PEM private key file of TLSCert
+	ACMEDomains   string        // comma separated domains to serve HTTPS for with Let's Encrypt certificates, instead of TLSCert
+	ACMECacheDir  string        // where Let's Encrypt certificates are kept, default autocert
+	GRPCAddr      string        // gRPC listen address, empty to not serve gRPC
+	MaxClients    int           // WebSocket and SSE clients, 0 for no limit
+	MaxRequests   int           // concurrent HTTP requests, 0 for no limit
+	ClientsPerIP  int           // WebSocket and SSE clients per address, 0 for no limit
+	RateLimit     float64       // requests per second per address, 0 for no limit
+	RateBurst     int           // requests an address may send at once before RateLimit applies
+	TrustProxy    bool          // take client addresses from X-Forwarded-For, behind a reverse proxy
+	CORSOrigins   string        // comma separated origins allowed to call the API from a browser, "*" for any, empty for its own only
+	AdminToken    string        // bearer token for admin endpoints, empty disables them
+	APIToken      string        // bearer token for endpoints that change state, empty leaves them open
+	WSAuth        bool          // require APIToken on WebSocket and SSE connections too
+	WSCompression bool          // compress WebSocket messages for clients that offer permessage-deflate
+	StreamRate    float64       // price, stats and candle updates per second per subscription clients start with, 0 for every trade
+	Strategies    string        // comma separated strategies to run, e.g. ema_cross
+	WarmCandles   time.Duration // stored history a symbol's candles start with, 0 to disable
+	RedisURL      string        // shares the feed and state with other instances, empty to run alone
+	NamesFile     string        // JSON coin and market name overrides, see NameOverrides
+	ReportDir     string        // directory daily reports are written to, empty to not write them
+	ReportWebhook string        // URL daily reports are posted to, empty to not post them
+	ReportFormat  string        // daily report format, markdown (default) or html
+	TelegramToken string        // bot token alerts are sent to Telegram with, empty to not send them
+	TelegramChat  string        // chat ID the Telegram bot sends alerts to
+	PriceFormat   string        // JSON price format, number (default) or string
+	BinanceAPIURL string        // exchangeInfo source making every Binance pair selectable, empty for the coin list only
+}
+
+// New validates cfg and returns a Server ready to Run
+func New(cfg Config) (*Server, error) {
+	if cfg.NATSURL == "" {
+		cfg.NATSURL = "nats://localhost:4222"
+	}
+	if cfg.Symbol == "" {
+		cfg.Symbol = "btcusdt"
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":8080"
+	}
+	if cfg.BoltRetention == 0 {
+		cfg.BoltRetention = 7 * 24 * time.Hour
+	}
+	if cfg.NamesFile != "" {
+		overrides, err := LoadNameOverrides(cfg.NamesFile)
+		if err != nil {
+			return nil, fmt.Errorf("name overrides: %w", err)
+		}
+		names = overrides
+	}
+	market, ok := lookupMarket(strings.ToLower(cfg.Symbol))
+	if !ok && cfg.BinanceAPIURL != "" {
+		// A pair outside the coin list needs exchangeInfo before it starts
+		if err := loadExchangeInfo(context.Background(), cfg.BinanceAPIURL); err != nil {
+			return nil, fmt.Errorf("symbol %q: %w", cfg.Symbol, err)
+		}
+		market, ok = lookupMarket(strings.ToLower(cfg.Symbol))
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown symbol %q, see /api/coins for the supported markets", cfg.Symbol)
+	}
+	switch cfg.ReportFormat {
+	case "":
+		cfg.ReportFormat = reportMarkdown
+	case reportMarkdown, reportHTML:
+	default:
+		return nil, fmt.Errorf("unknown report format %q, want markdown or html", cfg.ReportFormat)
+	}
+	if (cfg.TelegramToken == "") != (cfg.TelegramChat == "") {
+		return nil, errors.New("alerts to Telegram need both a bot token and a chat ID")
+	}
+	switch cfg.PriceFormat {
+	case "":
+		cfg.PriceFormat = priceNumber
+	case priceNumber, priceString:
+	default:
+		return nil, fmt.Errorf("unknown price format %q, want number or string", cfg.PriceFormat)
+	}
+	if !validRate(cfg.StreamRate) {
+		return nil, fmt.Errorf("stream rate %v out of range, want 0 or %v to %v", cfg.StreamRate, minClientRate, maxClientRate)
+	}
+	strategies, err := NewStrategyEngine(cfg.Strategies)
+	if err != nil {
+		return nil, err
+	}
+	origins, err := parseOrigins(cfg.CORSOrigins)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := checkTLS(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	hub := NewHub()
+	return &Server{
+		cfg:          cfg,
+		symbol:       market.Symbol,
+		coinName:     market.Name,
+		anchors:      make(map[string]Anchor),
+		hub:          hub,
+		subsNotifier: &subscriptionNotifier{hub: hub},
+		clientLimit:  NewLimiter(cfg.MaxClients),
+		requestLimit: NewLimiter(cfg.MaxRequests),
+		addrClients:  NewAddrLimiter(cfg.ClientsPerIP),
+		rateLimiter:  NewRateLimiter(cfg.RateLimit, cfg.RateBurst),
+		adminToken:   cfg.AdminToken,
+		origins:      origins,
+		tlsConfig:    tlsConfig,
+		acme:         newACMEManager(cfg),
+		trades:       newTradeStreams(),
+		alerts:       NewAlertEngine(),
+		strategies:   strategies,
+		frames:       NewFrameTracker(),
+		candles:      NewCandleAggregator(),
+		rates:        NewRateTracker(),
+		sparks:       NewSparklineTracker(),
+		reports:      NewReportTracker(),
+		stored:       NewStoreWatermark(),
+		tracked:      NewTradeWatermark(),
+	}, nil
+}
+
+// Run connects to NATS and the store, serves HTTP until ctx is done, then
+// shuts down gracefully, flushing pending store writes. A Server runs once.
+func (s *Server) Run(ctx context.Context) error {
+	if names := s.strategies.Names(); len(names) > 0 {
+		log.Printf("Running strategies: %s", strings.Join(names, ", "))
+	}
+	log.Printf("API service starting, tracking %s...", s.coinName)
+	s.started = time.Now()
+
+	// Connect to NATS
+	var nc *nats.Conn
+	var err error
+	closed := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		nc, err = nats.Connect(s.cfg.NATSURL, nats.ClosedHandler(func(*nats.Conn) { close(closed) }))
+		if err == nil {
+			break
+		}
+		log.Printf("NATS connection failed, retrying in 2s... (%v)", err)
+		time.Sleep(2 * time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	log.Println("Connected to NATS")
+	s.nc = nc
+
+	// Use the embedded store when a bolt file is configured, then SQLite for
+	// a sqlite:// URL, TimescaleDB otherwise
+	var db Store
+	sqlitePath, isSQLite := store.SQLitePath(s.cfg.DatabaseURL)
+	if s.cfg.BoltPath != "" {
+		db, err = store.NewBoltStore(s.cfg.BoltPath, s.cfg.BoltRetention)
+		if err != nil {
+			nc.Close()
+			return fmt.Errorf("failed to open bolt store: %w", err)
+		}
+		log.Printf("Using bolt store at %s (retention %s)", s.cfg.BoltPath, s.cfg.BoltRetention)
+	} else if isSQLite {
+		db, err = store.NewSQLiteStore(context.Background(), sqlitePath)
+		if err != nil {
+			nc.Close()
+			return fmt.Errorf("failed to open SQLite store: %w", err)
+		}
+		log.Printf("Using SQLite store at %s", sqlitePath)
+	} else if s.cfg.DatabaseURL != "" {
+		for i := 0; i < 10; i++ {
+			db, err = store.NewPostgresStore(context.Background(), s.cfg.DatabaseURL)
+			if err == nil {
+				break
+			}
+			log.Printf("DB connection failed, retrying in 2s... (%v)", err)
+			time.Sleep(2 * time.Second)
+		}
+		if err != nil {
+			log.Printf("Warning: Database not available: %v", err)
+			db = nil
+		} else {
+			log.Println("Connected to TimescaleDB")
+			if err := db.(*store.PostgresStore).SetPolicies(context.Background(), s.cfg.Retention, s.cfg.CompressAfter); err != nil {
+				log.Printf("Warning: %v", err)
+			} else {
+				log.Printf("Trade retention %s, compression after %s (0 disables)", s.cfg.Retention, s.cfg.CompressAfter)
+			}
+		}
+	}
+	s.store = db
+
+	// Paper orders are replayed from the store to restore the portfolio
+	s.paper, err = NewPortfolio(context.Background(), db)
+	if err != nil {
+		nc.Close()
+		if db != nil {
+			db.Close()
+		}
+		return fmt.Errorf("failed to load paper orders: %w", err)
+	}
+
+	go s.hub.Run()
+	go s.runReports(ctx)
+	if s.cfg.BinanceAPIURL != "" {
+		go s.refreshExchangeInfo(ctx)
+	}
+
+	// Track exchange clock skew reported by ingestion
+	nc.Subscribe("control.clock", func(msg *nats.Msg) {
+		var clock struct {
+			SkewMs int64 `json:"skew_ms"`
+		}
+		if err := json.Unmarshal(msg.Data, &clock); err != nil {
+			return
+		}
+		s.clock.SetSkew(time.Duration(clock.SkewMs) * time.Millisecond)
+	})
+
+	// Keep the latest feed health report for /api/status
+	nc.Subscribe("status.ingestion", func(msg *nats.Msg) {
+		var status IngestionStatus
+		if err := json.Unmarshal(msg.Data, &status); err != nil {
+			return
+		}
+		s.ingestion.Store(&status)
+	})
+
+	// And the latest processor metrics
+	nc.Subscribe("status.processing", func(msg *nats.Msg) {
+		var status ProcessingStatus
+		if err := json.Unmarshal(msg.Data, &status); err != nil {
+			return
+		}
+		s.processing.Store(&status)
+	})
+
+	// Tell clients when the streamed symbols change
+	s.watchSubscriptions(nc)
+
+	// Subscribe to processed trades, through Redis when sharing the feed
+	// with other instances
+	leaseCtx, stopLease := context.WithCancel(context.Background())
+	defer stopLease()
+	if s.cfg.RedisURL != "" {
+		s.cluster, err = connectCluster(ctx, s.cfg.RedisURL)
+		if err != nil {
+			nc.Close()
+			if db != nil {
+				db.Close()
+			}
+			return err
+		}
+		s.joinCluster(leaseCtx)
+	} else {
+		nc.Subscribe("trades.processed", func(msg *nats.Msg) {
+			var processed ProcessedMessage
+			if err := json.Unmarshal(msg.Data, &processed); err != nil {
+				return
+			}
+			s.onProcessed(processed)
+		})
+	}
+
+	// HTTP routes
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/price", s.handlePrice)
+	mux.HandleFunc("/api/stats", s.handleStats)
+	mux.HandleFunc("/api/stats/reset", s.handleStatsReset)
+	mux.HandleFunc("/api/snapshot", s.handleSnapshot)
+	mux.HandleFunc("/api/history", s.handleHistory)
+	mux.HandleFunc("/api/history/export", s.handleHistoryExport)
+	mux.HandleFunc("/api/candles", s.handleCandles)
+	mux.HandleFunc("GET /api/summary", s.handleSummary)
+	mux.HandleFunc("/api/patterns", s.handlePatterns)
+	mux.HandleFunc("/api/indicators", s.handleIndicators)
+	mux.HandleFunc("/api/reports/daily", s.handleDailyReport)
+	mux.HandleFunc("/api/symbol", s.handleSymbol)
+	mux.HandleFunc("GET /api/symbol/meta", s.handleSymbolMeta)
+	mux.HandleFunc("/api/coins", s.handleCoins)
+	mux.HandleFunc("POST /api/symbols/{symbol}/pause", s.handleSymbolPause)
+	mux.HandleFunc("POST /api/symbols/{symbol}/resume", s.handleSymbolResume)
+	mux.HandleFunc("/api/watchlist", s.handleWatchlist)
+	mux.HandleFunc("POST /api/admin/dump", s.handleAdminDump)
+	mux.HandleFunc("/api/config", s.handleConfig)
+	mux.HandleFunc("/api/anchor", s.handleAnchor)
+	mux.HandleFunc("/api/alerts", s.handleAlerts)
+	mux.HandleFunc("POST /api/alerts/{id}/test", s.handleAlertTest)
+	mux.HandleFunc("/api/schema", s.handleSchema)
+	mux.HandleFunc("/api/signals", s.handleSignals)
+	mux.HandleFunc("/api/paper/orders", s.handlePaperOrders)
+	mux.HandleFunc("/api/paper/positions", s.handlePaperPositions)
+	mux.HandleFunc("/api/paper/pnl", s.handlePaperPnL)
+	mux.HandleFunc("/api/stream", s.handleStream)
+	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/version", s.handleVersion)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.Handle("/", dashboardHandler())
+
+	if s.tlsConfig != nil || s.acme != nil {
+		log.Printf("Server %s listening on %s (HTTPS)", Version, s.cfg.Addr)
+	} else {
+		log.Printf("Server %s listening on %s", Version, s.cfg.Addr)
+	}
+	log.Println("Endpoints:")
+	log.Println("  GET  /api/price   - Current price")
+	log.Println("  GET  /api/stats   - Moving average, high, low (?window= over 1m/5m/1h/24h)")
+	log.Println("  POST /api/stats/reset - Start a new session (admin)")
+	log.Println("  GET  /api/snapshot - Price, stats and 1m/5m/1h/24h timeframes")
+	log.Println("  GET  /api/history - Historical trades (DELETE ?before= prunes, admin)")
+	log.Println("  GET  /api/history/export - Stream trades as CSV or NDJSON")
+	log.Println("  GET  /api/candles - OHLC candles (1s/1m/5m/1h/1d)")
+	log.Println("  GET  /api/summary - 24h open, change, high, low, volume and weighted average")
+	log.Println("  GET  /api/patterns - Candle patterns (doji, hammer, engulfing)")
+	log.Println("  GET  /api/indicators - RSI, MACD, Bollinger Bands and ATR over closed candles")
+	log.Println("  GET  /api/reports/daily - Today's summary so far (json, markdown or html)")
+	log.Println("  GET  /api/symbol  - Current symbol")
+	log.Println("  POST /api/symbol  - Change symbol")
+	log.Println("  GET  /api/symbol/meta - Tick size, assets and display precision (?symbol=)")
+	log.Println("  GET  /api/coins   - Available coins (?q= searches every Binance pair)")
+	log.Println("  POST /api/symbols/{symbol}/pause - Mute a symbol in ingestion (admin, resume undoes)")
+	log.Println("  GET  /api/watchlist - Streamed symbols (POST adds/removes, admin)")
+	log.Println("  POST /api/admin/dump - Dump ingestion's recent raw exchange messages to disk (admin)")
+	log.Println("  GET  /api/config  - Processor parameters")
+	log.Println("  POST /api/config  - Change processor parameters")
+	log.Println("  POST /api/anchor  - Anchor the current price (GET/DELETE)")
+	log.Println("  POST /api/alerts  - Create an alert rule (GET lists, DELETE ?id= removes)")
+	log.Println("  POST /api/alerts/{id}/test - Send a test notification")
+	log.Println("  GET  /api/schema  - Response field types, units and descriptions")
+	log.Println("  GET  /api/signals - Stored strategy signals")
+	log.Println("  POST /api/paper/orders - Place a paper market order (GET lists)")
+	log.Println("  GET  /api/paper/positions - Open paper positions")
+	log.Println("  GET  /api/paper/pnl - Paper realized and unrealized PnL")
+	log.Println("  GET  /api/stream  - Price and stats as Server-Sent Events")
+	log.Println("  GET  /api/status  - Client and request load")
+	log.Println("  GET  /api/version - Version, build, features and protocol versions")
+	log.Println("  WS   /ws          - Real-time prices")
+	log.Println("  GET  /            - Web dashboard")
+	if s.cfg.GRPCAddr != "" {
+		log.Printf("  gRPC %s - MarketData: prices, stats, trade streams, symbol changes", s.cfg.GRPCAddr)
+	}
+	if s.cfg.APIToken != "" {
+		if s.cfg.WSAuth {
+			log.Println("API token required on POST, PUT and DELETE requests, WebSocket and SSE")
+		} else {
+			log.Println("API token required on POST, PUT and DELETE requests")
+		}
+	}
+
+	httpServer := &http.Server{Addr: s.cfg.Addr, Handler: s.cors(s.rateLimit(s.limitRequests(s.requireToken(mux))))}
+	// SSE streams are ordinary requests, so end them when shutdown begins or
+	// Shutdown would wait for them until the timeout
+	httpServer.RegisterOnShutdown(s.hub.Shutdown)
+	serveErr := make(chan error, 2)
+	go func() {
+		if err := s.serve(ctx, httpServer); err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+	var grpcServer *grpc.Server
+	if s.cfg.GRPCAddr != "" {
+		grpcServer = s.newGRPCServer()
+		go func() {
+			lis, err := net.Listen("tcp", s.cfg.GRPCAddr)
+			if err == nil {
+				err = grpcServer.Serve(lis)
+			}
+			if err != nil && err != grpc.ErrServerStopped {
+				serveErr <- fmt.Errorf("gRPC: %w", err)
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+	}
+	log.Println("Shutting down...")
+
+	// Stop accepting requests, then stop the trade feed so nothing new is
+	// written or broadcast while we flush
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown error: %v", err)
+	}
+	s.trades.shutdown()
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	if s.cluster != nil {
+		stopLease()
+		s.cluster.leave()
+	}
+	if err := nc.Drain(); err != nil {
+		log.Printf("NATS drain error: %v", err)
+	}
+	<-closed
+
+	s.writes.Wait()
+	s.hub.Shutdown()
+	if db != nil {
+		db.Close()
+	}
+	log.Println("API service stopped")
+	return err
+}
+
+// onProcessed aggregates and persists a processed trade, then updates the
+// current state when it belongs to the selected symbol. Backfilled trades
+// seed the aggregates and state but aren't streamed, counted toward the
+// trade rate or checked against alerts.
+func (s *Server) onProcessed(processed ProcessedMessage) {
+	// Round once here so the stream, candles, history and alerts all agree.
+	// The exact price is rounded when processing passed it on, and the float
+	// follows it.
+	price := store.DecimalOf(processed.Price)
+	if processed.PriceE8 != 0 {
+		price = Decimal(processed.PriceE8)
+	}
+	price = price.Round(marketInfo(processed.Symbol).Precision)
+	processed.Price, processed.PriceE8 = price.Float64(), int64(price)
+	processed.MovingAverage = roundPrice(processed.Symbol, processed.MovingAverage)
+	processed.High = roundPrice(processed.Symbol, processed.High)
+	processed.Low = roundPrice(processed.Symbol, processed.Low)
+	processed.VWAP = roundPrice(processed.Symbol, processed.VWAP)
+	processed.StdDev = roundPrice(processed.Symbol, processed.StdDev)
+	processed.Volume = roundQuantity(processed.Volume)
+	processed.BuyVolume = roundQuantity(processed.BuyVolume)
+	processed.SellVolume = roundQuantity(processed.SellVolume)
+	if processed.EMA != nil {
+		ema := make(map[string]float64, len(processed.EMA))
+		for span, v := range processed.EMA {
+			ema[span] = roundPrice(processed.Symbol, v)
+		}
+		processed.EMA = ema
+	}
+
+	ts := s.clock.TradeTime(processed.Time)
+	s.warmCandles(processed.Symbol, ts)
+	closed := s.candles.Add(processed.Symbol, processed.Price, processed.Qty, processed.Side, ts.UnixMilli())
+	// Candles drop late trades themselves; frames and sparklines skip
+	// backfilled history overlapping the trades they already counted
+	if s.tracked.Admit(processed.Symbol, ts.UnixMilli(), processed.Backfill) {
+		s.frames.Add(processed.Symbol, processed.Price, processed.Qty, processed.Side, ts.UnixMilli())
+		s.sparks.Add(processed.Symbol, processed.Price, ts.UnixMilli())
+	}
+	if !processed.Backfill {
+		s.rates.Add(processed.Symbol, s.clock.Now())
+	}
+	if !processed.Backfill {
+		s.lastTrades.Store(processed.Symbol, ts)
+	}
+
+	// Write to database. Insert only queues, so trades are stored, and
+	// numbered, in the order they arrive.
+	if s.writesStore() && s.stored.Admit(s.store, processed.Symbol, ts, processed.Backfill) {
+		trade := Trade{
+			Symbol:    processed.Symbol,
+			Price:     price,
+			Qty:       processed.Qty,
+			Side:      processed.Side,
+			Timestamp: ts,
+		}
+		if err := s.store.Insert(context.Background(), trade); err != nil {
+			log.Printf("DB write error: %v", err)
+		}
+	}
+
+	// Only the selected symbol drives the current price and stats
+	s.mu.Lock()
+	selected := processed.Symbol == s.symbol
+	// History backfilled for a symbol that was already streaming is older
+	// than the current trade
+	if selected && (!processed.Backfill || processed.Time >= s.current.Time) {
+		s.current = processed
+	}
+	s.mu.Unlock()
+
+	s.runStrategies(Trade{Symbol: processed.Symbol, Price: price, Timestamp: ts}, closed, processed.Backfill)
+	if processed.Backfill {
+		return
+	}
+
+	if done := s.reports.AddTrade(processed.Symbol, processed.Price, processed.Qty, ts); done != nil {
+		go s.deliverReport(done)
+	}
+
+	// Broadcast to WebSocket, SSE and gRPC clients
+	s.broadcast(processed, selected)
+	s.trades.publish(processed)
+	s.evaluateAlerts(processed.Symbol, processed.Price, ts, closed)
+	s.broadcastIndicators(processed.Symbol, closed)
+}
+
+func (s *Server) handlePrice(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	price := Decimal(s.current.PriceE8)
+	s.mu.RUnlock()
+
+	s.writeJSON(w, r, map[string]Decimal{"price": price})
+}
+
+// handleStats reports the selected symbol's session stats, or with
+// ?window= a symbol's stats over a rolling timeframe
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		s.writeJSON(w, r, s.stats())
+		return
+	}
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+	stats, ok := s.frames.Window(symbol, window, s.clock.Now())
+	if !ok {
+		http.Error(w, "Unknown window, want 1m, 5m, 1h or 24h", http.StatusBadRequest)
+		return
+	}
+	s.writeJSON(w, r, WindowStats{Symbol: symbol, Window: window, TimeframeStats: stats})
+}
+
+// Snapshot is everything a dashboard needs to render the selected symbol
+type Snapshot struct {
+	Symbol    string  `json:"symbol"`
+	Name      string  `json:"name"`
+	Quote     string  `json:"quote"`
+	Precision int     `json:"precision"`
+	Price     Decimal `json:"price"`
+	Stats
+	Anchor     *AnchorDelta              `json:"anchor"`
+	LastTrade  *time.Time                `json:"last_trade"`
+	Stale      bool                      `json:"stale"`
+	Timeframes map[string]TimeframeStats `json:"timeframes"`
+	Bands      map[string]PercentileBand `json:"bands"`
+}
+
+// snapshot captures the selected symbol's current state
+func (s *Server) snapshot() Snapshot {
+	s.mu.RLock()
+	current := s.current
+	symbol := s.symbol
+	name := s.coinName
+	stats := s.statsLocked()
+	anchor := s.anchorDelta()
+	s.mu.RUnlock()
+
+	now := s.clock.Now()
+	var lastTrade *time.Time
+	stale := true
+	if current.Time != 0 {
+		t := time.UnixMilli(current.Time)
+		lastTrade = &t
+		stale = now.Sub(t) > staleAfter
+	}
+
+	market := marketInfo(symbol)
+	return Snapshot{
+		Symbol:     symbol,
+		Name:       name,
+		Quote:      market.Quote,
+		Precision:  market.Precision,
+		Price:      Decimal(current.PriceE8),
+		Stats:      stats,
+		Anchor:     anchor,
+		LastTrade:  lastTrade,
+		Stale:      stale,
+		Timeframes: s.frames.Stats(symbol, now),
+		Bands:      s.bands(symbol, current.Price, now),
+	}
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, s.snapshot())
+}
+
+// Recent one-minute candles included in the WebSocket connect snapshot
+const snapshotCandles = 60
+
+// connectSnapshot is the first message on a new WebSocket: the snapshot
+// plus recent candles, so a dashboard can render before the next trade.
+// It carries "price" like the default stream, so clients that only read
+// that field keep working, and the quote and precision prices are in.
+func (s *Server) connectSnapshot(prices string) []byte {
+	snap := s.snapshot()
+	msg := struct {
+		Type string `json:"type"`
+		Snapshot
+		Interval string   `json:"interval"`
+		Candles  []Candle `json:"candles"`
+	}{
+		Type:     "snapshot",
+		Snapshot: snap,
+		Interval: "1m",
+		Candles:  s.candles.Candles(snap.Symbol, "1m", snapshotCandles),
+	}
+	if prices == priceString {
+		data, _ := stringPrices(msg)
+		return bytes.TrimSuffix(data, []byte("\n"))
+	}
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "1m"
+	}
+	if _, ok := candleIntervals[interval]; !ok {
+		http.Error(w, "Unknown interval", http.StatusBadRequest)
+		return
+	}
+
+	limit := 200
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxCandles)
+	}
+
+	var from, to time.Time
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := parseHistoryTime(v)
+			if err != nil {
+				http.Error(w, "Invalid "+p.name, http.StatusBadRequest)
+				return
+			}
+			*p.t = t
+		}
+	}
+
+	candles := s.candleRange(r.Context(), symbol, interval, from, to, limit)
+	if candles == nil {
+		candles = []Candle{}
+	}
+	s.writeJSON(w, r, candles)
+}
+
+// errUnknownSymbol is returned when a symbol isn't in the coin list
+var errUnknownSymbol = errors.New("unknown symbol")
+
+// changeSymbol switches the tracked symbol and notifies the other services.
+// Changes are serialized so the state update and the control.symbol publish
+// of concurrent callers can't interleave, and selecting the current symbol
+// again is a no-op that reports changed=false.
+func (s *Server) changeSymbol(symbol string) (name string, changed bool, err error) {
+	market, ok := lookupMarket(symbol)
+	if !ok {
+		return "", false, errUnknownSymbol
+	}
+	newName := market.Name
+
+	s.symbolChangeMu.Lock()
+	defer s.symbolChangeMu.Unlock()
+
+	if !s.setSymbol(symbol, newName) {
+		return newName, false, nil
+	}
+
+	// Notify other services via NATS, and the other instances via Redis
+	msg, _ := json.Marshal(map[string]string{"symbol": symbol})
+	s.nc.Publish("control.symbol", msg)
+	if s.cluster != nil {
+		s.cluster.shareSymbol(symbol)
+	}
+
+	log.Printf("Changed to %s", newName)
+	return newName, true, nil
+}
+
+// setSymbol makes symbol the selected one and tells this instance's
+// clients, reporting false when it already was. Callers hold
+// symbolChangeMu.
+func (s *Server) setSymbol(symbol, name string) bool {
+	s.mu.Lock()
+	if s.symbol == symbol {
+		s.mu.Unlock()
+		return false
+	}
+	s.symbol = symbol
+	s.coinName = name
+	s.current = ProcessedMessage{}
+	s.mu.Unlock()
+
+	// Prices now come in another unit; tell clients before the first trade
+	s.hub.Broadcast(event{control: marketMessage(symbol)})
+	return true
+}
+
+// writesStore reports whether this instance persists trades and signals:
+// with a store, and when clustered only while feeding, so each is stored once
+func (s *Server) writesStore() bool {
+	return s.store != nil && (s.cluster == nil || s.cluster.feeder.Load())
+}
+
+// stats returns the moving averages, session high/low, VWAP, deviation,
+// volume and trade rate of the current symbol
+func (s *Server) stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.statsLocked()
+}
+
+// statsLocked is stats for callers already holding s.mu
+func (s *Server) statsLocked() Stats {
+	return s.statsOf(s.symbol, s.current)
+}
+
+// statsOf is symbol's session stats as of its processed trade p
+func (s *Server) statsOf(symbol string, p ProcessedMessage) Stats {
+	return Stats{
+		MovingAverage: p.MovingAverage,
+		High:          p.High,
+		Low:           p.Low,
+		VWAP:          p.VWAP,
+		StdDev:        p.StdDev,
+		EMA:           p.EMA,
+		Volume:        p.Volume,
+		BuyVolume:     p.BuyVolume,
+		SellVolume:    p.SellVolume,
+		BuyRatio:      buyRatio(p.BuyVolume, p.SellVolume),
+		TradesPerSec:  s.rates.Rate(symbol, s.clock.Now()),
+		Samples:       p.Samples,
+		WindowFull:    p.Window > 0 && p.Samples >= p.Window,
+	}
+}
+
+// buyRatio is the share of buy and sell volume that takers bought, 0 when
+// there is none
+func buyRatio(buy, sell float64) float64 {
+	if buy+sell == 0 {
+		return 0
+	}
+	return math.Round(buy/(buy+sell)*1e4) / 1e4
+}
+
+func (s *Server) handleSymbol(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		var req struct {
+			Symbol string `json:"symbol"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		newName, changed, err := s.changeSymbol(req.Symbol)
+		if err != nil {
+			http.Error(w, "Unknown symbol", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"symbol": req.Symbol, "name": newName, "changed": changed})
+		return
+	}
+
+	s.mu.RLock()
+	symbol := s.symbol
+	name := s.coinName
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"symbol": symbol, "name": name})
+}
+
+// handleSymbolMeta describes the selected market, or ?symbol=, so clients
+// can format its prices with the decimals the API rounds them to
+func (s *Server) handleSymbolMeta(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.ToLower(r.URL.Query().Get("symbol"))
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+	meta, ok := symbolMeta(symbol)
+	if !ok {
+		http.Error(w, "Unknown symbol", http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, r, meta)
+}
+
+// handleCoins lists the selectable coins; ?with_sparkline=true adds each
+// market's last 30 minutes as sparkline points. ?q= instead searches every
+// pair the exchange trades, up to ?limit=.
+func (s *Server) handleCoins(w http.ResponseWriter, r *http.Request) {
+	if q := r.URL.Query().Get("q"); q != "" {
+		limit := maxCoinResults
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxCoinResults)
+		}
+		s.writeJSON(w, r, searchSymbols(q, limit))
+		return
+	}
+
+	coins := coinList()
+	if with, _ := strconv.ParseBool(r.URL.Query().Get("with_sparkline")); with {
+		now := s.clock.Now()
+		for i := range coins {
+			for j := range coins[i].Markets {
+				m := &coins[i].Markets[j]
+				m.Sparkline = s.sparks.Points(m.Symbol, now)
+			}
+			coins[i].Sparkline = coins[i].Markets[0].Sparkline
+		}
+	}
+
+	s.writeJSON(w, r, coins)
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin:       s.checkOrigin,
+		EnableCompression: s.cfg.WSCompression,
+	}
+
+	version := protocolV1
+	if v := r.URL.Query().Get("v"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < protocolV1 || n > latestProtocol {
+			http.Error(w, "Unsupported protocol version", http.StatusBadRequest)
+			return
+		}
+		version = n
+	}
+	var binary bool
+	switch r.URL.Query().Get("format") {
+	case "", formatJSON:
+	case formatProtobuf:
+		binary = true
+	default:
+		http.Error(w, "Unsupported format, want json or protobuf", http.StatusBadRequest)
+		return
+	}
+
+	if !s.clientLimit.Acquire() {
+		rejectBusy(w)
+		return
+	}
+	defer s.clientLimit.Release()
+	addr := s.clientAddr(r)
+	admitted := s.addrClients.Acquire(addr)
+	if admitted {
+		defer s.addrClients.Release(addr)
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+	if !admitted {
+		closeTooMany(conn)
+		return
+	}
+	client := s.hub.NewClient(conn, binary)
+	client.version = version
+	client.authorized = s.authorized(r)
+	client.rate.setInterval(rateInterval(s.cfg.StreamRate))
+	client.prices = s.priceFormat(r)
+	// Queued before registering so it goes out ahead of any broadcast
+	client.enqueue(s.connectSnapshot(client.prices))
+	s.hub.Register(client)
+
+	log.Printf("Client connected. Total: %d", s.hub.Clients())
+
+	// A client that vanished without a close frame stops answering the
+	// write pump's pings, and the read deadline reaps it
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			s.hub.Unregister(client)
+			log.Printf("Client disconnected (%d messages dropped). Total: %d",
+				client.dropped.Load(), s.hub.Clients())
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		s.handleClientMessage(client, data)
+	}
+}
+
+// handleClientMessage dispatches a message from a WebSocket client to the
+// subscription protocol ("op") or JSON-RPC ("method")
+func (s *Server) handleClientMessage(c *Client, data []byte) {
+	var req subscriptionRequest
+	if err := json.Unmarshal(data, &req); err == nil && req.Op != "" {
+		if req.Op == "set_rate" {
+			s.handleSetRate(c, req)
+		} else {
+			s.handleSubscription(c, req)
+		}
+		return
+	}
+	var flow struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(data, &flow); err == nil && flow.Action != "" {
+		s.handleFlowControl(c, flow.Action)
+		return
+	}
+	s.handleRPC(c, data)
+}
+
+// handleFlowControl pauses or resumes a client's market data. A paused
+// client keeps its subscriptions and still gets alerts and control events.
+// Resuming sends a fresh snapshot, since the prices missed in between are
+// not replayed.
+func (s *Server) handleFlowControl(c *Client, action string) {
+	switch action {
+	case "pause":
+		c.paused.Store(true)
+		c.rate.clear()
+		c.enqueue([]byte(`{"type":"paused"}`))
+	case "resume":
+		c.paused.Store(false)
+		c.enqueue([]byte(`{"type":"resumed"}`))
+		c.enqueue(s.connectSnapshot(c.prices))
+	default:
+		s.sendError(c, "unknown action")
+	}
+}
+
+// broadcast routes a processed trade to every client subscribed to its
+// symbol; clients without subscriptions get the selected symbol's price
+func (s *Server) broadcast(p ProcessedMessage, selected bool) {
+	e := event{
+		symbol: p.Symbol,
+		build: func(sub subscription) []byte {
+			return s.channelMessage(sub, p, priceNumber)
+		},
+		buildFrame: func(sub subscription) []byte {
+			return s.channelFrame(sub, p)
+		},
+		stringBuild: func(sub subscription) []byte {
+			// Only the price channel carries the trade price
+			if sub.Channel != channelPrice {
+				return nil
+			}
+			return s.channelMessage(sub, p, priceString)
+		},
+	}
+	if selected {
+		e.legacy = legacyMessage(p, priceNumber)
+		e.stringLegacy = sync.OnceValue(func() []byte { return legacyMessage(p, priceString) })
+		e.trade = tradeMessage(p, priceNumber)
+		e.stringTrade = sync.OnceValue(func() []byte { return tradeMessage(p, priceString) })
+		e.tradeFrame = sync.OnceValue(func() []byte { return tradeFrame(p) })
+	}
+	s.hub.Broadcast(e)
+}
+
+// legacyMessage is the selected symbol's price for v1 clients without
+// subscriptions
+func legacyMessage(p ProcessedMessage, prices string) []byte {
+	data, _ := json.Marshal(map[string]any{"price": streamPrice(Decimal(p.PriceE8), prices)})
+	return data
+}
+
+// tradeMessage is the selected symbol's trade event for v2 clients without
+// subscriptions
+func tradeMessage(p ProcessedMessage, prices string) []byte {
+	market := marketInfo(p.Symbol)
+	data, _ := json.Marshal(map[string]any{
+		"type":      "trade",
+		"symbol":    p.Symbol,
+		"price":     streamPrice(Decimal(p.PriceE8), prices),
+		"quote":     market.Quote,
+		"precision": market.Precision,
+		"ts":        p.Time,
+	})
+	return data
+}
+
+// marketMessage announces the selected market, so v2 clients can switch the
+// unit and decimals they format prices with
+func marketMessage(symbol string) []byte {
+	market := marketInfo(symbol)
+	data, _ := json.Marshal(map[string]any{
+		"type":      "market",
+		"symbol":    market.Symbol,
+		"name":      market.Name,
+		"quote":     market.Quote,
+		"precision": market.Precision,
+	})
+	return data
+}
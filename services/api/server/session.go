@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+)
+
+// Session joined by meta subscriptions that don't name one
+const defaultSession = "default"
+
+// Largest view state a client may publish to a session
+const maxSessionState = 4096
+
+// publishSession shares a client's view state, e.g. the selected timeframe,
+// with the other clients in its session on the meta channel. The server
+// doesn't interpret the state; it only keeps the latest per session for
+// clients that join later.
+func (s *Server) publishSession(c *Client, session string, state json.RawMessage) {
+	if len(state) == 0 || state[0] != '{' {
+		s.sendError(c, "state must be a JSON object")
+		return
+	}
+	if len(state) > maxSessionState {
+		s.sendError(c, "state too large")
+		return
+	}
+	if session == "*" {
+		s.sendError(c, "can't publish to every session")
+		return
+	}
+
+	data, _ := json.Marshal(map[string]any{
+		"type":    "meta",
+		"channel": channelMeta,
+		"session": session,
+		"state":   state,
+	})
+	s.sessions.Store(session, data)
+	s.hub.Broadcast(event{
+		symbol: session,
+		from:   c,
+		build: func(sub subscription) []byte {
+			if sub.Channel != channelMeta {
+				return nil
+			}
+			return data
+		},
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Coin selector sparklines cover sparklineWindow in sparklinePoints buckets
+const (
+	sparklinePoints = 20
+	sparklineWindow = 30 * time.Minute
+)
+
+type sparkBucket struct {
+	slot  int64 // unix ms / bucket width, 0 when unused
+	close float64
+}
+
+// SparklineTracker keeps the last price of each bucket per symbol, so the
+// coin list can show a mini chart for every streamed symbol
+type SparklineTracker struct {
+	mu      sync.Mutex
+	symbols map[string]*[sparklinePoints]sparkBucket
+}
+
+func NewSparklineTracker() *SparklineTracker {
+	return &SparklineTracker{symbols: make(map[string]*[sparklinePoints]sparkBucket)}
+}
+
+func sparklineSlot(ts int64) int64 {
+	return ts / (sparklineWindow / sparklinePoints).Milliseconds()
+}
+
+// Add records a trade at ts (unix ms)
+func (t *SparklineTracker) Add(symbol string, price float64, ts int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	buckets, ok := t.symbols[symbol]
+	if !ok {
+		buckets = &[sparklinePoints]sparkBucket{}
+		t.symbols[symbol] = buckets
+	}
+
+	slot := sparklineSlot(ts)
+	b := &buckets[slot%sparklinePoints]
+	if slot < b.slot {
+		// Late trade for a bucket that has been reused
+		return
+	}
+	*b = sparkBucket{slot: slot, close: price}
+}
+
+// Points returns the closing price of each bucket in the window ending at
+// now, oldest first. Buckets without trades repeat the previous close so the
+// points stay evenly spaced; the line starts at the first bucket with data.
+func (t *SparklineTracker) Points(symbol string, now time.Time) []float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	buckets, ok := t.symbols[symbol]
+	if !ok {
+		return nil
+	}
+
+	last := sparklineSlot(now.UnixMilli())
+	var points []float64
+	for slot := last - sparklinePoints + 1; slot <= last; slot++ {
+		b := buckets[slot%sparklinePoints]
+		switch {
+		case b.slot == slot:
+			points = append(points, b.close)
+		case len(points) > 0:
+			points = append(points, points[len(points)-1])
+		}
+	}
+	return points
+}
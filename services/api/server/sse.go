@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval between SSE heartbeat comments, short enough that proxies don't
+// drop an idle stream
+const sseHeartbeat = 15 * time.Second
+
+// sseTransport writes hub messages as Server-Sent Events. The event name is
+// the message's channel ("price", "stats") or type ("server_shutdown").
+type sseTransport struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (t sseTransport) WriteMessage(msg []byte) error {
+	var head struct {
+		Channel string `json:"channel"`
+		Type    string `json:"type"`
+	}
+	json.Unmarshal(msg, &head)
+	name := head.Channel
+	if name == "" {
+		name = head.Type
+	}
+	if name == "" {
+		name = "message"
+	}
+	if _, err := fmt.Fprintf(t.w, "event: %s\ndata: %s\n\n", name, msg); err != nil {
+		return err
+	}
+	return t.rc.Flush()
+}
+
+func (t sseTransport) WriteKeepalive() error {
+	if _, err := fmt.Fprint(t.w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	return t.rc.Flush()
+}
+
+// WriteClose is a no-op; the stream ends when the handler returns
+func (t sseTransport) WriteClose(reason string) error {
+	return nil
+}
+
+func (t sseTransport) SetWriteDeadline(d time.Time) error {
+	return t.rc.SetWriteDeadline(d)
+}
+
+func (t sseTransport) Close() error {
+	return nil
+}
+
+// handleStream serves price and stats events for one symbol as an SSE stream,
+// e.g. GET /api/stream?symbol=ethusdt (defaults to the selected symbol)
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.clientLimit.Acquire() {
+		rejectBusy(w)
+		return
+	}
+	defer s.clientLimit.Release()
+	addr := s.clientAddr(r)
+	if !s.addrClients.Acquire(addr) {
+		rejectTooMany(w, retryAfter)
+		return
+	}
+	defer s.addrClients.Release(addr)
+
+	rate := s.cfg.StreamRate
+	if v := r.URL.Query().Get("max_per_sec"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || !validRate(n) {
+			http.Error(w, rateRangeError, http.StatusBadRequest)
+			return
+		}
+		rate = n
+	}
+
+	symbol := strings.ToLower(r.URL.Query().Get("symbol"))
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	client := s.hub.newClient(sseTransport{w: w, rc: rc}, sseHeartbeat)
+	client.prices = s.priceFormat(r)
+	client.subs.add(subscription{Channel: channelPrice, Symbol: symbol})
+	client.subs.add(subscription{Channel: channelStats, Symbol: symbol})
+	client.rate.setInterval(rateInterval(rate))
+	s.hub.Register(client)
+
+	// The response must not be touched once the handler returns, so wait for
+	// the write pump to finish
+	select {
+	case <-r.Context().Done():
+		s.hub.Unregister(client)
+	case <-client.done:
+	}
+	<-client.stopped
+}
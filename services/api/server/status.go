@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// IngestionStatus is the feed health published by ingestion on
+// status.ingestion
+type IngestionStatus struct {
+	Exchange       string                    `json:"exchange"`
+	Connected      bool                      `json:"connected"`
+	Connects       int64                     `json:"connects"`
+	Stalls         int64                     `json:"stalls"`
+	Trades         int64                     `json:"trades"`
+	Rejected       int64                     `json:"rejected"` // exchange messages and trades that didn't parse
+	LastTrade      int64                     `json:"last_trade"`
+	Reconnects     int64                     `json:"reconnects"`
+	ReconnectDelay int64                     `json:"reconnect_delay_ms"`  // last wait before reconnecting
+	Paused         []string                  `json:"paused"`              // symbols muted through the pause endpoint
+	Outputs        map[string]OutputStatus   `json:"outputs,omitempty"`   // kafka, nats and udp, when configured
+	Exchanges      map[string]ExchangeStatus `json:"exchanges,omitempty"` // by exchange, from ingestion versions that report it
+	StartedAt      int64                     `json:"started_at,omitempty"`
+	ReportedAt     int64                     `json:"reported_at"`
+}
+
+// ExchangeStatus is the connection to one exchange, as ingestion reports it
+type ExchangeStatus struct {
+	Connected   bool  `json:"connected"`
+	ConnectedAt int64 `json:"connected_at"` // unix ms the current connection opened, 0 while down
+	Symbols     int   `json:"symbols"`      // streamed from it
+	Connects    int64 `json:"connects"`
+	Reconnects  int64 `json:"reconnects"`
+	Stalls      int64 `json:"stalls"`
+	Trades      int64 `json:"trades"`
+	LastTrade   int64 `json:"last_trade"`
+}
+
+// OutputStatus counts the trades ingestion copied to Kafka or NATS
+type OutputStatus struct {
+	Published int64 `json:"published"`
+	Failed    int64 `json:"failed"`
+}
+
+// ProcessingStatus is the cost of the processing service's cgo calls,
+// published on status.processing
+type ProcessingStatus struct {
+	Trades     int64                    `json:"trades"`
+	Sampled    int64                    `json:"sampled"`
+	MaxCgoRate int                      `json:"max_cgo_rate"`
+	Cgo        map[string]CgoCallStatus `json:"cgo"` // by C function
+	ReportedAt int64                    `json:"reported_at"`
+}
+
+// CgoCallStatus is the cost of one C function since processing started
+type CgoCallStatus struct {
+	Calls   int64   `json:"calls"`
+	TotalMs float64 `json:"total_ms"`
+	AvgNs   int64   `json:"avg_ns"`
+}
+
+// ClusterStatus is this instance's part in a deployment sharing state
+// through Redis
+type ClusterStatus struct {
+	Instance string `json:"instance"`
+	Feeder   bool   `json:"feeder"` // reads trades from NATS, fans them out and stores them
+}
+
+// handleStatus reports uptime, current and maximum load, each symbol's
+// latest trade, feed health and processor cost
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	sse := s.hub.SSEClients()
+	status := struct {
+		StartedAt  time.Time            `json:"started_at"`
+		UptimeMs   int64                `json:"uptime_ms"`
+		Clients    LimiterStatus        `json:"clients"`
+		WebSockets int                  `json:"websocket_clients"`
+		SSE        int                  `json:"sse_clients"`
+		Requests   LimiterStatus        `json:"requests"`
+		PerAddress AddrLimiterStatus    `json:"clients_per_address"`
+		RateLimit  RateLimitStatus      `json:"rate_limit"`
+		Dropped    int64                `json:"dropped_messages"`
+		LastTrades map[string]time.Time `json:"last_trades"` // by symbol, live trades since the API started
+		Ingestion  *IngestionStatus     `json:"ingestion"`
+		Processing *ProcessingStatus    `json:"processing"`
+		Writes     *WriteQueueStatus    `json:"store_writes,omitempty"` // with TimescaleDB
+		Cluster    *ClusterStatus       `json:"cluster,omitempty"`      // with REDIS_URL
+	}{
+		StartedAt:  s.started,
+		UptimeMs:   time.Since(s.started).Milliseconds(),
+		Clients:    s.clientLimit.Status(),
+		WebSockets: s.hub.Clients() - sse,
+		SSE:        sse,
+		Requests:   s.requestLimit.Status(),
+		PerAddress: s.addrClients.Status(),
+		RateLimit:  s.rateLimiter.Status(),
+		Dropped:    s.hub.Dropped(),
+		LastTrades: make(map[string]time.Time),
+		Ingestion:  s.ingestion.Load(),
+		Processing: s.processing.Load(),
+	}
+	s.lastTrades.Range(func(symbol, ts any) bool {
+		status.LastTrades[symbol.(string)] = ts.(time.Time)
+		return true
+	})
+	if queued, ok := s.store.(interface{ WriteQueue() WriteQueueStatus }); ok {
+		writes := queued.WriteQueue()
+		status.Writes = &writes
+	}
+	if s.cluster != nil {
+		status.Cluster = s.cluster.Status()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
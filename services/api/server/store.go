@@ -0,0 +1,29 @@
+package server
+
+import "api/store"
+
+// The server keeps trades, paper orders and signals in a store.Store, and
+// hands out the store's types as they are
+type (
+	Store            = store.Store
+	Trade            = store.Trade
+	HistoryQuery     = store.HistoryQuery
+	HistoryCursor    = store.HistoryCursor
+	Candle           = store.Candle
+	PaperOrder       = store.PaperOrder
+	Signal           = store.Signal
+	Decimal          = store.Decimal
+	WriteQueueStatus = store.WriteQueueStatus
+)
+
+// Taker sides of a trade
+const (
+	sideBuy  = store.SideBuy
+	sideSell = store.SideSell
+)
+
+// Limits for the history endpoint
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
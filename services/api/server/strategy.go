@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Strategy reacts to the live pipeline and may emit trading signals. One
+// instance sees every streamed symbol, in order, from a single goroutine.
+type Strategy interface {
+	Name() string
+	// OnTrade is called for every processed trade
+	OnTrade(t Trade) []Signal
+	// OnCandle is called when a candle of the interval closes
+	OnCandle(symbol, interval string, c Candle) []Signal
+}
+
+// strategyFactories holds every strategy that can be enabled by name;
+// strategies add themselves from init
+var strategyFactories = map[string]func() Strategy{}
+
+func registerStrategy(name string, factory func() Strategy) {
+	strategyFactories[name] = factory
+}
+
+// strategyNames lists the registered strategies, sorted
+func strategyNames() []string {
+	names := make([]string, 0, len(strategyFactories))
+	for name := range strategyFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StrategyEngine runs the enabled strategies
+type StrategyEngine struct {
+	mu         sync.Mutex
+	strategies []Strategy
+}
+
+// NewStrategyEngine instantiates the strategies named in a comma separated
+// list
+func NewStrategyEngine(list string) (*StrategyEngine, error) {
+	e := &StrategyEngine{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		factory, ok := strategyFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown strategy %q (have %s)", name, strings.Join(strategyNames(), ", "))
+		}
+		e.strategies = append(e.strategies, factory())
+	}
+	return e, nil
+}
+
+// Names lists the enabled strategies
+func (e *StrategyEngine) Names() []string {
+	names := make([]string, len(e.strategies))
+	for i, st := range e.strategies {
+		names[i] = st.Name()
+	}
+	return names
+}
+
+// Feed passes a trade and the candles it closed to every strategy and
+// returns the signals they emitted, stamped with the strategy's name
+func (e *StrategyEngine) Feed(t Trade, closed []ClosedCandle) []Signal {
+	if len(e.strategies) == 0 {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var out []Signal
+	for _, st := range e.strategies {
+		signals := st.OnTrade(t)
+		for _, cc := range closed {
+			signals = append(signals, st.OnCandle(t.Symbol, cc.Interval, cc.Candle)...)
+		}
+		for _, sig := range signals {
+			sig.Strategy = st.Name()
+			if sig.Symbol == "" {
+				sig.Symbol = t.Symbol
+			}
+			if sig.Time.IsZero() {
+				sig.Time = t.Timestamp
+			}
+			out = append(out, sig)
+		}
+	}
+	return out
+}
+
+// runStrategies feeds a trade to the strategies, then stores and publishes
+// their signals. Backfilled trades only warm the strategies up; signals on
+// history nobody could have traded would be noise.
+func (s *Server) runStrategies(t Trade, closed []ClosedCandle, backfill bool) {
+	signals := s.strategies.Feed(t, closed)
+	if backfill {
+		return
+	}
+	for _, sig := range signals {
+		log.Printf("Signal from %s: %s %s at %v", sig.Strategy, sig.Side, sig.Symbol, sig.Price)
+		s.broadcastSignal(sig)
+		if s.writesStore() {
+			s.writes.Add(1)
+			go func() {
+				defer s.writes.Done()
+				if err := s.store.InsertSignal(context.Background(), sig); err != nil {
+					log.Printf("DB signal write error: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// broadcastSignal sends a signal to signals subscribers
+func (s *Server) broadcastSignal(sig Signal) {
+	data, _ := json.Marshal(struct {
+		Type    string `json:"type"`
+		Channel string `json:"channel"`
+		Signal
+	}{"signal", channelSignals, sig})
+	s.hub.Broadcast(event{
+		symbol: sig.Symbol,
+		build: func(sub subscription) []byte {
+			if sub.Channel != channelSignals {
+				return nil
+			}
+			return data
+		},
+	})
+}
+
+// handleSignals lists a symbol's stored signals, newest first
+func (s *Server) handleSignals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.store == nil {
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+	limit := defaultHistoryLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxHistoryLimit)
+	}
+
+	signals, err := s.store.Signals(r.Context(), symbol, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch signals", http.StatusInternalServerError)
+		return
+	}
+	if signals == nil {
+		signals = []Signal{}
+	}
+	s.writeJSON(w, r, signals)
+}
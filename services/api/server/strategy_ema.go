@@ -0,0 +1,69 @@
+package server
+
+import "fmt"
+
+func init() {
+	registerStrategy("ema_cross", func() Strategy {
+		return &EMACross{Interval: "1m", Fast: 9, Slow: 21, symbols: make(map[string]*emaCrossState)}
+	})
+}
+
+// EMACross signals when a fast exponential moving average of candle closes
+// crosses a slow one: buy when it crosses above, sell when below
+type EMACross struct {
+	Interval   string
+	Fast, Slow int
+
+	symbols map[string]*emaCrossState
+}
+
+type emaCrossState struct {
+	fast, slow float64
+	candles    int
+	above      bool // fast was above slow at the last close
+}
+
+func (s *EMACross) Name() string { return "ema_cross" }
+
+func (s *EMACross) OnTrade(Trade) []Signal { return nil }
+
+func (s *EMACross) OnCandle(symbol, interval string, c Candle) []Signal {
+	if interval != s.Interval {
+		return nil
+	}
+	st, ok := s.symbols[symbol]
+	if !ok {
+		// Both averages start at the first close
+		s.symbols[symbol] = &emaCrossState{fast: c.Close, slow: c.Close, candles: 1}
+		return nil
+	}
+
+	st.fast = ema(st.fast, c.Close, s.Fast)
+	st.slow = ema(st.slow, c.Close, s.Slow)
+	st.candles++
+	above := st.fast > st.slow
+	crossed := above != st.above
+	st.above = above
+
+	// Until the slow average has seen a full window it mostly echoes its
+	// starting point, and crossings against it mean little
+	if !crossed || st.candles <= s.Slow {
+		return nil
+	}
+	side, dir := "sell", "below"
+	if above {
+		side, dir = "buy", "above"
+	}
+	return []Signal{{
+		Time:   c.Time.Add(candleIntervals[interval]),
+		Side:   side,
+		Price:  c.Close,
+		Reason: fmt.Sprintf("EMA%d crossed %s EMA%d on %s closes", s.Fast, dir, s.Slow, interval),
+	}}
+}
+
+// ema folds value into an exponential moving average over period samples
+func ema(prev, value float64, period int) float64 {
+	k := 2 / float64(period+1)
+	return prev + k*(value-prev)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Channels a WebSocket client can subscribe to
+const (
+	channelPrice      = "price"
+	channelStats      = "stats"
+	channelCandles    = "candles"
+	channelAlerts     = "alerts"
+	channelSignals    = "signals"
+	channelMeta       = "meta"
+	channelIndicators = "indicators"
+)
+
+// subscription selects one channel for one symbol, or every symbol when
+// Symbol is "*". Interval only applies to the candles and indicators
+// channels, Set (sorted indicator names, comma separated) to indicators. On
+// the meta channel Symbol holds the session name instead.
+type subscription struct {
+	Channel  string
+	Symbol   string
+	Interval string
+	Set      string
+}
+
+// subscriptionRequest is sent by clients, e.g.
+// {"op":"subscribe","channel":"price","symbol":"ethusdt"}
+type subscriptionRequest struct {
+	Op        string          `json:"op"`
+	Channel   string          `json:"channel"`
+	Symbol    string          `json:"symbol,omitempty"`
+	Interval  string          `json:"interval,omitempty"`
+	Set       string          `json:"set,omitempty"`         // indicators channel only
+	Session   string          `json:"session,omitempty"`     // meta channel only
+	State     json.RawMessage `json:"state,omitempty"`       // for op publish
+	MaxPerSec *float64        `json:"max_per_sec,omitempty"` // for op set_rate
+}
+
+// subscriptionSet tracks a client's subscriptions. A client that has never
+// subscribed receives the legacy price feed for the selected symbol.
+type subscriptionSet struct {
+	mu     sync.RWMutex
+	active bool
+	subs   map[subscription]bool
+}
+
+func (set *subscriptionSet) add(sub subscription) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	if set.subs == nil {
+		set.subs = make(map[subscription]bool)
+	}
+	set.active = true
+	set.subs[sub] = true
+}
+
+func (set *subscriptionSet) remove(sub subscription) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	delete(set.subs, sub)
+}
+
+// forSymbol returns the client's subscriptions for a symbol and whether the
+// client uses the subscription protocol at all
+func (set *subscriptionSet) forSymbol(symbol string) ([]subscription, bool) {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	var out []subscription
+	for sub := range set.subs {
+		if sub.Symbol == symbol || sub.Symbol == "*" {
+			out = append(out, sub)
+		}
+	}
+	return out, set.active
+}
+
+// knownSymbol reports whether symbol is a market the API knows or one it
+// has had trades for, such as a watchlist extra while exchangeInfo is
+// unavailable
+func (s *Server) knownSymbol(symbol string) bool {
+	if _, ok := lookupMarket(symbol); ok {
+		return true
+	}
+	_, ok := s.lastTrades.Load(symbol)
+	return ok
+}
+
+// handleSubscription applies a subscribe/unsubscribe request and acknowledges it
+func (s *Server) handleSubscription(c *Client, req subscriptionRequest) {
+	// Pipeline symbols are lowercase; "BTCUSDT" would never match a trade
+	sub := subscription{Channel: req.Channel, Symbol: strings.ToLower(req.Symbol)}
+	switch req.Channel {
+	case channelPrice, channelStats, channelAlerts, channelSignals:
+	case channelMeta:
+		sub.Symbol = req.Session
+		if sub.Symbol == "" {
+			sub.Symbol = defaultSession
+		}
+	case channelCandles, channelIndicators:
+		sub.Interval = req.Interval
+		if sub.Interval == "" {
+			sub.Interval = "1m"
+		}
+		if _, ok := candleIntervals[sub.Interval]; !ok {
+			s.sendError(c, "unknown interval")
+			return
+		}
+		if sub.Channel == channelIndicators {
+			set, ok := parseIndicatorSet(req.Set)
+			if !ok {
+				s.sendError(c, "unknown indicator")
+				return
+			}
+			sub.Set = strings.Join(set, ",")
+		}
+	default:
+		s.sendError(c, "unknown channel")
+		return
+	}
+	if sub.Symbol == "" {
+		s.mu.RLock()
+		sub.Symbol = s.symbol
+		s.mu.RUnlock()
+	} else if sub.Channel != channelMeta && sub.Symbol != "*" && !s.knownSymbol(sub.Symbol) {
+		s.sendError(c, "unknown symbol")
+		return
+	}
+
+	switch {
+	case req.Op == "subscribe":
+		c.subs.add(sub)
+	case req.Op == "unsubscribe":
+		c.subs.remove(sub)
+	case req.Op == "publish" && sub.Channel == channelMeta:
+		s.publishSession(c, sub.Symbol, req.State)
+		return
+	default:
+		s.sendError(c, "unknown op")
+		return
+	}
+
+	ack := subscriptionRequest{
+		Op:       req.Op + "d",
+		Channel:  sub.Channel,
+		Symbol:   sub.Symbol,
+		Interval: sub.Interval,
+		Set:      sub.Set,
+	}
+	if sub.Channel == channelMeta {
+		ack.Symbol, ack.Session = "", sub.Symbol
+	}
+	data, _ := json.Marshal(ack)
+	c.enqueue(data)
+
+	// A client joining a session catches up with its view right away
+	if sub.Channel == channelMeta && req.Op == "subscribe" {
+		if state, ok := s.sessions.Load(sub.Symbol); ok {
+			c.enqueue(state.([]byte))
+		}
+	}
+}
+
+func (s *Server) sendError(c *Client, message string) {
+	data, _ := json.Marshal(map[string]string{"op": "error", "message": message})
+	c.enqueue(data)
+}
+
+// channelMessage builds the payload for one subscription from a processed trade
+func (s *Server) channelMessage(sub subscription, p ProcessedMessage, prices string) []byte {
+	var payload any
+	switch sub.Channel {
+	case channelPrice:
+		market := marketInfo(p.Symbol)
+		payload = map[string]any{
+			"type":      "trade",
+			"channel":   channelPrice,
+			"symbol":    p.Symbol,
+			"price":     streamPrice(Decimal(p.PriceE8), prices),
+			"quote":     market.Quote,
+			"precision": market.Precision,
+			"time":      p.Time,
+			"ts":        p.Time,
+		}
+	case channelStats:
+		payload = map[string]any{
+			"type":           "stats",
+			"channel":        channelStats,
+			"symbol":         p.Symbol,
+			"moving_average": p.MovingAverage,
+			"high":           p.High,
+			"low":            p.Low,
+			"vwap":           p.VWAP,
+			"std_dev":        p.StdDev,
+			"ema":            p.EMA,
+			"volume":         p.Volume,
+			"buy_volume":     p.BuyVolume,
+			"sell_volume":    p.SellVolume,
+			"buy_ratio":      buyRatio(p.BuyVolume, p.SellVolume),
+		}
+	case channelCandles:
+		candles := s.candles.Candles(p.Symbol, sub.Interval, 1)
+		if len(candles) == 0 {
+			return nil
+		}
+		payload = map[string]any{
+			"type":     "candle",
+			"channel":  channelCandles,
+			"symbol":   p.Symbol,
+			"interval": sub.Interval,
+			"candle":   candles[0],
+		}
+	default:
+		// Alerts are built by evaluateAlerts, not from trades
+		return nil
+	}
+	data, _ := json.Marshal(payload)
+	return data
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSubscriptionSymbols(t *testing.T) {
+	s := &Server{symbol: "btcusdt"}
+	s.lastTrades.Store("newcoinusdt", int64(0)) // streamed, but in no market list
+
+	for _, tc := range []struct {
+		symbol string
+		want   string // the subscribed symbol, "" for an error
+	}{
+		{"", "btcusdt"},
+		{"ethusdt", "ethusdt"},
+		{"ETHUSDT", "ethusdt"},
+		{"*", "*"},
+		{"newcoinusdt", "newcoinusdt"},
+		{"nosuchusdt", ""},
+	} {
+		c := (&Hub{}).newClient(nil, 0)
+		s.handleSubscription(c, subscriptionRequest{Op: "subscribe", Channel: channelPrice, Symbol: tc.symbol})
+		var reply subscriptionRequest
+		if err := json.Unmarshal(<-c.send, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if tc.want == "" {
+			if reply.Op != "error" {
+				t.Errorf("%q: got %+v, want an error", tc.symbol, reply)
+			}
+			continue
+		}
+		if reply.Op != "subscribed" || reply.Symbol != tc.want {
+			t.Errorf("%q: got %+v, want subscribed to %s", tc.symbol, reply, tc.want)
+		}
+		if subs, _ := c.subs.forSymbol(tc.want); len(subs) != 1 {
+			t.Errorf("%q: %d subscriptions for %s, want 1", tc.symbol, len(subs), tc.want)
+		}
+	}
+}
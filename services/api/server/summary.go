@@ -0,0 +1,112 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The summary covers the trailing day, read from the 5m candles in memory,
+// or the 1m candles the store aggregates when memory doesn't reach back a
+// whole day, e.g. after a restart
+const summaryWindow = 24 * time.Hour
+
+// Summary is a symbol's last 24 hours, like an exchange's 24h ticker
+type Summary struct {
+	Symbol          string    `json:"symbol"`
+	Open            float64   `json:"open"`
+	High            float64   `json:"high"`
+	Low             float64   `json:"low"`
+	Close           float64   `json:"close"`
+	Change          float64   `json:"change"`
+	ChangePercent   float64   `json:"change_percent"`
+	Volume          float64   `json:"volume"`
+	WeightedAverage float64   `json:"weighted_average"`
+	Trades          int64     `json:"trades"`
+	Interval        string    `json:"interval"` // of the candles summarized, 5m or 1m
+	From            time.Time `json:"from"`     // start of the oldest candle
+	Complete        bool      `json:"complete"` // the candles span the whole 24h
+}
+
+// trailingDay keeps the candles of interval that end within the day before
+// now, and reports whether they reach back to its start, give or take the
+// one candle a quiet market may not have traded in, like bands
+func trailingDay(candles []Candle, interval string, now time.Time) ([]Candle, bool) {
+	width := candleIntervals[interval]
+	cutoff := now.Add(-summaryWindow)
+	for len(candles) > 0 && !candles[0].Time.Add(width).After(cutoff) {
+		candles = candles[1:]
+	}
+	return candles, len(candles) > 0 && !candles[0].Time.After(cutoff.Add(width))
+}
+
+// summarize folds the candles of the trailing day into a Summary. The
+// weighted average weighs each candle's typical price, (high+low+close)/3,
+// by its volume, and is the mean close when no candle has a volume.
+func summarize(symbol, interval string, candles []Candle, complete bool) Summary {
+	first, last := candles[0], candles[len(candles)-1]
+	sum := Summary{
+		Symbol:   symbol,
+		Open:     first.Open,
+		High:     first.High,
+		Low:      first.Low,
+		Close:    last.Close,
+		Interval: interval,
+		From:     first.Time,
+		Complete: complete,
+	}
+	var weighted, closes float64
+	for _, c := range candles {
+		sum.High = max(sum.High, c.High)
+		sum.Low = min(sum.Low, c.Low)
+		sum.Volume += c.Volume
+		sum.Trades += c.Trades
+		weighted += (c.High + c.Low + c.Close) / 3 * c.Volume
+		closes += c.Close
+	}
+	if sum.Volume > 0 {
+		sum.WeightedAverage = weighted / sum.Volume
+	} else {
+		sum.WeightedAverage = closes / float64(len(candles))
+	}
+	sum.Change = sum.Close - sum.Open
+	if sum.Open > 0 {
+		sum.ChangePercent = math.Round(sum.Change/sum.Open*100*1e4) / 1e4
+	}
+
+	sum.Open = roundPrice(symbol, sum.Open)
+	sum.High = roundPrice(symbol, sum.High)
+	sum.Low = roundPrice(symbol, sum.Low)
+	sum.Close = roundPrice(symbol, sum.Close)
+	sum.Change = roundPrice(symbol, sum.Change)
+	sum.WeightedAverage = roundPrice(symbol, sum.WeightedAverage)
+	sum.Volume = roundQuantity(sum.Volume)
+	return sum
+}
+
+// handleSummary reports the selected symbol's last 24 hours, or ?symbol='s
+func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.ToLower(r.URL.Query().Get("symbol"))
+	if symbol == "" {
+		s.mu.RLock()
+		symbol = s.symbol
+		s.mu.RUnlock()
+	}
+
+	now := s.clock.Now()
+	interval := "5m"
+	candles, complete := trailingDay(s.candles.Candles(symbol, interval, int(summaryWindow/candleIntervals[interval])+1), interval, now)
+	if !complete {
+		from := now.Add(-summaryWindow).Truncate(time.Minute)
+		stored, storedComplete := trailingDay(s.candleRange(r.Context(), symbol, "1m", from, time.Time{}, int(summaryWindow/time.Minute)+1), "1m", now)
+		if len(stored) > 0 && (len(candles) == 0 || stored[0].Time.Before(candles[0].Time)) {
+			interval, candles, complete = "1m", stored, storedComplete
+		}
+	}
+	if len(candles) == 0 {
+		http.Error(w, "No trades for symbol in the last 24h", http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, r, summarize(symbol, interval, candles, complete))
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Rates a client may ask for with max_per_sec, besides 0 for no limit.
+// Anything faster than the highest is no different from every trade; the
+// lowest keeps the interval between updates well inside a Duration.
+const (
+	minClientRate = 0.01
+	maxClientRate = 1000
+)
+
+const rateRangeError = "max_per_sec must be 0 or between 0.01 and 1000"
+
+// validRate reports whether maxPerSec is 0 or in the allowed range. NaN is
+// neither.
+func validRate(maxPerSec float64) bool {
+	return maxPerSec == 0 || (maxPerSec >= minClientRate && maxPerSec <= maxClientRate)
+}
+
+// throttle coalesces a client's market data to at most a set number of
+// updates per second for each subscription, keeping only the latest one
+// held in between. Trades come faster than dashboards can show them, and
+// the newest price and stats are all they need.
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration // 0 sends every update as it comes
+	held     map[subscription]heldUpdate
+	order    []subscription // of held, in the order first held
+	changed  chan struct{}  // tells writePump the interval changed
+}
+
+// heldUpdate is a message waiting for the next tick, JSON or a StreamFrame
+type heldUpdate struct {
+	msg    []byte
+	binary bool
+}
+
+func newThrottle() throttle {
+	return throttle{changed: make(chan struct{}, 1)}
+}
+
+// rateInterval is the time between updates at maxPerSec, 0 for no limit
+func rateInterval(maxPerSec float64) time.Duration {
+	if maxPerSec <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / maxPerSec)
+}
+
+func (t *throttle) setInterval(d time.Duration) {
+	t.mu.Lock()
+	t.interval = d
+	t.mu.Unlock()
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (t *throttle) Interval() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.interval
+}
+
+// hold keeps msg as key's latest update until the next tick, replacing the
+// one held before, or reports false when updates aren't throttled
+func (t *throttle) hold(key subscription, msg []byte, binary bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.interval <= 0 {
+		return false
+	}
+	if t.held == nil {
+		t.held = make(map[subscription]heldUpdate)
+	}
+	if _, ok := t.held[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.held[key] = heldUpdate{msg, binary}
+	return true
+}
+
+// take empties the throttle, calling send with each held update in the
+// order they were first held
+func (t *throttle) take(send func(heldUpdate)) {
+	t.mu.Lock()
+	order, held := t.order, t.held
+	t.order, t.held = nil, nil
+	t.mu.Unlock()
+	for _, key := range order {
+		send(held[key])
+	}
+}
+
+// clear drops the held updates, e.g. when the client pauses
+func (t *throttle) clear() {
+	t.take(func(heldUpdate) {})
+}
+
+// offer queues an update of key's market data, or holds it back while the
+// client is throttled. The zero subscription stands for the feed clients
+// without subscriptions get.
+func (c *Client) offer(key subscription, msg, frame []byte) {
+	if c.binary && frame != nil {
+		if !c.rate.hold(key, frame, true) {
+			c.enqueueFrame(frame)
+		}
+		return
+	}
+	if !c.rate.hold(key, msg, false) {
+		c.enqueue(msg)
+	}
+}
+
+// release queues the updates held since the last tick
+func (c *Client) release() {
+	c.rate.take(func(u heldUpdate) {
+		if u.binary {
+			c.enqueueFrame(u.msg)
+		} else {
+			c.enqueue(u.msg)
+		}
+	})
+}
+
+// coalesced reports whether a channel's updates may be throttled; alerts,
+// signals and session state always arrive as they happen
+func coalesced(channel string) bool {
+	return channel == channelPrice || channel == channelStats || channel == channelCandles
+}
+
+// handleSetRate throttles a client's market data, e.g.
+// {"op":"set_rate","max_per_sec":2}; 0 sends every update again
+func (s *Server) handleSetRate(c *Client, req subscriptionRequest) {
+	if req.MaxPerSec == nil || !validRate(*req.MaxPerSec) {
+		s.sendError(c, rateRangeError)
+		return
+	}
+	c.rate.setInterval(rateInterval(*req.MaxPerSec))
+	data, _ := json.Marshal(map[string]any{"op": "rate_set", "max_per_sec": *req.MaxPerSec})
+	c.enqueue(data)
+}
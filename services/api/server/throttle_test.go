@@ -0,0 +1,34 @@
+package server
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidRate(t *testing.T) {
+	for _, tc := range []struct {
+		rate float64
+		want bool
+	}{
+		{0, true},
+		{minClientRate, true},
+		{2, true},
+		{maxClientRate, true},
+		{1e-10, false}, // its interval overflows a Duration
+		{-1, false},
+		{maxClientRate + 1, false},
+		{math.NaN(), false},
+	} {
+		if got := validRate(tc.rate); got != tc.want {
+			t.Errorf("validRate(%v) = %v, want %v", tc.rate, got, tc.want)
+		}
+	}
+}
+
+func TestNegativeIntervalIsUnthrottled(t *testing.T) {
+	th := newThrottle()
+	th.setInterval(-1)
+	if th.hold(subscription{Channel: channelPrice, Symbol: "btcusdt"}, []byte(`{}`), false) {
+		t.Fatal("update held with a negative interval")
+	}
+}
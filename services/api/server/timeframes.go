@@ -0,0 +1,211 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Number of buckets kept per timeframe; bucket width is window/frameBuckets
+const frameBuckets = 60
+
+// Timeframes reported in the dashboard snapshot, shortest first
+var timeframeDefs = []struct {
+	name   string
+	window time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+}
+
+// TimeframeStats summarizes trades over one rolling window
+type TimeframeStats struct {
+	Open          float64 `json:"open"`
+	Close         float64 `json:"close"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	Average       float64 `json:"average"` // mean trade price
+	Trades        int64   `json:"trades"`
+	Volume        float64 `json:"volume"`
+	BuyVolume     float64 `json:"buy_volume"`
+	SellVolume    float64 `json:"sell_volume"`
+}
+
+// WindowStats are a symbol's stats over one rolling window
+type WindowStats struct {
+	Symbol string `json:"symbol"`
+	Window string `json:"window"`
+	TimeframeStats
+}
+
+type frameBucket struct {
+	start  int64 // unix ms of bucket start, 0 when unused
+	open   float64
+	high   float64
+	low    float64
+	close  float64
+	sum    float64 // of trade prices, for the average
+	trades int64
+	volume float64
+	buy    float64 // volume bought by takers
+	sell   float64 // and sold
+}
+
+// timeframe is a ring of fixed-width buckets covering one window
+type timeframe struct {
+	window  time.Duration
+	width   int64 // bucket width in ms
+	buckets [frameBuckets]frameBucket
+}
+
+func newTimeframe(window time.Duration) *timeframe {
+	return &timeframe{
+		window: window,
+		width:  window.Milliseconds() / frameBuckets,
+	}
+}
+
+func (f *timeframe) add(price, qty float64, side string, ts int64) {
+	start := ts - ts%f.width
+	b := &f.buckets[(start/f.width)%frameBuckets]
+	switch {
+	case start < b.start:
+		// From a lap of the ring the slot has moved past, such as history
+		// backfilled after live trades
+		return
+	case start > b.start:
+		// Bucket is unused or holds data from a previous lap of the ring
+		*b = frameBucket{start: start, open: price, high: price, low: price}
+	}
+	if price > b.high {
+		b.high = price
+	}
+	if price < b.low {
+		b.low = price
+	}
+	b.close = price
+	b.sum += price
+	b.trades++
+	b.volume += qty
+	switch side {
+	case sideBuy:
+		b.buy += qty
+	case sideSell:
+		b.sell += qty
+	}
+}
+
+func (f *timeframe) stats(now int64) TimeframeStats {
+	var out TimeframeStats
+	cutoff := now - f.window.Milliseconds()
+	var first, last int64
+	var sum float64
+	for _, b := range f.buckets {
+		if b.start == 0 || b.start+f.width <= cutoff || b.start > now {
+			continue
+		}
+		if out.Trades == 0 || b.start < first {
+			first = b.start
+			out.Open = b.open
+		}
+		if out.Trades == 0 || b.start > last {
+			last = b.start
+			out.Close = b.close
+		}
+		if out.Trades == 0 || b.high > out.High {
+			out.High = b.high
+		}
+		if out.Trades == 0 || b.low < out.Low {
+			out.Low = b.low
+		}
+		out.Trades += b.trades
+		sum += b.sum
+		out.Volume += b.volume
+		out.BuyVolume += b.buy
+		out.SellVolume += b.sell
+	}
+	out.Volume = roundQuantity(out.Volume)
+	out.BuyVolume = roundQuantity(out.BuyVolume)
+	out.SellVolume = roundQuantity(out.SellVolume)
+	if out.Trades > 0 {
+		out.Average = sum / float64(out.Trades)
+	}
+	if out.Open > 0 {
+		out.Change = out.Close - out.Open
+		out.ChangePercent = out.Change / out.Open * 100
+	}
+	return out
+}
+
+// FrameTracker keeps rolling timeframe stats per symbol
+type FrameTracker struct {
+	mu      sync.Mutex
+	symbols map[string][]*timeframe
+}
+
+func NewFrameTracker() *FrameTracker {
+	return &FrameTracker{symbols: make(map[string][]*timeframe)}
+}
+
+// Add records a trade at ts (unix ms). qty is 0 and side empty where the
+// exchange doesn't report them.
+func (t *FrameTracker) Add(symbol string, price, qty float64, side string, ts int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	frames, ok := t.symbols[symbol]
+	if !ok {
+		for _, def := range timeframeDefs {
+			frames = append(frames, newTimeframe(def.window))
+		}
+		t.symbols[symbol] = frames
+	}
+	for _, f := range frames {
+		f.add(price, qty, side, ts)
+	}
+}
+
+// Stats returns every timeframe for a symbol keyed by name ("1m", "5m", ...)
+func (t *FrameTracker) Stats(symbol string, now time.Time) map[string]TimeframeStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make(map[string]TimeframeStats, len(timeframeDefs))
+	frames := t.symbols[symbol]
+	for i, def := range timeframeDefs {
+		if frames == nil {
+			out[def.name] = TimeframeStats{}
+			continue
+		}
+		out[def.name] = frameStats(symbol, frames[i], now)
+	}
+	return out
+}
+
+// Window returns one timeframe for a symbol by name, false when there is
+// no such timeframe
+func (t *FrameTracker) Window(symbol, name string, now time.Time) (TimeframeStats, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, def := range timeframeDefs {
+		if def.name != name {
+			continue
+		}
+		if frames := t.symbols[symbol]; frames != nil {
+			return frameStats(symbol, frames[i], now), true
+		}
+		return TimeframeStats{}, true
+	}
+	return TimeframeStats{}, false
+}
+
+// frameStats is a timeframe's stats with the average rounded like prices
+func frameStats(symbol string, f *timeframe, now time.Time) TimeframeStats {
+	stats := f.stats(now.UnixMilli())
+	stats.Average = roundPrice(symbol, stats.Average)
+	return stats
+}
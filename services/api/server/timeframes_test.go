@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeframeKeepsNewerLap(t *testing.T) {
+	f := newTimeframe(time.Minute) // 1s buckets
+	now := int64(10 * 60_000)
+	f.add(100, 1, sideBuy, now)
+	f.add(101, 2, sideSell, now+10)
+
+	// A trade a lap older maps to the same slot
+	f.add(50, 4, sideBuy, now-60_000)
+	got := f.stats(now + 20)
+	if got.Trades != 2 || got.Open != 100 || got.Close != 101 || got.Low != 100 {
+		t.Fatalf("older lap overwrote the bucket: %+v", got)
+	}
+	if got.Volume != 3 || got.BuyVolume != 1 || got.SellVolume != 2 {
+		t.Fatalf("volumes %v, %v bought, %v sold, want 3, 1 and 2", got.Volume, got.BuyVolume, got.SellVolume)
+	}
+
+	// A newer lap replaces it
+	f.add(120, 0, "", now+60_000)
+	got = f.stats(now + 60_000)
+	if got.Trades != 1 || got.Open != 120 {
+		t.Fatalf("newer lap didn't replace the bucket: %+v", got)
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Where Let's Encrypt's HTTP-01 challenges arrive, and plain HTTP requests
+// that are redirected to HTTPS
+const acmeHTTPAddr = ":80"
+
+// checkTLS validates the TLS settings of cfg and loads its certificate, or
+// returns nil when the API serves plain HTTP or gets certificates from Let's
+// Encrypt
+func checkTLS(cfg *Config) (*tls.Config, error) {
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, errors.New("TLS needs both a certificate and a key")
+	}
+	if cfg.TLSCert != "" && cfg.ACMEDomains != "" {
+		return nil, errors.New("TLS certificate and Let's Encrypt domains both set, use one")
+	}
+	if cfg.ACMEDomains != "" && cfg.ACMECacheDir == "" {
+		cfg.ACMECacheDir = "autocert"
+	}
+	if cfg.TLSCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("TLS certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// newACMEManager gets and renews certificates for cfg's ACMEDomains from
+// Let's Encrypt, or is nil without any
+func newACMEManager(cfg Config) *autocert.Manager {
+	var domains []string
+	for _, d := range strings.Split(cfg.ACMEDomains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	if len(domains) == 0 {
+		return nil
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cfg.ACMECacheDir),
+	}
+}
+
+// serve accepts connections on httpServer until it shuts down: over HTTPS
+// with the configured certificate, with certificates Let's Encrypt issues
+// for ACMEDomains, or over plain HTTP
+func (s *Server) serve(ctx context.Context, httpServer *http.Server) error {
+	switch {
+	case s.tlsConfig != nil:
+		httpServer.TLSConfig = s.tlsConfig
+		return httpServer.ListenAndServeTLS("", "")
+
+	case s.acme != nil:
+		// Certificates are requested over TLS-ALPN-01 on the HTTPS port
+		// itself; port 80 adds HTTP-01 and redirects, where it can be bound
+		challenges := &http.Server{Addr: acmeHTTPAddr, Handler: s.acme.HTTPHandler(nil)}
+		go func() {
+			if err := challenges.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Warning: not redirecting HTTP to HTTPS: %v", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			challenges.Close()
+		}()
+		httpServer.TLSConfig = s.acme.TLSConfig()
+		return httpServer.ListenAndServeTLS("", "")
+	}
+	return httpServer.ListenAndServe()
+}
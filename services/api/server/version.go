@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"api/store"
+)
+
+// Build information, set at link time, e.g.
+// go build -ldflags "-X api/server.Version=1.2.0 -X api/server.Commit=$(git rev-parse HEAD)"
+var (
+	Version   = "0.0.0-dev" // semantic version of the release
+	Commit    = ""          // git commit; read from the build's VCS stamp when unset
+	BuildDate = ""          // RFC 3339
+)
+
+// VersionInfo identifies the running API and what it was built and
+// configured with
+type VersionInfo struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	BuildDate string    `json:"build_date"`
+	GoVersion string    `json:"go_version"`
+	Features  Features  `json:"features"`
+	Protocols Protocols `json:"protocols"`
+}
+
+// Features are the optional parts of the pipeline in use
+type Features struct {
+	Database  string   `json:"database"`  // timescaledb, sqlite, bolt, or empty without history
+	Processor string   `json:"processor"` // cgo once processing has reported, else empty
+	Exchanges []string `json:"exchanges"` // ingestion's feeds, once it has reported
+	Redis     bool     `json:"redis"`
+}
+
+// Protocols are the wire protocol versions this API speaks
+type Protocols struct {
+	WebSocket []int    `json:"websocket"` // /ws?v= values
+	Formats   []string `json:"formats"`   // /ws?format= values
+	Schema    int      `json:"schema"`    // /api/schema version
+	JSONRPC   string   `json:"jsonrpc"`
+}
+
+// buildInfo fills in the commit and build date from the VCS stamp Go adds
+// to binaries built inside a git checkout, for builds without -ldflags
+func buildInfo() (commit, date string) {
+	commit, date = Commit, BuildDate
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return commit, date
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && commit == "":
+			commit = setting.Value
+		case setting.Key == "vcs.time" && date == "":
+			date = setting.Value
+		}
+	}
+	return commit, date
+}
+
+// versionInfo describes this instance
+func (s *Server) versionInfo() VersionInfo {
+	commit, date := buildInfo()
+	info := VersionInfo{
+		Version:   Version,
+		Commit:    commit,
+		BuildDate: date,
+		GoVersion: runtime.Version(),
+		Features: Features{
+			Exchanges: []string{},
+			Redis:     s.cluster != nil,
+		},
+		Protocols: Protocols{
+			Formats: []string{formatJSON, formatProtobuf},
+			Schema:  schemaVersion,
+			JSONRPC: "2.0",
+		},
+	}
+	for v := protocolV1; v <= latestProtocol; v++ {
+		info.Protocols.WebSocket = append(info.Protocols.WebSocket, v)
+	}
+
+	switch s.store.(type) {
+	case *store.PostgresStore:
+		info.Features.Database = "timescaledb"
+	case *store.SQLiteStore:
+		info.Features.Database = "sqlite"
+	case *store.BoltStore:
+		info.Features.Database = "bolt"
+	}
+	if s.processing.Load() != nil {
+		info.Features.Processor = "cgo"
+	}
+	if ing := s.ingestion.Load(); ing != nil && ing.Exchange != "" {
+		info.Features.Exchanges = strings.Split(ing.Exchange, ", ")
+	}
+	return info
+}
+
+// handleVersion reports the version, build and enabled features so clients
+// can check compatibility
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.versionInfo())
+}
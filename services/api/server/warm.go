@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Trades read per store query while warming candles
+const warmPageSize = 5000
+
+// warmCandles rebuilds a symbol's recent candles from stored trades the
+// first time it streams, so /api/candles has history right after a restart,
+// watchlist addition or symbol change instead of filling up one trade at a
+// time. Stored trades are all older than the first streamed one at until;
+// trades streaming meanwhile are aggregated as usual and the warm candles
+// are merged in underneath them.
+func (s *Server) warmCandles(symbol string, until time.Time) {
+	if s.store == nil || s.cfg.WarmCandles <= 0 {
+		return
+	}
+	if _, seen := s.warmed.LoadOrStore(symbol, true); seen {
+		return
+	}
+
+	s.writes.Add(1)
+	go func() {
+		defer s.writes.Done()
+		start := time.Now()
+		older := NewCandleAggregator()
+		q := HistoryQuery{
+			Symbol:    symbol,
+			From:      until.Add(-s.cfg.WarmCandles),
+			To:        until,
+			Limit:     warmPageSize,
+			Ascending: true,
+		}
+		count := 0
+		for {
+			trades, err := s.store.History(context.Background(), q)
+			if err != nil {
+				log.Printf("Candle warm-up for %s failed: %v", symbol, err)
+				return
+			}
+			for _, t := range trades {
+				older.Add(symbol, t.Price.Float64(), t.Qty, t.Side, t.Timestamp.UnixMilli())
+			}
+			count += len(trades)
+			if len(trades) < q.Limit {
+				break
+			}
+			next := nextCursor(q.Cursor, trades)
+			q.Cursor = &next
+		}
+		if count == 0 {
+			return
+		}
+		s.candles.Seed(symbol, older)
+		log.Printf("Warmed %s candles from %d stored trades in %v", symbol, count, time.Since(start).Round(time.Millisecond))
+	}()
+}
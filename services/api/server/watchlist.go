@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// How long a watchlist edit may take; added symbols are backfilled first
+const watchlistTimeout = 30 * time.Second
+
+// Changes to the streamed symbols are announced once they have been quiet
+// for subscriptionsQuiet, and at most subscriptionsMaxDelay after the first
+const (
+	subscriptionsQuiet    = 250 * time.Millisecond
+	subscriptionsMaxDelay = time.Second
+)
+
+// SubscriptionState is what ingestion streams: the selected symbol, the
+// watchlist, and which of them are paused
+type SubscriptionState struct {
+	Symbols   []string `json:"symbols"` // streamed, sorted
+	Selected  string   `json:"selected"`
+	Watchlist []string `json:"watchlist"`
+	Paused    []string `json:"paused"`
+}
+
+// subscriptionNotifier turns bursts of status.subscriptions reports, such
+// as a watchlist edit followed by a symbol change, into one
+// subscriptions_updated event listing what was added and removed overall
+type subscriptionNotifier struct {
+	hub *Hub
+
+	mu       sync.Mutex
+	last     *SubscriptionState // as last announced, nil until known
+	pending  *SubscriptionState
+	timer    *time.Timer
+	deadline time.Time
+}
+
+// baseline sets the state changes are announced against, unless a report
+// came first
+func (n *subscriptionNotifier) baseline(state SubscriptionState) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.last == nil && n.pending == nil {
+		n.last = &state
+	}
+}
+
+// update records a report and (re)arms the announcement
+func (n *subscriptionNotifier) update(state SubscriptionState) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.pending = &state
+	now := time.Now()
+	if n.timer == nil {
+		n.deadline = now.Add(subscriptionsMaxDelay)
+		n.timer = time.AfterFunc(subscriptionsQuiet, n.flush)
+		return
+	}
+	n.timer.Reset(min(subscriptionsQuiet, n.deadline.Sub(now)))
+}
+
+func (n *subscriptionNotifier) flush() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.timer = nil
+	state := n.pending
+	if state == nil {
+		return
+	}
+	n.pending = nil
+
+	// The first state known, e.g. when ingestion starts after the API, is
+	// where changes start from rather than a change
+	if n.last == nil {
+		n.last = state
+		return
+	}
+	prev := *n.last
+	n.last = state
+	added := diffSymbols(state.Symbols, prev.Symbols)
+	removed := diffSymbols(prev.Symbols, state.Symbols)
+	if len(added) == 0 && len(removed) == 0 && slices.Equal(state.Paused, prev.Paused) &&
+		slices.Equal(state.Watchlist, prev.Watchlist) {
+		return
+	}
+
+	data, _ := json.Marshal(struct {
+		Type    string   `json:"type"`
+		Added   []string `json:"added"`
+		Removed []string `json:"removed"`
+		SubscriptionState
+	}{"subscriptions_updated", added, removed, *state})
+	n.hub.Broadcast(event{control: data})
+}
+
+// diffSymbols returns the symbols of a missing from b
+func diffSymbols(a, b []string) []string {
+	out := []string{}
+	for _, sym := range a {
+		if !slices.Contains(b, sym) {
+			out = append(out, sym)
+		}
+	}
+	return out
+}
+
+// watchSubscriptions feeds ingestion's reports to the notifier, starting
+// from the state ingestion reports now
+func (s *Server) watchSubscriptions(nc *nats.Conn) {
+	nc.Subscribe("status.subscriptions", func(msg *nats.Msg) {
+		var state SubscriptionState
+		if err := json.Unmarshal(msg.Data, &state); err != nil {
+			return
+		}
+		s.subsNotifier.update(state)
+	})
+	go func() {
+		if state, _, err := s.requestWatchlist(nil, nil); err == nil {
+			s.subsNotifier.baseline(state)
+		}
+	}()
+}
+
+// requestWatchlist asks ingestion to edit its watchlist and returns the
+// resulting state, with the reason when ingestion refused the edit. With
+// nothing to change it just reports.
+func (s *Server) requestWatchlist(add, remove []string) (SubscriptionState, string, error) {
+	body, _ := json.Marshal(map[string][]string{"add": add, "remove": remove})
+	timeout := configTimeout
+	if len(add) > 0 {
+		timeout = watchlistTimeout
+	}
+	reply, err := s.nc.Request("control.watchlist", body, timeout)
+	if err != nil {
+		return SubscriptionState{}, "", err
+	}
+	var result struct {
+		State SubscriptionState `json:"state"`
+		Error string            `json:"error"`
+	}
+	err = json.Unmarshal(reply.Data, &result)
+	return result.State, result.Error, err
+}
+
+// handleWatchlist reports (GET) or edits (POST, admin) the symbols
+// ingestion streams besides the selected one. An edit is applied at once:
+// clients see a single subscriptions_updated event however many symbols
+// it adds or removes.
+func (s *Server) handleWatchlist(w http.ResponseWriter, r *http.Request) {
+	var add, remove []string
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !s.authorizeAdmin(w, r) {
+			return
+		}
+		var req struct {
+			Add    []string `json:"add"`
+			Remove []string `json:"remove"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		for _, sym := range req.Add {
+			sym = strings.ToLower(sym)
+			if _, ok := lookupMarket(sym); !ok {
+				http.Error(w, "Unknown symbol "+sym, http.StatusNotFound)
+				return
+			}
+			add = append(add, sym)
+		}
+		for _, sym := range req.Remove {
+			remove = append(remove, strings.ToLower(sym))
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state, refused, err := s.requestWatchlist(add, remove)
+	if err == nats.ErrNoResponders || err == nats.ErrTimeout {
+		http.Error(w, "Ingestion service not available", http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		log.Printf("Watchlist request error: %v", err)
+		http.Error(w, "Failed to reach ingestion service", http.StatusInternalServerError)
+		return
+	}
+	if refused != "" {
+		http.Error(w, refused, http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
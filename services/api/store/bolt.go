@@ -0,0 +1,318 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Trades deleted per transaction when pruning old data
+const boltPruneBatch = 10000
+
+// Buckets that aren't a symbol's trades start with an underscore, which no
+// symbol does, and are left alone by pruning
+const (
+	boltOrdersBucket  = "_paper_orders"
+	boltSignalsBucket = "_signals"
+)
+
+// BoltStore keeps trades in an embedded bbolt file, one bucket per symbol.
+// Keys are the big-endian trade time in nanoseconds followed by a sequence
+// number, so cursor order is time order.
+type BoltStore struct {
+	db        *bolt.DB
+	retention time.Duration
+	done      chan struct{}
+	writes    *writeBehind
+}
+
+// NewBoltStore opens (or creates) the database file and starts pruning trades
+// older than retention
+func NewBoltStore(path string, retention time.Duration) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &BoltStore{db: db, retention: retention, done: make(chan struct{})}
+	s.writes = newWriteBehind(s.InsertBatch)
+	go s.pruneLoop()
+	return s, nil
+}
+
+func (s *BoltStore) Insert(ctx context.Context, t Trade) error {
+	return s.writes.add(t)
+}
+
+// InsertBatch stores many trades in one transaction
+func (s *BoltStore) InsertBatch(ctx context.Context, trades []Trade) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, t := range trades {
+			if err := boltPutTrade(tx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func boltPutTrade(tx *bolt.Tx, t Trade) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(t.Symbol))
+	if err != nil {
+		return err
+	}
+	seq, _ := b.NextSequence()
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(t.Timestamp.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+
+	return b.Put(key, boltTradeValue(t))
+}
+
+// Bolt trade values: the price, then the size and a side byte. Trades
+// stored before sizes were recorded have only the price.
+var boltSides = []string{"", SideBuy, SideSell}
+
+func boltTradeValue(t Trade) []byte {
+	val := make([]byte, 17)
+	binary.BigEndian.PutUint64(val, math.Float64bits(t.Price.Float64()))
+	binary.BigEndian.PutUint64(val[8:], math.Float64bits(t.Qty))
+	val[16] = byte(max(slices.Index(boltSides, t.Side), 0))
+	return val
+}
+
+func boltTrade(symbol string, ts int64, val []byte) Trade {
+	t := Trade{
+		Symbol:    symbol,
+		Price:     DecimalOf(math.Float64frombits(binary.BigEndian.Uint64(val))),
+		Timestamp: time.Unix(0, ts),
+	}
+	if len(val) >= 17 {
+		t.Qty = math.Float64frombits(binary.BigEndian.Uint64(val[8:]))
+		if int(val[16]) < len(boltSides) {
+			t.Side = boltSides[val[16]]
+		}
+	}
+	return t
+}
+
+func (s *BoltStore) History(ctx context.Context, q HistoryQuery) ([]Trade, error) {
+	// Time bounds as nanoseconds: lo inclusive, hi exclusive
+	lo, hi := int64(0), int64(math.MaxInt64)
+	if !q.From.IsZero() {
+		lo = q.From.UnixNano()
+	}
+	if !q.To.IsZero() {
+		hi = q.To.UnixNano()
+	}
+	skip, skipAt := 0, int64(0)
+	if c := q.Cursor; c != nil {
+		if q.Ascending {
+			lo = max(lo, c.Time.UnixNano())
+		} else {
+			hi = min(hi, c.Time.UnixNano()+1)
+		}
+		skip, skipAt = c.Skip, c.Time.UnixNano()
+	}
+
+	var trades []Trade
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(q.Symbol))
+		if b == nil || lo >= hi {
+			return nil
+		}
+		c := b.Cursor()
+
+		var k, v []byte
+		step := c.Next
+		if q.Ascending {
+			k, v = c.Seek(boltTimeKey(lo))
+		} else {
+			// Start at the last key before hi
+			step = c.Prev
+			if k, v = c.Seek(boltTimeKey(hi)); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+
+		for ; k != nil && len(trades) < q.Limit; k, v = step() {
+			ts := int64(binary.BigEndian.Uint64(k[:8]))
+			if ts < lo || ts >= hi {
+				break
+			}
+			// The cursor's time is first in iteration order; drop the trades
+			// an earlier page already returned
+			if skip > 0 && ts == skipAt {
+				skip--
+				continue
+			}
+			trades = append(trades, boltTrade(q.Symbol, ts, v))
+		}
+		return nil
+	})
+	return trades, err
+}
+
+// InsertOrder stores the order as JSON under its big-endian ID
+func (s *BoltStore) InsertOrder(ctx context.Context, o PaperOrder) error {
+	val, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(boltOrdersBucket))
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, uint64(o.ID))
+		return b.Put(key, val)
+	})
+}
+
+func (s *BoltStore) Orders(ctx context.Context) ([]PaperOrder, error) {
+	var orders []PaperOrder
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(boltOrdersBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var o PaperOrder
+			if err := json.Unmarshal(v, &o); err != nil {
+				return err
+			}
+			orders = append(orders, o)
+			return nil
+		})
+	})
+	return orders, err
+}
+
+// InsertSignal stores the signal as JSON under a time key like a trade's
+func (s *BoltStore) InsertSignal(ctx context.Context, sig Signal) error {
+	val, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(boltSignalsBucket))
+		if err != nil {
+			return err
+		}
+		seq, _ := b.NextSequence()
+		key := boltTimeKey(sig.Time.UnixNano())
+		binary.BigEndian.PutUint64(key[8:], seq)
+		return b.Put(key, val)
+	})
+}
+
+// Signals walks every symbol's signals back from the newest; they are few
+// enough that a bucket per symbol isn't worth it
+func (s *BoltStore) Signals(ctx context.Context, symbol string, limit int) ([]Signal, error) {
+	var signals []Signal
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(boltSignalsBucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && len(signals) < limit; k, v = c.Prev() {
+			var sig Signal
+			if err := json.Unmarshal(v, &sig); err != nil {
+				return err
+			}
+			if sig.Symbol == symbol {
+				signals = append(signals, sig)
+			}
+		}
+		return nil
+	})
+	return signals, err
+}
+
+// boltTimeKey is the smallest key at or after a time
+func boltTimeKey(ns int64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(ns))
+	return key
+}
+
+// WriteQueue reports the write-behind buffer
+func (s *BoltStore) WriteQueue() WriteQueueStatus {
+	return s.writes.Status()
+}
+
+// Close writes the queued trades before closing the file
+func (s *BoltStore) Close() {
+	close(s.done)
+	s.writes.close()
+	s.db.Close()
+}
+
+func (s *BoltStore) pruneLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if _, err := s.prune(time.Now().Add(-s.retention)); err != nil {
+				log.Printf("Bolt prune error: %v", err)
+			}
+		}
+	}
+}
+
+// DeleteTradesBefore deletes every symbol's trades older than before
+func (s *BoltStore) DeleteTradesBefore(ctx context.Context, before time.Time) (int64, error) {
+	return s.prune(before)
+}
+
+// prune deletes trades older than cutoff from every symbol bucket and
+// returns how many
+func (s *BoltStore) prune(cutoff time.Time) (int64, error) {
+	limit := make([]byte, 8)
+	binary.BigEndian.PutUint64(limit, uint64(cutoff.UnixNano()))
+
+	var total int64
+	for {
+		deleted := 0
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				if name[0] == '_' {
+					return nil
+				}
+				c := b.Cursor()
+				for k, _ := c.First(); k != nil && deleted < boltPruneBatch; k, _ = c.First() {
+					if string(k[:8]) >= string(limit) {
+						break
+					}
+					if err := c.Delete(); err != nil {
+						return err
+					}
+					deleted++
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return total, err
+		}
+		total += int64(deleted)
+		if deleted < boltPruneBatch {
+			return total, nil
+		}
+	}
+}
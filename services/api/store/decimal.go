@@ -0,0 +1,128 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Decimal is a trade price in fixed point, a whole number of 1e-8 quote
+// units. Ingestion reads it from the exchange's text and processing passes it
+// on as price_e8, so trade prices reach clients exactly as quoted. JSON
+// encodes it as a plain decimal number, e.g. 0.00001234 where a float64
+// would give 1.234e-05, or as a string from the API with ?prices=string.
+type Decimal int64
+
+const (
+	decimalPlaces = 8 // the most any supported exchange quotes
+	decimalScale  = 100_000_000
+)
+
+// DecimalOf converts a float price, e.g. one read back from a store, through
+// the shortest decimal that reads back as it. That is the price as quoted for
+// any price of up to 15 significant digits.
+func DecimalOf(f float64) Decimal {
+	d, err := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ParseDecimal reads plain decimal text, e.g. "-0.00001234", rounding half
+// away from zero beyond 8 decimals
+func ParseDecimal(s string) (Decimal, error) {
+	text, neg := strings.CutPrefix(s, "-")
+	whole, frac, _ := strings.Cut(text, ".")
+	if whole == "" || strings.Trim(whole+frac, "0123456789") != "" {
+		return 0, errors.New("invalid decimal " + strconv.Quote(s))
+	}
+	roundUp := false
+	if len(frac) > decimalPlaces {
+		roundUp = frac[decimalPlaces] >= '5'
+		frac = frac[:decimalPlaces]
+	}
+	frac += strings.Repeat("0", decimalPlaces-len(frac))
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || (roundUp && n == math.MaxInt64) {
+		return 0, errors.New("decimal out of range " + strconv.Quote(s))
+	}
+	if roundUp {
+		n++
+	}
+	if neg {
+		n = -n
+	}
+	return Decimal(n), nil
+}
+
+func (d Decimal) Float64() float64 {
+	return float64(d) / decimalScale
+}
+
+// Round rounds to places decimals, half away from zero, leaving d as it is
+// where the result wouldn't fit
+func (d Decimal) Round(places int) Decimal {
+	if places < 0 || places >= decimalPlaces {
+		return d
+	}
+	unit := int64(1)
+	for range decimalPlaces - places {
+		unit *= 10
+	}
+	n := int64(d)
+	q, r := n/unit, n%unit
+	if r >= unit-r {
+		q++
+	} else if -r >= unit+r {
+		q--
+	}
+	if q > math.MaxInt64/unit || q < math.MinInt64/unit {
+		return d
+	}
+	return Decimal(q * unit)
+}
+
+// String formats the price with as many decimals as it has, e.g. 0.1 or
+// 97123.45
+func (d Decimal) String() string {
+	n := int64(d)
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	whole, frac := n/decimalScale, n%decimalScale
+	if frac == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+	digits := strconv.FormatInt(frac+decimalScale, 10)[1:] // zero padded
+	return sign + strconv.FormatInt(whole, 10) + "." + strings.TrimRight(digits, "0")
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads a number or a decimal string, so responses sent with
+// either price format read back, e.g. a bundle being imported
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	v, err := ParseDecimal(text)
+	if err != nil {
+		// Exponents and the like, from other encoders
+		f, ferr := strconv.ParseFloat(text, 64)
+		if ferr != nil {
+			return err
+		}
+		v = DecimalOf(f)
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		want    Decimal
+		wantErr bool
+	}{
+		{text: "0", want: 0},
+		{text: "0.00001234", want: 1234},
+		{text: "97123.45", want: 9712345000000},
+		{text: "5.", want: 500000000},
+		{text: "-1.5", want: -150000000},
+		{text: "1.234567894", want: 123456789},
+		{text: "1.234567895", want: 123456790},   // half up
+		{text: "-1.234567895", want: -123456790}, // half away from zero
+		{text: "0.000000004", want: 0},
+		{text: "92233720368.54775807", want: math.MaxInt64},
+		{text: "92233720368.547758075", wantErr: true}, // rounds past the top
+		{text: "92233720368.54775808", wantErr: true},
+		{text: ".", wantErr: true},
+		{text: ".5", wantErr: true},
+		{text: "", wantErr: true},
+		{text: "-", wantErr: true},
+		{text: "1e-7", wantErr: true},
+		{text: "1.2.3", wantErr: true},
+		{text: "+1", wantErr: true},
+	} {
+		got, err := ParseDecimal(tc.text)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) = %d, want an error", tc.text, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseDecimal(%q) = %d, %v, want %d", tc.text, got, err, tc.want)
+		}
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	for _, tc := range []struct {
+		d      Decimal
+		places int
+		want   Decimal
+	}{
+		{123456789, 2, 123000000},
+		{124900000, 1, 120000000},
+		{125000000, 1, 130000000},
+		{-125000000, 1, -130000000},
+		{-124900000, 1, -120000000},
+		{123456789, 8, 123456789},
+		{123456789, -1, 123456789},
+		{150000000, 0, 200000000},
+		{math.MaxInt64, 0, math.MaxInt64}, // rounding up wouldn't fit
+	} {
+		if got := tc.d.Round(tc.places); got != tc.want {
+			t.Errorf("%d.Round(%d) = %d, want %d", tc.d, tc.places, got, tc.want)
+		}
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	for _, tc := range []struct {
+		d    Decimal
+		want string
+	}{
+		{0, "0"},
+		{100000000, "1"},
+		{1234, "0.00001234"},
+		{9712345000000, "97123.45"},
+		{-150000000, "-1.5"},
+		{math.MaxInt64, "92233720368.54775807"},
+	} {
+		if got := tc.d.String(); got != tc.want {
+			t.Errorf("Decimal(%d).String() = %q, want %q", int64(tc.d), got, tc.want)
+		}
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	data, err := json.Marshal(map[string]Decimal{"price": 1234})
+	if err != nil || string(data) != `{"price":0.00001234}` {
+		t.Fatalf("got %s, %v", data, err)
+	}
+	for _, text := range []string{`0.00001234`, `"0.00001234"`, `1.234e-05`} {
+		var d Decimal
+		if err := json.Unmarshal([]byte(text), &d); err != nil || d != 1234 {
+			t.Errorf("%s read as %d, %v, want 1234", text, d, err)
+		}
+	}
+	d := Decimal(5)
+	if err := json.Unmarshal([]byte(`null`), &d); err != nil || d != 5 {
+		t.Errorf("null read as %d, %v, want it left alone", d, err)
+	}
+	if err := json.Unmarshal([]byte(`"abc"`), &d); err == nil {
+		t.Error("abc read as a decimal")
+	}
+}
+
+func TestDecimalOf(t *testing.T) {
+	for _, tc := range []struct {
+		f    float64
+		want Decimal
+	}{
+		{0.1 + 0.2, 30000000}, // 0.30000000000000004
+		{97123.45, 9712345000000},
+		{0.00001234, 1234},
+		{1e300, 0}, // doesn't fit
+	} {
+		if got := DecimalOf(tc.f); got != tc.want {
+			t.Errorf("DecimalOf(%v) = %d, want %d", tc.f, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps trades in a TimescaleDB hypertable. Live trades go
+// through a write-behind buffer and are copied in batches, since one
+// INSERT per trade falls behind at high trade rates.
+type PostgresStore struct {
+	db     *pgxpool.Pool
+	writes *writeBehind
+	// Candle views with volume columns; views created before trades had a
+	// size report zero volume
+	viewVolume map[string]bool
+}
+
+// NewPostgresStore connects to the database and prepares the schema
+func NewPostgresStore(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &PostgresStore{db: db, viewVolume: make(map[string]bool)}
+	if err := s.initSchema(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.writes = newWriteBehind(s.InsertBatch)
+	return s, nil
+}
+
+func (s *PostgresStore) initSchema(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS trades (
+			id BIGSERIAL,
+			time TIMESTAMPTZ NOT NULL,
+			symbol TEXT NOT NULL,
+			price DOUBLE PRECISION NOT NULL,
+			qty DOUBLE PRECISION,
+			side TEXT
+		)`,
+		`SELECT create_hypertable('trades', 'time', chunk_time_interval => INTERVAL '` + pgChunkInterval + `', if_not_exists => TRUE)`,
+		// Tables created with the default week long chunks switch from
+		// their next chunk on
+		`SELECT set_chunk_time_interval('trades', INTERVAL '` + pgChunkInterval + `')`,
+		// Tables created before trades had a size and side
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS qty DOUBLE PRECISION`,
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS side TEXT`,
+		// Tables created before trades were numbered; the trades already
+		// stored keep a NULL id
+		`ALTER TABLE trades ADD COLUMN IF NOT EXISTS id BIGINT`,
+		`CREATE SEQUENCE IF NOT EXISTS trades_id_seq OWNED BY trades.id`,
+		`ALTER TABLE trades ALTER COLUMN id SET DEFAULT nextval('trades_id_seq')`,
+		// History reads one symbol's trades in time and then id order; each
+		// chunk gets its own copy of the index, so queries touch only the
+		// chunks in range. It replaces the index on time alone.
+		`CREATE INDEX IF NOT EXISTS trades_symbol_time_id_idx ON trades (symbol, time DESC, id DESC NULLS LAST)`,
+		`DROP INDEX IF EXISTS trades_symbol_time_idx`,
+		`CREATE TABLE IF NOT EXISTS paper_orders (
+			id BIGINT PRIMARY KEY,
+			time TIMESTAMPTZ NOT NULL,
+			symbol TEXT NOT NULL,
+			side TEXT NOT NULL,
+			quantity DOUBLE PRECISION NOT NULL,
+			price DOUBLE PRECISION NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS signals (
+			time TIMESTAMPTZ NOT NULL,
+			strategy TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			side TEXT NOT NULL,
+			price DOUBLE PRECISION NOT NULL,
+			reason TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS signals_symbol_time_idx ON signals (symbol, time DESC)`,
+	}
+	// Candles are aggregated continuously, and queries add the trades the
+	// refresh policy hasn't reached yet
+	for _, view := range pgCandleViews {
+		stmts = append(stmts,
+			`CREATE MATERIALIZED VIEW IF NOT EXISTS `+view.name+`
+			WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+			SELECT time_bucket('`+view.bucket+`', time) AS bucket, symbol,
+				first(price, time) AS open, max(price) AS high, min(price) AS low,
+				last(price, time) AS close, count(*) AS trades,
+				coalesce(sum(qty), 0) AS volume,
+				coalesce(sum(CASE WHEN side = 'buy' THEN qty END), 0) AS buy_volume,
+				coalesce(sum(CASE WHEN side = 'sell' THEN qty END), 0) AS sell_volume
+			FROM trades GROUP BY bucket, symbol
+			WITH NO DATA`,
+			`SELECT add_continuous_aggregate_policy('`+view.name+`',
+				start_offset => INTERVAL '`+view.refresh+`',
+				end_offset => INTERVAL '`+view.bucket+`',
+				schedule_interval => INTERVAL '`+view.bucket+`',
+				if_not_exists => TRUE)`)
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+	}
+
+	// A continuous aggregate can't gain columns, and dropping one would lose
+	// candles whose trades retention already deleted, so older views are
+	// kept without volume
+	for _, view := range pgCandleViews {
+		var hasVolume bool
+		err := s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM information_schema.columns
+			WHERE table_name = $1 AND column_name = 'volume')`, view.name).Scan(&hasVolume)
+		if err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		s.viewVolume[view.name] = hasVolume
+		if !hasVolume {
+			log.Printf("Warning: %s predates trade volume, stored candles report none; drop it to rebuild", view.name)
+		}
+	}
+	return nil
+}
+
+// Trades are partitioned into chunks of one day. A chunk of the busiest
+// symbols and its index fit in memory, and retention and compression, which
+// work a chunk at a time, drop or compress a day at a time rather than a week.
+const pgChunkInterval = "1 day"
+
+// Continuous aggregates of the trades by candle interval. Each refresh
+// recomputes the buckets within refresh of now, which covers late trades.
+var pgCandleViews = map[string]struct{ name, bucket, refresh string }{
+	"1m": {"candles_1m", "1 minute", "1 hour"},
+	"1h": {"candles_1h", "1 hour", "1 day"},
+	"1d": {"candles_1d", "1 day", "7 days"},
+}
+
+// StoredCandles reads the continuous aggregate of interval
+func (s *PostgresStore) StoredCandles(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]Candle, bool, error) {
+	view, ok := pgCandleViews[interval]
+	if !ok {
+		return nil, false, nil
+	}
+	args := []any{symbol, to, limit}
+	volume := `volume, buy_volume, sell_volume`
+	if !s.viewVolume[view.name] {
+		volume = `0::float8, 0::float8, 0::float8`
+	}
+	query := `SELECT bucket, open, high, low, close, trades, ` + volume + ` FROM ` + view.name + ` WHERE symbol = $1 AND bucket < $2`
+	if !from.IsZero() {
+		query += ` AND bucket >= $4`
+		args = append(args, from)
+	}
+	query += ` ORDER BY bucket DESC LIMIT $3`
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, true, err
+	}
+	defer rows.Close()
+
+	var candles []Candle
+	for rows.Next() {
+		var c Candle
+		if err := rows.Scan(&c.Time, &c.Open, &c.High, &c.Low, &c.Close, &c.Trades, &c.Volume, &c.BuyVolume, &c.SellVolume); err != nil {
+			return nil, true, err
+		}
+		c.Time = c.Time.UTC()
+		candles = append(candles, c)
+	}
+	slices.Reverse(candles)
+	return candles, true, rows.Err()
+}
+
+// Insert queues t; it is written with the next batch, within
+// writeFlushInterval
+func (s *PostgresStore) Insert(ctx context.Context, t Trade) error {
+	return s.writes.add(t)
+}
+
+// InsertBatch copies trades into the table at once
+func (s *PostgresStore) InsertBatch(ctx context.Context, trades []Trade) error {
+	_, err := s.db.CopyFrom(ctx, pgx.Identifier{"trades"}, []string{"time", "symbol", "price", "qty", "side"},
+		pgx.CopyFromSlice(len(trades), func(i int) ([]any, error) {
+			t := trades[i]
+			return []any{t.Timestamp, t.Symbol, t.Price.Float64(), nullIfZero(t.Qty), nullIfZero(t.Side)}, nil
+		}))
+	return err
+}
+
+// DeleteTradesBefore deletes every symbol's trades older than before.
+// Candles already aggregated from them are kept.
+func (s *PostgresStore) DeleteTradesBefore(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.db.Exec(ctx, "DELETE FROM trades WHERE time < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SetPolicies makes TimescaleDB drop trade chunks older than retention and
+// compress those older than compressAfter, replacing earlier settings. Zero
+// removes a policy; chunks already compressed stay compressed.
+func (s *PostgresStore) SetPolicies(ctx context.Context, retention, compressAfter time.Duration) error {
+	exec := func(sql string, args ...any) error {
+		if _, err := s.db.Exec(ctx, sql, args...); err != nil {
+			return fmt.Errorf("set policies: %w", err)
+		}
+		return nil
+	}
+	if err := exec(`SELECT remove_retention_policy('trades', if_exists => TRUE)`); err != nil {
+		return err
+	}
+	if err := exec(`SELECT remove_compression_policy('trades', if_exists => TRUE)`); err != nil {
+		return err
+	}
+	if compressAfter > 0 {
+		// Segmented by symbol and ordered the way history reads them
+		err := exec(`ALTER TABLE trades SET (timescaledb.compress,
+			timescaledb.compress_segmentby = 'symbol',
+			timescaledb.compress_orderby = 'time DESC')`)
+		if err != nil {
+			return err
+		}
+		if err := exec(`SELECT add_compression_policy('trades', $1::interval)`, compressAfter); err != nil {
+			return err
+		}
+	}
+	if retention > 0 {
+		return exec(`SELECT add_retention_policy('trades', $1::interval)`, retention)
+	}
+	return nil
+}
+
+// WriteQueue reports the write-behind buffer
+func (s *PostgresStore) WriteQueue() WriteQueueStatus {
+	return s.writes.Status()
+}
+
+func (s *PostgresStore) History(ctx context.Context, q HistoryQuery) ([]Trade, error) {
+	args := []any{q.Symbol}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	query := `SELECT symbol, price, coalesce(qty, 0), coalesce(side, ''), time, coalesce(id, 0) FROM trades WHERE symbol = $1`
+	if !q.From.IsZero() {
+		query += ` AND time >= ` + arg(q.From)
+	}
+	if !q.To.IsZero() {
+		query += ` AND time < ` + arg(q.To)
+	}
+	// Trades stored before ids have a NULL one, which sorts before every id
+	order, idOrder, cmp, after := "DESC", "DESC NULLS LAST", "<=", "<"
+	if q.Ascending {
+		order, idOrder, cmp, after = "ASC", "ASC NULLS FIRST", ">=", ">"
+	}
+	// Pages continue strictly after the id of the last trade, which is
+	// unique, so trades at the same time are neither repeated nor skipped.
+	// Cursors ending on a trade stored before ids skip by count instead.
+	// Either way the plain bound on time lets TimescaleDB skip chunks and
+	// range scan the index.
+	if c := q.Cursor; c != nil {
+		t := arg(c.Time)
+		query += ` AND time ` + cmp + ` ` + t
+		if c.ID != 0 {
+			query += ` AND (time ` + after + ` ` + t + ` OR id ` + after + ` ` + arg(c.ID)
+			if !q.Ascending {
+				query += ` OR id IS NULL`
+			}
+			query += `)`
+		}
+	}
+	// price orders the trades without an id among themselves
+	query += ` ORDER BY time ` + order + `, id ` + idOrder + `, price ` + order
+	if c := q.Cursor; c != nil && c.ID == 0 {
+		query += ` OFFSET ` + arg(c.Skip)
+	}
+	query += ` LIMIT ` + arg(q.Limit)
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []Trade
+	for rows.Next() {
+		var t Trade
+		var price float64
+		if err := rows.Scan(&t.Symbol, &price, &t.Qty, &t.Side, &t.Timestamp, &t.ID); err != nil {
+			return nil, err
+		}
+		t.Price = DecimalOf(price)
+		trades = append(trades, t)
+	}
+	return trades, rows.Err()
+}
+
+func (s *PostgresStore) InsertOrder(ctx context.Context, o PaperOrder) error {
+	_, err := s.db.Exec(ctx,
+		"INSERT INTO paper_orders (id, time, symbol, side, quantity, price) VALUES ($1, $2, $3, $4, $5, $6)",
+		o.ID, o.Time, o.Symbol, o.Side, o.Quantity, o.Price)
+	return err
+}
+
+func (s *PostgresStore) Orders(ctx context.Context) ([]PaperOrder, error) {
+	rows, err := s.db.Query(ctx, "SELECT id, time, symbol, side, quantity, price FROM paper_orders ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []PaperOrder
+	for rows.Next() {
+		var o PaperOrder
+		if err := rows.Scan(&o.ID, &o.Time, &o.Symbol, &o.Side, &o.Quantity, &o.Price); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (s *PostgresStore) InsertSignal(ctx context.Context, sig Signal) error {
+	_, err := s.db.Exec(ctx,
+		"INSERT INTO signals (time, strategy, symbol, side, price, reason) VALUES ($1, $2, $3, $4, $5, $6)",
+		sig.Time, sig.Strategy, sig.Symbol, sig.Side, sig.Price, sig.Reason)
+	return err
+}
+
+func (s *PostgresStore) Signals(ctx context.Context, symbol string, limit int) ([]Signal, error) {
+	rows, err := s.db.Query(ctx,
+		"SELECT time, strategy, symbol, side, price, reason FROM signals WHERE symbol = $1 ORDER BY time DESC LIMIT $2",
+		symbol, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var signals []Signal
+	for rows.Next() {
+		var sig Signal
+		if err := rows.Scan(&sig.Time, &sig.Strategy, &sig.Symbol, &sig.Side, &sig.Price, &sig.Reason); err != nil {
+			return nil, err
+		}
+		signals = append(signals, sig)
+	}
+	return signals, rows.Err()
+}
+
+// Close writes the queued trades before disconnecting
+func (s *PostgresStore) Close() {
+	s.writes.close()
+	s.db.Close()
+}
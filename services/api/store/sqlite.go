@@ -0,0 +1,253 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore keeps trades in a local SQLite file, for trying the project
+// without TimescaleDB. Times are stored as unix nanoseconds, like the bolt
+// store's keys.
+type SQLiteStore struct {
+	db     *sql.DB
+	writes *writeBehind
+}
+
+// SQLitePath returns the file of a sqlite:// database URL, and false for
+// any other URL
+func SQLitePath(url string) (string, bool) {
+	return strings.CutPrefix(url, "sqlite://")
+}
+
+// NewSQLiteStore opens (or creates) the database file and prepares the
+// schema
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite:// URL without a file")
+	}
+	// WAL lets history reads run alongside the trade inserts, which wait
+	// for each other rather than failing while one holds the write lock
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStore{db: db}
+	if err := s.initSchema(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.writes = newWriteBehind(s.InsertBatch)
+	return s, nil
+}
+
+func (s *SQLiteStore) initSchema(ctx context.Context) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS trades (
+			time INTEGER NOT NULL,
+			symbol TEXT NOT NULL,
+			price REAL NOT NULL,
+			qty REAL,
+			side TEXT
+		)`,
+		// price is history's tiebreak, so the index hands back pages in
+		// order without sorting
+		`CREATE INDEX IF NOT EXISTS trades_symbol_time_price_idx ON trades (symbol, time DESC, price DESC)`,
+		`DROP INDEX IF EXISTS trades_symbol_time_idx`,
+		`CREATE TABLE IF NOT EXISTS paper_orders (
+			id INTEGER PRIMARY KEY,
+			time INTEGER NOT NULL,
+			symbol TEXT NOT NULL,
+			side TEXT NOT NULL,
+			quantity REAL NOT NULL,
+			price REAL NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS signals (
+			time INTEGER NOT NULL,
+			strategy TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			side TEXT NOT NULL,
+			price REAL NOT NULL,
+			reason TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS signals_symbol_time_idx ON signals (symbol, time DESC)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+	}
+
+	// Files created before trades had a size and side
+	for _, column := range []string{"qty REAL", "side TEXT"} {
+		name, _, _ := strings.Cut(column, " ")
+		var exists bool
+		err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pragma_table_info('trades') WHERE name = ?)", name).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, "ALTER TABLE trades ADD COLUMN "+column); err != nil {
+			return fmt.Errorf("init schema: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Insert(ctx context.Context, t Trade) error {
+	return s.writes.add(t)
+}
+
+// InsertBatch stores many trades in one transaction
+func (s *SQLiteStore) InsertBatch(ctx context.Context, trades []Trade) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO trades (time, symbol, price, qty, side) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, t := range trades {
+		if _, err := stmt.ExecContext(ctx, t.Timestamp.UnixNano(), t.Symbol, t.Price.Float64(), nullIfZero(t.Qty), nullIfZero(t.Side)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) History(ctx context.Context, q HistoryQuery) ([]Trade, error) {
+	args := []any{q.Symbol}
+	query := `SELECT symbol, price, coalesce(qty, 0), coalesce(side, ''), time FROM trades WHERE symbol = ?`
+	if !q.From.IsZero() {
+		query += ` AND time >= ?`
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		query += ` AND time < ?`
+		args = append(args, q.To.UnixNano())
+	}
+	order, cmp := "DESC", "<="
+	if q.Ascending {
+		order, cmp = "ASC", ">="
+	}
+	if q.Cursor != nil {
+		query += ` AND time ` + cmp + ` ?`
+		args = append(args, q.Cursor.Time.UnixNano())
+	}
+	// price breaks ties between trades at the same time
+	query += ` ORDER BY time ` + order + `, price ` + order + ` LIMIT ?`
+	args = append(args, q.Limit)
+	if q.Cursor != nil {
+		query += ` OFFSET ?`
+		args = append(args, q.Cursor.Skip)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []Trade
+	for rows.Next() {
+		var t Trade
+		var ts int64
+		var price float64
+		if err := rows.Scan(&t.Symbol, &price, &t.Qty, &t.Side, &ts); err != nil {
+			return nil, err
+		}
+		t.Price, t.Timestamp = DecimalOf(price), time.Unix(0, ts)
+		trades = append(trades, t)
+	}
+	return trades, rows.Err()
+}
+
+// DeleteTradesBefore deletes every symbol's trades older than before
+func (s *SQLiteStore) DeleteTradesBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM trades WHERE time < ?", before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLiteStore) InsertOrder(ctx context.Context, o PaperOrder) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO paper_orders (id, time, symbol, side, quantity, price) VALUES (?, ?, ?, ?, ?, ?)",
+		o.ID, o.Time.UnixNano(), o.Symbol, o.Side, o.Quantity, o.Price)
+	return err
+}
+
+func (s *SQLiteStore) Orders(ctx context.Context) ([]PaperOrder, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, time, symbol, side, quantity, price FROM paper_orders ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []PaperOrder
+	for rows.Next() {
+		var o PaperOrder
+		var ts int64
+		if err := rows.Scan(&o.ID, &ts, &o.Symbol, &o.Side, &o.Quantity, &o.Price); err != nil {
+			return nil, err
+		}
+		o.Time = time.Unix(0, ts)
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (s *SQLiteStore) InsertSignal(ctx context.Context, sig Signal) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO signals (time, strategy, symbol, side, price, reason) VALUES (?, ?, ?, ?, ?, ?)",
+		sig.Time.UnixNano(), sig.Strategy, sig.Symbol, sig.Side, sig.Price, sig.Reason)
+	return err
+}
+
+func (s *SQLiteStore) Signals(ctx context.Context, symbol string, limit int) ([]Signal, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT time, strategy, symbol, side, price, reason FROM signals WHERE symbol = ? ORDER BY time DESC LIMIT ?",
+		symbol, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var signals []Signal
+	for rows.Next() {
+		var sig Signal
+		var ts int64
+		if err := rows.Scan(&ts, &sig.Strategy, &sig.Symbol, &sig.Side, &sig.Price, &sig.Reason); err != nil {
+			return nil, err
+		}
+		sig.Time = time.Unix(0, ts)
+		signals = append(signals, sig)
+	}
+	return signals, rows.Err()
+}
+
+// WriteQueue reports the write-behind buffer
+func (s *SQLiteStore) WriteQueue() WriteQueueStatus {
+	return s.writes.Status()
+}
+
+// Close writes the queued trades before closing the file
+func (s *SQLiteStore) Close() {
+	s.writes.close()
+	s.db.Close()
+}
@@ -0,0 +1,146 @@
+// Package store persists trades, paper orders and strategy signals in
+// Postgres (TimescaleDB), SQLite or a Bolt file, and pages through trade
+// history. The API server keeps its trades in one, and other programs can
+// open the same databases without it:
+//
+//	db, err := store.NewBoltStore("trades.db", 7*24*time.Hour)
+//	...
+//	trades, err := db.History(ctx, store.HistoryQuery{Symbol: "btcusdt", Limit: 100})
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// Store persists trades and serves history queries
+type Store interface {
+	// Insert queues a trade to be stored in the order Insert is called,
+	// without waiting for the database
+	Insert(ctx context.Context, t Trade) error
+
+	// History returns up to q.Limit trades matching q, newest first unless
+	// q.Ascending. Trades at the same time come back in a stable order so a
+	// cursor can skip the ones already returned.
+	History(ctx context.Context, q HistoryQuery) ([]Trade, error)
+
+	// InsertOrder appends a paper order to the ledger
+	InsertOrder(ctx context.Context, o PaperOrder) error
+
+	// Orders returns the whole paper order ledger, oldest first
+	Orders(ctx context.Context) ([]PaperOrder, error)
+
+	// InsertSignal stores a strategy signal
+	InsertSignal(ctx context.Context, sig Signal) error
+
+	// Signals returns up to limit of a symbol's signals, newest first
+	Signals(ctx context.Context, symbol string, limit int) ([]Signal, error)
+
+	Close()
+}
+
+// TradeDeleter is a Store that can delete old trades on request
+type TradeDeleter interface {
+	// DeleteTradesBefore deletes every symbol's trades older than before and
+	// returns how many
+	DeleteTradesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// CandleStore is implemented by stores that aggregate candles themselves,
+// for ranges older than the in-memory series
+type CandleStore interface {
+	// StoredCandles returns up to limit of the most recent candles of an
+	// interval starting in [from, to), oldest first. ok is false for
+	// intervals the store doesn't aggregate.
+	StoredCandles(ctx context.Context, symbol, interval string, from, to time.Time, limit int) (candles []Candle, ok bool, err error)
+}
+
+// Trade is one stored trade. Qty and Side are empty for trades stored
+// before they were recorded, and for exchanges that don't report them.
+type Trade struct {
+	Symbol    string    `json:"symbol"`
+	Price     Decimal   `json:"price"`
+	Qty       float64   `json:"qty,omitempty"`
+	Side      string    `json:"side,omitempty"` // taker side, buy or sell
+	Timestamp time.Time `json:"timestamp"`
+
+	ID int64 `json:"-"` // row id in stores that number trades, 0 otherwise
+}
+
+// Taker sides of a trade
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// HistoryQuery selects one page of a symbol's trades
+type HistoryQuery struct {
+	Symbol    string
+	From      time.Time // inclusive, zero for no bound
+	To        time.Time // exclusive, zero for no bound
+	Limit     int
+	Ascending bool           // oldest first instead of newest first
+	Cursor    *HistoryCursor // continue after a previous page
+}
+
+// HistoryCursor marks where a page ended: the time of its last trade and how
+// many trades at exactly that time have been returned so far. Trades aren't
+// unique by time, so the count lets the next page skip the ones already seen.
+// Stores that number their trades also get the last trade's id, and continue
+// strictly after (Time, ID) instead.
+type HistoryCursor struct {
+	Time time.Time `json:"t"`
+	Skip int       `json:"n"`
+	ID   int64     `json:"id,omitempty"`
+}
+
+func (c HistoryCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Candle is an OHLC bar for one interval
+type Candle struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Trades int64     `json:"trades"`
+	// Base asset traded, and the part takers bought and sold; trades of
+	// unknown size or side count toward neither
+	Volume     float64 `json:"volume"`
+	BuyVolume  float64 `json:"buy_volume"`
+	SellVolume float64 `json:"sell_volume"`
+}
+
+// PaperOrder is a simulated market order, filled in full at the last price
+type PaperOrder struct {
+	ID       int64     `json:"id,string"`
+	Symbol   string    `json:"symbol"`
+	Side     string    `json:"side"` // buy or sell
+	Quantity float64   `json:"quantity"`
+	Price    float64   `json:"price"`
+	Time     time.Time `json:"time"`
+}
+
+// Signal is a strategy's call to buy or sell
+type Signal struct {
+	Time     time.Time `json:"time"`
+	Strategy string    `json:"strategy"`
+	Symbol   string    `json:"symbol"`
+	Side     string    `json:"side"` // buy or sell
+	Price    float64   `json:"price"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// nullIfZero stores an unreported trade size or side as NULL
+func nullIfZero[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// A write-behind buffer flushes once it holds writeBatchSize trades or
+// writeFlushInterval after its last flush, whichever comes first
+const (
+	writeBatchSize     = 1000
+	writeFlushInterval = time.Second
+)
+
+// Trades queued while flushes fail before inserts are refused, so an
+// outage costs bounded memory
+const maxQueuedWrites = 100_000
+
+// How long the flush on shutdown may take
+const finalFlushTimeout = 10 * time.Second
+
+// errWriteQueueFull is returned by Insert while the database is too far
+// behind to queue more
+var errWriteQueueFull = errors.New("write queue full")
+
+// WriteQueueStatus is the state of a store's write-behind buffer
+type WriteQueueStatus struct {
+	Queued      int   `json:"queued"`
+	MaxQueued   int   `json:"max_queued"`
+	Written     int64 `json:"written"`
+	Batches     int64 `json:"batches"`
+	Failures    int64 `json:"failures"` // failed flushes, retried with the next
+	Dropped     int64 `json:"dropped"`  // refused while the queue was full
+	LastFlushMs int64 `json:"last_flush_ms"`
+}
+
+// writeBehind queues trades in memory and writes them in batches, keeping a
+// failed batch queued to retry
+type writeBehind struct {
+	write func(ctx context.Context, trades []Trade) error
+
+	mu     sync.Mutex
+	queue  []Trade
+	status WriteQueueStatus
+
+	kick chan struct{}
+	done chan struct{}
+	idle chan struct{} // closed when the flush loop has returned
+}
+
+func newWriteBehind(write func(ctx context.Context, trades []Trade) error) *writeBehind {
+	w := &writeBehind{
+		write: write,
+		kick:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+		idle:  make(chan struct{}),
+	}
+	w.status.MaxQueued = maxQueuedWrites
+	go w.loop()
+	return w
+}
+
+// add queues t, waking the flusher once a batch is full
+func (w *writeBehind) add(t Trade) error {
+	w.mu.Lock()
+	if len(w.queue) >= maxQueuedWrites {
+		w.status.Dropped++
+		w.mu.Unlock()
+		return errWriteQueueFull
+	}
+	w.queue = append(w.queue, t)
+	full := len(w.queue) >= writeBatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (w *writeBehind) loop() {
+	defer close(w.idle)
+	ticker := time.NewTicker(writeFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		case <-w.kick:
+		}
+		w.flush(context.Background())
+	}
+}
+
+// flush writes the queue in batches until it is empty or a write fails
+func (w *writeBehind) flush(ctx context.Context) error {
+	for {
+		w.mu.Lock()
+		n := min(len(w.queue), writeBatchSize)
+		batch := w.queue[:n:n]
+		w.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		start := time.Now()
+		err := w.write(ctx, batch)
+
+		w.mu.Lock()
+		w.status.LastFlushMs = time.Since(start).Milliseconds()
+		if err != nil {
+			w.status.Failures++
+			w.mu.Unlock()
+			log.Printf("DB batch write error, %d trades queued: %v", w.Status().Queued, err)
+			return err
+		}
+		w.queue = w.queue[n:]
+		w.status.Written += int64(n)
+		w.status.Batches++
+		w.mu.Unlock()
+	}
+}
+
+// Status reports the queue depth and flush counts
+func (w *writeBehind) Status() WriteQueueStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	status.Queued = len(w.queue)
+	return status
+}
+
+// close stops the flusher and writes what is still queued
+func (w *writeBehind) close() {
+	close(w.done)
+	<-w.idle
+	ctx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+	defer cancel()
+	if err := w.flush(ctx); err != nil {
+		log.Printf("Lost %d queued trades on shutdown", w.Status().Queued)
+	}
+}
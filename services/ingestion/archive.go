@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the archive is flushed to disk, so a crash loses at most this
+// much of it
+const archiveFlushInterval = time.Second
+
+// archive writes every raw exchange message to disk when --record is set,
+// nil otherwise. Set up in main before the feeds start.
+var archive *Archive
+
+// Archive appends raw exchange messages to files, one JSON object per line
+// in the format of Recorder.Dump, for post-mortems of feed issues and as
+// input for --source=replay. Files are named after the --record path with
+// the time they were started, e.g. raw-20250102T140312Z.jsonl, and a new one
+// is started once maxSize bytes of messages went into the current one.
+type Archive struct {
+	base    string // path without its extension
+	ext     string
+	gzip    bool
+	maxSize int64 // 0 never rotates
+
+	mu      sync.Mutex
+	file    *os.File
+	gz      *gzip.Writer
+	w       *bufio.Writer
+	written int64
+	path    string
+	err     error // last write error, logged once
+	done    chan struct{}
+}
+
+// NewArchive starts the first file of an archive at path, gzipped with
+// compress or when path ends in .gz
+func NewArchive(path string, maxSize int64, compress bool) (*Archive, error) {
+	if strings.HasSuffix(path, ".gz") {
+		path, compress = strings.TrimSuffix(path, ".gz"), true
+	}
+	ext := filepath.Ext(path)
+	if ext == "" {
+		ext = ".jsonl"
+	}
+	a := &Archive{
+		base:    strings.TrimSuffix(path, filepath.Ext(path)),
+		ext:     ext,
+		gzip:    compress,
+		maxSize: maxSize,
+		done:    make(chan struct{}),
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := a.rotate(); err != nil {
+		return nil, err
+	}
+	go a.flushLoop()
+	return a, nil
+}
+
+// rotate closes the current file, if any, and starts the next. Called with
+// mu held.
+func (a *Archive) rotate() error {
+	if err := a.closeFile(); err != nil {
+		log.Printf("Closing %s: %v", a.path, err)
+	}
+	path := fmt.Sprintf("%s-%s%s", a.base, time.Now().UTC().Format("20060102T150405Z"), a.ext)
+	if a.gzip {
+		path += ".gz"
+	}
+	// A file started within the same second is appended to; gzip streams
+	// concatenate
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = file
+	if a.gzip {
+		a.gz = gzip.NewWriter(file)
+		w = a.gz
+	}
+	a.file, a.w, a.path, a.written = file, bufio.NewWriter(w), path, 0
+	return nil
+}
+
+// Record appends a message received from exchange now
+func (a *Archive) Record(exchange string, message []byte) {
+	data, _ := json.Marshal(rawLine(time.Now(), exchange, message))
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return
+	}
+	if a.maxSize > 0 && a.written > 0 && a.written+int64(len(data)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			a.failed(err)
+			return
+		}
+	}
+	a.w.Write(data)
+	a.w.WriteByte('\n')
+	a.written += int64(len(data)) + 1
+}
+
+// failed logs a write error, unless it is the one logged last
+func (a *Archive) failed(err error) {
+	if a.err == nil || a.err.Error() != err.Error() {
+		log.Printf("Raw message archive error: %v", err)
+	}
+	a.err = err
+}
+
+func (a *Archive) flushLoop() {
+	ticker := time.NewTicker(archiveFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			if a.file != nil {
+				if err := a.flush(); err != nil {
+					a.failed(err)
+				}
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// flush writes out what is buffered. Called with mu held.
+func (a *Archive) flush() error {
+	if err := a.w.Flush(); err != nil {
+		return err
+	}
+	if a.gz != nil {
+		return a.gz.Flush()
+	}
+	return nil
+}
+
+// closeFile flushes and closes the current file, ending its gzip stream.
+// Called with mu held.
+func (a *Archive) closeFile() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.w.Flush()
+	if a.gz != nil {
+		if cerr := a.gz.Close(); err == nil {
+			err = cerr
+		}
+		a.gz = nil
+	}
+	if cerr := a.file.Close(); err == nil {
+		err = cerr
+	}
+	a.file = nil
+	return err
+}
+
+// Path returns the file being written
+func (a *Archive) Path() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.path
+}
+
+// Close flushes and closes the archive; later messages are dropped
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	select {
+	case <-a.done:
+		return nil
+	default:
+	}
+	close(a.done)
+	return a.closeFile()
+}
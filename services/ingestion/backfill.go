@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"ingestion/feed"
+)
+
+// backfill publishes the last period of trades for symbols ahead of the live
+// stream, so the moving average, candles and history start out populated.
+// Symbols streamed from a feed without a Backfiller are skipped.
+func backfill(ctx context.Context, nc *nats.Conn, router *Router, symbols []string, period time.Duration) {
+	if period <= 0 {
+		return
+	}
+	for _, symbol := range symbols {
+		bf, ok := router.FeedFor(symbol).(feed.Backfiller)
+		if !ok {
+			continue
+		}
+		trades, err := bf.RecentTrades(ctx, symbol, period)
+		if err != nil {
+			log.Printf("Backfill error for %s: %v", symbol, err)
+			continue
+		}
+		for _, trade := range trades {
+			data, _ := json.Marshal(trade)
+			nc.Publish("trades.raw", data)
+		}
+		log.Printf("Backfilled %d trades for %s", len(trades), symbol)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// First reconnect delay; each failure doubles it up to the cap
+const reconnectBase = time.Second
+
+// How often an exchange without symbols to stream checks for new ones
+const idleExchangePoll = time.Second
+
+// Backoff spaces out reconnect attempts exponentially, so an exchange
+// outage isn't met with a retry every few seconds from every instance
+type Backoff struct {
+	Base, Max time.Duration
+	attempt   int
+}
+
+// Next returns the delay before the next attempt: base doubled per
+// consecutive failure, capped at Max, then jittered down by up to half so
+// instances that lost the exchange together don't retry together
+func (b *Backoff) Next() time.Duration {
+	d := b.Max
+	if b.attempt < 30 {
+		d = min(b.Base<<b.attempt, b.Max)
+	}
+	b.attempt++
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// Reset starts over from Base after a successful connection
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"ingestion/feed"
+)
+
+const clockSyncInterval = 5 * time.Minute
+
+// ClockMessage is published to control.clock with the exchange clock offset
+type ClockMessage struct {
+	SkewMs int64 `json:"skew_ms"` // exchange time minus local time
+}
+
+// syncClock periodically measures the offset between the exchange and the
+// local clock and publishes it for the other services until ctx is done
+func syncClock(ctx context.Context, nc *nats.Conn, src feed.Exchange) {
+	for {
+		skew, err := measureSkew(ctx, src)
+		if err != nil {
+			log.Printf("Clock sync error: %v", err)
+		} else {
+			data, _ := json.Marshal(ClockMessage{SkewMs: skew.Milliseconds()})
+			nc.Publish("control.clock", data)
+			log.Printf("Exchange clock skew: %v", skew)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(clockSyncInterval):
+		}
+	}
+}
+
+// measureSkew compares the exchange time with the local time at the midpoint
+// of the request, cancelling out most of the network latency
+func measureSkew(ctx context.Context, src feed.Exchange) (time.Duration, error) {
+	sent := time.Now()
+	serverTime, err := src.ServerTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+
+	local := sent.Add(received.Sub(sent) / 2)
+	return serverTime.Sub(local), nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/segmentio/kafka-go"
+)
+
+// How long each doctor check may take
+const doctorTimeout = 5 * time.Second
+
+// Skew beyond this is worth fixing on the host; trades would be bucketed
+// and flagged stale against a clock that far off
+const doctorMaxSkew = time.Second
+
+// doctorCheck is one line of the `ingestion doctor` report
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context) (detail string, err error)
+}
+
+// doctorWarning is a check that passed with a caveat worth reading
+type doctorWarning string
+
+func (w doctorWarning) Error() string { return string(w) }
+
+// runDoctor prints one line per check and returns the exit status: 1 when
+// any check failed
+func runDoctor(checks []doctorCheck) int {
+	status := 0
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+		detail, err := c.run(ctx)
+		cancel()
+
+		result := "ok"
+		var warning doctorWarning
+		switch {
+		case errors.As(err, &warning):
+			result, detail = "warn", err.Error()
+		case err != nil:
+			result, detail = "FAIL", err.Error()
+			status = 1
+		}
+		fmt.Printf("%-5s %-10s %s\n", result, c.name, detail)
+	}
+	return status
+}
+
+// ingestionChecks covers NATS, each exchange's REST and stream endpoints
+// and the local clock against the default exchange's
+func ingestionChecks(natsURL string, router *Router, subs *SubscriptionManager) []doctorCheck {
+	checks := []doctorCheck{
+		{"nats", func(ctx context.Context) (string, error) {
+			nc, err := nats.Connect(natsURL, nats.Timeout(doctorTimeout))
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", natsURL, err)
+			}
+			defer nc.Close()
+			return fmt.Sprintf("%s (server %s)", natsURL, nc.ConnectedServerVersion()), nil
+		}},
+	}
+	for i, exchange := range router.Exchanges() {
+		src, symbols := router.Feed(exchange), subs.SymbolsOn(exchange)
+		checks = append(checks, doctorCheck{"exchange", func(ctx context.Context) (string, error) {
+			skew, err := measureSkew(ctx, src)
+			if err != nil {
+				return "", fmt.Errorf("%s REST API unreachable: %v", src.Name(), err)
+			}
+			detail := fmt.Sprintf("%s REST API reachable, clock skew %v", src.Name(), skew.Round(time.Millisecond))
+			// Only the default exchange's clock is used
+			if i == 0 && skew.Abs() > doctorMaxSkew {
+				return "", doctorWarning(detail + ", sync the host clock (e.g. enable NTP)")
+			}
+			return detail, nil
+		}})
+		if len(symbols) == 0 {
+			continue
+		}
+		checks = append(checks, doctorCheck{"stream", func(ctx context.Context) (string, error) {
+			if err := src.Connect(ctx, symbols); err != nil {
+				return "", fmt.Errorf("%s stream: %v", src.Name(), err)
+			}
+			src.Close()
+			return fmt.Sprintf("%s stream opened for %s", src.Name(), strings.Join(symbols, ", ")), nil
+		}})
+	}
+	return checks
+}
+
+// kafkaCheck reaches a broker and looks up the topic
+func kafkaCheck(kafkaBrokers, kafkaTopic string) doctorCheck {
+	return doctorCheck{"kafka", func(ctx context.Context) (string, error) {
+		var err error
+		for _, broker := range parseBrokers(kafkaBrokers) {
+			var conn *kafka.Conn
+			conn, err = kafka.DialContext(ctx, "tcp", broker)
+			if err != nil {
+				continue
+			}
+			defer conn.Close()
+			partitions, err := conn.ReadPartitions(kafkaTopic)
+			if err != nil || len(partitions) == 0 {
+				return "", doctorWarning(fmt.Sprintf("%s reachable, topic %s will be created on the first trade if the cluster allows it", broker, kafkaTopic))
+			}
+			return fmt.Sprintf("%s reachable, topic %s has %d partitions", broker, kafkaTopic, len(partitions)), nil
+		}
+		return "", fmt.Errorf("no broker reachable: %v", err)
+	}}
+}
+
+// natsOutCheck connects to the NATS output server and looks up the stream,
+// if one is configured
+func natsOutCheck(url, stream string) doctorCheck {
+	return doctorCheck{"nats-out", func(ctx context.Context) (string, error) {
+		nc, err := nats.Connect(url, nats.Timeout(doctorTimeout))
+		if err != nil {
+			return "", err
+		}
+		defer nc.Close()
+		if stream == "" {
+			return "connected to " + nc.ConnectedUrl(), nil
+		}
+		js, err := jetstream.New(nc)
+		if err != nil {
+			return "", err
+		}
+		info, err := js.Stream(ctx, stream)
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			return "", doctorWarning(fmt.Sprintf("stream %s not found, it is created on start", stream))
+		} else if err != nil {
+			return "", fmt.Errorf("JetStream: %w", err)
+		}
+		return fmt.Sprintf("stream %s holds %d trades", stream, info.CachedInfo().State.Msgs), nil
+	}}
+}
+
+// udpOutCheck resolves the UDP output address and opens a socket to it
+func udpOutCheck(addr string) doctorCheck {
+	return doctorCheck{"udp-out", func(ctx context.Context) (string, error) {
+		out, err := NewUDPPublisher(addr)
+		if err != nil {
+			return "", err
+		}
+		defer out.Close()
+		raddr := out.conn.RemoteAddr().(*net.UDPAddr)
+		if raddr.IP.IsMulticast() {
+			return fmt.Sprintf("multicast group %s from %s", raddr, out.conn.LocalAddr()), nil
+		}
+		return fmt.Sprintf("%s from %s", raddr, out.conn.LocalAddr()), nil
+	}}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"ingestion/feed"
+)
+
+// tee receives a copy of every published trade when --tee-json is set. Logs
+// go to stderr, so stdout carries nothing but trades.
+var tee *json.Encoder
+
+// Trade sources selectable with --source: an exchange, a recording of one,
+// or made up trades for load tests
+const (
+	sourceLive      = "live"
+	sourceReplay    = "replay"
+	sourceSynthetic = "synthetic"
+)
+
+// Rejected messages are logged at most once per rejectLogInterval
+const rejectLogInterval = 10 * time.Second
+
+// logReject counts a message or trade a feed couldn't parse and logs it,
+// unless one was logged within rejectLogInterval
+func logReject(exchange string, err error) {
+	rejected := metrics.rejected.Add(1)
+	now := time.Now().UnixNano()
+	last := metrics.lastReject.Load()
+	if now-last < int64(rejectLogInterval) || !metrics.lastReject.CompareAndSwap(last, now) {
+		return
+	}
+	log.Printf("%s message rejected (%d so far): %v", exchange, rejected, err)
+}
+
+// runFeed publishes trades for the symbols managed on exchange until the
+// connection fails or ctx is done, and reports whether it connected at all.
+// A connection that delivers nothing for stallTimeout is assumed half-open
+// and closed so the caller reconnects.
+func runFeed(ctx context.Context, nc *nats.Conn, exchange string, src feed.Exchange, subs *SubscriptionManager, stallTimeout time.Duration) bool {
+	symbols := subs.SymbolsOn(exchange)
+	if err := src.Connect(ctx, symbols); err != nil {
+		log.Printf("%s connection error: %v", src.Name(), err)
+		return false
+	}
+	defer src.Close()
+	log.Printf("Connected to %s for %s", src.Name(), strings.Join(symbols, ", "))
+
+	subs.Attach(exchange, src, symbols)
+	defer subs.Detach(exchange)
+
+	em := metricsFor(exchange)
+	metrics.connects.Add(1)
+	metrics.connected.Add(1)
+	em.connects.Add(1)
+	em.connectedAt.Store(time.Now().UnixMilli())
+	defer func() {
+		metrics.connected.Add(-1)
+		em.connectedAt.Store(0)
+	}()
+
+	// Unblock ReadTrades on shutdown or when the connection stalls
+	var lastRead atomic.Int64
+	var stalled atomic.Bool
+	lastRead.Store(time.Now().UnixNano())
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(stallTimeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				src.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, lastRead.Load())) > stallTimeout {
+					stalled.Store(true)
+					metrics.stalls.Add(1)
+					em.stalls.Add(1)
+					src.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		trades, err := src.ReadTrades()
+		if err != nil {
+			if stalled.Load() {
+				log.Printf("%s sent nothing for %v, reconnecting", src.Name(), stallTimeout)
+			} else if ctx.Err() == nil {
+				log.Printf("%s read error: %v", src.Name(), err)
+			}
+			return true
+		}
+		lastRead.Store(time.Now().UnixNano())
+		if raw, ok := src.(feed.RawFeed); ok {
+			if recorder != nil {
+				recorder.Record(exchange, raw.LastMessage())
+			}
+			if archive != nil {
+				archive.Record(exchange, raw.LastMessage())
+			}
+		}
+
+		if rf, ok := src.(feed.RejectFeed); ok {
+			for _, err := range rf.Rejected() {
+				logReject(src.Name(), err)
+			}
+		}
+
+		for _, trade := range trades {
+			if subs.IsPaused(trade.Symbol) {
+				continue
+			}
+			data, _ := json.Marshal(trade)
+			nc.Publish("trades.raw", data)
+			for _, out := range outputs {
+				out.Publish(trade, data)
+			}
+			metrics.trades.Add(1)
+			metrics.lastTrade.Store(trade.Time)
+			em.trades.Add(1)
+			em.lastTrade.Store(trade.Time)
+			if tee != nil {
+				tee.Encode(trade)
+			}
+		}
+
+		// Order books, from feeds that keep them
+		if books, ok := src.(feed.BookFeed); ok {
+			for _, book := range books.UpdatedBooks() {
+				if subs.IsPaused(book.Symbol) {
+					continue
+				}
+				data, _ := json.Marshal(book)
+				nc.Publish("books.raw", data)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package feed
+
+import (
+	"context"
+	"time"
+)
+
+// Backfiller is implemented by feeds that can fetch recent history over REST
+type Backfiller interface {
+	// RecentTrades returns trades covering the last period for symbol,
+	// oldest first
+	RecentTrades(ctx context.Context, symbol string, period time.Duration) ([]Trade, error)
+}
+
+// klineTrades turns a one-minute kline into the trades that rebuild it: the
+// open, the extreme reached first, the other extreme and the close, spread
+// over the minute so every candle interval folds them back into the same bar.
+// The kline's volume is shared out evenly between them.
+func klineTrades(symbol string, openTime int64, open, high, low, close, volume float64) []Trade {
+	first, second := low, high
+	if close < open {
+		first, second = high, low
+	}
+	width := time.Minute.Milliseconds()
+	prices := []float64{open, first, second, close}
+	trades := make([]Trade, len(prices))
+	for i, p := range prices {
+		trades[i] = Trade{
+			Symbol:   symbol,
+			Price:    p,
+			PriceE8:  PriceE8(p),
+			Qty:      volume / float64(len(prices)),
+			Time:     openTime + int64(i)*width/int64(len(prices)),
+			Backfill: true,
+		}
+	}
+	return trades
+}
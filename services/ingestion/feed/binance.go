@@ -0,0 +1,193 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Binance deployments selectable with --binance-network. The testnet
+// serves the same streams with test data, and binance.us is the mirror for
+// US users who can't reach binance.com.
+var binanceNetworks = map[string]Endpoints{
+	"mainnet": {Stream: "wss://stream.binance.com:9443", API: "https://api.binance.com"},
+	"testnet": {Stream: "wss://stream.testnet.binance.vision", API: "https://testnet.binance.vision"},
+	"us":      {Stream: "wss://stream.binance.us:9443", API: "https://api.binance.us"},
+}
+
+// BinanceTrade represents a trade event from Binance. encoding/json matches
+// keys case-insensitively, so "E", "t" and "M" need their own fields or they
+// would land in Event, Time and BuyerMaker.
+type BinanceTrade struct {
+	Event     string      `json:"e"`
+	EventTime int64       `json:"E"`
+	Symbol    string      `json:"s"`
+	TradeID   int64       `json:"t"`
+	Price     json.Number `json:"p"` // decimal strings, checked on decode
+	Qty       json.Number `json:"q"`
+	Time      int64       `json:"T"`
+	// The buyer placed the resting order, so the taker sold
+	BuyerMaker bool `json:"m"`
+	Ignore     bool `json:"M"`
+}
+
+// binanceEnvelope wraps every message on a combined stream
+type binanceEnvelope struct {
+	Stream string       `json:"stream"`
+	Data   BinanceTrade `json:"data"`
+}
+
+// Binance reads trades for any number of symbols from one combined
+// Binance stream
+type Binance struct {
+	endpoints Endpoints
+	compress  bool
+	conn      *websocket.Conn
+	id        int
+	lastMessage
+	rejects
+}
+
+func (f *Binance) Name() string { return "Binance" }
+
+// Connect opens /stream?streams=btcusdt@trade/ethusdt@trade/...
+func (f *Binance) Connect(ctx context.Context, symbols []string) error {
+	streams := make([]string, len(symbols))
+	for i, sym := range symbols {
+		streams[i] = sym + "@trade"
+	}
+	url := f.endpoints.Stream + "/stream?streams=" + strings.Join(streams, "/")
+
+	conn, _, err := dialer(f.compress).DialContext(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	f.conn = conn
+	keepAlive(conn)
+	return nil
+}
+
+// Subscribe adds the symbol's trade stream to the connection
+func (f *Binance) Subscribe(symbol string) error {
+	return f.request("SUBSCRIBE", symbol)
+}
+
+// Unsubscribe removes the symbol's trade stream from the connection
+func (f *Binance) Unsubscribe(symbol string) error {
+	return f.request("UNSUBSCRIBE", symbol)
+}
+
+func (f *Binance) request(method, symbol string) error {
+	f.id++
+	return f.conn.WriteJSON(map[string]any{
+		"method": method,
+		"params": []string{symbol + "@trade"},
+		"id":     f.id,
+	})
+}
+
+func (f *Binance) ReadTrades() ([]Trade, error) {
+	message, err := readMessage(f.conn)
+	f.last = message
+	if err != nil {
+		return nil, err
+	}
+	return f.parse(message), nil
+}
+
+// parse decodes a combined stream message, rejecting it when it can't
+func (f *Binance) parse(message []byte) []Trade {
+	var envelope binanceEnvelope
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		f.reject(fmt.Errorf("undecodable message: %w", err))
+		return nil
+	}
+	if envelope.Data.Event != "trade" {
+		// Subscription replies and anything else that isn't a trade
+		return nil
+	}
+	trade := envelope.Data
+
+	var qty float64
+	price, e8, err := parsePrice(trade.Price.String())
+	if err == nil {
+		qty, err = parseQty(trade.Qty.String())
+	}
+	if err != nil {
+		f.reject(fmt.Errorf("%s trade %d: %w", trade.Symbol, trade.TradeID, err))
+		return nil
+	}
+
+	side := SideBuy
+	if trade.BuyerMaker {
+		side = SideSell
+	}
+	return []Trade{{
+		Symbol:  strings.ToLower(trade.Symbol),
+		Price:   price,
+		PriceE8: e8,
+		Qty:     qty,
+		Side:    side,
+		Time:    trade.Time,
+	}}
+}
+
+func (f *Binance) ServerTime(ctx context.Context) (time.Time, error) {
+	var body struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := getJSON(ctx, f.endpoints.API+"/api/v3/time", &body); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(body.ServerTime), nil
+}
+
+// Binance serves at most this many klines per request
+const maxBinanceKlines = 1000
+
+// RecentTrades rebuilds the last period from one-minute klines. The kline
+// still in progress is left to the live stream.
+func (f *Binance) RecentTrades(ctx context.Context, symbol string, period time.Duration) ([]Trade, error) {
+	limit := min(int(period/time.Minute), maxBinanceKlines-1) + 1
+	url := fmt.Sprintf("%s/api/v3/klines?symbol=%s&interval=1m&limit=%d", f.endpoints.API, strings.ToUpper(symbol), limit)
+
+	// Each kline is [open time, open, high, low, close, volume, close time, ...]
+	var klines [][]any
+	if err := getJSON(ctx, url, &klines); err != nil {
+		return nil, err
+	}
+	if len(klines) > 0 {
+		klines = klines[:len(klines)-1]
+	}
+
+	var trades []Trade
+	for _, k := range klines {
+		if len(k) < 6 {
+			return nil, fmt.Errorf("malformed kline %v", k)
+		}
+		openTime, _ := k[0].(float64)
+		var ohlc [5]float64 // and volume
+		for i := range ohlc {
+			s, _ := k[i+1].(string)
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed kline %v", k)
+			}
+			ohlc[i] = v
+		}
+		trades = append(trades, klineTrades(symbol, int64(openTime), ohlc[0], ohlc[1], ohlc[2], ohlc[3], ohlc[4])...)
+	}
+	return trades, nil
+}
+
+func (f *Binance) Close() error {
+	if f.conn == nil {
+		return nil
+	}
+	return f.conn.Close()
+}
@@ -0,0 +1,155 @@
+package feed_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"ingestion/feed"
+	"ingestion/feed/feedtest"
+)
+
+// How long tests wait for something the feed or the server should do at once
+const feedTestWait = 5 * time.Second
+
+// read is one ReadTrades result and the messages rejected reading it
+type read struct {
+	trades   []feed.Trade
+	rejected []error
+	err      error
+}
+
+// readAll calls ReadTrades until the connection fails, passing on every
+// result
+func readAll(ex feed.Exchange) <-chan read {
+	reads := make(chan read, 64)
+	go func() {
+		defer close(reads)
+		for {
+			trades, err := ex.ReadTrades()
+			r := read{trades: trades, err: err}
+			if rf, ok := ex.(feed.RejectFeed); ok {
+				r.rejected = rf.Rejected()
+			}
+			reads <- r
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return reads
+}
+
+// next waits for a read that match accepts, skipping the others, e.g.
+// subscription replies
+func next(t *testing.T, reads <-chan read, what string, match func(read) bool) read {
+	t.Helper()
+	timeout := time.After(feedTestWait)
+	for {
+		select {
+		case r, ok := <-reads:
+			if !ok {
+				t.Fatalf("connection closed waiting for %s", what)
+			}
+			if match(r) {
+				return r
+			}
+			if r.err != nil {
+				t.Fatalf("waiting for %s: %v", what, r.err)
+			}
+		case <-timeout:
+			t.Fatalf("no %s", what)
+		}
+	}
+}
+
+// expectTrade waits for the next trade and checks it
+func expectTrade(t *testing.T, reads <-chan read, want feed.Trade) {
+	t.Helper()
+	r := next(t, reads, want.Symbol+" trade", func(r read) bool { return len(r.trades) > 0 })
+	got := r.trades[0]
+	if len(r.trades) != 1 || got.Symbol != want.Symbol || got.Price != want.Price ||
+		got.PriceE8 != want.PriceE8 || got.Qty != want.Qty || got.Side != want.Side {
+		t.Fatalf("got trades %+v, want %+v", r.trades, want)
+	}
+}
+
+// waitForStreams waits until the server's connections stream exactly symbols
+func waitForStreams(t *testing.T, srv *feedtest.Server, symbols ...string) {
+	t.Helper()
+	deadline := time.Now().Add(feedTestWait)
+	for !slices.Equal(srv.Streams(), symbols) {
+		if time.Now().After(deadline) {
+			t.Fatalf("streams %v, want %v", srv.Streams(), symbols)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func play(t *testing.T, ctx context.Context, srv *feedtest.Server, steps ...feedtest.Step) {
+	t.Helper()
+	if err := srv.Play(ctx, steps...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBinanceSwitchAndReconnect(t *testing.T) {
+	srv := feedtest.NewServer()
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*feedTestWait)
+	defer cancel()
+
+	ex, err := feed.New("binance", feed.Config{Binance: srv.Endpoints()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ex.ServerTime(ctx); err != nil {
+		t.Fatalf("server time: %v", err)
+	}
+	if err := ex.Connect(ctx, []string{"btcusdt"}); err != nil {
+		t.Fatal(err)
+	}
+	defer ex.Close()
+	reads := readAll(ex)
+
+	play(t, ctx, srv,
+		feedtest.Trade("btcusdt", 97000.12, 0.5, feed.SideBuy),
+		feedtest.Trade("btcusdt", 97000.13, 0.1, feed.SideSell),
+	)
+	expectTrade(t, reads, feed.Trade{Symbol: "btcusdt", Price: 97000.12, PriceE8: 9700012000000, Qty: 0.5, Side: feed.SideBuy})
+	expectTrade(t, reads, feed.Trade{Symbol: "btcusdt", Price: 97000.13, PriceE8: 9700013000000, Qty: 0.1, Side: feed.SideSell})
+
+	// Switch symbols on the open connection, the way ingestion does
+	if err := ex.Subscribe("ethusdt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ex.Unsubscribe("btcusdt"); err != nil {
+		t.Fatal(err)
+	}
+	waitForStreams(t, srv, "ethusdt")
+	play(t, ctx, srv, feedtest.Trade("ethusdt", 3100.5, 1, feed.SideBuy))
+	expectTrade(t, reads, feed.Trade{Symbol: "ethusdt", Price: 3100.5, PriceE8: 310050000000, Qty: 1, Side: feed.SideBuy})
+
+	// A trade that doesn't parse is rejected, not passed on
+	play(t, ctx, srv, feedtest.Raw(`{"stream":"ethusdt@trade","data":{"e":"trade","s":"ETHUSDT","t":99,"p":"-1","q":"1","T":1}}`))
+	r := next(t, reads, "rejected trade", func(r read) bool { return len(r.rejected) > 0 || len(r.trades) > 0 })
+	if len(r.trades) != 0 || len(r.rejected) != 1 {
+		t.Fatalf("got %d trades and rejects %v, want one reject", len(r.trades), r.rejected)
+	}
+
+	// Dropped, the feed fails its read, and the caller reconnects with the
+	// symbols it streams now
+	play(t, ctx, srv, feedtest.Disconnect())
+	next(t, reads, "read error", func(r read) bool { return r.err != nil })
+	if err := ex.Connect(ctx, []string{"ethusdt"}); err != nil {
+		t.Fatal(err)
+	}
+	reads = readAll(ex)
+	waitForStreams(t, srv, "ethusdt")
+	if n := srv.Connections(); n != 2 {
+		t.Fatalf("%d connections, want 2", n)
+	}
+	play(t, ctx, srv, feedtest.Trade("ethusdt", 3101, 0.25, feed.SideSell))
+	expectTrade(t, reads, feed.Trade{Symbol: "ethusdt", Price: 3101, PriceE8: 310100000000, Qty: 0.25, Side: feed.SideSell})
+}
@@ -0,0 +1,68 @@
+package feed
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Book is the top of a symbol's order book, best levels first
+type Book struct {
+	Symbol string      `json:"symbol"`
+	Bids   []BookLevel `json:"bids"`
+	Asks   []BookLevel `json:"asks"`
+	Time   int64       `json:"time"` // unix ms of the last change
+}
+
+// BookLevel is the quantity resting at one price
+type BookLevel struct {
+	Price float64 `json:"price"`
+	Qty   float64 `json:"qty"`
+}
+
+// BookFeed is implemented by feeds that also keep order books for their
+// symbols
+type BookFeed interface {
+	// UpdatedBooks returns the books changed by the messages ReadTrades has
+	// read since the last call. Call it from the ReadTrades goroutine.
+	UpdatedBooks() []Book
+}
+
+// bookSide holds one side of a book by price
+type bookSide map[float64]float64
+
+// apply sets the quantity at a price, removing the level at zero
+func (s bookSide) apply(price, qty float64) {
+	if qty == 0 {
+		delete(s, price)
+	} else {
+		s[price] = qty
+	}
+}
+
+// levels returns up to depth levels, best first: highest price for bids,
+// lowest for asks
+func (s bookSide) levels(bids bool, depth int) []BookLevel {
+	out := make([]BookLevel, 0, len(s))
+	for price, qty := range s {
+		out = append(out, BookLevel{Price: price, Qty: qty})
+	}
+	slices.SortFunc(out, func(a, b BookLevel) int {
+		if bids {
+			return cmp.Compare(b.Price, a.Price)
+		}
+		return cmp.Compare(a.Price, b.Price)
+	})
+	return out[:min(len(out), depth)]
+}
+
+// truncate drops the levels beyond depth
+func (s bookSide) truncate(bids bool, depth int) {
+	if len(s) <= depth {
+		return
+	}
+	keep := s.levels(bids, depth)
+	clear(s)
+	for _, l := range keep {
+		s[l.Price] = l.Qty
+	}
+}
@@ -0,0 +1,224 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var coinbaseEndpoints = Endpoints{
+	Stream: "wss://advanced-trade-ws.coinbase.com",
+	API:    "https://api.coinbase.com",
+}
+
+// CoinbaseMessage is a market_trades message from Coinbase Advanced Trade
+type CoinbaseMessage struct {
+	Channel string `json:"channel"`
+	Events  []struct {
+		Type   string `json:"type"`
+		Trades []struct {
+			ProductID string    `json:"product_id"`
+			Price     string    `json:"price"`
+			Size      string    `json:"size"`
+			Side      string    `json:"side"` // BUY or SELL, the taker's
+			Time      time.Time `json:"time"`
+		} `json:"trades"`
+	} `json:"events"`
+}
+
+// Coinbase reads the Coinbase Advanced Trade market_trades channel.
+// Coinbase lists USD rather than USDT books for most coins, so "btcusdt"
+// is streamed from BTC-USD and published as "btcusdt".
+type Coinbase struct {
+	endpoints Endpoints
+	overrides map[string]string // pipeline symbol -> product id, see Config
+	conn      *websocket.Conn
+	mu        sync.Mutex
+	products  map[string][]string // product id -> pipeline symbols
+	lastMessage
+	rejects
+}
+
+func (f *Coinbase) Name() string { return "Coinbase" }
+
+func (f *Coinbase) Connect(ctx context.Context, symbols []string) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, f.endpoints.Stream, nil)
+	if err != nil {
+		return err
+	}
+	f.conn = conn
+	keepAlive(conn)
+	f.mu.Lock()
+	f.products = make(map[string][]string)
+	f.mu.Unlock()
+
+	for _, sym := range symbols {
+		if err := f.Subscribe(sym); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribe joins market_trades for the symbol's product, plus heartbeats so
+// the connection stays open while the book is quiet. Several symbols can
+// share a product (btcusdt and btcfdusd both follow BTC-USD); only the first
+// one subscribes.
+func (f *Coinbase) Subscribe(symbol string) error {
+	product := f.product(symbol)
+	f.mu.Lock()
+	symbols := f.products[product]
+	if slices.Contains(symbols, symbol) {
+		f.mu.Unlock()
+		return nil
+	}
+	f.products[product] = append(symbols, symbol)
+	f.mu.Unlock()
+	if len(symbols) > 0 {
+		return nil
+	}
+	return f.request("subscribe", product)
+}
+
+// Unsubscribe leaves both channels for the symbol's product once no other
+// symbol follows it
+func (f *Coinbase) Unsubscribe(symbol string) error {
+	product := f.product(symbol)
+	f.mu.Lock()
+	symbols := slices.DeleteFunc(f.products[product], func(s string) bool { return s == symbol })
+	if len(symbols) > 0 {
+		f.products[product] = symbols
+		f.mu.Unlock()
+		return nil
+	}
+	delete(f.products, product)
+	f.mu.Unlock()
+	return f.request("unsubscribe", product)
+}
+
+func (f *Coinbase) request(kind, product string) error {
+	for _, channel := range []string{"market_trades", "heartbeats"} {
+		err := f.conn.WriteJSON(map[string]any{
+			"type":        kind,
+			"product_ids": []string{product},
+			"channel":     channel,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Coinbase) ReadTrades() ([]Trade, error) {
+	message, err := readMessage(f.conn)
+	f.last = message
+	if err != nil {
+		return nil, err
+	}
+
+	var msg CoinbaseMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		f.reject(fmt.Errorf("undecodable message: %w", err))
+		return nil, nil
+	}
+	if msg.Channel != "market_trades" {
+		return nil, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var trades []Trade
+	for _, event := range msg.Events {
+		// The snapshot sent on subscribe replays recent trades; only
+		// forward live ones
+		if event.Type != "update" {
+			continue
+		}
+		for _, t := range event.Trades {
+			price, e8, err := parsePrice(t.Price)
+			if err != nil {
+				f.reject(fmt.Errorf("%s trade: %w", t.ProductID, err))
+				continue
+			}
+			qty, err := parseQty(t.Size)
+			if err != nil {
+				f.reject(fmt.Errorf("%s trade: %w", t.ProductID, err))
+				continue
+			}
+			for _, symbol := range f.products[t.ProductID] {
+				trades = append(trades, Trade{
+					Symbol:  symbol,
+					Price:   price,
+					PriceE8: e8,
+					Qty:     qty,
+					Side:    strings.ToLower(t.Side),
+					Time:    t.Time.UnixMilli(),
+				})
+			}
+		}
+	}
+	return trades, nil
+}
+
+func (f *Coinbase) ServerTime(ctx context.Context) (time.Time, error) {
+	var body struct {
+		EpochMillis string `json:"epochMillis"`
+	}
+	if err := getJSON(ctx, f.endpoints.API+"/api/v3/brokerage/time", &body); err != nil {
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(body.EpochMillis, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
+func (f *Coinbase) Close() error {
+	if f.conn == nil {
+		return nil
+	}
+	return f.conn.Close()
+}
+
+// Quote currencies Coinbase lists pairs in. Dollar stablecoins map to
+// the USD book, so "btcfdusd" follows BTC-USD like "btcusdt" does.
+var coinbaseQuotes = []struct{ suffix, quote string }{
+	{"FDUSD", "USD"},
+	{"USDT", "USD"},
+	{"USDC", "USD"},
+	{"EUR", "EUR"},
+	{"GBP", "GBP"},
+	{"USD", "USD"},
+}
+
+// product is the Coinbase product id symbol is streamed from, from the
+// overrides or else coinbaseProduct
+func (f *Coinbase) product(symbol string) string {
+	if product, ok := f.overrides[symbol]; ok {
+		return product
+	}
+	return coinbaseProduct(symbol)
+}
+
+// coinbaseProduct maps a pipeline symbol to a Coinbase product id,
+// e.g. "btcusdt" -> "BTC-USD" and "btceur" -> "BTC-EUR"
+func coinbaseProduct(symbol string) string {
+	upper := strings.ToUpper(symbol)
+	for _, q := range coinbaseQuotes {
+		if base, ok := strings.CutSuffix(upper, q.suffix); ok {
+			return base + "-" + q.quote
+		}
+	}
+	return upper + "-USD"
+}
@@ -0,0 +1,307 @@
+// Package feed streams normalized trades from crypto exchanges. It has no
+// NATS or pipeline dependencies, so other programs can use the exchange
+// clients on their own:
+//
+//	ex, err := feed.New("binance", feed.Config{BinanceNetwork: "mainnet"})
+//	...
+//	err = ex.Connect(ctx, []string{"btcusdt"})
+//	for {
+//		trades, err := ex.ReadTrades()
+//		...
+//	}
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Trade is one normalized trade, as published on trades.raw
+type Trade struct {
+	Symbol   string  `json:"symbol"`
+	Price    float64 `json:"price"`
+	PriceE8  int64   `json:"price_e8,omitempty"` // Price exactly, in 1e-8 units; 0 when it doesn't fit
+	Qty      float64 `json:"qty,omitempty"`      // base asset traded, 0 when unknown
+	Side     string  `json:"side,omitempty"`     // taker side, SideBuy or SideSell; empty when unknown
+	Time     int64   `json:"time"`
+	Backfill bool    `json:"backfill,omitempty"` // historical, fetched over REST
+}
+
+// Trade sides, named for the taker: a buy lifted an ask, a sell hit a bid
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// Exchange streams trades from one exchange, normalized to Trade with the
+// pipeline's symbol names (e.g. "btcusdt")
+type Exchange interface {
+	Name() string
+	// Connect opens the exchange's WebSocket streaming trades for symbols
+	Connect(ctx context.Context, symbols []string) error
+	// Subscribe and Unsubscribe add and remove a symbol on the open
+	// connection. They may be called while ReadTrades is blocked, but not
+	// concurrently with each other.
+	Subscribe(symbol string) error
+	Unsubscribe(symbol string) error
+	// ReadTrades blocks until the next message and returns the trades in it,
+	// which may be none for control messages
+	ReadTrades() ([]Trade, error)
+	// ServerTime asks the exchange for its current time
+	ServerTime(ctx context.Context) (time.Time, error)
+	Close() error
+}
+
+// Endpoints are the base URLs a feed talks to. Empty fields fall back to
+// the exchange's defaults.
+type Endpoints struct {
+	Stream string // WebSocket base, e.g. wss://stream.binance.com:9443
+	API    string // REST base, e.g. https://api.binance.com
+}
+
+// with returns e with the fields set in o replacing its own
+func (e Endpoints) with(o Endpoints) Endpoints {
+	if o.Stream != "" {
+		e.Stream = strings.TrimSuffix(o.Stream, "/")
+	}
+	if o.API != "" {
+		e.API = strings.TrimSuffix(o.API, "/")
+	}
+	return e
+}
+
+// Config holds the per-exchange connection settings
+type Config struct {
+	BinanceNetwork string // mainnet, testnet or us; empty means mainnet
+	Binance        Endpoints
+	Coinbase       Endpoints
+	Kraken         Endpoints
+	// Coinbase product ids by pipeline symbol, for pairs the quote mapping
+	// gets wrong, e.g. "btcusdt": "BTC-USDT" to read the USDT book instead
+	// of BTC-USD
+	CoinbaseProducts map[string]string
+	// Offer Binance permessage-deflate, which shrinks its repetitive JSON at
+	// the cost of inflating every message
+	BinanceCompression bool
+	// Trades the replay feed plays back, and how many times faster than
+	// they were recorded, 0 for real time
+	ReplayFile  string
+	ReplaySpeed float64
+	// Trades per second the synthetic feed makes up, across all symbols
+	SyntheticRate float64
+}
+
+// New returns the feed for an exchange name: binance, coinbase or kraken
+func New(exchange string, cfg Config) (Exchange, error) {
+	switch exchange {
+	case "binance":
+		if cfg.BinanceNetwork == "" {
+			cfg.BinanceNetwork = "mainnet"
+		}
+		network, ok := binanceNetworks[cfg.BinanceNetwork]
+		if !ok {
+			return nil, fmt.Errorf("unknown Binance network %q (want mainnet, testnet or us)", cfg.BinanceNetwork)
+		}
+		return &Binance{endpoints: network.with(cfg.Binance), compress: cfg.BinanceCompression}, nil
+	case "coinbase":
+		return &Coinbase{endpoints: coinbaseEndpoints.with(cfg.Coinbase), overrides: cfg.CoinbaseProducts}, nil
+	case "kraken":
+		return &Kraken{endpoints: krakenEndpoints.with(cfg.Kraken)}, nil
+	case "replay":
+		if cfg.ReplaySpeed == 0 {
+			cfg.ReplaySpeed = 1
+		}
+		return NewReplay(cfg.ReplayFile, cfg.ReplaySpeed)
+	case "synthetic":
+		return NewSynthetic(cfg.SyntheticRate)
+	default:
+		return nil, fmt.Errorf("unknown exchange %q (want binance, coinbase or kraken)", exchange)
+	}
+}
+
+// Exchange connections are pinged every pingInterval. One that sends
+// nothing, not even a pong, for pongWait has died without a close frame
+// and fails its next read.
+const (
+	pingInterval = 5 * time.Second
+	pongWait     = 15 * time.Second
+)
+
+// dialer opens exchange connections, offering permessage-deflate when
+// compress is set. The exchange decides whether to use it.
+func dialer(compress bool) *websocket.Dialer {
+	if !compress {
+		return websocket.DefaultDialer
+	}
+	d := *websocket.DefaultDialer
+	d.EnableCompression = true
+	return &d
+}
+
+// keepAlive arms the read deadline on an exchange connection and pings it
+// until a ping can't be written, i.e. until the connection is closed.
+// Feeds read through readMessage so every message also pushes the deadline
+// back.
+func keepAlive(conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			// WriteControl is safe alongside the feed's own writes
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingInterval)); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// readMessage reads the next message from a connection set up by keepAlive
+func readMessage(conn *websocket.Conn) ([]byte, error) {
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	return message, nil
+}
+
+// RawFeed is implemented by feeds that can hand back the exchange's own
+// messages, for recording what arrived before it was normalized
+type RawFeed interface {
+	// LastMessage returns the message read by the last ReadTrades call, nil
+	// if it failed. Call it from the ReadTrades goroutine.
+	LastMessage() []byte
+}
+
+// lastMessage implements RawFeed for the feeds that embed it
+type lastMessage struct{ last []byte }
+
+func (m *lastMessage) LastMessage() []byte { return m.last }
+
+// RejectFeed is implemented by feeds that report the messages and trades
+// they drop because they can't parse them
+type RejectFeed interface {
+	// Rejected returns why each message or trade was dropped since the last
+	// call. Call it from the ReadTrades goroutine.
+	Rejected() []error
+}
+
+// rejects implements RejectFeed for the feeds that embed it
+type rejects struct{ errs []error }
+
+func (r *rejects) reject(err error) { r.errs = append(r.errs, err) }
+
+func (r *rejects) Rejected() []error {
+	errs := r.errs
+	r.errs = nil
+	return errs
+}
+
+// Prices also travel in fixed point, as a whole number of 1e-8 quote units:
+// exchanges quote at most 8 decimals, which an integer carries exactly where
+// a float64 only holds the nearest binary fraction
+const priceE8Places = 8
+
+// parsePrice parses a decimal price, which has to be positive and finite,
+// and returns it in fixed point too, read from the text rather than the float
+func parsePrice(s string) (float64, int64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := checkPrice(v); err != nil {
+		return 0, 0, err
+	}
+	e8, ok := parseE8(s)
+	if !ok {
+		e8 = PriceE8(v)
+	}
+	return v, e8, nil
+}
+
+// PriceE8 returns a price in 1e-8 units, for feeds that only have a float:
+// the shortest decimal that reads back as price, which is the exchange's
+// text for any price of up to 15 significant digits, rounded to 8 places.
+// It is 0 for prices beyond int64.
+func PriceE8(price float64) int64 {
+	e8, _ := parseE8(strconv.FormatFloat(price, 'f', -1, 64))
+	return e8
+}
+
+// parseE8 reads plain decimal text, e.g. "0.00001234", in 1e-8 units,
+// rounding half up beyond 8 decimals. It reports false for anything else,
+// such as exponents, and for values beyond int64.
+func parseE8(s string) (int64, bool) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || strings.Trim(whole+frac, "0123456789") != "" {
+		return 0, false
+	}
+	roundUp := false
+	if len(frac) > priceE8Places {
+		roundUp = frac[priceE8Places] >= '5'
+		frac = frac[:priceE8Places]
+	}
+	frac += strings.Repeat("0", priceE8Places-len(frac))
+	e8, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || (roundUp && e8 == math.MaxInt64) {
+		return 0, false
+	}
+	if roundUp {
+		e8++
+	}
+	return e8, true
+}
+
+func checkPrice(v float64) error {
+	if !(v > 0) || math.IsInf(v, 0) {
+		return fmt.Errorf("invalid price %v", v)
+	}
+	return nil
+}
+
+// parseQty parses a decimal trade size, which can't be negative
+func parseQty(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return v, checkQty(v)
+}
+
+func checkQty(v float64) error {
+	if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return fmt.Errorf("invalid size %v", v)
+	}
+	return nil
+}
+
+// getJSON decodes a JSON response from an exchange REST endpoint
+func getJSON(ctx context.Context, url string, v any) error {
+	client := http.Client{Timeout: 5 * time.Second}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
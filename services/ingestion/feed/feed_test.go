@@ -0,0 +1,81 @@
+package feed
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseE8(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want int64
+		ok   bool
+	}{
+		{"0.00001234", 1234, true},
+		{"97123.45", 9712345000000, true},
+		{"5.", 500000000, true},
+		{"1.234567894", 123456789, true},
+		{"1.234567895", 123456790, true}, // half up
+		{"0.000000004", 0, true},
+		{"92233720368.54775807", math.MaxInt64, true},
+		{"92233720368.547758075", 0, false}, // rounds past the top
+		{"92233720368.54775808", 0, false},
+		{".", 0, false},
+		{".5", 0, false},
+		{"", 0, false},
+		{"-1", 0, false}, // prices are positive
+		{"1e-7", 0, false},
+		{"1.2.3", 0, false},
+	} {
+		got, ok := parseE8(tc.text)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseE8(%q) = %d, %v, want %d, %v", tc.text, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		price   float64
+		e8      int64
+		wantErr bool
+	}{
+		{text: "0.00001234", price: 0.00001234, e8: 1234},
+		{text: "97123.45000000", price: 97123.45, e8: 9712345000000},
+		{text: "1.234e-5", price: 0.00001234, e8: 1234}, // from the float
+		{text: "1e20", price: 1e20, e8: 0},              // too big for e8
+		{text: "0", wantErr: true},
+		{text: "-1", wantErr: true},
+		{text: "NaN", wantErr: true},
+		{text: "Inf", wantErr: true},
+		{text: "abc", wantErr: true},
+	} {
+		price, e8, err := parsePrice(tc.text)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parsePrice(%q) = %v, %d, want an error", tc.text, price, e8)
+			}
+			continue
+		}
+		if err != nil || price != tc.price || e8 != tc.e8 {
+			t.Errorf("parsePrice(%q) = %v, %d, %v, want %v, %d", tc.text, price, e8, err, tc.price, tc.e8)
+		}
+	}
+}
+
+func TestPriceE8(t *testing.T) {
+	for _, tc := range []struct {
+		price float64
+		want  int64
+	}{
+		{0.00001234, 1234},
+		{0.1 + 0.2, 30000000},
+		{1.234567895, 123456790},
+		{1e300, 0},
+	} {
+		if got := PriceE8(tc.price); got != tc.want {
+			t.Errorf("PriceE8(%v) = %d, want %d", tc.price, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package feedtest runs a fake Binance in process, for end-to-end tests of
+// the feed and everything behind it without reaching the exchange. The
+// server streams the trades a script makes up over the combined stream
+// protocol, answers SUBSCRIBE and UNSUBSCRIBE, and serves the REST
+// endpoints ingestion calls:
+//
+//	srv := feedtest.NewServer()
+//	defer srv.Close()
+//	ex, _ := feed.New("binance", feed.Config{Binance: srv.Endpoints()})
+//	go srv.Play(ctx,
+//		feedtest.Trade("btcusdt", 97000, 0.1, feed.SideBuy),
+//		feedtest.Disconnect(),
+//		feedtest.WaitForStream("ethusdt"),
+//		feedtest.Trade("ethusdt", 3100, 1, feed.SideSell),
+//	)
+//
+// The ingestion service takes the same endpoints as --binance-stream-url
+// and --binance-api-url.
+package feedtest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ingestion/feed"
+)
+
+// Server is a fake Binance listening on a local port until Close
+type Server struct {
+	http *httptest.Server
+
+	mu          sync.Mutex
+	conns       map[*conn]bool
+	connections int           // ever accepted
+	changed     chan struct{} // closed and replaced whenever conns or their streams change
+	tradeID     int64
+}
+
+// conn is one client connection and the symbols it streams
+type conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	symbols map[string]bool
+}
+
+func (c *conn) write(msg []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(time.Second))
+	return c.ws.WriteMessage(websocket.TextMessage, msg)
+}
+
+// NewServer starts a server with nothing to stream until a script plays
+func NewServer() *Server {
+	s := &Server{conns: make(map[*conn]bool), changed: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", s.handleStream)
+	mux.HandleFunc("/api/v3/time", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int64{"serverTime": time.Now().UnixMilli()})
+	})
+	// No history: ingestion backfills nothing
+	mux.HandleFunc("/api/v3/klines", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	s.http = httptest.NewServer(mux)
+	return s
+}
+
+// Endpoints are the server's URLs, for feed.Config.Binance
+func (s *Server) Endpoints() feed.Endpoints {
+	return feed.Endpoints{
+		Stream: "ws" + strings.TrimPrefix(s.http.URL, "http"),
+		API:    s.http.URL,
+	}
+}
+
+// Close drops every connection and stops the server
+func (s *Server) Close() {
+	s.drop()
+	s.http.Close()
+}
+
+// Connections returns how many connections the server has accepted, so
+// tests can tell a client reconnected
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+// Streams returns the symbols any open connection streams, sorted
+func (s *Server) Streams() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var symbols []string
+	for c := range s.conns {
+		for sym := range c.symbols {
+			if !slices.Contains(symbols, sym) {
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+	slices.Sort(symbols)
+	return symbols
+}
+
+// notify wakes the steps waiting for a connection or stream. Called with mu
+// held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// handleStream accepts /stream?streams=btcusdt@trade/... and applies the
+// client's SUBSCRIBE and UNSUBSCRIBE requests until it disconnects
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &conn{ws: ws, symbols: make(map[string]bool)}
+	for _, stream := range strings.Split(r.URL.Query().Get("streams"), "/") {
+		if sym, ok := strings.CutSuffix(stream, "@trade"); ok {
+			c.symbols[sym] = true
+		}
+	}
+	s.mu.Lock()
+	s.conns[c] = true
+	s.connections++
+	s.notify()
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.notify()
+		s.mu.Unlock()
+		ws.Close()
+	}()
+	for {
+		var req struct {
+			Method string   `json:"method"`
+			Params []string `json:"params"`
+			ID     int      `json:"id"`
+		}
+		if err := ws.ReadJSON(&req); err != nil {
+			return
+		}
+		s.mu.Lock()
+		for _, stream := range req.Params {
+			sym, ok := strings.CutSuffix(stream, "@trade")
+			switch {
+			case !ok:
+			case req.Method == "SUBSCRIBE":
+				c.symbols[sym] = true
+			case req.Method == "UNSUBSCRIBE":
+				delete(c.symbols, sym)
+			}
+		}
+		s.notify()
+		s.mu.Unlock()
+		reply, _ := json.Marshal(map[string]any{"result": nil, "id": req.ID})
+		c.write(reply)
+	}
+}
+
+// streaming returns the connections streaming symbol, or a channel closed
+// once that may have changed when there are none
+func (s *Server) streaming(symbol string) ([]*conn, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var conns []*conn
+	for c := range s.conns {
+		if c.symbols[symbol] {
+			conns = append(conns, c)
+		}
+	}
+	return conns, s.changed
+}
+
+// waitFor blocks until a connection streams symbol
+func (s *Server) waitFor(ctx context.Context, symbol string) ([]*conn, error) {
+	for {
+		conns, changed := s.streaming(symbol)
+		if len(conns) > 0 {
+			return conns, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// drop closes every connection without a close frame, like a network
+// failure
+func (s *Server) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.ws.Close()
+	}
+}
+
+// Step is one action of a script
+type Step func(ctx context.Context, s *Server) error
+
+// Play runs a script's steps in order until one fails or ctx is done
+func (s *Server) Play(ctx context.Context, steps ...Step) error {
+	for _, step := range steps {
+		if err := step(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Trade sends a trade, stamped with the current time, to the connections
+// streaming its symbol. It waits for one if there is none, so a script
+// doesn't race the client connecting, reconnecting or resubscribing.
+func Trade(symbol string, price, qty float64, side string) Step {
+	return func(ctx context.Context, s *Server) error {
+		conns, err := s.waitFor(ctx, symbol)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.tradeID++
+		id := s.tradeID
+		s.mu.Unlock()
+		now := time.Now().UnixMilli()
+		msg, _ := json.Marshal(map[string]any{
+			"stream": symbol + "@trade",
+			"data": map[string]any{
+				"e": "trade",
+				"E": now,
+				"s": strings.ToUpper(symbol),
+				"t": id,
+				"p": strconv.FormatFloat(price, 'f', -1, 64),
+				"q": strconv.FormatFloat(qty, 'f', -1, 64),
+				"T": now,
+				"m": side == feed.SideSell, // the buyer made the market when the taker sold
+				"M": true,
+			},
+		})
+		for _, c := range conns {
+			c.write(msg)
+		}
+		return nil
+	}
+}
+
+// Raw sends a message as is to every open connection, e.g. one the feed
+// should reject
+func Raw(message string) Step {
+	return func(ctx context.Context, s *Server) error {
+		s.mu.Lock()
+		conns := make([]*conn, 0, len(s.conns))
+		for c := range s.conns {
+			conns = append(conns, c)
+		}
+		s.mu.Unlock()
+		for _, c := range conns {
+			c.write([]byte(message))
+		}
+		return nil
+	}
+}
+
+// Sleep pauses the script
+func Sleep(d time.Duration) Step {
+	return func(ctx context.Context, s *Server) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+			return nil
+		}
+	}
+}
+
+// Disconnect drops every connection without a close frame, to test
+// reconnects
+func Disconnect() Step {
+	return func(ctx context.Context, s *Server) error {
+		s.drop()
+		return nil
+	}
+}
+
+// WaitForStream waits until a connection streams symbol, e.g. after the
+// client was asked to switch to it
+func WaitForStream(symbol string) Step {
+	return func(ctx context.Context, s *Server) error {
+		_, err := s.waitFor(ctx, symbol)
+		return err
+	}
+}
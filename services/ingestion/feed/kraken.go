@@ -0,0 +1,384 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var krakenEndpoints = Endpoints{
+	Stream: "wss://ws.kraken.com/v2",
+	API:    "https://api.kraken.com",
+}
+
+// Levels per side kept of each Kraken book; the checksum covers the top 10
+const krakenBookDepth = 10
+
+// krakenMessage is any message on a Kraken v2 connection; data depends on
+// the channel
+type krakenMessage struct {
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type krakenTrade struct {
+	Symbol    string      `json:"symbol"`
+	Price     json.Number `json:"price"` // kept as text for the exact price
+	Qty       float64     `json:"qty"`
+	Side      string      `json:"side"` // buy or sell, the taker's
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+type krakenBookUpdate struct {
+	Symbol    string        `json:"symbol"`
+	Bids      []krakenLevel `json:"bids"`
+	Asks      []krakenLevel `json:"asks"`
+	Checksum  uint32        `json:"checksum"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+type krakenLevel struct {
+	Price float64 `json:"price"`
+	Qty   float64 `json:"qty"`
+}
+
+type krakenInstruments struct {
+	Pairs []struct {
+		Symbol         string `json:"symbol"`
+		PricePrecision int    `json:"price_precision"`
+		QtyPrecision   int    `json:"qty_precision"`
+	} `json:"pairs"`
+}
+
+// krakenBook is a pair's book as kept from snapshots and updates
+type krakenBook struct {
+	bids, asks bookSide
+	time       int64
+}
+
+// Kraken reads the trade and book channels of Kraken's WebSocket v2 API.
+// Books are verified against the checksum Kraken sends with every update
+// and resubscribed from a fresh snapshot when they drift.
+type Kraken struct {
+	endpoints Endpoints
+	conn      *websocket.Conn
+	writeMu   sync.Mutex // the reader resubscribes books alongside Subscribe
+
+	mu        sync.Mutex
+	pairs     map[string][]string // Kraken pair -> pipeline symbols
+	precision map[string][2]int   // Kraken pair -> price and qty decimals
+	books     map[string]*krakenBook
+	changed   []string // pairs whose book changed since UpdatedBooks
+	lastMessage
+	rejects
+}
+
+func (f *Kraken) Name() string { return "Kraken" }
+
+// Connect opens the v2 stream, asks for the instrument list the book
+// checksums need, and subscribes symbols
+func (f *Kraken) Connect(ctx context.Context, symbols []string) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, f.endpoints.Stream, nil)
+	if err != nil {
+		return err
+	}
+	f.conn = conn
+	keepAlive(conn)
+	f.mu.Lock()
+	f.pairs = make(map[string][]string)
+	f.precision = make(map[string][2]int)
+	f.books = make(map[string]*krakenBook)
+	f.changed = nil
+	f.mu.Unlock()
+
+	if err := f.write("subscribe", map[string]any{"channel": "instrument"}); err != nil {
+		conn.Close()
+		return err
+	}
+	for _, sym := range symbols {
+		if err := f.Subscribe(sym); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribe joins the trade and book channels for the symbol's pair.
+// Several symbols can share a pair (btcfdusd and btcusd both follow
+// BTC/USD); only the first one subscribes.
+func (f *Kraken) Subscribe(symbol string) error {
+	pair := krakenPair(symbol)
+	f.mu.Lock()
+	symbols := f.pairs[pair]
+	if slices.Contains(symbols, symbol) {
+		f.mu.Unlock()
+		return nil
+	}
+	f.pairs[pair] = append(symbols, symbol)
+	f.mu.Unlock()
+	if len(symbols) > 0 {
+		return nil
+	}
+	if err := f.write("subscribe", krakenParams("trade", pair)); err != nil {
+		return err
+	}
+	return f.write("subscribe", krakenParams("book", pair))
+}
+
+// Unsubscribe leaves both channels for the symbol's pair once no other
+// symbol follows it
+func (f *Kraken) Unsubscribe(symbol string) error {
+	pair := krakenPair(symbol)
+	f.mu.Lock()
+	symbols := slices.DeleteFunc(f.pairs[pair], func(s string) bool { return s == symbol })
+	if len(symbols) > 0 {
+		f.pairs[pair] = symbols
+		f.mu.Unlock()
+		return nil
+	}
+	delete(f.pairs, pair)
+	delete(f.books, pair)
+	f.mu.Unlock()
+	if err := f.write("unsubscribe", krakenParams("trade", pair)); err != nil {
+		return err
+	}
+	return f.write("unsubscribe", krakenParams("book", pair))
+}
+
+func krakenParams(channel, pair string) map[string]any {
+	params := map[string]any{"channel": channel, "symbol": []string{pair}}
+	switch channel {
+	case "trade":
+		params["snapshot"] = false
+	case "book":
+		params["depth"] = krakenBookDepth
+	}
+	return params
+}
+
+func (f *Kraken) write(method string, params map[string]any) error {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+	return f.conn.WriteJSON(map[string]any{"method": method, "params": params})
+}
+
+func (f *Kraken) ReadTrades() ([]Trade, error) {
+	message, err := readMessage(f.conn)
+	f.last = message
+	if err != nil {
+		return nil, err
+	}
+
+	var msg krakenMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		f.reject(fmt.Errorf("undecodable message: %w", err))
+		return nil, nil
+	}
+	switch msg.Channel {
+	case "trade":
+		return f.trades(msg)
+	case "book":
+		f.applyBook(msg)
+	case "instrument":
+		var instruments krakenInstruments
+		if json.Unmarshal(msg.Data, &instruments) == nil {
+			f.mu.Lock()
+			for _, p := range instruments.Pairs {
+				f.precision[p.Symbol] = [2]int{p.PricePrecision, p.QtyPrecision}
+			}
+			f.mu.Unlock()
+		}
+	}
+	// Heartbeats, status and subscription replies carry no trades
+	return nil, nil
+}
+
+func (f *Kraken) trades(msg krakenMessage) ([]Trade, error) {
+	var data []krakenTrade
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		f.reject(fmt.Errorf("undecodable trades: %w", err))
+		return nil, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var trades []Trade
+	for _, t := range data {
+		price, e8, err := parsePrice(t.Price.String())
+		if err != nil {
+			f.reject(fmt.Errorf("%s trade: %w", t.Symbol, err))
+			continue
+		}
+		if err := checkQty(t.Qty); err != nil {
+			f.reject(fmt.Errorf("%s trade: %w", t.Symbol, err))
+			continue
+		}
+		for _, symbol := range f.pairs[t.Symbol] {
+			trades = append(trades, Trade{
+				Symbol:  symbol,
+				Price:   price,
+				PriceE8: e8,
+				Qty:     t.Qty,
+				Side:    t.Side,
+				Time:    t.Timestamp.UnixMilli(),
+			})
+		}
+	}
+	return trades, nil
+}
+
+// applyBook folds a snapshot or update into the pair's book and checks the
+// result against Kraken's checksum. A book that doesn't match is dropped
+// and resubscribed, which starts it over from a snapshot.
+func (f *Kraken) applyBook(msg krakenMessage) {
+	var data []krakenBookUpdate
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return
+	}
+
+	var resubscribe []string
+	f.mu.Lock()
+	for _, u := range data {
+		if _, ok := f.pairs[u.Symbol]; !ok {
+			continue
+		}
+		book := f.books[u.Symbol]
+		if msg.Type == "snapshot" {
+			book = &krakenBook{bids: bookSide{}, asks: bookSide{}}
+			f.books[u.Symbol] = book
+		} else if book == nil {
+			// Updates still in flight from before a resubscribe
+			continue
+		}
+		for _, l := range u.Bids {
+			book.bids.apply(l.Price, l.Qty)
+		}
+		for _, l := range u.Asks {
+			book.asks.apply(l.Price, l.Qty)
+		}
+		book.bids.truncate(true, krakenBookDepth)
+		book.asks.truncate(false, krakenBookDepth)
+		book.time = time.Now().UnixMilli()
+		if !u.Timestamp.IsZero() {
+			book.time = u.Timestamp.UnixMilli()
+		}
+
+		// Without the instrument's precision there is nothing to check against
+		if precision, ok := f.precision[u.Symbol]; ok && krakenChecksum(book, precision) != u.Checksum {
+			log.Printf("Kraken %s book checksum mismatch, resubscribing", u.Symbol)
+			delete(f.books, u.Symbol)
+			resubscribe = append(resubscribe, u.Symbol)
+			continue
+		}
+		if !slices.Contains(f.changed, u.Symbol) {
+			f.changed = append(f.changed, u.Symbol)
+		}
+	}
+	f.mu.Unlock()
+
+	for _, pair := range resubscribe {
+		if f.write("unsubscribe", krakenParams("book", pair)) == nil {
+			f.write("subscribe", krakenParams("book", pair))
+		}
+	}
+}
+
+// krakenChecksum is the CRC32 Kraken computes over the top 10 asks, lowest
+// first, then the top 10 bids, highest first: each level's price and qty
+// written at the pair's precision without the decimal point and leading
+// zeros
+func krakenChecksum(book *krakenBook, precision [2]int) uint32 {
+	var b strings.Builder
+	write := func(levels []BookLevel) {
+		for _, l := range levels {
+			for i, v := range []float64{l.Price, l.Qty} {
+				s := strconv.FormatFloat(v, 'f', precision[i], 64)
+				b.WriteString(strings.TrimLeft(strings.Replace(s, ".", "", 1), "0"))
+			}
+		}
+	}
+	write(book.asks.levels(false, 10))
+	write(book.bids.levels(true, 10))
+	return crc32.ChecksumIEEE([]byte(b.String()))
+}
+
+func (f *Kraken) UpdatedBooks() []Book {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var books []Book
+	for _, pair := range f.changed {
+		book, ok := f.books[pair]
+		if !ok {
+			continue
+		}
+		for _, symbol := range f.pairs[pair] {
+			books = append(books, Book{
+				Symbol: symbol,
+				Bids:   book.bids.levels(true, krakenBookDepth),
+				Asks:   book.asks.levels(false, krakenBookDepth),
+				Time:   book.time,
+			})
+		}
+	}
+	f.changed = f.changed[:0]
+	return books
+}
+
+func (f *Kraken) ServerTime(ctx context.Context) (time.Time, error) {
+	var body struct {
+		Error  []string `json:"error"`
+		Result struct {
+			UnixTime int64 `json:"unixtime"`
+		} `json:"result"`
+	}
+	if err := getJSON(ctx, f.endpoints.API+"/0/public/Time", &body); err != nil {
+		return time.Time{}, err
+	}
+	if len(body.Error) > 0 {
+		return time.Time{}, fmt.Errorf("kraken: %s", strings.Join(body.Error, ", "))
+	}
+	return time.Unix(body.Result.UnixTime, 0), nil
+}
+
+func (f *Kraken) Close() error {
+	if f.conn == nil {
+		return nil
+	}
+	return f.conn.Close()
+}
+
+// Quote currencies Kraken lists pairs in. FDUSD isn't one, so "btcfdusd"
+// follows BTC/USD.
+var krakenQuotes = []struct{ suffix, quote string }{
+	{"FDUSD", "USD"},
+	{"USDT", "USDT"},
+	{"USDC", "USDC"},
+	{"EUR", "EUR"},
+	{"GBP", "GBP"},
+	{"USD", "USD"},
+}
+
+// krakenPair maps a pipeline symbol to a Kraken v2 pair, e.g. "btcusdt" ->
+// "BTC/USDT" and "btceur" -> "BTC/EUR"
+func krakenPair(symbol string) string {
+	upper := strings.ToUpper(symbol)
+	for _, q := range krakenQuotes {
+		if base, ok := strings.CutSuffix(upper, q.suffix); ok {
+			return base + "/" + q.quote
+		}
+	}
+	return upper + "/USD"
+}
@@ -0,0 +1,269 @@
+package feed
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Longest pause a replay keeps between two trades. Recordings can have gaps
+// of minutes, which would look like a dead connection downstream.
+const replayMaxGap = 5 * time.Second
+
+// Replay plays back a recording instead of streaming from an exchange. It
+// reads JSON lines, gzipped when the file name ends in .gz, each either a
+// Trade as --tee-json writes them or a raw exchange message as --record
+// archives them, which is decoded like the live feed would. Trades keep the
+// spacing they were recorded or received with, divided by the replay
+// speed, and are stamped with the time they are replayed at, so the rest of
+// the pipeline sees them as live. At the end of the file it starts over.
+type Replay struct {
+	path    string
+	speed   float64
+	binance Binance // decodes raw Binance messages
+
+	mu      sync.Mutex
+	symbols map[string]bool // subscribed; trades of other symbols are skipped
+	file    *os.File
+	lines   *bufio.Scanner
+	closed  chan struct{}
+
+	start   time.Time // when the first trade of this pass was replayed
+	first   int64     // recorded time of that trade, ms
+	prev    int64     // recorded time of the last trade read, ms
+	skipped time.Duration
+	matched bool // a trade of a subscribed symbol came up this pass
+	lastMessage
+	rejects
+}
+
+// NewReplay returns a feed replaying path at speed times the recorded pace,
+// 1 for real time
+func NewReplay(path string, speed float64) (*Replay, error) {
+	if path == "" {
+		return nil, errors.New("replay needs a file of recorded trades")
+	}
+	if !(speed > 0) {
+		return nil, fmt.Errorf("invalid replay speed %v, want more than 0", speed)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return &Replay{path: path, speed: speed}, nil
+}
+
+func (f *Replay) Name() string { return "Replay" }
+
+// Connect opens the file and replays it from the start
+func (f *Replay) Connect(ctx context.Context, symbols []string) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.symbols = make(map[string]bool)
+	for _, sym := range symbols {
+		f.symbols[sym] = true
+	}
+	f.file = file
+	f.closed = make(chan struct{})
+	if err := f.rewind(); err != nil {
+		file.Close()
+		return err
+	}
+	return nil
+}
+
+// rewind starts a pass over the file. Called with mu held.
+func (f *Replay) rewind() error {
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var r io.Reader = f.file
+	if strings.HasSuffix(f.path, ".gz") {
+		gz, err := gzip.NewReader(f.file)
+		if err != nil {
+			return err
+		}
+		r = gz
+	}
+	f.lines = bufio.NewScanner(r)
+	f.lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	f.start, f.first, f.prev, f.skipped, f.matched = time.Time{}, 0, 0, 0, false
+	return nil
+}
+
+func (f *Replay) Subscribe(symbol string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.symbols[symbol] = true
+	return nil
+}
+
+func (f *Replay) Unsubscribe(symbol string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.symbols, symbol)
+	return nil
+}
+
+// ReadTrades waits until the next recorded trades of subscribed symbols
+// are due and returns them
+func (f *Replay) ReadTrades() ([]Trade, error) {
+	for {
+		trades, due, err := f.next()
+		if err != nil {
+			return nil, err
+		}
+		if len(trades) == 0 {
+			continue
+		}
+
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-f.closed:
+			timer.Stop()
+			return nil, net.ErrClosed
+		case <-timer.C:
+		}
+		now := time.Now().UnixMilli()
+		for i := range trades {
+			trades[i].Time = now
+		}
+		return trades, nil
+	}
+}
+
+// recordedLine is a line of a recording, a Trade or a raw message
+type recordedLine struct {
+	Trade
+	Received string          `json:"received"` // RFC 3339, of raw messages
+	Exchange string          `json:"exchange"`
+	Message  json.RawMessage `json:"message"`
+}
+
+// next reads the following line and works out when its trades are due,
+// returning none for lines to skip
+func (f *Replay) next() ([]Trade, time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.closed:
+		return nil, time.Time{}, net.ErrClosed
+	default:
+	}
+
+	f.last = nil
+	if !f.lines.Scan() {
+		// A recording cut short, e.g. by a crash, ends like a complete one
+		if err := f.lines.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, time.Time{}, err
+		}
+		if !f.matched {
+			return nil, time.Time{}, fmt.Errorf("no trades of the subscribed symbols in %s", f.path)
+		}
+		return nil, time.Time{}, f.rewind()
+	}
+	line := f.lines.Bytes()
+	if len(line) == 0 {
+		return nil, time.Time{}, nil
+	}
+	f.last = append([]byte(nil), line...)
+
+	var rec recordedLine
+	if err := json.Unmarshal(line, &rec); err != nil {
+		f.reject(fmt.Errorf("undecodable line: %w", err))
+		return nil, time.Time{}, nil
+	}
+	var trades []Trade
+	at := rec.Time
+	if rec.Message != nil {
+		received, err := time.Parse(time.RFC3339Nano, rec.Received)
+		if err != nil {
+			f.reject(fmt.Errorf("raw message without a receive time: %w", err))
+			return nil, time.Time{}, nil
+		}
+		at = received.UnixMilli()
+		trades = f.decode(rec.Exchange, rec.Message)
+	} else if err := checkPrice(rec.Price); err != nil {
+		f.reject(err)
+		return nil, time.Time{}, nil
+	} else {
+		// Recorded before trades carried the exact price
+		if rec.PriceE8 == 0 {
+			rec.PriceE8 = PriceE8(rec.Price)
+		}
+		trades = []Trade{rec.Trade}
+	}
+
+	// Gaps beyond replayMaxGap, and clocks running backwards, are skipped
+	// over by moving the recorded start forward
+	if f.start.IsZero() {
+		f.start, f.first, f.prev = time.Now(), at, at
+	}
+	gap := time.Duration(float64(time.Duration(at-f.prev)*time.Millisecond) / f.speed)
+	if gap > replayMaxGap {
+		f.skipped += gap - replayMaxGap
+	} else if gap < 0 {
+		f.skipped += gap
+	}
+	f.prev = at
+
+	subscribed := trades[:0]
+	for _, trade := range trades {
+		if f.symbols[trade.Symbol] && !trade.Backfill {
+			subscribed = append(subscribed, trade)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil, time.Time{}, nil
+	}
+	f.matched = true
+	elapsed := time.Duration(float64(time.Duration(at-f.first)*time.Millisecond) / f.speed)
+	return subscribed, f.start.Add(elapsed - f.skipped), nil
+}
+
+// decode turns a raw message archived from exchange into trades, like the
+// live feed would have
+func (f *Replay) decode(exchange string, message []byte) []Trade {
+	if exchange != "binance" {
+		f.reject(fmt.Errorf("can't replay raw %s messages, only binance", exchange))
+		return nil
+	}
+	trades := f.binance.parse(message)
+	for _, err := range f.binance.Rejected() {
+		f.reject(err)
+	}
+	return trades
+}
+
+// ServerTime is the local clock; a replay has no exchange to be skewed from
+func (f *Replay) ServerTime(ctx context.Context) (time.Time, error) {
+	return time.Now(), nil
+}
+
+// Close ends the replay, unblocking ReadTrades
+func (f *Replay) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	select {
+	case <-f.closed:
+		return nil
+	default:
+	}
+	close(f.closed)
+	return f.file.Close()
+}
@@ -0,0 +1,159 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// How often a synthetic feed hands out the trades that came due, so
+	// high rates go out in batches instead of one timer per trade
+	syntheticTick = 10 * time.Millisecond
+
+	// Price every symbol starts its random walk at, and the standard
+	// deviation of each step as a fraction of the price
+	syntheticStart      = 100.0
+	syntheticVolatility = 0.0005
+)
+
+// Synthetic makes up trades instead of streaming them from an exchange: a
+// random walk per symbol at a fixed rate shared between the subscribed
+// symbols, for load testing the pipeline without depending on the market.
+type Synthetic struct {
+	rate float64 // trades per second across all symbols
+
+	mu      sync.Mutex
+	symbols []string
+	prices  map[string]float64 // kept across reconnects
+	next    int                // symbol the next trade is for
+	owed    float64            // trades due but not yet made, below 1
+	last    time.Time          // when trades were last made
+	ticker  *time.Ticker
+	closed  chan struct{}
+}
+
+// NewSynthetic returns a feed making rate trades per second
+func NewSynthetic(rate float64) (*Synthetic, error) {
+	if !(rate > 0) || math.IsInf(rate, 0) {
+		return nil, fmt.Errorf("invalid synthetic trade rate %v, want more than 0", rate)
+	}
+	return &Synthetic{rate: rate, prices: make(map[string]float64)}, nil
+}
+
+func (f *Synthetic) Name() string { return "Synthetic" }
+
+func (f *Synthetic) Connect(ctx context.Context, symbols []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.symbols = append([]string(nil), symbols...)
+	f.last = time.Now()
+	f.owed = 0
+	f.ticker = time.NewTicker(syntheticTick)
+	f.closed = make(chan struct{})
+	return nil
+}
+
+func (f *Synthetic) Subscribe(symbol string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, sym := range f.symbols {
+		if sym == symbol {
+			return nil
+		}
+	}
+	f.symbols = append(f.symbols, symbol)
+	return nil
+}
+
+func (f *Synthetic) Unsubscribe(symbol string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, sym := range f.symbols {
+		if sym == symbol {
+			f.symbols = append(f.symbols[:i], f.symbols[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// ReadTrades waits for the next tick and returns the trades that came due
+// since the last one, taking turns between the symbols
+func (f *Synthetic) ReadTrades() ([]Trade, error) {
+	for {
+		select {
+		case <-f.closed:
+			return nil, net.ErrClosed
+		case <-f.ticker.C:
+		}
+		if trades := f.make(time.Now()); len(trades) > 0 {
+			return trades, nil
+		}
+	}
+}
+
+func (f *Synthetic) make(now time.Time) []Trade {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// A stalled reader doesn't get the backlog in one go; at most a second
+	f.owed += f.rate * min(now.Sub(f.last).Seconds(), 1)
+	f.last = now
+	n := int(f.owed)
+	f.owed -= float64(n)
+	if len(f.symbols) == 0 {
+		return nil
+	}
+
+	trades := make([]Trade, n)
+	for i := range trades {
+		symbol := f.symbols[f.next%len(f.symbols)]
+		f.next++
+		price, ok := f.prices[symbol]
+		if !ok {
+			price = syntheticStart
+		}
+		price *= math.Exp(rand.NormFloat64() * syntheticVolatility)
+		f.prices[symbol] = price
+
+		side := SideBuy
+		if rand.IntN(2) == 0 {
+			side = SideSell
+		}
+		trades[i] = Trade{
+			Symbol:  symbol,
+			Price:   price,
+			PriceE8: PriceE8(price),
+			Qty:     (math.Round(rand.ExpFloat64()*1e4) + 1) / 1e5,
+			Side:    side,
+			Time:    now.UnixMilli(),
+		}
+	}
+	return trades
+}
+
+// ServerTime is the local clock; synthetic trades are stamped with it
+func (f *Synthetic) ServerTime(ctx context.Context) (time.Time, error) {
+	return time.Now(), nil
+}
+
+// Close stops making trades, unblocking ReadTrades
+func (f *Synthetic) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed == nil {
+		return nil
+	}
+	select {
+	case <-f.closed:
+		return nil
+	default:
+	}
+	close(f.closed)
+	f.ticker.Stop()
+	return nil
+}
@@ -5,12 +5,15 @@ go 1.23
 require (
 	github.com/gorilla/websocket v1.5.3
 	github.com/nats-io/nats.go v1.38.0
+	github.com/segmentio/kafka-go v0.4.47
 )
 
 require (
 	github.com/klauspost/compress v1.17.11 // indirect
 	github.com/nats-io/nkeys v0.4.9 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	golang.org/x/crypto v0.31.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
 )
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"ingestion/feed"
+)
+
+// KafkaPublisher copies normalized trades to a Kafka topic for downstream
+// jobs. Messages are keyed by symbol, so each symbol's trades land in one
+// partition in order.
+type KafkaPublisher struct {
+	outputCounter
+	w *kafka.Writer
+}
+
+// NewKafkaPublisher writes to topic on the comma separated brokers. Writes
+// are batched in the background so a slow or unreachable cluster never
+// holds up the NATS pipeline.
+func NewKafkaPublisher(brokers, topic string) *KafkaPublisher {
+	p := &KafkaPublisher{outputCounter: outputCounter{name: "Kafka"}}
+	p.w = &kafka.Writer{
+		Addr:                   kafka.TCP(parseBrokers(brokers)...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireOne,
+		BatchTimeout:           50 * time.Millisecond,
+		Async:                  true,
+		AllowAutoTopicCreation: true,
+		Completion:             p.completed,
+	}
+	return p
+}
+
+// Publish queues one trade, already encoded as JSON. The writer looks up
+// the topic's partitions before queueing, so an unreachable cluster fails
+// here rather than in completed.
+func (p *KafkaPublisher) Publish(trade feed.Trade, data []byte) {
+	msg := kafka.Message{Key: []byte(trade.Symbol), Value: data}
+	if err := p.w.WriteMessages(context.Background(), msg); err != nil {
+		p.failure(1, err)
+	}
+}
+
+// completed is called with each batch the writer sent or gave up on
+func (p *KafkaPublisher) completed(messages []kafka.Message, err error) {
+	if err != nil {
+		p.failure(len(messages), err)
+		return
+	}
+	p.published.Add(int64(len(messages)))
+}
+
+// Close flushes queued trades and closes the connections
+func (p *KafkaPublisher) Close() error {
+	return p.w.Close()
+}
+
+// parseBrokers splits a comma separated broker list
+func parseBrokers(list string) []string {
+	var brokers []string
+	for _, b := range strings.Split(list, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
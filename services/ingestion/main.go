@@ -1,28 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/nats-io/nats.go"
-)
-
-// TradeMessage is published to NATS
-type TradeMessage struct {
-	Symbol string  `json:"symbol"`
-	Price  float64 `json:"price"`
-	Time   int64   `json:"time"`
-}
 
-// BinanceTrade represents a trade event from Binance
-type BinanceTrade struct {
-	Price string `json:"p"`
-	Time  int64  `json:"T"`
-}
+	"ingestion/feed"
+)
 
 func main() {
 	symbol := os.Getenv("SYMBOL")
@@ -35,13 +30,219 @@ func main() {
 		natsURL = "nats://localhost:4222"
 	}
 
-	log.Printf("Ingestion service starting for %s", symbol)
+	exchange := os.Getenv("EXCHANGE")
+	if exchange == "" {
+		exchange = "binance"
+	}
+	flag.StringVar(&exchange, "exchange", exchange, "exchange to stream trades from: binance, coinbase or kraken (env EXCHANGE)")
+	source := os.Getenv("SOURCE")
+	if source == "" {
+		source = sourceLive
+	}
+	flag.StringVar(&source, "source", source, "where trades come from: live from --exchange, replay from --file, or synthetic random walks (env SOURCE)")
+	replayFile := os.Getenv("REPLAY_FILE")
+	flag.StringVar(&replayFile, "file", replayFile, "trades to replay with --source=replay, as JSON lines like --tee-json writes (env REPLAY_FILE)")
+	replaySpeed := 1.0
+	if v, err := strconv.ParseFloat(os.Getenv("REPLAY_SPEED"), 64); err == nil {
+		replaySpeed = v
+	}
+	flag.Float64Var(&replaySpeed, "speed", replaySpeed, "how many times faster than recorded to replay, 1 for real time (env REPLAY_SPEED)")
+	syntheticRate := 100.0
+	if v, err := strconv.ParseFloat(os.Getenv("SYNTHETIC_RATE"), 64); err == nil {
+		syntheticRate = v
+	}
+	flag.Float64Var(&syntheticRate, "synthetic-rate", syntheticRate, "trades per second --source=synthetic makes up, shared between the streamed symbols (env SYNTHETIC_RATE)")
+	pairExchanges := os.Getenv("PAIR_EXCHANGES")
+	flag.StringVar(&pairExchanges, "pair-exchange", pairExchanges, "comma separated symbol=exchange pairs streamed from another exchange than --exchange, e.g. ethusdt=coinbase (env PAIR_EXCHANGES)")
+	watchlist := os.Getenv("WATCHLIST")
+	flag.StringVar(&watchlist, "watchlist", watchlist, "comma separated symbols to stream besides the selected one (env WATCHLIST)")
+	stallTimeout := time.Minute
+	if v, err := time.ParseDuration(os.Getenv("STALL_TIMEOUT")); err == nil {
+		stallTimeout = v
+	}
+	flag.DurationVar(&stallTimeout, "stall-timeout", stallTimeout, "reconnect when the exchange sends nothing for this long (env STALL_TIMEOUT)")
+	reconnectMax := time.Minute
+	if v, err := time.ParseDuration(os.Getenv("RECONNECT_MAX")); err == nil {
+		reconnectMax = v
+	}
+	flag.DurationVar(&reconnectMax, "reconnect-max", reconnectMax, "longest wait between exchange reconnect attempts (env RECONNECT_MAX)")
+	backfillPeriod := 30 * time.Minute
+	if v, err := time.ParseDuration(os.Getenv("BACKFILL")); err == nil {
+		backfillPeriod = v
+	}
+	flag.DurationVar(&backfillPeriod, "backfill", backfillPeriod, "history to fetch for a symbol before streaming it, 0 to disable (env BACKFILL)")
+	teeJSON := flag.Bool("tee-json", false, "also write every trade to stdout as JSON lines")
+	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
+	flag.StringVar(&kafkaBrokers, "kafka-brokers", kafkaBrokers, "comma separated Kafka brokers to also publish trades to, empty to disable (env KAFKA_BROKERS)")
+	kafkaTopic := os.Getenv("KAFKA_TOPIC")
+	if kafkaTopic == "" {
+		kafkaTopic = "trades"
+	}
+	flag.StringVar(&kafkaTopic, "kafka-topic", kafkaTopic, "Kafka topic for trades, keyed by symbol (env KAFKA_TOPIC)")
+	natsOutURL := os.Getenv("NATS_OUT_URL")
+	flag.StringVar(&natsOutURL, "nats-out-url", natsOutURL, "NATS server to also publish trades to on per-symbol subjects, empty to disable (env NATS_OUT_URL)")
+	natsOutPrefix := os.Getenv("NATS_OUT_PREFIX")
+	if natsOutPrefix == "" {
+		natsOutPrefix = "trades"
+	}
+	flag.StringVar(&natsOutPrefix, "nats-out-prefix", natsOutPrefix, "subject prefix for --nats-out-url, trades go to <prefix>.<symbol> (env NATS_OUT_PREFIX)")
+	natsOutStream := os.Getenv("NATS_OUT_STREAM")
+	flag.StringVar(&natsOutStream, "nats-out-stream", natsOutStream, "JetStream stream to persist --nats-out-url subjects in, empty for plain NATS (env NATS_OUT_STREAM)")
+	recordWindow := time.Minute
+	if v, err := time.ParseDuration(os.Getenv("RECORD_WINDOW")); err == nil {
+		recordWindow = v
+	}
+	flag.DurationVar(&recordWindow, "record-window", recordWindow, "keep this much of the raw exchange messages in memory for dumps, 0 to disable (env RECORD_WINDOW)")
+	dumpDir := os.Getenv("DUMP_DIR")
+	if dumpDir == "" {
+		dumpDir = os.TempDir()
+	}
+	flag.StringVar(&dumpDir, "dump-dir", dumpDir, "directory raw message dumps are written to (env DUMP_DIR)")
+	recordPath := os.Getenv("RECORD")
+	flag.StringVar(&recordPath, "record", recordPath, "archive every raw exchange message with its receive time to files named after this path, e.g. recordings/raw.jsonl, empty to disable (env RECORD)")
+	recordMaxSize := 100
+	if v, err := strconv.Atoi(os.Getenv("RECORD_MAX_SIZE")); err == nil {
+		recordMaxSize = v
+	}
+	flag.IntVar(&recordMaxSize, "record-max-size", recordMaxSize, "MB of messages per --record file before the next one is started, 0 for one file (env RECORD_MAX_SIZE)")
+	recordGzip := os.Getenv("RECORD_GZIP") != ""
+	flag.BoolVar(&recordGzip, "record-gzip", recordGzip, "gzip --record files (env RECORD_GZIP)")
+	udpOut := os.Getenv("UDP_OUT")
+	flag.StringVar(&udpOut, "udp-out", udpOut, "host:port or multicast group:port to also send trades to as binary UDP datagrams, empty to disable (env UDP_OUT)")
+
+	// Per-exchange endpoints, for testnets, regional mirrors or proxies
+	feedCfg := feed.Config{
+		BinanceNetwork: os.Getenv("BINANCE_NETWORK"),
+		Binance:        feed.Endpoints{Stream: os.Getenv("BINANCE_STREAM_URL"), API: os.Getenv("BINANCE_API_URL")},
+		Coinbase:       feed.Endpoints{Stream: os.Getenv("COINBASE_STREAM_URL"), API: os.Getenv("COINBASE_API_URL")},
+		Kraken:         feed.Endpoints{Stream: os.Getenv("KRAKEN_STREAM_URL"), API: os.Getenv("KRAKEN_API_URL")},
+	}
+	if feedCfg.BinanceNetwork == "" {
+		feedCfg.BinanceNetwork = "mainnet"
+	}
+	flag.StringVar(&feedCfg.BinanceNetwork, "binance-network", feedCfg.BinanceNetwork, "Binance deployment: mainnet, testnet or us (env BINANCE_NETWORK)")
+	flag.StringVar(&feedCfg.Binance.Stream, "binance-stream-url", feedCfg.Binance.Stream, "Binance WebSocket base URL, overriding the network's (env BINANCE_STREAM_URL)")
+	feedCfg.BinanceCompression = os.Getenv("BINANCE_COMPRESSION") != ""
+	flag.BoolVar(&feedCfg.BinanceCompression, "binance-compression", feedCfg.BinanceCompression, "ask Binance to compress its stream with permessage-deflate, trading CPU for bandwidth (env BINANCE_COMPRESSION)")
+	flag.StringVar(&feedCfg.Binance.API, "binance-api-url", feedCfg.Binance.API, "Binance REST base URL, overriding the network's (env BINANCE_API_URL)")
+	flag.StringVar(&feedCfg.Coinbase.Stream, "coinbase-stream-url", feedCfg.Coinbase.Stream, "Coinbase WebSocket URL (env COINBASE_STREAM_URL)")
+	flag.StringVar(&feedCfg.Coinbase.API, "coinbase-api-url", feedCfg.Coinbase.API, "Coinbase REST base URL (env COINBASE_API_URL)")
+	flag.StringVar(&feedCfg.Kraken.Stream, "kraken-stream-url", feedCfg.Kraken.Stream, "Kraken WebSocket v2 URL (env KRAKEN_STREAM_URL)")
+	flag.StringVar(&feedCfg.Kraken.API, "kraken-api-url", feedCfg.Kraken.API, "Kraken REST base URL (env KRAKEN_API_URL)")
+	coinbaseProducts := os.Getenv("COINBASE_PRODUCTS")
+	flag.StringVar(&coinbaseProducts, "coinbase-products", coinbaseProducts, "comma separated symbol=product pairs overriding the Coinbase product a symbol is read from, e.g. btcusdt=BTC-USDT (env COINBASE_PRODUCTS)")
+	flag.Parse()
+	if stallTimeout <= 0 {
+		log.Fatal("--stall-timeout must be positive")
+	}
+	if reconnectMax < reconnectBase {
+		log.Fatalf("--reconnect-max must be at least %v", reconnectBase)
+	}
+	if recordWindow < 0 {
+		log.Fatal("--record-window must not be negative")
+	}
+	switch source {
+	case sourceLive:
+	case sourceReplay:
+		// Replayed trades take the place of every exchange, and there is no
+		// REST history to backfill from
+		exchange, pairExchanges, backfillPeriod = "replay", "", 0
+		feedCfg.ReplayFile, feedCfg.ReplaySpeed = replayFile, replaySpeed
+		log.Printf("Replaying trades from %s at %gx speed", replayFile, replaySpeed)
+	case sourceSynthetic:
+		exchange, pairExchanges, backfillPeriod = "synthetic", "", 0
+		feedCfg.SyntheticRate = syntheticRate
+		log.Printf("Making up %g trades per second", syntheticRate)
+	default:
+		log.Fatalf("Unknown --source %q, want live, replay or synthetic", source)
+	}
+
+	if *teeJSON {
+		tee = json.NewEncoder(os.Stdout)
+	}
+	if kafkaBrokers != "" && flag.Arg(0) != "doctor" {
+		outputs["kafka"] = NewKafkaPublisher(kafkaBrokers, kafkaTopic)
+		log.Printf("Publishing trades to Kafka topic %s on %s", kafkaTopic, kafkaBrokers)
+	}
+	if natsOutURL != "" && flag.Arg(0) != "doctor" {
+		out, err := NewNATSPublisher(context.Background(), natsOutURL, natsOutPrefix, natsOutStream)
+		if err != nil {
+			log.Fatalf("NATS output: %v", err)
+		}
+		outputs["nats"] = out
+		if natsOutStream != "" {
+			log.Printf("Publishing trades to %s.<symbol> on %s, stored in stream %s", natsOutPrefix, natsOutURL, natsOutStream)
+		} else {
+			log.Printf("Publishing trades to %s.<symbol> on %s", natsOutPrefix, natsOutURL)
+		}
+	}
+
+	if udpOut != "" && flag.Arg(0) != "doctor" {
+		out, err := NewUDPPublisher(udpOut)
+		if err != nil {
+			log.Fatalf("UDP output: %v", err)
+		}
+		outputs["udp"] = out
+		log.Printf("Sending trades as UDP datagrams to %s", udpOut)
+	}
+
+	routes, err := parsePairs(pairExchanges)
+	if err != nil {
+		log.Fatalf("Invalid --pair-exchange: %v", err)
+	}
+	for sym, name := range routes {
+		routes[sym] = strings.ToLower(name)
+	}
+	feedCfg.CoinbaseProducts, err = parsePairs(coinbaseProducts)
+	if err != nil {
+		log.Fatalf("Invalid --coinbase-products: %v", err)
+	}
+	router, err := NewRouter(exchange, routes, feedCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stream the selected symbol plus the watchlist, one connection per
+	// exchange
+	subs := NewSubscriptionManager(symbol, parseWatchlist(watchlist), router.Exchange)
+
+	if flag.Arg(0) == "doctor" {
+		checks := ingestionChecks(natsURL, router, subs)
+		if kafkaBrokers != "" {
+			checks = append(checks, kafkaCheck(kafkaBrokers, kafkaTopic))
+		}
+		if natsOutURL != "" {
+			checks = append(checks, natsOutCheck(natsOutURL, natsOutStream))
+		}
+		if udpOut != "" {
+			checks = append(checks, udpOutCheck(udpOut))
+		}
+		os.Exit(runDoctor(checks))
+	}
+
+	log.Printf("Ingestion service starting for %s on %s", symbol, router.Name())
+	defer dumpOnPanic()
+	if recordWindow > 0 {
+		recorder = NewRecorder(recordWindow, dumpDir)
+		log.Printf("Recording the last %v of raw exchange messages, dumped to %s", recordWindow, dumpDir)
+	}
+	if recordPath != "" {
+		archive, err = NewArchive(recordPath, int64(recordMaxSize)<<20, recordGzip)
+		if err != nil {
+			log.Fatalf("Raw message archive: %v", err)
+		}
+		log.Printf("Archiving raw exchange messages to %s", archive.Path())
+	}
+
+	// Cancel on SIGINT/SIGTERM so the reader stops and pending publishes flush
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Connect to NATS with retry
 	var nc *nats.Conn
-	var err error
+	closed := make(chan struct{})
 	for i := 0; i < 10; i++ {
-		nc, err = nats.Connect(natsURL)
+		nc, err = nats.Connect(natsURL, nats.ClosedHandler(func(*nats.Conn) { close(closed) }))
 		if err == nil {
 			break
 		}
@@ -51,14 +252,11 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to connect to NATS: %v", err)
 	}
-	defer nc.Close()
 	log.Println("Connected to NATS")
 
-	// Track current symbol for dynamic switching
-	var mu sync.RWMutex
-	currentSymbol := symbol
-
-	// Subscribe to symbol change requests
+	// Subscribe to symbol change requests. The new symbol is backfilled
+	// before it is streamed, since the API drops trades older than the
+	// candles it already has.
 	nc.Subscribe("control.symbol", func(msg *nats.Msg) {
 		var req struct {
 			Symbol string `json:"symbol"`
@@ -66,68 +264,148 @@ func main() {
 		if err := json.Unmarshal(msg.Data, &req); err != nil {
 			return
 		}
-		mu.Lock()
-		currentSymbol = req.Symbol
-		mu.Unlock()
 		log.Printf("Symbol changed to %s", req.Symbol)
+		if !slices.Contains(subs.Symbols(), req.Symbol) {
+			backfill(ctx, nc, router, []string{req.Symbol}, backfillPeriod)
+		}
+		subs.Select(req.Symbol)
 	})
 
-	// Start Binance connection loop
-	for {
-		mu.RLock()
-		sym := currentSymbol
-		mu.RUnlock()
-
-		connectToBinance(nc, sym, &mu, &currentSymbol)
-		time.Sleep(2 * time.Second)
-	}
-}
+	// Mute or unmute a symbol (POST /api/symbols/{symbol}/pause and resume).
+	// A resumed symbol is backfilled over the gap like a newly selected one.
+	nc.Subscribe("control.pause", func(msg *nats.Msg) {
+		var req struct {
+			Symbol string `json:"symbol"`
+			Paused bool   `json:"paused"`
+		}
+		reply := map[string]any{}
+		if err := json.Unmarshal(msg.Data, &req); err != nil || req.Symbol == "" {
+			reply["error"] = "invalid request"
+		} else if req.Paused {
+			if err := subs.Pause(req.Symbol); err != nil {
+				reply["error"] = err.Error()
+			}
+		} else if subs.IsPaused(req.Symbol) {
+			backfill(ctx, nc, router, []string{req.Symbol}, backfillPeriod)
+			subs.Resume(req.Symbol)
+		}
+		reply["paused"] = subs.Paused()
+		data, _ := json.Marshal(reply)
+		msg.Respond(data)
+	})
 
-func connectToBinance(nc *nats.Conn, symbol string, mu *sync.RWMutex, currentSymbol *string) {
-	url := "wss://stream.binance.com:9443/ws/" + symbol + "@trade"
+	// Add and remove watchlist symbols in one go (POST /api/watchlist).
+	// Added symbols are backfilled first, like a newly selected one.
+	nc.Subscribe("control.watchlist", func(msg *nats.Msg) {
+		var req struct {
+			Add    []string `json:"add"`
+			Remove []string `json:"remove"`
+		}
+		reply := map[string]any{}
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			reply["error"] = "invalid request"
+		} else {
+			streamed := subs.Symbols()
+			var added []string
+			for _, sym := range req.Add {
+				if !slices.Contains(streamed, sym) && !slices.Contains(req.Remove, sym) {
+					added = append(added, sym)
+				}
+			}
+			backfill(ctx, nc, router, added, backfillPeriod)
+			subs.Watch(req.Add, req.Remove)
+		}
+		reply["state"] = subs.State()
+		data, _ := json.Marshal(reply)
+		msg.Respond(data)
+	})
 
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		log.Printf("Binance connection error: %v", err)
-		return
-	}
-	defer conn.Close()
-	log.Printf("Connected to Binance for %s", symbol)
-
-	for {
-		// Check if symbol changed
-		mu.RLock()
-		newSymbol := *currentSymbol
-		mu.RUnlock()
-		if newSymbol != symbol {
-			log.Printf("Symbol changed, reconnecting...")
-			return
+	// Write the recorded raw messages to disk (POST /api/admin/dump)
+	nc.Subscribe("control.dump", func(msg *nats.Msg) {
+		reply := map[string]any{}
+		if recorder == nil {
+			reply["error"] = "raw message recording is disabled (--record-window=0)"
+		} else if info, err := recorder.Dump("request"); err != nil {
+			reply["error"] = err.Error()
+		} else {
+			log.Printf("Dumped %d raw messages to %s", info.Messages, info.Path)
+			reply["dump"] = info
 		}
+		data, _ := json.Marshal(reply)
+		msg.Respond(data)
+	})
 
-		_, message, err := conn.ReadMessage()
-		if err != nil {
-			log.Printf("Read error: %v", err)
-			return
+	// Tell the API what is streamed now and whenever that changes
+	subs.OnChange(func(state SubscriptionState) {
+		data, _ := json.Marshal(state)
+		nc.Publish("status.subscriptions", data)
+	})
+
+	backfill(ctx, nc, router, subs.Symbols(), backfillPeriod)
+
+	// Keep other services informed of the exchange clock offset
+	go syncClock(ctx, nc, router.Default())
+	go reportStatus(ctx, nc, router, subs)
+
+	// Run each exchange's connection loop until shutdown
+	var feeds sync.WaitGroup
+	for _, name := range router.Exchanges() {
+		feeds.Add(1)
+		go func() {
+			defer feeds.Done()
+			streamExchange(ctx, nc, name, router.Feed(name), subs, stallTimeout, reconnectMax)
+		}()
+	}
+	feeds.Wait()
+
+	log.Println("Shutting down, flushing pending messages...")
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			log.Printf("Raw message archive close error: %v", err)
+		}
+	}
+	for name, out := range outputs {
+		if err := out.Close(); err != nil {
+			log.Printf("%s output flush error: %v", name, err)
 		}
+	}
+	if err := nc.Drain(); err != nil {
+		log.Printf("NATS drain error: %v", err)
+	}
+	<-closed
+	log.Println("Ingestion service stopped")
+}
 
-		var trade BinanceTrade
-		if err := json.Unmarshal(message, &trade); err != nil {
+// streamExchange keeps a connection to one exchange open while it has
+// symbols to stream, backing off while the exchange is unreachable
+func streamExchange(ctx context.Context, nc *nats.Conn, exchange string, src feed.Exchange, subs *SubscriptionManager, stallTimeout, reconnectMax time.Duration) {
+	defer dumpOnPanic()
+	backoff := Backoff{Base: reconnectBase, Max: reconnectMax}
+	for ctx.Err() == nil {
+		// Nothing routed here, e.g. until a watchlist edit adds a pair
+		if len(subs.SymbolsOn(exchange)) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(idleExchangePoll):
+			}
 			continue
 		}
 
-		var price float64
-		if _, err := json.Number(trade.Price).Float64(); err == nil {
-			json.Unmarshal([]byte(trade.Price), &price)
+		if runFeed(ctx, nc, exchange, src, subs, stallTimeout) {
+			backoff.Reset()
+		}
+		if ctx.Err() != nil {
+			break
 		}
 
-		if price > 0 {
-			msg := TradeMessage{
-				Symbol: symbol,
-				Price:  price,
-				Time:   trade.Time,
-			}
-			data, _ := json.Marshal(msg)
-			nc.Publish("trades.raw", data)
+		delay := backoff.Next()
+		metrics.reconnects.Add(1)
+		metricsFor(exchange).reconnects.Add(1)
+		metrics.reconnectDelay.Store(delay.Milliseconds())
+		log.Printf("Reconnecting to %s in %v", src.Name(), delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
 		}
 	}
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"ingestion/feed"
+)
+
+// Unacknowledged JetStream publishes allowed before Publish gives up on a
+// trade; keeps memory bounded while the server is away
+const natsOutMaxPending = 4096
+
+// NATSPublisher copies normalized trades to per-symbol subjects,
+// <prefix>.<symbol>, on a NATS server: a lighter alternative to Kafka for
+// other consumers. With a stream name the subjects are captured by a
+// JetStream stream, so consumers can replay them.
+type NATSPublisher struct {
+	outputCounter
+	nc     *nats.Conn
+	js     jetstream.JetStream // nil for plain NATS
+	prefix string
+}
+
+// NewNATSPublisher connects to url, retrying in the background for as long
+// as the server is unreachable, and creates or updates the stream if one is
+// named
+func NewNATSPublisher(ctx context.Context, url, prefix, stream string) (*NATSPublisher, error) {
+	p := &NATSPublisher{outputCounter: outputCounter{name: "NATS output"}, prefix: prefix}
+	nc, err := nats.Connect(url,
+		nats.Name("ingestion-output"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("NATS output disconnected: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Printf("NATS output reconnected to %s", nc.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.nc = nc
+	if stream == "" {
+		return p, nil
+	}
+
+	p.js, err = jetstream.New(nc,
+		jetstream.WithPublishAsyncMaxPending(natsOutMaxPending),
+		jetstream.WithPublishAsyncErrHandler(func(_ jetstream.JetStream, _ *nats.Msg, err error) {
+			p.failure(1, err)
+		}),
+	)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err = p.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: []string{prefix + ".>"},
+	})
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("stream %s: %w", stream, err)
+	}
+	return p, nil
+}
+
+// Publish sends one trade, already encoded as JSON. While disconnected,
+// trades are buffered by the client up to its reconnect buffer.
+func (p *NATSPublisher) Publish(trade feed.Trade, data []byte) {
+	subject := p.prefix + "." + trade.Symbol
+	var err error
+	if p.js != nil {
+		_, err = p.js.PublishAsync(subject, data)
+	} else {
+		err = p.nc.Publish(subject, data)
+	}
+	if err != nil {
+		p.failure(1, err)
+		return
+	}
+	p.published.Add(1)
+}
+
+// Close waits briefly for outstanding JetStream acks, then drains the
+// connection
+func (p *NATSPublisher) Close() error {
+	if p.js != nil {
+		select {
+		case <-p.js.PublishAsyncComplete():
+		case <-time.After(5 * time.Second):
+		}
+	}
+	return p.nc.Drain()
+}
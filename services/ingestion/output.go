@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"ingestion/feed"
+)
+
+// Failed writes to an output are logged at most once per outputErrorInterval
+const outputErrorInterval = 10 * time.Second
+
+// Output is a destination for normalized trades besides the pipeline's
+// trades.raw, such as a Kafka topic. Publish gets the trade and its JSON
+// encoding, and must not block on the destination; failures are counted in
+// Status.
+type Output interface {
+	Publish(trade feed.Trade, data []byte)
+	Status() OutputStatus
+	Close() error
+}
+
+// outputs receive a copy of every live trade, by name. Set up in main
+// before the feed starts.
+var outputs = map[string]Output{}
+
+// OutputStatus counts the trades an output has handled, reported under
+// outputs on status.ingestion
+type OutputStatus struct {
+	Published int64 `json:"published"`
+	Failed    int64 `json:"failed"`
+}
+
+// outputCounter keeps an output's counts and logs failures without flooding
+// the log while the destination is down
+type outputCounter struct {
+	name      string
+	published atomic.Int64
+	failed    atomic.Int64
+	lastError atomic.Int64 // unix ns of the last logged error
+}
+
+func (c *outputCounter) failure(lost int, err error) {
+	failed := c.failed.Add(int64(lost))
+	now := time.Now().UnixNano()
+	last := c.lastError.Load()
+	if now-last < int64(outputErrorInterval) || !c.lastError.CompareAndSwap(last, now) {
+		return
+	}
+	log.Printf("%s write error (%d trades lost so far): %v", c.name, failed, err)
+}
+
+func (c *outputCounter) Status() OutputStatus {
+	return OutputStatus{Published: c.published.Load(), Failed: c.failed.Load()}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Most raw message bytes the recorder holds, so a burst on a busy stream
+// can't grow the window without bound. The oldest messages go first.
+const maxRecordBytes = 64 << 20
+
+// recorder keeps the last --record-window of raw exchange messages, nil
+// when recording is off. Set up in main before the feeds start.
+var recorder *Recorder
+
+// Recorder is a ring of the raw messages received from the exchanges over
+// the last window, dumped to disk on a panic or when asked through
+// control.dump, so odd prices can be traced back to what the exchange sent
+type Recorder struct {
+	mu      sync.Mutex
+	window  time.Duration
+	dir     string
+	entries []recordedMessage // oldest first
+	bytes   int
+}
+
+type recordedMessage struct {
+	received time.Time
+	exchange string
+	message  []byte
+}
+
+// DumpInfo describes a dump file, sent back on control.dump
+type DumpInfo struct {
+	Path     string `json:"path"`
+	Messages int    `json:"messages"`
+	From     int64  `json:"from,omitempty"` // unix ms received, 0 when empty
+	To       int64  `json:"to,omitempty"`
+}
+
+func NewRecorder(window time.Duration, dir string) *Recorder {
+	return &Recorder{window: window, dir: dir}
+}
+
+// Record adds a message received from exchange and drops the ones that
+// fell out of the window. message must not be modified afterwards.
+func (r *Recorder) Record(exchange string, message []byte) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, recordedMessage{received: now, exchange: exchange, message: message})
+	r.bytes += len(message)
+
+	drop := 0
+	for drop < len(r.entries)-1 && (now.Sub(r.entries[drop].received) > r.window || r.bytes > maxRecordBytes) {
+		r.bytes -= len(r.entries[drop].message)
+		r.entries[drop] = recordedMessage{}
+		drop++
+	}
+	r.entries = r.entries[drop:]
+}
+
+// Dump writes the messages in the window to a new file in the dump
+// directory, one JSON object per line, oldest first. reason ends up in the
+// file name, e.g. raw-20250102T140312Z-panic.jsonl.
+func (r *Recorder) Dump(reason string) (DumpInfo, error) {
+	r.mu.Lock()
+	cutoff := time.Now().Add(-r.window)
+	var entries []recordedMessage
+	for _, e := range r.entries {
+		if e.received.After(cutoff) {
+			entries = append(entries, e)
+		}
+	}
+	r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return DumpInfo{}, err
+	}
+	info := DumpInfo{
+		Path:     filepath.Join(r.dir, fmt.Sprintf("raw-%s-%s.jsonl", time.Now().UTC().Format("20060102T150405Z"), reason)),
+		Messages: len(entries),
+	}
+	if len(entries) > 0 {
+		info.From = entries[0].received.UnixMilli()
+		info.To = entries[len(entries)-1].received.UnixMilli()
+	}
+
+	f, err := os.Create(info.Path)
+	if err != nil {
+		return DumpInfo{}, err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(rawLine(e.received, e.exchange, e.message)); err != nil {
+			f.Close()
+			return DumpInfo{}, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return DumpInfo{}, err
+	}
+	return info, f.Close()
+}
+
+// rawLine is how dumps and the archive write a raw message, e.g.
+// {"received":"2025-01-02T14:03:12.345Z","exchange":"binance","message":{...}}
+func rawLine(received time.Time, exchange string, message []byte) any {
+	line := struct {
+		Received string `json:"received"`
+		Exchange string `json:"exchange"`
+		Message  any    `json:"message"`
+	}{received.UTC().Format(time.RFC3339Nano), exchange, string(message)}
+	// Exchanges send JSON, kept as is; anything else as a string
+	if json.Valid(message) {
+		line.Message = json.RawMessage(message)
+	}
+	return line
+}
+
+// dumpOnPanic dumps the recorder when the calling goroutine panics, then
+// panics on. Defer it at the top of goroutines that parse exchange messages.
+func dumpOnPanic() {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if recorder != nil {
+		if info, err := recorder.Dump("panic"); err != nil {
+			log.Printf("Raw message dump failed: %v", err)
+		} else {
+			log.Printf("Dumped %d raw messages to %s", info.Messages, info.Path)
+		}
+	}
+	panic(v)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"ingestion/feed"
+)
+
+// Router picks the exchange each symbol is streamed from: the one
+// --pair-exchange names for it, or the --exchange default. Every exchange
+// in use gets its own connection.
+type Router struct {
+	def    string
+	routes map[string]string        // symbol -> exchange, for pairs not on def
+	feeds  map[string]feed.Exchange // by exchange
+}
+
+// NewRouter creates a feed for the default exchange and each one a pair is
+// routed to
+func NewRouter(def string, routes map[string]string, cfg feed.Config) (*Router, error) {
+	r := &Router{def: def, routes: routes, feeds: make(map[string]feed.Exchange)}
+	for _, name := range append([]string{def}, routedExchanges(routes)...) {
+		if _, ok := r.feeds[name]; ok {
+			continue
+		}
+		src, err := feed.New(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		r.feeds[name] = src
+	}
+	return r, nil
+}
+
+func routedExchanges(routes map[string]string) []string {
+	var names []string
+	for _, name := range routes {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Exchange returns the exchange symbol is streamed from
+func (r *Router) Exchange(symbol string) string {
+	if name, ok := r.routes[symbol]; ok {
+		return name
+	}
+	return r.def
+}
+
+// Feed returns an exchange's feed
+func (r *Router) Feed(exchange string) feed.Exchange {
+	return r.feeds[exchange]
+}
+
+// FeedFor returns the feed symbol is streamed from
+func (r *Router) FeedFor(symbol string) feed.Exchange {
+	return r.feeds[r.Exchange(symbol)]
+}
+
+// Default returns the feed of the --exchange default, which also serves
+// the clock
+func (r *Router) Default() feed.Exchange {
+	return r.feeds[r.def]
+}
+
+// Exchanges lists the exchanges in use, the default first
+func (r *Router) Exchanges() []string {
+	names := []string{r.def}
+	for _, name := range routedExchanges(r.routes) {
+		if name != r.def {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Name is the display name of the exchanges in use, e.g. "Binance, Coinbase"
+func (r *Router) Name() string {
+	var names []string
+	for _, name := range r.Exchanges() {
+		names = append(names, r.feeds[name].Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+// parsePairs reads a comma separated list of symbol=value pairs, e.g.
+// "ethusdt=coinbase,solusdt=coinbase", keyed by the lowercased symbol
+func parsePairs(list string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		symbol, value, ok := strings.Cut(pair, "=")
+		symbol, value = strings.TrimSpace(symbol), strings.TrimSpace(value)
+		if !ok || symbol == "" || value == "" {
+			return nil, fmt.Errorf("invalid pair %q, want symbol=value", pair)
+		}
+		pairs[strings.ToLower(symbol)] = value
+	}
+	return pairs, nil
+}
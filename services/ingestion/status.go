@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// How often feed health is published on status.ingestion
+const statusInterval = 10 * time.Second
+
+// IngestionStatus is published to status.ingestion so the API can report
+// feed health
+type IngestionStatus struct {
+	Exchange       string                    `json:"exchange"`  // every exchange in use, e.g. "Binance, Coinbase"
+	Connected      bool                      `json:"connected"` // to each exchange with symbols to stream
+	Connects       int64                     `json:"connects"`
+	Stalls         int64                     `json:"stalls"`
+	Trades         int64                     `json:"trades"`
+	Rejected       int64                     `json:"rejected"`   // messages and trades that didn't parse
+	LastTrade      int64                     `json:"last_trade"` // unix ms, 0 before the first trade
+	Reconnects     int64                     `json:"reconnects"`
+	ReconnectDelay int64                     `json:"reconnect_delay_ms"` // last wait before reconnecting
+	Paused         []string                  `json:"paused"`
+	Outputs        map[string]OutputStatus   `json:"outputs,omitempty"` // Kafka, NATS and UDP outputs, by name
+	Exchanges      map[string]ExchangeStatus `json:"exchanges"`         // by exchange, e.g. "binance"
+	StartedAt      int64                     `json:"started_at"`        // unix ms
+	ReportedAt     int64                     `json:"reported_at"`
+}
+
+// ExchangeStatus is the connection to one exchange
+type ExchangeStatus struct {
+	Connected   bool  `json:"connected"`
+	ConnectedAt int64 `json:"connected_at"` // unix ms the current connection opened, 0 while down
+	Symbols     int   `json:"symbols"`      // routed to the exchange
+	Connects    int64 `json:"connects"`
+	Reconnects  int64 `json:"reconnects"`
+	Stalls      int64 `json:"stalls"`
+	Trades      int64 `json:"trades"`
+	LastTrade   int64 `json:"last_trade"`
+}
+
+// When the process started, for the uptime in /api/status
+var startedAt = time.Now()
+
+// Feed health counters, updated by runFeed
+var metrics struct {
+	connected atomic.Int32 // open exchange connections
+	connects  atomic.Int64
+	stalls    atomic.Int64
+	trades    atomic.Int64
+	lastTrade atomic.Int64
+
+	rejected   atomic.Int64
+	lastReject atomic.Int64 // unix ns of the last logged rejection
+
+	reconnects     atomic.Int64
+	reconnectDelay atomic.Int64
+}
+
+// exchangeMetrics are the feed health counters of one exchange, the
+// per-exchange part of metrics
+type exchangeMetrics struct {
+	connectedAt atomic.Int64 // unix ms, 0 while disconnected
+	connects    atomic.Int64
+	reconnects  atomic.Int64
+	stalls      atomic.Int64
+	trades      atomic.Int64
+	lastTrade   atomic.Int64
+}
+
+// Counters by exchange, created on first use
+var exchanges sync.Map
+
+// metricsFor returns the counters of an exchange
+func metricsFor(exchange string) *exchangeMetrics {
+	m, _ := exchanges.LoadOrStore(exchange, new(exchangeMetrics))
+	return m.(*exchangeMetrics)
+}
+
+// reportStatus publishes feed health every statusInterval until ctx is done
+func reportStatus(ctx context.Context, nc *nats.Conn, router *Router, subs *SubscriptionManager) {
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var outs map[string]OutputStatus
+		if len(outputs) > 0 {
+			outs = make(map[string]OutputStatus, len(outputs))
+			for name, out := range outputs {
+				outs[name] = out.Status()
+			}
+		}
+		streaming := 0
+		byExchange := make(map[string]ExchangeStatus)
+		for _, exchange := range router.Exchanges() {
+			symbols := len(subs.SymbolsOn(exchange))
+			if symbols > 0 {
+				streaming++
+			}
+			m := metricsFor(exchange)
+			byExchange[exchange] = ExchangeStatus{
+				Connected:   m.connectedAt.Load() > 0,
+				ConnectedAt: m.connectedAt.Load(),
+				Symbols:     symbols,
+				Connects:    m.connects.Load(),
+				Reconnects:  m.reconnects.Load(),
+				Stalls:      m.stalls.Load(),
+				Trades:      m.trades.Load(),
+				LastTrade:   m.lastTrade.Load(),
+			}
+		}
+		data, _ := json.Marshal(IngestionStatus{
+			Exchange:       router.Name(),
+			Connected:      int(metrics.connected.Load()) >= streaming,
+			Connects:       metrics.connects.Load(),
+			Stalls:         metrics.stalls.Load(),
+			Trades:         metrics.trades.Load(),
+			Rejected:       metrics.rejected.Load(),
+			LastTrade:      metrics.lastTrade.Load(),
+			Reconnects:     metrics.reconnects.Load(),
+			ReconnectDelay: metrics.reconnectDelay.Load(),
+			Paused:         subs.Paused(),
+			Outputs:        outs,
+			Exchanges:      byExchange,
+			StartedAt:      startedAt.UnixMilli(),
+			ReportedAt:     time.Now().UnixMilli(),
+		})
+		nc.Publish("status.ingestion", data)
+	}
+}
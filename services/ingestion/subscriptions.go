@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"ingestion/feed"
+)
+
+// errLastSymbol is returned when pausing would leave nothing to stream
+var errLastSymbol = errors.New("can't pause the only streamed symbol")
+
+// SubscriptionManager keeps the live feeds subscribed to the selected symbol
+// plus the watchlist, each symbol on the exchange route names, adding and
+// removing streams on the open connections instead of reconnecting
+type SubscriptionManager struct {
+	mu        sync.Mutex
+	watchlist []string
+	selected  string
+	paused    map[string]bool            // muted symbols, kept in the watchlist
+	route     func(symbol string) string // exchange a symbol is streamed from
+	feeds     map[string]*subscribedFeed // connected feeds by exchange
+	onChange  func(SubscriptionState)
+}
+
+// subscribedFeed is an open exchange connection and what it streams
+type subscribedFeed struct {
+	src    feed.Exchange
+	active map[string]bool // symbols subscribed on src
+}
+
+// SubscriptionState is what ingestion streams, published on
+// status.subscriptions after every change
+type SubscriptionState struct {
+	Symbols   []string `json:"symbols"` // streamed, sorted
+	Selected  string   `json:"selected"`
+	Watchlist []string `json:"watchlist"`
+	Paused    []string `json:"paused"`
+}
+
+func NewSubscriptionManager(selected string, watchlist []string, route func(symbol string) string) *SubscriptionManager {
+	return &SubscriptionManager{
+		selected:  selected,
+		watchlist: watchlist,
+		paused:    make(map[string]bool),
+		route:     route,
+		feeds:     make(map[string]*subscribedFeed),
+	}
+}
+
+// parseWatchlist splits a comma separated list of symbols
+func parseWatchlist(list string) []string {
+	var out []string
+	for _, sym := range strings.Split(list, ",") {
+		sym = strings.ToLower(strings.TrimSpace(sym))
+		if sym != "" && !slices.Contains(out, sym) {
+			out = append(out, sym)
+		}
+	}
+	return out
+}
+
+// Symbols returns every symbol that should be streamed
+func (m *SubscriptionManager) Symbols() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.wanted()
+}
+
+// SymbolsOn returns the symbols that should be streamed from exchange
+func (m *SubscriptionManager) SymbolsOn(exchange string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.wantedOn(exchange)
+}
+
+func (m *SubscriptionManager) wantedOn(exchange string) []string {
+	return slices.DeleteFunc(m.wanted(), func(sym string) bool { return m.route(sym) != exchange })
+}
+
+func (m *SubscriptionManager) wanted() []string {
+	out := slices.Clone(m.watchlist)
+	if !slices.Contains(out, m.selected) {
+		out = append(out, m.selected)
+	}
+	return slices.DeleteFunc(out, func(sym string) bool { return m.paused[sym] })
+}
+
+// Pause mutes symbol until Resume: its stream is unsubscribed and trades
+// still in flight are dropped, but it stays in the watchlist or selected
+func (m *SubscriptionManager) Pause(symbol string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.paused[symbol] {
+		return nil
+	}
+	if wanted := m.wanted(); len(wanted) == 1 && wanted[0] == symbol {
+		return errLastSymbol
+	}
+	m.paused[symbol] = true
+	log.Printf("Paused %s", symbol)
+	m.sync()
+	m.changed()
+	return nil
+}
+
+// Resume streams a paused symbol again
+func (m *SubscriptionManager) Resume(symbol string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.paused[symbol] {
+		return
+	}
+	delete(m.paused, symbol)
+	log.Printf("Resumed %s", symbol)
+	m.sync()
+	m.changed()
+}
+
+// IsPaused reports whether symbol's trades are being dropped
+func (m *SubscriptionManager) IsPaused(symbol string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.paused[symbol]
+}
+
+// Paused returns the paused symbols, sorted
+func (m *SubscriptionManager) Paused() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pausedLocked()
+}
+
+func (m *SubscriptionManager) pausedLocked() []string {
+	out := make([]string, 0, len(m.paused))
+	for sym := range m.paused {
+		out = append(out, sym)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Select changes the selected symbol
+func (m *SubscriptionManager) Select(symbol string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.selected = symbol
+	m.sync()
+	m.changed()
+}
+
+// Watch adds symbols to the watchlist and removes others in one change,
+// so the feed and the state published to OnChange move once however many
+// symbols are edited. A removed symbol that is selected keeps streaming.
+func (m *SubscriptionManager) Watch(add, remove []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	watchlist := slices.DeleteFunc(slices.Clone(m.watchlist), func(sym string) bool {
+		return slices.Contains(remove, sym)
+	})
+	for _, sym := range add {
+		if !slices.Contains(watchlist, sym) && !slices.Contains(remove, sym) {
+			watchlist = append(watchlist, sym)
+		}
+	}
+	if slices.Equal(watchlist, m.watchlist) {
+		return
+	}
+	m.watchlist = watchlist
+	log.Printf("Watchlist is now %s", strings.Join(watchlist, ","))
+	m.sync()
+	m.changed()
+}
+
+// OnChange calls fn with the current state, then with the new state after
+// each change to the selection, watchlist or paused symbols
+func (m *SubscriptionManager) OnChange(fn func(SubscriptionState)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+	m.changed()
+}
+
+// State returns what is streamed and why
+func (m *SubscriptionManager) State() SubscriptionState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state()
+}
+
+func (m *SubscriptionManager) state() SubscriptionState {
+	symbols := m.wanted()
+	slices.Sort(symbols)
+	return SubscriptionState{
+		Symbols:   symbols,
+		Selected:  m.selected,
+		Watchlist: append([]string{}, m.watchlist...),
+		Paused:    m.pausedLocked(),
+	}
+}
+
+// Attach records an exchange's feed that was connected with symbols and
+// brings it up to date with any change made while it was connecting
+func (m *SubscriptionManager) Attach(exchange string, src feed.Exchange, symbols []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := &subscribedFeed{src: src, active: make(map[string]bool)}
+	for _, sym := range symbols {
+		f.active[sym] = true
+	}
+	m.feeds[exchange] = f
+	m.sync()
+}
+
+// Detach forgets an exchange's feed once its connection is gone
+func (m *SubscriptionManager) Detach(exchange string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.feeds, exchange)
+}
+
+// changed hands the new state to OnChange. Caller holds m.mu.
+func (m *SubscriptionManager) changed() {
+	if m.onChange != nil {
+		m.onChange(m.state())
+	}
+}
+
+// sync subscribes and unsubscribes until each feed matches wantedOn() its
+// exchange. A failed write means that connection is broken; its reader will
+// notice and reconnect with the full set. Caller holds m.mu.
+func (m *SubscriptionManager) sync() {
+	for exchange, f := range m.feeds {
+		f.sync(m.wantedOn(exchange))
+	}
+}
+
+func (f *subscribedFeed) sync(wanted []string) {
+	for _, sym := range wanted {
+		if f.active[sym] {
+			continue
+		}
+		if err := f.src.Subscribe(sym); err != nil {
+			log.Printf("%s subscribe error: %v", f.src.Name(), err)
+			return
+		}
+		f.active[sym] = true
+		log.Printf("Subscribed to %s on %s", sym, f.src.Name())
+	}
+	for sym := range f.active {
+		if slices.Contains(wanted, sym) {
+			continue
+		}
+		if err := f.src.Unsubscribe(sym); err != nil {
+			log.Printf("%s unsubscribe error: %v", f.src.Name(), err)
+			return
+		}
+		delete(f.active, sym)
+		log.Printf("Unsubscribed from %s on %s", sym, f.src.Name())
+	}
+}
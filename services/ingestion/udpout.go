@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"sync/atomic"
+
+	"ingestion/feed"
+)
+
+// UDP trade datagrams: one trade each, big-endian, udpHeaderSize bytes
+// followed by the symbol
+//
+//	offset  size  field
+//	0       2     magic, "SG"
+//	2       1     version, udpVersion
+//	3       1     symbol length n
+//	4       8     sequence number, from 1 per sender start; a gap is a lost datagram
+//	12      8     exchange time, unix ms
+//	20      8     price, IEEE 754 double
+//	28      8     price in 1e-8 units, signed; 0 when it doesn't fit
+//	36      8     quantity, IEEE 754 double; 0 when unknown
+//	44      1     taker side, udpSideBuy, udpSideSell or 0 when unknown
+//	45      n     symbol, lowercase ASCII, e.g. btcusdt
+//
+// Version 1 stopped after the price, with the symbol at offset 28.
+const (
+	udpMagic      = "SG"
+	udpVersion    = 2
+	udpHeaderSize = 45
+	udpSideBuy    = 'B'
+	udpSideSell   = 'S'
+)
+
+// UDPPublisher sends each trade as one compact binary datagram, to a
+// multicast group or a single host, for colocated consumers that can't
+// afford JSON over WebSockets. Delivery isn't guaranteed; the sequence
+// number lets consumers notice what they missed.
+type UDPPublisher struct {
+	outputCounter
+	conn *net.UDPConn
+	seq  atomic.Uint64
+}
+
+// NewUDPPublisher opens a socket sending to addr, e.g. 239.1.1.1:5005.
+// Multicast datagrams go out with a TTL of 1, so they stay on the local
+// network.
+func NewUDPPublisher(addr string) (*UDPPublisher, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPPublisher{outputCounter: outputCounter{name: "UDP output"}, conn: conn}, nil
+}
+
+// Publish sends one trade; the JSON encoding is unused
+func (p *UDPPublisher) Publish(trade feed.Trade, _ []byte) {
+	if _, err := p.conn.Write(encodeUDPTrade(p.seq.Add(1), trade)); err != nil {
+		p.failure(1, err)
+		return
+	}
+	p.published.Add(1)
+}
+
+func (p *UDPPublisher) Close() error {
+	return p.conn.Close()
+}
+
+// encodeUDPTrade lays out a trade datagram
+func encodeUDPTrade(seq uint64, trade feed.Trade) []byte {
+	symbol := trade.Symbol[:min(len(trade.Symbol), math.MaxUint8)]
+	buf := make([]byte, udpHeaderSize+len(symbol))
+	copy(buf, udpMagic)
+	buf[2] = udpVersion
+	buf[3] = byte(len(symbol))
+	binary.BigEndian.PutUint64(buf[4:], seq)
+	binary.BigEndian.PutUint64(buf[12:], uint64(trade.Time))
+	binary.BigEndian.PutUint64(buf[20:], math.Float64bits(trade.Price))
+	binary.BigEndian.PutUint64(buf[28:], uint64(trade.PriceE8))
+	binary.BigEndian.PutUint64(buf[36:], math.Float64bits(trade.Qty))
+	switch trade.Side {
+	case feed.SideBuy:
+		buf[44] = udpSideBuy
+	case feed.SideSell:
+		buf[44] = udpSideSell
+	}
+	copy(buf[udpHeaderSize:], symbol)
+	return buf
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Bounds for the moving average window
+const (
+	minMAWindow = 1
+	maxMAWindow = 10000
+)
+
+// Most exponential moving averages a processor computes
+const maxEMASpans = 8
+
+// Config holds the tunable processor parameters
+type Config struct {
+	MAWindow   int   `json:"ma_window"`
+	MaxCgoRate int   `json:"max_cgo_rate"` // per symbol and second, 0 for no limit
+	EMASpans   []int `json:"ema_spans"`
+}
+
+// ConfigUpdate changes only the fields that are set
+type ConfigUpdate struct {
+	MAWindow   *int   `json:"ma_window,omitempty"`
+	MaxCgoRate *int   `json:"max_cgo_rate,omitempty"`
+	EMASpans   *[]int `json:"ema_spans,omitempty"`
+}
+
+var (
+	config   Config
+	configMu sync.RWMutex
+)
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// parseSpans reads a comma separated list of EMA spans, e.g. "12,26"
+func parseSpans(list string) ([]int, error) {
+	var spans []int
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		span, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid span %q", s)
+		}
+		spans = append(spans, span)
+	}
+	if err := checkSpans(spans); err != nil {
+		return nil, err
+	}
+	return spans, nil
+}
+
+// checkSpans rejects spans outside the moving average window bounds,
+// duplicates, and more than maxEMASpans
+func checkSpans(spans []int) error {
+	if len(spans) > maxEMASpans {
+		return fmt.Errorf("at most %d spans", maxEMASpans)
+	}
+	for i, span := range spans {
+		if span < minMAWindow || span > maxMAWindow {
+			return fmt.Errorf("span %d out of range", span)
+		}
+		if slices.Contains(spans[:i], span) {
+			return fmt.Errorf("duplicate span %d", span)
+		}
+	}
+	return nil
+}
+
+// handleConfig answers control.config requests. An empty update returns the
+// current config; otherwise the update is applied to every processor.
+func handleConfig(msg *nats.Msg) {
+	var update ConfigUpdate
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &update); err != nil {
+			msg.Respond([]byte(`{"error":"invalid config"}`))
+			return
+		}
+	}
+
+	if update.MAWindow != nil {
+		if window := *update.MAWindow; window < minMAWindow || window > maxMAWindow {
+			msg.Respond([]byte(`{"error":"ma_window out of range"}`))
+			return
+		}
+	}
+	if update.MaxCgoRate != nil && *update.MaxCgoRate < 0 {
+		msg.Respond([]byte(`{"error":"max_cgo_rate must not be negative"}`))
+		return
+	}
+	if update.EMASpans != nil {
+		if err := checkSpans(*update.EMASpans); err != nil {
+			data, _ := json.Marshal(map[string]string{"error": "ema_spans: " + err.Error()})
+			msg.Respond(data)
+			return
+		}
+	}
+
+	if update.MAWindow != nil {
+		window := *update.MAWindow
+
+		configMu.Lock()
+		config.MAWindow = window
+		configMu.Unlock()
+
+		processorsMu.Lock()
+		for _, proc := range processors {
+			proc.SetWindow(window)
+		}
+		processorsMu.Unlock()
+		log.Printf("Moving average window set to %d", window)
+	}
+	if update.MaxCgoRate != nil {
+		configMu.Lock()
+		config.MaxCgoRate = *update.MaxCgoRate
+		configMu.Unlock()
+		log.Printf("Processor rate limit set to %d trades/s per symbol", *update.MaxCgoRate)
+	}
+	if update.EMASpans != nil {
+		spans := append([]int{}, *update.EMASpans...)
+
+		configMu.Lock()
+		config.EMASpans = spans
+		configMu.Unlock()
+
+		processorsMu.Lock()
+		for _, proc := range processors {
+			proc.SetEMASpans(spans)
+		}
+		processorsMu.Unlock()
+		log.Printf("EMA spans set to %v", spans)
+	}
+
+	data, _ := json.Marshal(currentConfig())
+	msg.Respond(data)
+}
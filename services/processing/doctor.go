@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// How long each doctor check may take
+const doctorTimeout = 5 * time.Second
+
+// doctorCheck is one line of the `processing doctor` report
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context) (detail string, err error)
+}
+
+// runDoctor prints one line per check and returns the exit status: 1 when
+// any check failed
+func runDoctor(checks []doctorCheck) int {
+	status := 0
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+		detail, err := c.run(ctx)
+		cancel()
+
+		result := "ok"
+		if err != nil {
+			result, detail = "FAIL", err.Error()
+			status = 1
+		}
+		fmt.Printf("%-5s %-10s %s\n", result, c.name, detail)
+	}
+	return status
+}
+
+// processingChecks covers NATS and the C++ library. There is no pure-Go
+// fallback, and the binary can't start at all when libprocess.so is
+// missing from LD_LIBRARY_PATH, so reaching the check means it loaded; the
+// self-test makes sure it also computes.
+func processingChecks(natsURL string) []doctorCheck {
+	return []doctorCheck{
+		{"nats", func(ctx context.Context) (string, error) {
+			nc, err := nats.Connect(natsURL, nats.Timeout(doctorTimeout))
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", natsURL, err)
+			}
+			defer nc.Close()
+			return fmt.Sprintf("%s (server %s)", natsURL, nc.ConnectedServerVersion()), nil
+		}},
+		{"processor", func(ctx context.Context) (string, error) {
+			proc := NewProcessor("doctor", 3, nil)
+			defer proc.Close()
+			for _, price := range []float64{1, 2, 3, 4} {
+				proc.Add(price, 1)
+			}
+			ma, high, low := proc.Stats()
+			vwap, _ := proc.Spread()
+			if ma != 3 || high != 4 || low != 1 || vwap != 2.5 {
+				return "", errors.New("libprocess loaded but its self-test computed wrong stats")
+			}
+			return "libprocess loaded, self-test passed", nil
+		}},
+	}
+}
@@ -1,56 +1,113 @@
 package main
 
-/*
-#cgo LDFLAGS: -L. -lprocess -lpthread -lstdc++
-#include "process.h"
-*/
-import "C"
-
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/nats-io/nats.go"
 )
 
 var (
-	currentSymbol string
-	symbolMu      sync.RWMutex
+	processors   = make(map[string]*Processor)
+	processorsMu sync.Mutex
+)
+
+// Taker sides of a TradeMessage
+const (
+	sideBuy  = "buy"
+	sideSell = "sell"
 )
 
 // TradeMessage from ingestion service
 type TradeMessage struct {
-	Symbol string  `json:"symbol"`
-	Price  float64 `json:"price"`
-	Time   int64   `json:"time"`
+	Symbol   string  `json:"symbol"`
+	Price    float64 `json:"price"`
+	PriceE8  int64   `json:"price_e8,omitempty"` // Price exactly, in 1e-8 units
+	Qty      float64 `json:"qty,omitempty"`
+	Side     string  `json:"side,omitempty"` // taker side, buy or sell
+	Time     int64   `json:"time"`
+	Backfill bool    `json:"backfill,omitempty"`
 }
 
 // ProcessedMessage published after C++ processing
 type ProcessedMessage struct {
-	Symbol        string  `json:"symbol"`
-	Price         float64 `json:"price"`
-	MovingAverage float64 `json:"moving_average"`
-	High          float64 `json:"high"`
-	Low           float64 `json:"low"`
-	Time          int64   `json:"time"`
+	Symbol        string             `json:"symbol"`
+	Price         float64            `json:"price"`
+	PriceE8       int64              `json:"price_e8,omitempty"` // passed through
+	MovingAverage float64            `json:"moving_average"`
+	High          float64            `json:"high"`
+	Low           float64            `json:"low"`
+	VWAP          float64            `json:"vwap"`
+	StdDev        float64            `json:"std_dev"`
+	EMA           map[string]float64 `json:"ema,omitempty"`  // by span
+	Qty           float64            `json:"qty,omitempty"`  // trade size, passed through
+	Side          string             `json:"side,omitempty"` // taker side, passed through
+	Volume        float64            `json:"volume"`         // base asset this session
+	BuyVolume     float64            `json:"buy_volume"`
+	SellVolume    float64            `json:"sell_volume"`
+	Samples       int                `json:"samples"`
+	Window        int                `json:"window"`
+	Time          int64              `json:"time"`
+	Backfill      bool               `json:"backfill,omitempty"` // seeded from exchange history
 }
 
 func main() {
+	maWindow := 20
+	if v, err := strconv.Atoi(os.Getenv("MA_WINDOW")); err == nil {
+		maWindow = v
+	}
+	flag.IntVar(&maWindow, "ma-window", maWindow, "moving average window in trades (env MA_WINDOW)")
+	maxCgoRate := 0
+	if v, err := strconv.Atoi(os.Getenv("MAX_CGO_RATE")); err == nil {
+		maxCgoRate = v
+	}
+	flag.IntVar(&maxCgoRate, "max-cgo-rate", maxCgoRate, "max trades per second and symbol passed to the C++ processor, the rest reuse its last stats; 0 for no limit (env MAX_CGO_RATE)")
+	emaSpans := "12,26"
+	if v := os.Getenv("EMA_SPANS"); v != "" {
+		emaSpans = v
+	}
+	flag.StringVar(&emaSpans, "ema-spans", emaSpans, "comma separated spans of the exponential moving averages, in trades (env EMA_SPANS)")
+	flag.Parse()
+	if maWindow < minMAWindow || maWindow > maxMAWindow {
+		log.Fatalf("--ma-window must be between %d and %d", minMAWindow, maxMAWindow)
+	}
+	if maxCgoRate < 0 {
+		log.Fatal("--max-cgo-rate must not be negative")
+	}
+	spans, err := parseSpans(emaSpans)
+	if err != nil {
+		log.Fatalf("--ema-spans: %v", err)
+	}
+	config.MAWindow = maWindow
+	config.EMASpans = spans
+	config.MaxCgoRate = maxCgoRate
+
 	natsURL := os.Getenv("NATS_URL")
 	if natsURL == "" {
 		natsURL = "nats://localhost:4222"
 	}
+	if flag.Arg(0) == "doctor" {
+		os.Exit(runDoctor(processingChecks(natsURL)))
+	}
 
 	log.Println("Processing service starting...")
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Connect to NATS with retry
 	var nc *nats.Conn
-	var err error
+	closed := make(chan struct{})
 	for i := 0; i < 10; i++ {
-		nc, err = nats.Connect(natsURL)
+		nc, err = nats.Connect(natsURL, nats.ClosedHandler(func(*nats.Conn) { close(closed) }))
 		if err == nil {
 			break
 		}
@@ -60,22 +117,25 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to connect to NATS: %v", err)
 	}
-	defer nc.Close()
 	log.Println("Connected to NATS")
 
-	// Subscribe to symbol change for processor reset
-	nc.Subscribe("control.symbol", func(msg *nats.Msg) {
+	// Runtime configuration from the API
+	nc.Subscribe("control.config", handleConfig)
+
+	// Start a fresh session on demand (POST /api/stats/reset). A symbol
+	// change doesn't: each symbol has its own processor, so one the
+	// watchlist already streams keeps its session.
+	nc.Subscribe("control.reset", func(msg *nats.Msg) {
 		var req struct {
 			Symbol string `json:"symbol"`
 		}
-		if err := json.Unmarshal(msg.Data, &req); err != nil {
+		if err := json.Unmarshal(msg.Data, &req); err != nil || req.Symbol == "" {
+			msg.Respond([]byte(`{"error":"invalid request"}`))
 			return
 		}
-		symbolMu.Lock()
-		currentSymbol = req.Symbol
-		symbolMu.Unlock()
-		C.reset_processor()
-		log.Printf("Processor reset for symbol change to %s", req.Symbol)
+		getProcessor(req.Symbol).Reset()
+		log.Printf("Processor reset for %s", req.Symbol)
+		msg.Respond(msg.Data)
 	})
 
 	// Subscribe to raw trades
@@ -85,33 +145,75 @@ func main() {
 			return
 		}
 
-		// Ignore trades from old symbol after a symbol change
-		symbolMu.RLock()
-		sym := currentSymbol
-		symbolMu.RUnlock()
-		if sym != "" && trade.Symbol != sym {
-			return
+		// Process through this symbol's C++ processor
+		stats, sampled := getProcessor(trade.Symbol).Process(trade.Price, trade.Qty, trade.Side, currentConfig().MaxCgoRate)
+		metrics.trades.Add(1)
+		if sampled {
+			metrics.sampled.Add(1)
 		}
-
-		// Process through C++
-		C.add_price(C.double(trade.Price))
-
-		// Get stats
 		processed := ProcessedMessage{
 			Symbol:        trade.Symbol,
 			Price:         trade.Price,
-			MovingAverage: float64(C.get_moving_average()),
-			High:          float64(C.get_high()),
-			Low:           float64(C.get_low()),
+			PriceE8:       trade.PriceE8,
+			MovingAverage: stats.MovingAverage,
+			High:          stats.High,
+			Low:           stats.Low,
+			VWAP:          stats.VWAP,
+			StdDev:        stats.StdDev,
+			EMA:           stats.EMA,
+			Qty:           trade.Qty,
+			Side:          trade.Side,
+			Volume:        stats.Volume,
+			BuyVolume:     stats.BuyVolume,
+			SellVolume:    stats.SellVolume,
+			Samples:       stats.Samples,
+			Window:        stats.Window,
 			Time:          trade.Time,
+			Backfill:      trade.Backfill,
 		}
 
 		data, _ := json.Marshal(processed)
 		nc.Publish("trades.processed", data)
 	})
 
+	// Publish the cost of the cgo boundary for /api/status
+	go reportStatus(ctx, nc)
+
 	log.Println("Processing service running, subscribed to trades.raw")
 
-	// Keep running
-	select {}
+	// Run until signalled, then finish in-flight trades before freeing state
+	<-ctx.Done()
+	log.Println("Shutting down, draining subscriptions...")
+	if err := nc.Drain(); err != nil {
+		log.Printf("NATS drain error: %v", err)
+	}
+	<-closed
+	closeProcessors()
+	log.Println("Processing service stopped")
+}
+
+// getProcessor returns the processor for a symbol, creating it on first use
+func getProcessor(symbol string) *Processor {
+	processorsMu.Lock()
+	defer processorsMu.Unlock()
+
+	proc, ok := processors[symbol]
+	if !ok {
+		cfg := currentConfig()
+		proc = NewProcessor(symbol, cfg.MAWindow, cfg.EMASpans)
+		processors[symbol] = proc
+		log.Printf("Created processor for %s", symbol)
+	}
+	return proc
+}
+
+// closeProcessors frees every processor's C++ state
+func closeProcessors() {
+	processorsMu.Lock()
+	defer processorsMu.Unlock()
+
+	for symbol, proc := range processors {
+		proc.Close()
+		delete(processors, symbol)
+	}
 }
@@ -1,70 +1,249 @@
 #include "process.h"
 #include <vector>
+#include <map>
 #include <mutex>
 #include <limits>
+#include <cmath>
 
-// Buffer size for moving average calculation
-const int BUFFER_SIZE = 20;
+// Per-symbol price state
+struct Processor {
+    size_t window = 20;
+    std::vector<double> price_buffer;
+    double high_price = 0.0;
+    double low_price = std::numeric_limits<double>::max();
 
-// Thread-safe price processor
+    // Session volume-weighted average
+    double notional = 0.0;
+    double volume = 0.0;
+
+    // Exponential moving averages, one per span
+    std::vector<int> ema_spans;
+    std::vector<double> emas;
+    double last_price = 0.0;
+    bool seen = false;
+};
+
+// Thread-safe registry of processors keyed by handle
 static std::mutex mtx;
-static std::vector<double> price_buffer;
-static double high_price = 0.0;
-static double low_price = std::numeric_limits<double>::max();
+static std::map<int, Processor> processors;
+static int next_id = 1;
+
+// Look up a processor, caller must hold mtx
+static Processor* find(int id) {
+    auto it = processors.find(id);
+    if (it == processors.end()) {
+        return nullptr;
+    }
+    return &it->second;
+}
 
 extern "C" {
 
-void add_price(double price) {
+int create_processor(int window) {
+    std::lock_guard<std::mutex> lock(mtx);
+    int id = next_id++;
+    processors[id] = Processor();
+    if (window > 0) {
+        processors[id].window = window;
+    }
+    return id;
+}
+
+void set_window(int id, int window) {
+    std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+    if (p == nullptr || window <= 0) {
+        return;
+    }
+
+    p->window = window;
+    if (p->price_buffer.size() > p->window) {
+        p->price_buffer.erase(p->price_buffer.begin(),
+                              p->price_buffer.end() - p->window);
+    }
+}
+
+void destroy_processor(int id) {
     std::lock_guard<std::mutex> lock(mtx);
+    processors.erase(id);
+}
+
+void add_price(int id, double price) {
+    add_trade(id, price, 0.0);
+}
+
+void add_trade(int id, double price, double qty) {
+    std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+    if (p == nullptr) {
+        return;
+    }
 
     // Update high/low
-    if (price > high_price) {
-        high_price = price;
+    if (price > p->high_price) {
+        p->high_price = price;
     }
-    if (price < low_price) {
-        low_price = price;
+    if (price < p->low_price) {
+        p->low_price = price;
     }
 
     // Add to circular buffer
-    if (price_buffer.size() >= BUFFER_SIZE) {
-        price_buffer.erase(price_buffer.begin());
+    if (p->price_buffer.size() >= p->window) {
+        p->price_buffer.erase(p->price_buffer.begin());
+    }
+    p->price_buffer.push_back(price);
+
+    if (qty > 0) {
+        p->notional += price * qty;
+        p->volume += qty;
     }
-    price_buffer.push_back(price);
+
+    // Seed the averages with the first price, then smooth by 2/(span+1)
+    for (size_t i = 0; i < p->ema_spans.size(); i++) {
+        if (!p->seen) {
+            p->emas[i] = price;
+            continue;
+        }
+        double alpha = 2.0 / (p->ema_spans[i] + 1);
+        p->emas[i] += alpha * (price - p->emas[i]);
+    }
+    p->last_price = price;
+    p->seen = true;
 }
 
-double get_moving_average(void) {
+double get_vwap(int id) {
     std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+    if (p == nullptr || p->volume == 0.0) {
+        return 0.0;
+    }
+    return p->notional / p->volume;
+}
 
-    if (price_buffer.empty()) {
+double get_stddev(int id) {
+    std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+    if (p == nullptr || p->price_buffer.empty()) {
+        return 0.0;
+    }
+
+    double mean = 0.0;
+    for (double price : p->price_buffer) {
+        mean += price;
+    }
+    mean /= p->price_buffer.size();
+
+    double variance = 0.0;
+    for (double price : p->price_buffer) {
+        variance += (price - mean) * (price - mean);
+    }
+    return std::sqrt(variance / p->price_buffer.size());
+}
+
+void set_ema_spans(int id, const int* spans, int n) {
+    std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+    if (p == nullptr) {
+        return;
+    }
+
+    std::vector<int> new_spans;
+    std::vector<double> new_emas;
+    for (int i = 0; i < n; i++) {
+        if (spans[i] <= 0) {
+            continue;
+        }
+        double value = p->last_price;
+        for (size_t j = 0; j < p->ema_spans.size(); j++) {
+            if (p->ema_spans[j] == spans[i]) {
+                value = p->emas[j];
+                break;
+            }
+        }
+        new_spans.push_back(spans[i]);
+        new_emas.push_back(value);
+    }
+    p->ema_spans = new_spans;
+    p->emas = new_emas;
+}
+
+int get_emas(int id, double* out, int n) {
+    std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+    if (p == nullptr) {
+        return 0;
+    }
+    int count = 0;
+    for (; count < n && count < (int)p->emas.size(); count++) {
+        out[count] = p->emas[count];
+    }
+    return count;
+}
+
+double get_moving_average(int id) {
+    std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+
+    if (p == nullptr || p->price_buffer.empty()) {
         return 0.0;
     }
 
     double sum = 0.0;
-    for (double p : price_buffer) {
-        sum += p;
+    for (double price : p->price_buffer) {
+        sum += price;
     }
-    return sum / price_buffer.size();
+    return sum / p->price_buffer.size();
 }
 
-double get_high(void) {
+int get_sample_count(int id) {
     std::lock_guard<std::mutex> lock(mtx);
-    return high_price;
+    Processor* p = find(id);
+    if (p == nullptr) {
+        return 0;
+    }
+    return p->price_buffer.size();
 }
 
-double get_low(void) {
+int get_window(int id) {
     std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+    if (p == nullptr) {
+        return 0;
+    }
+    return p->window;
+}
+
+double get_high(int id) {
+    std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
+    if (p == nullptr) {
+        return 0.0;
+    }
+    return p->high_price;
+}
+
+double get_low(int id) {
+    std::lock_guard<std::mutex> lock(mtx);
+    Processor* p = find(id);
     // Return 0 if no prices have been added yet
-    if (low_price == std::numeric_limits<double>::max()) {
+    if (p == nullptr || p->low_price == std::numeric_limits<double>::max()) {
         return 0.0;
     }
-    return low_price;
+    return p->low_price;
 }
 
-void reset_processor(void) {
+void reset_processor(int id) {
     std::lock_guard<std::mutex> lock(mtx);
-    price_buffer.clear();
-    high_price = 0.0;
-    low_price = std::numeric_limits<double>::max();
+    Processor* p = find(id);
+    if (p == nullptr) {
+        return;
+    }
+    size_t window = p->window;
+    std::vector<int> ema_spans = p->ema_spans;
+    *p = Processor();
+    p->window = window;
+    p->ema_spans = ema_spans;
+    p->emas.assign(ema_spans.size(), 0.0);
 }
 
 } // extern "C"
@@ -5,20 +5,56 @@
 extern "C" {
 #endif
 
-// Add a new price to the buffer
-void add_price(double price);
+// Create a new processor averaging over the last `window` prices and
+// return its handle
+int create_processor(int window);
+
+// Change the moving average window, dropping the oldest prices if it shrinks
+void set_window(int id, int window);
+
+// Free a processor and its buffered prices
+void destroy_processor(int id);
+
+// Add a new price to the processor's buffer
+void add_price(int id, double price);
+
+// Add a trade: its price as add_price does, and its quantity to the
+// volume-weighted average
+void add_trade(int id, double price, double qty);
 
 // Get the simple moving average of buffered prices
-double get_moving_average(void);
+double get_moving_average(int id);
+
+// Get the volume-weighted average price of the session, 0 before any
+// trade with a quantity
+double get_vwap(int id);
+
+// Get the population standard deviation of buffered prices
+double get_stddev(int id);
+
+// Set the spans of the exponential moving averages, at most `n`. Averages
+// for spans that were already set carry on; new ones start from the last
+// price.
+void set_ema_spans(int id, const int* spans, int n);
+
+// Copy up to `n` exponential moving averages, in the order their spans were
+// set, into `out` and return how many were copied
+int get_emas(int id, double* out, int n);
+
+// Get the number of prices in the moving average buffer
+int get_sample_count(int id);
+
+// Get the moving average window size
+int get_window(int id);
 
 // Get the highest price seen
-double get_high(void);
+double get_high(int id);
 
 // Get the lowest price seen
-double get_low(void);
+double get_low(int id);
 
-// Reset all data
-void reset_processor(void);
+// Reset all data for one processor
+void reset_processor(int id);
 
 #ifdef __cplusplus
 }
@@ -0,0 +1,224 @@
+package main
+
+/*
+#cgo LDFLAGS: -L. -lprocess -lpthread -lstdc++
+#include "process.h"
+*/
+import "C"
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Processor is a handle to one C++ price processor
+type Processor struct {
+	symbol string
+	id     C.int
+	spans  []int // EMA spans, guarded by mu
+
+	// Sampling state for Process
+	mu     sync.Mutex
+	second int64 // unix second the budget applies to
+	used   int   // trades processed in that second
+	last   ProcessorStats
+
+	// Session volume, counted for every trade including sampled ones
+	volume, buyVolume, sellVolume float64
+
+	// What sampling keeps from the C++ processor's session high, low and
+	// VWAP: the extremes and notional of the trades that skipped it (high
+	// and low 0 before the first), and the size of those that didn't, to
+	// weigh its VWAP against theirs
+	skipHigh, skipLow     float64
+	skipNotional, skipQty float64
+	cgoQty                float64
+}
+
+// ProcessorStats is what a processor reports after a trade
+type ProcessorStats struct {
+	MovingAverage, High, Low float64
+	VWAP, StdDev             float64
+	EMA                      map[string]float64 // by span
+	Samples, Window          int
+	// Base asset traded this session, and the part with a known taker side
+	Volume, BuyVolume, SellVolume float64
+}
+
+// NewProcessor allocates a processor for a symbol with a moving average
+// over the last window prices and exponential moving averages over spans
+func NewProcessor(symbol string, window int, spans []int) *Processor {
+	p := &Processor{
+		symbol: symbol,
+		id:     C.create_processor(C.int(window)),
+	}
+	p.SetEMASpans(spans)
+	return p
+}
+
+// SetWindow changes the moving average window
+func (p *Processor) SetWindow(window int) {
+	C.set_window(p.id, C.int(window))
+}
+
+// SetEMASpans changes the exponential moving averages computed. Averages
+// over spans that were already set carry on.
+func (p *Processor) SetEMASpans(spans []int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.spans = append([]int(nil), spans...)
+	if len(spans) == 0 {
+		C.set_ema_spans(p.id, nil, 0)
+		return
+	}
+	cSpans := make([]C.int, len(spans))
+	for i, span := range spans {
+		cSpans[i] = C.int(span)
+	}
+	C.set_ema_spans(p.id, &cSpans[0], C.int(len(cSpans)))
+}
+
+// Add feeds a trade into the processor; qty is 0 when the trade's size is
+// unknown, leaving it out of the VWAP
+func (p *Processor) Add(price, qty float64) {
+	defer observeCgo(cgoAddTrade, time.Now())
+	C.add_trade(p.id, C.double(price), C.double(qty))
+}
+
+// Stats returns the moving average and session high/low
+func (p *Processor) Stats() (movingAverage, high, low float64) {
+	start := time.Now()
+	movingAverage = float64(C.get_moving_average(p.id))
+	start = observeCgo(cgoGetMovingAverage, start)
+	high = float64(C.get_high(p.id))
+	start = observeCgo(cgoGetHigh, start)
+	low = float64(C.get_low(p.id))
+	observeCgo(cgoGetLow, start)
+	return movingAverage, high, low
+}
+
+// Spread returns the session VWAP and the standard deviation of the prices
+// the moving average covers
+func (p *Processor) Spread() (vwap, stdDev float64) {
+	start := time.Now()
+	vwap = float64(C.get_vwap(p.id))
+	start = observeCgo(cgoGetVWAP, start)
+	stdDev = float64(C.get_stddev(p.id))
+	observeCgo(cgoGetStdDev, start)
+	return vwap, stdDev
+}
+
+// emas returns the exponential moving averages keyed by span. Caller holds
+// p.mu.
+func (p *Processor) emas() map[string]float64 {
+	if len(p.spans) == 0 {
+		return nil
+	}
+	defer observeCgo(cgoGetEMAs, time.Now())
+	values := make([]C.double, len(p.spans))
+	n := int(C.get_emas(p.id, &values[0], C.int(len(values))))
+	out := make(map[string]float64, n)
+	for i := 0; i < n; i++ {
+		out[strconv.Itoa(p.spans[i])] = float64(values[i])
+	}
+	return out
+}
+
+// Samples returns how many prices the moving average covers and the window
+// size, so callers can tell a warming-up average from a full one
+func (p *Processor) Samples() (samples, window int) {
+	start := time.Now()
+	samples = int(C.get_sample_count(p.id))
+	start = observeCgo(cgoGetSampleCount, start)
+	window = int(C.get_window(p.id))
+	observeCgo(cgoGetWindow, start)
+	return samples, window
+}
+
+// Process adds a trade price and returns the processor's stats. With
+// maxRate set, at most maxRate trades per second go through the C++
+// processor; the rest skip it and get the moving averages of the last one
+// that went through, trading their accuracy for a bounded cgo cost. Session
+// volume, high, low and VWAP count every trade.
+func (p *Processor) Process(price, qty float64, side string, maxRate int) (stats ProcessorStats, sampled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.volume += qty
+	switch side {
+	case sideBuy:
+		p.buyVolume += qty
+	case sideSell:
+		p.sellVolume += qty
+	}
+
+	if maxRate > 0 {
+		if now := time.Now().Unix(); now != p.second {
+			p.second, p.used = now, 0
+		}
+		if p.used >= maxRate {
+			p.skip(price, qty)
+			return p.session(p.last), true
+		}
+		p.used++
+	}
+
+	p.Add(price, qty)
+	if qty > 0 {
+		p.cgoQty += qty
+	}
+	p.last.MovingAverage, p.last.High, p.last.Low = p.Stats()
+	p.last.VWAP, p.last.StdDev = p.Spread()
+	p.last.EMA = p.emas()
+	p.last.Samples, p.last.Window = p.Samples()
+	return p.session(p.last), false
+}
+
+// skip records a trade sampling kept from the C++ processor. Caller holds
+// p.mu.
+func (p *Processor) skip(price, qty float64) {
+	if p.skipHigh == 0 || price > p.skipHigh {
+		p.skipHigh = price
+	}
+	if p.skipLow == 0 || price < p.skipLow {
+		p.skipLow = price
+	}
+	if qty > 0 {
+		p.skipNotional += price * qty
+		p.skipQty += qty
+	}
+}
+
+// session fills in the session volume and folds the skipped trades into the
+// C++ processor's high, low and VWAP. Caller holds p.mu.
+func (p *Processor) session(stats ProcessorStats) ProcessorStats {
+	stats.Volume, stats.BuyVolume, stats.SellVolume = p.volume, p.buyVolume, p.sellVolume
+	if p.skipHigh > stats.High {
+		stats.High = p.skipHigh
+	}
+	if p.skipLow > 0 && (stats.Low == 0 || p.skipLow < stats.Low) {
+		stats.Low = p.skipLow
+	}
+	if p.skipQty > 0 {
+		stats.VWAP = (stats.VWAP*p.cgoQty + p.skipNotional) / (p.cgoQty + p.skipQty)
+	}
+	return stats
+}
+
+// Reset clears buffered prices, high/low, VWAP and volume, and the stats
+// sampled trades get
+func (p *Processor) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	C.reset_processor(p.id)
+	p.used = 0
+	p.last = ProcessorStats{}
+	p.volume, p.buyVolume, p.sellVolume = 0, 0, 0
+	p.skipHigh, p.skipLow, p.skipNotional, p.skipQty, p.cgoQty = 0, 0, 0, 0, 0
+}
+
+// Close frees the C++ state; the processor must not be used afterwards
+func (p *Processor) Close() {
+	C.destroy_processor(p.id)
+}
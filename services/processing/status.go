@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// How often processor metrics are published on status.processing
+const statusInterval = 10 * time.Second
+
+// cgoCall identifies a C function whose calls are counted and timed
+type cgoCall int
+
+const (
+	cgoAddTrade cgoCall = iota
+	cgoGetMovingAverage
+	cgoGetHigh
+	cgoGetLow
+	cgoGetVWAP
+	cgoGetStdDev
+	cgoGetEMAs
+	cgoGetSampleCount
+	cgoGetWindow
+	numCgoCalls
+)
+
+var cgoCallNames = [numCgoCalls]string{
+	"add_trade",
+	"get_moving_average",
+	"get_high",
+	"get_low",
+	"get_vwap",
+	"get_stddev",
+	"get_emas",
+	"get_sample_count",
+	"get_window",
+}
+
+// Processor counters, updated by Processor and the trades.raw handler
+var metrics struct {
+	trades  atomic.Int64
+	sampled atomic.Int64 // trades that skipped the C++ processor
+	cgo     [numCgoCalls]struct{ calls, nanos atomic.Int64 }
+}
+
+// observeCgo records a C call that began at start and returns when it
+// ended, so back-to-back calls are timed with one clock read each
+func observeCgo(call cgoCall, start time.Time) time.Time {
+	end := time.Now()
+	m := &metrics.cgo[call]
+	m.calls.Add(1)
+	m.nanos.Add(int64(end.Sub(start)))
+	return end
+}
+
+// CgoCallStatus is the cost of one C function since start
+type CgoCallStatus struct {
+	Calls   int64   `json:"calls"`
+	TotalMs float64 `json:"total_ms"`
+	AvgNs   int64   `json:"avg_ns"`
+}
+
+// ProcessingStatus is published to status.processing so the API can report
+// what the cgo boundary costs
+type ProcessingStatus struct {
+	Trades     int64                    `json:"trades"`
+	Sampled    int64                    `json:"sampled"`
+	MaxCgoRate int                      `json:"max_cgo_rate"`
+	Cgo        map[string]CgoCallStatus `json:"cgo"`
+	ReportedAt int64                    `json:"reported_at"`
+}
+
+// reportStatus publishes processor metrics every statusInterval until ctx is
+// done
+func reportStatus(ctx context.Context, nc *nats.Conn) {
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		status := ProcessingStatus{
+			Trades:     metrics.trades.Load(),
+			Sampled:    metrics.sampled.Load(),
+			MaxCgoRate: currentConfig().MaxCgoRate,
+			Cgo:        make(map[string]CgoCallStatus, numCgoCalls),
+			ReportedAt: time.Now().UnixMilli(),
+		}
+		for call, name := range cgoCallNames {
+			calls, nanos := metrics.cgo[call].calls.Load(), metrics.cgo[call].nanos.Load()
+			cs := CgoCallStatus{Calls: calls, TotalMs: float64(nanos) / 1e6}
+			if calls > 0 {
+				cs.AvgNs = nanos / calls
+			}
+			status.Cgo[name] = cs
+		}
+		data, _ := json.Marshal(status)
+		nc.Publish("status.processing", data)
+	}
+}
@@ -0,0 +1,347 @@
+// Package client is a Go client for the API service: typed calls for its
+// REST endpoints and a WebSocket stream of prices and server events. The
+// TUI is built on it, and other programs can use it the same way:
+//
+//	c := client.New("http://localhost:8080")
+//	snap, err := c.Snapshot(ctx)
+//	...
+//	prices, err := c.StreamPrices(ctx)
+//	for p := range prices {
+//		fmt.Println(p.Symbol, p.Price)
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to one API server
+type Client struct {
+	BaseURL string // e.g. http://localhost:8080
+	HTTP    *http.Client
+	Token   string // the server's API token, sent as a bearer token when set
+}
+
+// New returns a client for the server at baseURL
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// authorize adds the bearer token to a request's headers, if there is one
+func (c *Client) authorize(h http.Header) {
+	if c.Token != "" {
+		h.Set("Authorization", "Bearer "+c.Token)
+	}
+}
+
+// Error is a response with an error status. Message is the body the
+// server sent, e.g. "Unknown symbol".
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Stats are the selected symbol's session statistics
+type Stats struct {
+	MovingAverage float64            `json:"moving_average"`
+	High          float64            `json:"high"`
+	Low           float64            `json:"low"`
+	VWAP          float64            `json:"vwap"`
+	StdDev        float64            `json:"std_dev"`
+	EMA           map[string]float64 `json:"ema"` // by span in trades
+	Volume        float64            `json:"volume"`
+	BuyVolume     float64            `json:"buy_volume"`
+	SellVolume    float64            `json:"sell_volume"`
+	BuyRatio      float64            `json:"buy_ratio"` // taker buys over buy plus sell volume
+	TradesPerSec  float64            `json:"trades_per_sec"`
+	Samples       int                `json:"samples"`
+	WindowFull    bool               `json:"window_full"`
+}
+
+// TimeframeStats is the price movement over a rolling timeframe
+type TimeframeStats struct {
+	Open          float64 `json:"open"`
+	Close         float64 `json:"close"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	Average       float64 `json:"average"`
+	Trades        int64   `json:"trades"`
+	Volume        float64 `json:"volume"`      // 0 from servers before schema 3
+	BuyVolume     float64 `json:"buy_volume"`  // likewise
+	SellVolume    float64 `json:"sell_volume"` // likewise
+}
+
+// PercentileBand places the price within a trailing range
+type PercentileBand struct {
+	Percentile float64 `json:"percentile"`
+	Low        float64 `json:"low"`
+	High       float64 `json:"high"`
+	Candles    int     `json:"candles"`
+	Complete   bool    `json:"complete"` // the candles span the whole range
+}
+
+// Anchor is the current price relative to an anchored one
+type Anchor struct {
+	Price         float64   `json:"price"`
+	SetAt         time.Time `json:"set_at"`
+	Symbol        string    `json:"symbol"`
+	Current       float64   `json:"current"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+}
+
+// Snapshot is everything a dashboard needs to render the selected symbol
+type Snapshot struct {
+	Symbol    string  `json:"symbol"`
+	Name      string  `json:"name"`
+	Quote     string  `json:"quote"`
+	Precision int     `json:"precision"`
+	Price     Decimal `json:"price"`
+	Stats
+	Anchor     *Anchor                   `json:"anchor"`
+	LastTrade  *time.Time                `json:"last_trade"`
+	Stale      bool                      `json:"stale"`
+	Timeframes map[string]TimeframeStats `json:"timeframes"` // 1m, 5m, 1h and 24h
+	Bands      map[string]PercentileBand `json:"bands"`      // 24h and 7d, once they have candles
+}
+
+// Market is a coin traded against one quote currency
+type Market struct {
+	Symbol    string    `json:"symbol"`
+	Quote     string    `json:"quote"`
+	Name      string    `json:"name"`
+	Precision int       `json:"precision"`
+	Sparkline []float64 `json:"sparkline,omitempty"`
+}
+
+// CoinInfo is a selectable coin. Symbol and Name describe its default
+// market, the first in Markets.
+type CoinInfo struct {
+	Symbol    string    `json:"symbol"`
+	Name      string    `json:"name"`
+	Sparkline []float64 `json:"sparkline,omitempty"`
+	Markets   []Market  `json:"markets"`
+}
+
+// SymbolMeta describes a market and how its prices are formatted
+type SymbolMeta struct {
+	Symbol    string  `json:"symbol"`
+	Name      string  `json:"name"`
+	Base      string  `json:"base_asset"`
+	Quote     string  `json:"quote_asset"`
+	TickSize  float64 `json:"tick_size"`
+	Precision int     `json:"precision"` // decimals the API rounds prices to
+}
+
+// Trade is a stored trade
+type Trade struct {
+	Symbol    string    `json:"symbol"`
+	Price     Decimal   `json:"price"`
+	Qty       float64   `json:"qty,omitempty"`  // 0 when unknown
+	Side      string    `json:"side,omitempty"` // taker side, buy or sell; empty when unknown
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HistoryQuery selects a page of history. Zero fields use the server's
+// defaults: the selected symbol and 100 trades, newest first.
+type HistoryQuery struct {
+	Symbol string
+	Limit  int
+	Cursor string // NextCursor of the previous page
+	// SinceID asks for only the trades stored after a page's SinceID
+	SinceID string
+}
+
+// HistoryPage is one page of trades, newest first
+type HistoryPage struct {
+	Trades     []Trade
+	NextCursor string // fetches the following page, empty on the last one
+	SinceID    string // pass as SinceID to fetch what is stored later
+	// Reset is set when a SinceID query fell too far behind and got the
+	// newest page instead, to replace rather than prepend
+	Reset bool
+}
+
+// Price returns the selected symbol's last price
+func (c *Client) Price(ctx context.Context) (Decimal, error) {
+	var body struct {
+		Price Decimal `json:"price"`
+	}
+	_, err := c.do(ctx, http.MethodGet, "/api/price", nil, &body)
+	return body.Price, err
+}
+
+// Stats returns the selected symbol's session statistics
+func (c *Client) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	_, err := c.do(ctx, http.MethodGet, "/api/stats", nil, &stats)
+	return stats, err
+}
+
+// Snapshot returns the selected symbol's price, stats and timeframes in
+// one request
+func (c *Client) Snapshot(ctx context.Context) (Snapshot, error) {
+	var snap Snapshot
+	_, err := c.do(ctx, http.MethodGet, "/api/snapshot", nil, &snap)
+	return snap, err
+}
+
+// VersionInfo identifies the server and what it was built and configured
+// with
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Features  struct {
+		Database  string   `json:"database"`
+		Processor string   `json:"processor"`
+		Exchanges []string `json:"exchanges"`
+		Redis     bool     `json:"redis"`
+	} `json:"features"`
+	Protocols struct {
+		WebSocket []int  `json:"websocket"`
+		Schema    int    `json:"schema"`
+		JSONRPC   string `json:"jsonrpc"`
+	} `json:"protocols"`
+}
+
+// Version returns the server's version, build and features
+func (c *Client) Version(ctx context.Context) (VersionInfo, error) {
+	var info VersionInfo
+	_, err := c.do(ctx, http.MethodGet, "/api/version", nil, &info)
+	return info, err
+}
+
+// Coins lists the selectable coins, with the last 30 minutes of prices
+// when withSparkline is set
+func (c *Client) Coins(ctx context.Context, withSparkline bool) ([]CoinInfo, error) {
+	path := "/api/coins"
+	if withSparkline {
+		path += "?with_sparkline=true"
+	}
+	var coins []CoinInfo
+	_, err := c.do(ctx, http.MethodGet, path, nil, &coins)
+	return coins, err
+}
+
+// History returns a page of stored trades. A SinceID query with nothing
+// new returns an empty page carrying the same SinceID.
+func (c *Client) History(ctx context.Context, q HistoryQuery) (HistoryPage, error) {
+	params := url.Values{}
+	if q.Symbol != "" {
+		params.Set("symbol", q.Symbol)
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Cursor != "" {
+		params.Set("cursor", q.Cursor)
+	}
+	if q.SinceID != "" {
+		params.Set("since_id", q.SinceID)
+	}
+	path := "/api/history"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	var page HistoryPage
+	resp, err := c.do(ctx, http.MethodGet, path, nil, &page.Trades)
+	if err != nil {
+		return page, err
+	}
+	page.NextCursor = resp.Header.Get("X-Next-Cursor")
+	page.SinceID = resp.Header.Get("X-Since-Id")
+	page.Reset = resp.Header.Get("X-History-Reset") != ""
+	if page.SinceID == "" {
+		page.SinceID = q.SinceID
+	}
+	return page, nil
+}
+
+// SymbolMeta describes a market, the selected one when symbol is empty
+func (c *Client) SymbolMeta(ctx context.Context, symbol string) (SymbolMeta, error) {
+	path := "/api/symbol/meta"
+	if symbol != "" {
+		path += "?symbol=" + url.QueryEscape(symbol)
+	}
+	var meta SymbolMeta
+	_, err := c.do(ctx, http.MethodGet, path, nil, &meta)
+	return meta, err
+}
+
+// SetSymbol switches the tracked symbol for every client and returns the
+// market's name. Selecting the current symbol again is a no-op.
+func (c *Client) SetSymbol(ctx context.Context, symbol string) (string, error) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	_, err := c.do(ctx, http.MethodPost, "/api/symbol", map[string]string{"symbol": symbol}, &body)
+	return body.Name, err
+}
+
+// SetAnchor anchors the selected symbol's current price
+func (c *Client) SetAnchor(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/api/anchor", nil, nil)
+	return err
+}
+
+// ClearAnchor removes the selected symbol's anchor
+func (c *Client) ClearAnchor(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodDelete, "/api/anchor", nil, nil)
+	return err
+}
+
+// do sends a request with body encoded as JSON, if any, and decodes the
+// response into out unless it is nil or the server answered 304
+func (c *Client) do(ctx context.Context, method, path string, body, out any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authorize(req.Header)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	return resp, json.NewDecoder(resp.Body).Decode(out)
+}
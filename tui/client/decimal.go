@@ -0,0 +1,96 @@
+package client
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Decimal is a trade price exactly as the server quotes it, a whole number
+// of 1e-8 quote units like price_e8 in its protobuf messages. It reads
+// prices sent as numbers, or as strings from servers asked for ?prices=string
+// or started with --price-format=string, without going through a float64.
+type Decimal int64
+
+const (
+	decimalPlaces = 8
+	decimalScale  = 100_000_000
+)
+
+// ParseDecimal reads plain decimal text, e.g. "0.00001234", rounding half
+// away from zero beyond 8 decimals
+func ParseDecimal(s string) (Decimal, error) {
+	text, neg := strings.CutPrefix(s, "-")
+	whole, frac, _ := strings.Cut(text, ".")
+	if whole == "" || strings.Trim(whole+frac, "0123456789") != "" {
+		return 0, errors.New("invalid decimal " + strconv.Quote(s))
+	}
+	roundUp := false
+	if len(frac) > decimalPlaces {
+		roundUp = frac[decimalPlaces] >= '5'
+		frac = frac[:decimalPlaces]
+	}
+	frac += strings.Repeat("0", decimalPlaces-len(frac))
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || (roundUp && n == math.MaxInt64) {
+		return 0, errors.New("decimal out of range " + strconv.Quote(s))
+	}
+	if roundUp {
+		n++
+	}
+	if neg {
+		n = -n
+	}
+	return Decimal(n), nil
+}
+
+// Float64 returns d as a float, for arithmetic and display
+func (d Decimal) Float64() float64 {
+	return float64(d) / decimalScale
+}
+
+// String formats d with as many decimals as it has, e.g. 0.00001234
+func (d Decimal) String() string {
+	n := int64(d)
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	whole, frac := n/decimalScale, n%decimalScale
+	if frac == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+	digits := strconv.FormatInt(frac+decimalScale, 10)[1:] // zero padded
+	return sign + strconv.FormatInt(whole, 10) + "." + strings.TrimRight(digits, "0")
+}
+
+// MarshalJSON encodes d as a plain decimal number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads a number or a decimal string. Servers before exact
+// prices send floats, e.g. 1.234e-05, which are read through the shortest
+// decimal that reads back as them.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	v, err := ParseDecimal(text)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(text, 64)
+		if ferr != nil {
+			return err
+		}
+		if v, err = ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Delay before StreamPrices reconnects a dropped stream
+const reconnectDelay = 2 * time.Second
+
+// Event is one message on the WebSocket stream. Type tells them apart:
+// snapshot on connect, trade for the selected symbol's trades, market when
+// the selected symbol changes, paused and resumed after Pause and Resume,
+// server_shutdown before the server goes away, meta for session state,
+// plus one type per subscription channel. Fields a type doesn't carry are
+// left zero; Raw holds the whole message.
+type Event struct {
+	Type      string          `json:"type"`
+	Seq       int64           `json:"seq"`
+	Channel   string          `json:"channel"` // for subscription messages
+	Symbol    string          `json:"symbol"`
+	Price     Decimal         `json:"price"`
+	Quote     string          `json:"quote"`
+	Precision int             `json:"precision"`
+	Time      int64           `json:"ts"`      // exchange time of a trade, unix ms
+	Session   string          `json:"session"` // for meta
+	State     json.RawMessage `json:"state"`   // for meta, as published
+	Raw       []byte          `json:"-"`
+}
+
+// PriceUpdate is a trade of the selected symbol
+type PriceUpdate struct {
+	Symbol    string
+	Price     Decimal
+	Quote     string
+	Precision int
+	Time      time.Time
+}
+
+// Stream is an open WebSocket to the server
+type Stream struct {
+	conn *websocket.Conn
+	mu   sync.Mutex // one writer at a time
+}
+
+// StreamProtocol is the WebSocket protocol version Dial asks for; check it
+// against VersionInfo.Protocols.WebSocket
+const StreamProtocol = 2
+
+// streamDialer offers the server permessage-deflate, which it uses when
+// started with --ws-compression
+var streamDialer = &websocket.Dialer{
+	Proxy:             http.ProxyFromEnvironment,
+	HandshakeTimeout:  websocket.DefaultDialer.HandshakeTimeout,
+	EnableCompression: true,
+}
+
+// Dial opens the server's WebSocket stream
+func (c *Client) Dial(ctx context.Context) (*Stream, error) {
+	url := "ws" + strings.TrimPrefix(c.BaseURL, "http") + "/ws?v=" + strconv.Itoa(StreamProtocol)
+	header := http.Header{}
+	c.authorize(header)
+	conn, resp, err := streamDialer.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+		}
+		return nil, err
+	}
+	return &Stream{conn: conn}, nil
+}
+
+// Next blocks until the next event. Messages that aren't JSON objects are
+// skipped.
+func (s *Stream) Next() (Event, error) {
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return Event{}, err
+		}
+		var e Event
+		if json.Unmarshal(data, &e) != nil {
+			continue
+		}
+		e.Raw = data
+		return e, nil
+	}
+}
+
+// Pause asks the server to hold back market data, e.g. while it isn't
+// shown. Subscriptions are kept, and alerts and control events still
+// arrive.
+func (s *Stream) Pause() error {
+	return s.send(map[string]string{"action": "pause"})
+}
+
+// Resume restarts market data after Pause; the server sends a fresh
+// snapshot first
+func (s *Stream) Resume() error {
+	return s.send(map[string]string{"action": "resume"})
+}
+
+// SetRate asks the server to send market data at most maxPerSec times a
+// second per subscription, coalescing what arrives in between; 0 sends
+// every update
+func (s *Stream) SetRate(maxPerSec float64) error {
+	return s.send(map[string]any{"op": "set_rate", "max_per_sec": maxPerSec})
+}
+
+// JoinSession subscribes to a session's shared view state. The latest
+// state, if any was published, arrives as a meta event right away, and
+// each later one from the session's other clients follows.
+func (s *Stream) JoinSession(session string) error {
+	return s.send(map[string]string{"op": "subscribe", "channel": "meta", "session": session})
+}
+
+// PublishSession shares state, which must encode as a JSON object, with
+// the session's other clients. The sender doesn't get it back.
+func (s *Stream) PublishSession(session string, state any) error {
+	return s.send(map[string]any{"op": "publish", "channel": "meta", "session": session, "state": state})
+}
+
+func (s *Stream) send(msg any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.WriteJSON(msg)
+}
+
+// Close closes the connection, unblocking Next
+func (s *Stream) Close() error {
+	return s.conn.Close()
+}
+
+// StreamPrices delivers the selected symbol's trades until ctx is done,
+// reconnecting whenever the connection drops. Only the first connection
+// attempt is reported as an error; the channel is closed when ctx is done.
+func (c *Client) StreamPrices(ctx context.Context) (<-chan PriceUpdate, error) {
+	stream, err := c.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(chan PriceUpdate)
+	go func() {
+		defer close(prices)
+		for {
+			c.forwardPrices(ctx, stream, prices)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(reconnectDelay):
+				}
+				if stream, err = c.Dial(ctx); err == nil {
+					break
+				}
+			}
+		}
+	}()
+	return prices, nil
+}
+
+// forwardPrices sends a stream's trade events to prices until the
+// connection drops or ctx is done, then closes it
+func (c *Client) forwardPrices(ctx context.Context, stream *Stream, prices chan<- PriceUpdate) {
+	stop := context.AfterFunc(ctx, func() { stream.Close() })
+	defer stop()
+	defer stream.Close()
+
+	for {
+		e, err := stream.Next()
+		if err != nil {
+			return
+		}
+		if e.Type != "trade" || e.Channel != "" {
+			continue
+		}
+		select {
+		case prices <- PriceUpdate{
+			Symbol:    e.Symbol,
+			Price:     e.Price,
+			Quote:     e.Quote,
+			Precision: e.Precision,
+			Time:      time.UnixMilli(e.Time),
+		}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// compact is set by --compact to always draw the dashboard as a few
+// unframed lines. Without it the dashboard turns compact by itself when the
+// terminal is too short for the full one, e.g. in a small tmux pane.
+var compact bool
+
+// useCompact reports whether the dashboard should be drawn compact, given
+// the full one that would be drawn otherwise
+func (m model) useCompact(full string) bool {
+	return compact || m.height > 0 && lipgloss.Height(full) > m.height
+}
+
+// viewCompact draws the dashboard in three lines, name, price with change
+// and sparkline, plus the key hints when the terminal has a fourth
+func (m model) viewCompact() string {
+	var status string
+	switch {
+	case m.restarting:
+		status = "Server restarting, reconnecting" + glyphs.ellipsis
+	case m.data.Error != "":
+		status = errorStyle.Render(m.data.Error)
+	case !m.data.Connected:
+		status = "Connecting to server..."
+	case m.switching:
+		status = "Switching coin..."
+	}
+	if status != "" {
+		return labelStyle.Render(status)
+	}
+
+	coinName := m.data.CoinName
+	if coinName == "" {
+		coinName = "Crypto"
+	}
+	tfLabel := "session"
+	change, changePercent := m.data.Change, m.data.ChangePercent
+	if tf, ok := m.data.Timeframes[m.timeframe]; ok && m.timeframe != "" {
+		tfLabel = m.timeframe
+		change, changePercent = tf.Change, tf.ChangePercent
+	}
+
+	lines := []string{
+		selectedStyle.Render(glyphs.header+coinName) + " " + labelStyle.Render("["+tfLabel+"]"),
+		priceStyle.Render(m.formatPrice(m.data.Price)) + "  " + m.renderChange(change, changePercent),
+		m.renderSparkline(),
+	}
+	if m.height == 0 || m.height > len(lines) {
+		lines = append(lines, labelStyle.Render(helpLine("c: coin", "1-4: timeframe", "q: quit")))
+	}
+	return strings.Join(lines, "\n")
+}
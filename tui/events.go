@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"tui-client/client"
+)
+
+// serverShutdownMsg is sent when the server announces it is going away
+type serverShutdownMsg struct{}
+
+// viewState is what TUIs in one session share: the operator's coin,
+// timeframe and sparkline scale
+type viewState struct {
+	Symbol    string `json:"symbol"`
+	Timeframe string `json:"timeframe"`
+	LogScale  bool   `json:"log_scale"`
+}
+
+// sessionMsg is a view published by another TUI in the session
+type sessionMsg viewState
+
+// The session set by --session, empty for none. With --follow this TUI
+// only follows it and never publishes.
+var session struct {
+	name   string
+	follow bool
+}
+
+// stream is the open event connection and whether the server should hold
+// back prices on it. Writes go through mu, one at a time.
+var stream struct {
+	mu     sync.Mutex
+	conn   *client.Stream // nil while disconnected
+	paused bool
+}
+
+// pauseStream asks the server to stop or restart sending prices, which the
+// selector and history views don't show. The choice also applies to later
+// connections.
+func pauseStream(paused bool) tea.Cmd {
+	return func() tea.Msg {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		if stream.paused != paused && stream.conn != nil {
+			sendFlowControl(stream.conn, paused)
+		}
+		stream.paused = paused
+		return nil
+	}
+}
+
+// publishView shares the view with the session, unless following only or
+// disconnected; the server hands the latest view to TUIs that join later
+func publishView(state viewState) tea.Cmd {
+	if session.name == "" || session.follow {
+		return nil
+	}
+	return func() tea.Msg {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		if stream.conn != nil {
+			stream.conn.PublishSession(session.name, state)
+		}
+		return nil
+	}
+}
+
+func sendFlowControl(conn *client.Stream, paused bool) {
+	if paused {
+		conn.Pause()
+	} else {
+		conn.Resume()
+	}
+}
+
+// listenEvents keeps a WebSocket open to the server and forwards server
+// events to the program, reconnecting whenever the connection drops
+func listenEvents(p *tea.Program) {
+	for {
+		conn, err := api.Dial(context.Background())
+		if err != nil {
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		stream.mu.Lock()
+		stream.conn = conn
+		if stream.paused {
+			sendFlowControl(conn, true)
+		}
+		if session.name != "" {
+			conn.JoinSession(session.name)
+		}
+		stream.mu.Unlock()
+
+		for {
+			event, err := conn.Next()
+			if err != nil {
+				break
+			}
+			switch event.Type {
+			case "server_shutdown":
+				p.Send(serverShutdownMsg{})
+			case "meta":
+				var state viewState
+				if json.Unmarshal(event.State, &state) == nil {
+					p.Send(sessionMsg(state))
+				}
+			}
+		}
+		stream.mu.Lock()
+		stream.conn = nil
+		stream.mu.Unlock()
+		conn.Close()
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -1,18 +1,26 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
+	"math"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"tui-client/client"
 )
 
-const serverURL = "http://localhost:8080"
+// The API server, at the URL set by --server or SIGN_SERVER_URL
+var api *client.Client
 
 // Styles
 var (
@@ -60,31 +68,11 @@ var (
 			Foreground(lipgloss.Color("6"))
 )
 
-// API response types
-type PriceResponse struct {
-	Price float64 `json:"price"`
-}
-
-type StatsResponse struct {
-	MovingAverage float64 `json:"moving_average"`
-	High          float64 `json:"high"`
-	Low           float64 `json:"low"`
-}
-
-type SymbolResponse struct {
-	Symbol string `json:"symbol"`
-	Name   string `json:"name"`
-}
-
-type CoinInfo struct {
-	Symbol string `json:"symbol"`
-	Name   string `json:"name"`
-}
-
-type HistoryTrade struct {
-	Symbol    string    `json:"symbol"`
-	Price     float64   `json:"price"`
-	Timestamp time.Time `json:"timestamp"`
+// coinRow is a line of the coin selector: a coin, or one of its markets
+// when the coin is expanded (market >= 0)
+type coinRow struct {
+	coin   int
+	market int
 }
 
 // Dashboard data
@@ -98,10 +86,19 @@ type DashboardData struct {
 	MovingAverage float64
 	Change        float64
 	ChangePercent float64
+	TradesPerSec  float64
+	Samples       int
+	WindowFull    bool
+	Anchor        *client.Anchor
+	Timeframes    map[string]client.TimeframeStats
+	Bands         map[string]client.PercentileBand
 	Connected     bool
 	Error         string
 }
 
+// Timeframes selectable with keys 1-4; key 0 returns to session stats
+var timeframes = []string{"1m", "5m", "1h", "24h"}
+
 // View modes
 type viewMode int
 
@@ -114,32 +111,135 @@ const (
 // Messages
 type tickMsg time.Time
 type dataMsg DashboardData
-type coinsMsg []CoinInfo
+type coinsMsg []client.CoinInfo
 type symbolChangedMsg struct{}
-type historyMsg []HistoryTrade
+type versionMsg client.VersionInfo
+type metaMsg client.SymbolMeta
+
+// historyMsg is one page of history; more pages follow when next is set
+type historyMsg struct {
+	trades []client.Trade
+	next   string
+	since  string // since_id for the next refresh
+	page   bool   // a follow-up page to append rather than a fresh load
+	delta  bool   // trades newer than the loaded history, to prepend
+}
 
 // Model
 type model struct {
-	mode         viewMode
-	data         DashboardData
-	history      []float64
-	dbHistory    []HistoryTrade
-	quitting     bool
-	coins        []CoinInfo
-	coinCursor   int
-	switching    bool
+	mode          viewMode
+	data          DashboardData
+	history       []float64
+	dbHistory     []client.Trade
+	quitting      bool
+	coins         []client.CoinInfo
+	coinCursor    int // index into coinRows()
+	expanded      int // coin whose markets are listed, -1 for none
+	switching     bool
 	historyScroll int
+	historyNext   string // cursor for the next page, empty when at the end
+	historyPaging bool   // a follow-up page is being fetched
+	historySince  string // since_id of the newest loaded trade
+	logScale      bool
+	timeframe     string              // empty for session stats
+	restarting    bool                // server announced a shutdown, waiting for it to return
+	server        *client.VersionInfo // nil until /api/version answers
+	height        int                 // terminal rows, 0 until the terminal reports its size
+	meta          *client.SymbolMeta  // formatting of the tracked market, nil until /api/symbol/meta answers
 }
 
 func initialModel() model {
-	return model{
-		mode:    coinSelectView, // Start with coin selection
-		history: make([]float64, 0, 20),
+	m := model{
+		mode:     coinSelectView, // Start with coin selection
+		history:  make([]float64, 0, 20),
+		expanded: -1,
 	}
+	if session.follow {
+		// A follower shows whatever the operator picked
+		m.mode = dashboardView
+	}
+	return m
+}
+
+// view is the state shared with the session
+func (m model) view() viewState {
+	return viewState{Symbol: m.data.Symbol, Timeframe: m.timeframe, LogScale: m.logScale}
+}
+
+// coinRows lists the selector lines, with the expanded coin's markets
+// under it
+func (m model) coinRows() []coinRow {
+	var rows []coinRow
+	for i, coin := range m.coins {
+		rows = append(rows, coinRow{coin: i, market: -1})
+		if i == m.expanded {
+			for j := range coin.Markets {
+				rows = append(rows, coinRow{coin: i, market: j})
+			}
+		}
+	}
+	return rows
+}
+
+// selectCurrentCoin expands the coin holding the tracked symbol when it is
+// a secondary market, and puts the cursor on the tracked symbol
+func (m *model) selectCurrentCoin() {
+	m.expanded = -1
+	m.coinCursor = 0
+	for i, coin := range m.coins {
+		for j, market := range coin.Markets {
+			if market.Symbol == m.data.Symbol && j > 0 {
+				m.expanded = i
+			}
+		}
+	}
+	for i, row := range m.coinRows() {
+		if m.rowIsCurrent(row) {
+			m.coinCursor = i
+			return
+		}
+	}
+}
+
+// rowIsCurrent reports whether a selector line holds the tracked symbol. A
+// collapsed coin counts when any of its markets is tracked.
+func (m model) rowIsCurrent(row coinRow) bool {
+	coin := m.coins[row.coin]
+	if row.market >= 0 {
+		return coin.Markets[row.market].Symbol == m.data.Symbol
+	}
+	if row.coin == m.expanded {
+		return false
+	}
+	if coin.Symbol == m.data.Symbol {
+		return true
+	}
+	for _, market := range coin.Markets {
+		if market.Symbol == m.data.Symbol {
+			return true
+		}
+	}
+	return false
 }
 
 func (m model) Init() tea.Cmd {
-	return fetchCoins() // Fetch coins first
+	if m.mode == dashboardView {
+		return tea.Batch(fetchData(), tick(), fetchVersion())
+	}
+	// Fetch coins first; prices aren't shown until a coin is picked
+	return tea.Batch(fetchCoins(), pauseStream(true), fetchVersion())
+}
+
+// fetchVersion asks which server is running; older servers without
+// /api/version just leave the line out
+func fetchVersion() tea.Cmd {
+	return func() tea.Msg {
+		info, err := api.Version(context.Background())
+		if err != nil {
+			return nil
+		}
+		return versionMsg(info)
+	}
 }
 
 func tick() tea.Cmd {
@@ -152,93 +252,115 @@ func fetchData() tea.Cmd {
 	return func() tea.Msg {
 		data := DashboardData{}
 
-		// Fetch symbol info
-		symbolResp, err := http.Get(serverURL + "/api/symbol")
-		if err != nil {
-			data.Error = "Server not running. Start with 'make run'"
-			return dataMsg(data)
-		}
-		defer symbolResp.Body.Close()
-
-		var symbolData SymbolResponse
-		if err := json.NewDecoder(symbolResp.Body).Decode(&symbolData); err == nil {
-			data.Symbol = symbolData.Symbol
-			data.CoinName = symbolData.Name
-		}
-
-		// Fetch price
-		priceResp, err := http.Get(serverURL + "/api/price")
-		if err != nil {
-			data.Error = "Failed to fetch price"
+		// Fetch symbol, price, stats and timeframes in one request
+		snapshot, err := api.Snapshot(context.Background())
+		var netErr *url.Error
+		if errors.As(err, &netErr) {
+			data.Error = fmt.Sprintf("Server not running at %s. Start with 'make run'", api.BaseURL)
 			return dataMsg(data)
-		}
-		defer priceResp.Body.Close()
-
-		var priceData PriceResponse
-		if err := json.NewDecoder(priceResp.Body).Decode(&priceData); err == nil {
-			data.Price = priceData.Price
-		}
-
-		// Fetch stats
-		statsResp, err := http.Get(serverURL + "/api/stats")
-		if err != nil {
-			data.Error = "Failed to fetch stats"
+		} else if err != nil {
+			data.Error = "Failed to fetch snapshot"
 			return dataMsg(data)
 		}
-		defer statsResp.Body.Close()
-
-		var statsData StatsResponse
-		if err := json.NewDecoder(statsResp.Body).Decode(&statsData); err == nil {
-			data.MovingAverage = statsData.MovingAverage
-			data.High = statsData.High
-			data.Low = statsData.Low
-		}
+		data.Symbol = snapshot.Symbol
+		data.CoinName = snapshot.Name
+		data.Price = snapshot.Price.Float64()
+		data.MovingAverage = snapshot.MovingAverage
+		data.High = snapshot.High
+		data.Low = snapshot.Low
+		data.TradesPerSec = snapshot.TradesPerSec
+		data.Samples = snapshot.Samples
+		data.WindowFull = snapshot.WindowFull
+		data.Anchor = snapshot.Anchor
+		data.Timeframes = snapshot.Timeframes
+		data.Bands = snapshot.Bands
 
 		data.Connected = true
 		return dataMsg(data)
 	}
 }
 
-func fetchCoins() tea.Cmd {
+// fetchMeta asks how the tracked market's prices are formatted; older
+// servers without /api/symbol/meta leave the dashboard guessing
+func fetchMeta() tea.Cmd {
 	return func() tea.Msg {
-		resp, err := http.Get(serverURL + "/api/coins")
+		meta, err := api.SymbolMeta(context.Background(), "")
 		if err != nil {
-			return coinsMsg(nil)
+			return nil
 		}
-		defer resp.Body.Close()
+		return metaMsg(meta)
+	}
+}
 
-		var coins []CoinInfo
-		json.NewDecoder(resp.Body).Decode(&coins)
+func fetchCoins() tea.Cmd {
+	return func() tea.Msg {
+		coins, _ := api.Coins(context.Background(), true)
 		return coinsMsg(coins)
 	}
 }
 
-func fetchHistory() tea.Cmd {
+// Trades fetched per history page
+const historyPageSize = 100
+
+// fetchHistory loads the newest page of history, or the page after cursor
+func fetchHistory(cursor string) tea.Cmd {
 	return func() tea.Msg {
-		resp, err := http.Get(serverURL + "/api/history")
+		page, err := api.History(context.Background(), client.HistoryQuery{Limit: historyPageSize, Cursor: cursor})
 		if err != nil {
-			return historyMsg(nil)
+			return historyMsg{page: cursor != ""}
 		}
-		defer resp.Body.Close()
+		return historyMsg{
+			trades: page.Trades,
+			next:   page.NextCursor,
+			since:  page.SinceID,
+			page:   cursor != "",
+		}
+	}
+}
 
-		var trades []HistoryTrade
-		json.NewDecoder(resp.Body).Decode(&trades)
-		return historyMsg(trades)
+// refreshHistory fetches only the trades stored after since. The server
+// answers with nothing when there are none, and falls back to the newest
+// page when too many arrived to send as a delta.
+func refreshHistory(since string) tea.Cmd {
+	return func() tea.Msg {
+		page, err := api.History(context.Background(), client.HistoryQuery{Limit: historyPageSize, SinceID: since})
+		if err != nil {
+			return historyMsg{since: since, delta: true}
+		}
+		msg := historyMsg{trades: page.Trades, since: page.SinceID, delta: true}
+		if page.Reset {
+			msg.delta = false
+			msg.next = page.NextCursor
+		}
+		return msg
 	}
 }
 
 func changeSymbol(symbol string) tea.Cmd {
 	return func() tea.Msg {
-		body, _ := json.Marshal(map[string]string{"symbol": symbol})
-		resp, err := http.Post(serverURL+"/api/symbol", "application/json", bytes.NewReader(body))
-		if err != nil {
+		// An unknown symbol still answers, and the dashboard shows the one
+		// that stayed selected
+		_, err := api.SetSymbol(context.Background(), symbol)
+		var netErr *url.Error
+		if errors.As(err, &netErr) {
 			return nil
 		}
-		resp.Body.Close()
 		return symbolChangedMsg{}
 	}
 }
 
+// setAnchor anchors the current price, or clears the anchor when clear is set
+func setAnchor(clear bool) tea.Cmd {
+	return func() tea.Msg {
+		if clear {
+			api.ClearAnchor(context.Background())
+		} else {
+			api.SetAnchor(context.Background())
+		}
+		return nil
+	}
+}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -251,13 +373,30 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "c":
 				// Switch to coin selection
 				m.mode = coinSelectView
-				m.coinCursor = 0
-				return m, fetchCoins()
+				m.selectCurrentCoin()
+				return m, tea.Batch(fetchCoins(), pauseStream(true))
 			case "h":
 				// Switch to history view
 				m.mode = historyView
 				m.historyScroll = 0
-				return m, fetchHistory()
+				m.historySince = ""
+				return m, tea.Batch(fetchHistory(""), pauseStream(true))
+			case "l":
+				// Toggle logarithmic sparkline scale
+				m.logScale = !m.logScale
+				return m, publishView(m.view())
+			case "p":
+				// Anchor the current price
+				return m, setAnchor(false)
+			case "P":
+				return m, setAnchor(true)
+			case "0":
+				m.timeframe = ""
+				return m, publishView(m.view())
+			case "1", "2", "3", "4":
+				// Keys map onto timeframes in order
+				m.timeframe = timeframes[msg.String()[0]-'1']
+				return m, publishView(m.view())
 			}
 
 		case coinSelectView:
@@ -265,20 +404,38 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "ctrl+c", "q", "esc":
 				// Go back to dashboard
 				m.mode = dashboardView
-				return m, nil
+				return m, pauseStream(false)
 			case "up", "k":
 				if m.coinCursor > 0 {
 					m.coinCursor--
 				}
 			case "down", "j":
-				if m.coinCursor < len(m.coins)-1 {
+				if m.coinCursor < len(m.coinRows())-1 {
 					m.coinCursor++
 				}
+			case "right", "l":
+				// Expand the coin under the cursor to pick a market
+				if len(m.coins) > 0 {
+					row := m.coinRows()[m.coinCursor]
+					if row.market < 0 && len(m.coins[row.coin].Markets) > 1 {
+						m.expanded = row.coin
+						m.coinCursor++
+					}
+				}
+			case "left", "h":
+				if m.expanded >= 0 {
+					m.coinCursor = m.expanded
+					m.expanded = -1
+				}
 			case "enter", " ":
 				if len(m.coins) > 0 {
 					m.switching = true
-					selectedCoin := m.coins[m.coinCursor]
-					return m, changeSymbol(selectedCoin.Symbol)
+					row := m.coinRows()[m.coinCursor]
+					symbol := m.coins[row.coin].Symbol
+					if row.market >= 0 {
+						symbol = m.coins[row.coin].Markets[row.market].Symbol
+					}
+					return m, changeSymbol(symbol)
 				}
 			}
 
@@ -287,7 +444,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "ctrl+c", "q", "esc":
 				// Go back to dashboard
 				m.mode = dashboardView
-				return m, tea.Batch(fetchData(), tick())
+				return m, tea.Batch(fetchData(), tick(), pauseStream(false))
 			case "up", "k":
 				if m.historyScroll > 0 {
 					m.historyScroll--
@@ -300,12 +457,44 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if m.historyScroll < maxScroll {
 					m.historyScroll++
 				}
+				// Fetch the next page before the end comes into view
+				if m.historyNext != "" && !m.historyPaging && m.historyScroll+30 >= len(m.dbHistory) {
+					m.historyPaging = true
+					return m, fetchHistory(m.historyNext)
+				}
 			case "r":
-				// Refresh history
-				return m, fetchHistory()
+				// Fetch trades stored since the last load
+				if m.historySince != "" {
+					return m, refreshHistory(m.historySince)
+				}
+				m.historyScroll = 0
+				return m, fetchHistory("")
 			}
 		}
 
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		return m, nil
+
+	case serverShutdownMsg:
+		m.restarting = true
+		return m, nil
+
+	case sessionMsg:
+		if slices.Contains(timeframes, msg.Timeframe) || msg.Timeframe == "" {
+			m.timeframe = msg.Timeframe
+		}
+		m.logScale = msg.LogScale
+		// The selected symbol is the server's, so the new coin's prices are
+		// already coming; a TUI still picking one goes to the dashboard
+		if m.mode == coinSelectView && msg.Symbol != "" {
+			return m.Update(symbolChangedMsg{})
+		}
+		if msg.Symbol != m.data.Symbol && m.mode == dashboardView {
+			return m, fetchData()
+		}
+		return m, nil
+
 	case tickMsg:
 		if m.mode == dashboardView && !m.switching {
 			return m, tea.Batch(fetchData(), tick())
@@ -314,11 +503,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case dataMsg:
 		newData := DashboardData(msg)
+		if newData.Error == "" {
+			m.restarting = false
+		}
 
-		// Check if symbol changed (reset history)
+		// Check if symbol changed (reset history), and tell the session
+		var cmd tea.Cmd
 		if m.data.Symbol != "" && m.data.Symbol != newData.Symbol {
 			m.history = make([]float64, 0, 20)
 		}
+		if newData.Symbol != "" && m.data.Symbol != newData.Symbol {
+			cmd = tea.Batch(publishView(viewState{Symbol: newData.Symbol, Timeframe: m.timeframe, LogScale: m.logScale}), fetchMeta())
+		}
 
 		// Calculate change
 		if m.data.Price > 0 && newData.Price > 0 && m.data.Symbol == newData.Symbol {
@@ -336,28 +532,49 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.history = m.history[1:]
 			}
 		}
+		return m, cmd
+
+	case metaMsg:
+		meta := client.SymbolMeta(msg)
+		m.meta = &meta
+		return m, nil
+
+	case versionMsg:
+		info := client.VersionInfo(msg)
+		m.server = &info
 		return m, nil
 
 	case coinsMsg:
 		m.coins = msg
-		// Find current coin and set cursor
-		for i, coin := range m.coins {
-			if coin.Symbol == m.data.Symbol {
-				m.coinCursor = i
-				break
-			}
-		}
+		m.selectCurrentCoin()
 		return m, nil
 
 	case historyMsg:
-		m.dbHistory = msg
+		switch {
+		case msg.page:
+			m.historyPaging = false
+			m.dbHistory = append(m.dbHistory, msg.trades...)
+			m.historyNext = msg.next
+			return m, nil
+		case msg.delta:
+			// Keep the rows on screen in place unless already at the top
+			m.dbHistory = append(msg.trades, m.dbHistory...)
+			if m.historyScroll > 0 {
+				m.historyScroll += len(msg.trades)
+			}
+		default:
+			m.dbHistory = msg.trades
+			m.historyNext = msg.next
+			m.historyScroll = 0
+		}
+		m.historySince = msg.since
 		return m, nil
 
 	case symbolChangedMsg:
 		m.switching = false
 		m.mode = dashboardView
 		m.history = make([]float64, 0, 20)
-		return m, tea.Batch(fetchData(), tick())
+		return m, tea.Batch(fetchData(), tick(), pauseStream(false))
 	}
 
 	return m, nil
@@ -374,7 +591,11 @@ func (m model) View() string {
 	case historyView:
 		return m.viewHistory()
 	default:
-		return m.viewDashboard()
+		full := m.viewDashboard()
+		if m.useCompact(full) {
+			return m.viewCompact()
+		}
+		return full
 	}
 }
 
@@ -384,23 +605,31 @@ func (m model) viewCoinSelect() string {
 	if len(m.coins) == 0 {
 		s += labelStyle.Render("Loading coins...")
 	} else {
-		for i, coin := range m.coins {
+		for i, row := range m.coinRows() {
 			cursor := "  "
 			style := itemStyle
 			if i == m.coinCursor {
-				cursor = "▸ "
+				cursor = glyphs.cursor
 				style = selectedStyle
 			}
-			// Mark current coin
+			coin := m.coins[row.coin]
+			label, points := coin.Name, coin.Sparkline
+			if row.market >= 0 {
+				label = "    " + coin.Markets[row.market].Quote
+				points = coin.Markets[row.market].Sparkline
+			} else if len(coin.Markets) > 1 && row.coin != m.expanded {
+				label += fmt.Sprintf(" +%d", len(coin.Markets)-1)
+			}
 			current := ""
-			if coin.Symbol == m.data.Symbol {
+			if m.rowIsCurrent(row) {
 				current = " (current)"
 			}
-			s += style.Render(fmt.Sprintf("%s%s%s", cursor, coin.Name, current)) + "\n"
+			s += style.Render(fmt.Sprintf("%s%-24s", cursor, label)) + " " + miniSparkline(points) +
+				style.Render(current) + "\n"
 		}
 	}
 
-	s += helpStyle.Render("\n↑/↓: navigate • enter: select • esc: cancel")
+	s += helpStyle.Render("\n" + helpLine(glyphs.upDown+": navigate", glyphs.leftRight+": markets", "enter: select", "esc: cancel"))
 
 	return boxStyle.Render(s)
 }
@@ -411,7 +640,7 @@ func (m model) viewHistory() string {
 		coinName = "Crypto"
 	}
 
-	s := headerStyle.Render(fmt.Sprintf("◆ %s Trade History (from TimescaleDB)", coinName)) + "\n\n"
+	s := headerStyle.Render(fmt.Sprintf("%s%s Trade History (from TimescaleDB)", glyphs.header, coinName)) + "\n\n"
 
 	if len(m.dbHistory) == 0 {
 		s += labelStyle.Render("Loading history...")
@@ -421,7 +650,7 @@ func (m model) viewHistory() string {
 			labelStyle.Render("Time"),
 			labelStyle.Render("          Price"),
 			labelStyle.Render("Symbol"))
-		s += labelStyle.Render("─────────────────────────────────────────") + "\n"
+		s += labelStyle.Render(strings.Repeat(glyphs.rule, 41)) + "\n"
 
 		// Show trades with scrolling (15 visible)
 		endIdx := m.historyScroll + 15
@@ -432,10 +661,7 @@ func (m model) viewHistory() string {
 		for i := m.historyScroll; i < endIdx; i++ {
 			trade := m.dbHistory[i]
 			timeStr := trade.Timestamp.Local().Format("15:04:05")
-			priceStr := fmt.Sprintf("$%.2f", trade.Price)
-			if trade.Price < 1 {
-				priceStr = fmt.Sprintf("$%.6f", trade.Price)
-			}
+			priceStr := m.formatPrice(trade.Price.Float64())
 
 			s += fmt.Sprintf("%s  %s  %s\n",
 				timeStyle.Render(timeStr),
@@ -443,22 +669,37 @@ func (m model) viewHistory() string {
 				labelStyle.Render(trade.Symbol))
 		}
 
-		s += labelStyle.Render("─────────────────────────────────────────") + "\n"
-		s += labelStyle.Render(fmt.Sprintf("Showing %d-%d of %d trades",
-			m.historyScroll+1, endIdx, len(m.dbHistory)))
+		s += labelStyle.Render(strings.Repeat(glyphs.rule, 41)) + "\n"
+		more := ""
+		if m.historyNext != "" {
+			more = "+"
+		}
+		s += labelStyle.Render(fmt.Sprintf("Showing %d-%d of %d%s trades",
+			m.historyScroll+1, endIdx, len(m.dbHistory), more))
 	}
 
-	s += helpStyle.Render("\n↑/↓: scroll • r: refresh • esc: back to dashboard")
+	s += helpStyle.Render("\n" + helpLine(glyphs.upDown+": scroll", "r: refresh", "esc: back to dashboard"))
 
 	return boxStyle.Render(s)
 }
 
 func (m model) viewDashboard() string {
+	// Server announced a restart; polling keeps retrying until it's back
+	if m.restarting {
+		content := fmt.Sprintf(
+			"%s\n\n%s\n\n%s",
+			headerStyle.Render(glyphs.header+"Trading Pipeline Dashboard"),
+			labelStyle.Render("Server restarting, reconnecting"+glyphs.ellipsis),
+			helpStyle.Render("Press 'q' to quit"),
+		)
+		return boxStyle.Render(content)
+	}
+
 	// Error state
 	if m.data.Error != "" {
 		content := fmt.Sprintf(
 			"%s\n\n%s\n\n%s",
-			headerStyle.Render("◆ Trading Pipeline Dashboard"),
+			headerStyle.Render(glyphs.header+"Trading Pipeline Dashboard"),
 			errorStyle.Render(m.data.Error),
 			helpStyle.Render("Press 'q' to quit"),
 		)
@@ -469,7 +710,7 @@ func (m model) viewDashboard() string {
 	if !m.data.Connected {
 		content := fmt.Sprintf(
 			"%s\n\n%s\n\n%s",
-			headerStyle.Render("◆ Trading Pipeline Dashboard"),
+			headerStyle.Render(glyphs.header+"Trading Pipeline Dashboard"),
 			labelStyle.Render("Connecting to server..."),
 			helpStyle.Render("Press 'q' to quit"),
 		)
@@ -480,7 +721,7 @@ func (m model) viewDashboard() string {
 	if m.switching {
 		content := fmt.Sprintf(
 			"%s\n\n%s\n\n%s",
-			headerStyle.Render("◆ Trading Pipeline Dashboard"),
+			headerStyle.Render(glyphs.header+"Trading Pipeline Dashboard"),
 			labelStyle.Render("Switching coin..."),
 			helpStyle.Render("Please wait..."),
 		)
@@ -492,39 +733,71 @@ func (m model) viewDashboard() string {
 	if coinName == "" {
 		coinName = "Crypto"
 	}
-	header := headerStyle.Render(fmt.Sprintf("◆ %s Real-Time Dashboard", coinName))
+	tfLabel := "session"
+	if m.timeframe != "" {
+		tfLabel = m.timeframe
+	}
+	header := headerStyle.Render(fmt.Sprintf("%s%s Real-Time Dashboard [%s]", glyphs.header, coinName, tfLabel))
 
 	// Price display
-	priceStr := fmt.Sprintf("$%.2f", m.data.Price)
-	if m.data.Price < 1 {
-		priceStr = fmt.Sprintf("$%.6f", m.data.Price)
+	priceStr := m.formatPrice(m.data.Price)
+
+	// Session shows tick-to-tick change; a timeframe shows change over its window
+	change, changePercent := m.data.Change, m.data.ChangePercent
+	high, low := m.data.High, m.data.Low
+	highLabel, lowLabel := "Session High:", "Session Low:"
+	tf, windowed := m.data.Timeframes[m.timeframe]
+	windowed = windowed && m.timeframe != ""
+	if windowed {
+		change, changePercent = tf.Change, tf.ChangePercent
+		high, low = tf.High, tf.Low
+		highLabel, lowLabel = m.timeframe+" High:", m.timeframe+" Low:"
 	}
 
-	// Change indicator
-	var changeStr string
-	if m.data.Change > 0 {
-		changeStr = upStyle.Render(fmt.Sprintf("▲ +%.2f (+%.4f%%)", m.data.Change, m.data.ChangePercent))
-	} else if m.data.Change < 0 {
-		changeStr = downStyle.Render(fmt.Sprintf("▼ %.2f (%.4f%%)", m.data.Change, m.data.ChangePercent))
-	} else {
-		changeStr = labelStyle.Render("━ 0.00 (0.00%)")
+	priceDisplay := priceStyle.Render(priceStr) + "  " + m.renderChange(change, changePercent)
+
+	// Change since the anchored price
+	if a := m.data.Anchor; a != nil {
+		anchorChange := labelStyle.Render(glyphs.flat + " 0.00 (0.00%)")
+		if a.Change > 0 {
+			anchorChange = upStyle.Render(fmt.Sprintf("%s +%.*f (+%.2f%%)", glyphs.up, m.decimals(a.Price), a.Change, a.ChangePercent))
+		} else if a.Change < 0 {
+			anchorChange = downStyle.Render(fmt.Sprintf("%s %.*f (%.2f%%)", glyphs.down, m.decimals(a.Price), a.Change, a.ChangePercent))
+		}
+		priceDisplay += "\n" + labelStyle.Render("Since "+m.formatPrice(a.Price)+": ") + anchorChange
 	}
 
-	priceDisplay := priceStyle.Render(priceStr) + "  " + changeStr
+	// Moving average is dimmed until the window has filled up; a timeframe
+	// shows its mean price instead
+	maLabel := "Moving Avg:"
+	maStr := valueStyle.Render(m.formatPrice(m.data.MovingAverage))
+	if windowed {
+		maLabel = m.timeframe + " Avg:"
+		maStr = valueStyle.Render(m.formatPrice(tf.Average))
+	} else if !m.data.WindowFull {
+		maStr = labelStyle.Render(fmt.Sprintf("%s (warming up, %d trades)", m.formatPrice(m.data.MovingAverage), m.data.Samples))
+	}
 
 	// Stats
 	stats := fmt.Sprintf(
-		"%s %s\n%s %s\n%s %s\n%s %s",
-		labelStyle.Render("Moving Avg:"),
-		valueStyle.Render(fmt.Sprintf("$%.2f", m.data.MovingAverage)),
-		labelStyle.Render("Session High:"),
-		upStyle.Render(fmt.Sprintf("$%.2f", m.data.High)),
-		labelStyle.Render("Session Low:"),
-		downStyle.Render(fmt.Sprintf("$%.2f", m.data.Low)),
+		"%s %s\n%s %s\n%s %s\n%s %s\n%s %s",
+		labelStyle.Render(maLabel),
+		maStr,
+		labelStyle.Render(highLabel),
+		upStyle.Render(m.formatPrice(high)),
+		labelStyle.Render(lowLabel),
+		downStyle.Render(m.formatPrice(low)),
 		labelStyle.Render("Spread:"),
-		valueStyle.Render(fmt.Sprintf("$%.2f", m.data.High-m.data.Low)),
+		valueStyle.Render(m.formatPrice(high-low)),
+		labelStyle.Render("Activity:"),
+		valueStyle.Render(fmt.Sprintf("%.1f trades/s", m.data.TradesPerSec)),
 	)
 
+	// Where the price sits within the 24h and 7d ranges
+	if bands := m.renderBands(); bands != "" {
+		stats += "\n" + labelStyle.Render("Range:") + " " + bands
+	}
+
 	// Sparkline
 	sparkline := m.renderSparkline()
 
@@ -536,12 +809,151 @@ func (m model) viewDashboard() string {
 		stats,
 		labelStyle.Render("Price History: "),
 		sparkline,
-		helpStyle.Render("'c': change coin • 'h': view DB history • 'q': quit"),
+		helpStyle.Render(helpLine("'c': change coin", "'h': view DB history", "'p'/'P': set/clear anchor", "'1-4': timeframe", "'l': log scale", "'q': quit")),
 	)
+	if server := m.renderServer(); server != "" {
+		content += "\n" + server
+	}
 
 	return boxStyle.Render(content)
 }
 
+// renderChange draws a price change with its direction marker
+func (m model) renderChange(change, changePercent float64) string {
+	if change > 0 {
+		return upStyle.Render(fmt.Sprintf("%s +%.*f (+%.4f%%)", glyphs.up, m.decimals(m.data.Price), change, changePercent))
+	} else if change < 0 {
+		return downStyle.Render(fmt.Sprintf("%s %.*f (%.4f%%)", glyphs.down, m.decimals(m.data.Price), change, changePercent))
+	}
+	return labelStyle.Render(glyphs.flat + " 0.00 (0.00%)")
+}
+
+// helpLine joins key hints with the item separator
+func helpLine(items ...string) string {
+	return strings.Join(items, glyphs.separator)
+}
+
+// Minimum vertical span of the sparkline, relative to the mid price, so that
+// moves of a few ticks on a high-priced coin don't fill the whole chart.
+const sparkMinSpan = 0.001
+
+// decimals is how many decimals prices of the tracked market are shown
+// with: the API's precision once /api/symbol/meta has answered, and before
+// that, or from older servers, enough for sub-dollar coins.
+func (m model) decimals(p float64) int {
+	if m.meta != nil && m.meta.Symbol == m.data.Symbol {
+		return m.meta.Precision
+	}
+	if p < 1 {
+		return 6
+	}
+	return 2
+}
+
+// formatPrice renders a price of the tracked market
+func (m model) formatPrice(p float64) string {
+	return fmt.Sprintf("$%.*f", m.decimals(p), p)
+}
+
+// priceTick returns the smallest price increment shown for a price.
+func (m model) priceTick(p float64) float64 {
+	if m.meta != nil && m.meta.Symbol == m.data.Symbol && m.meta.TickSize > 0 {
+		return m.meta.TickSize
+	}
+	return math.Pow10(-m.decimals(p))
+}
+
+// Width of the coin selector sparklines, matching the server's point count
+const miniSparkWidth = 20
+
+// miniSparkline draws the coin selector chart for a market's last 30
+// minutes, colored by its overall direction
+func miniSparkline(points []float64) string {
+	if len(points) < 2 {
+		return labelStyle.Render(strings.Repeat(" ", miniSparkWidth))
+	}
+	lo, hi := points[0], points[0]
+	for _, v := range points {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	chars := glyphs.bars
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", max(miniSparkWidth-len(points), 0)))
+	for _, v := range points {
+		idx := 0
+		if hi > lo {
+			idx = int((v - lo) / (hi - lo) * float64(len(chars)-1))
+		}
+		b.WriteRune(chars[idx])
+	}
+
+	style := valueStyle
+	if last := points[len(points)-1]; last > points[0] {
+		style = upStyle
+	} else if last < points[0] {
+		style = downStyle
+	}
+	return style.Render(b.String())
+}
+
+// renderServer names the API version and its exchanges, and warns when it
+// can't serve this client's stream protocol
+func (m model) renderServer() string {
+	if m.server == nil {
+		return ""
+	}
+	line := "API " + m.server.Version
+	if commit := m.server.Commit; commit != "" {
+		line += " (" + commit[:min(len(commit), 7)] + ")"
+	}
+	if exchanges := m.server.Features.Exchanges; len(exchanges) > 0 {
+		line += glyphs.separator + strings.Join(exchanges, ", ")
+	}
+	if !slices.Contains(m.server.Protocols.WebSocket, client.StreamProtocol) {
+		return helpStyle.Render(line) + " " + downStyle.Render(fmt.Sprintf("doesn't speak stream protocol v%d", client.StreamProtocol))
+	}
+	return helpStyle.Render(line)
+}
+
+// renderBands reads e.g. "92nd percentile of 24h • 61st of 7d", dimming a
+// range the server hasn't seen whole yet
+func (m model) renderBands() string {
+	var parts []string
+	for _, name := range []string{"24h", "7d"} {
+		band, ok := m.data.Bands[name]
+		if !ok {
+			continue
+		}
+		text := ordinal(int(math.Round(band.Percentile)))
+		if len(parts) == 0 {
+			text += " percentile"
+		}
+		text += " of " + name
+		if band.Complete {
+			parts = append(parts, valueStyle.Render(text))
+		} else {
+			parts = append(parts, labelStyle.Render(text+" (partial)"))
+		}
+	}
+	return strings.Join(parts, labelStyle.Render(glyphs.separator))
+}
+
+// ordinal formats n as 1st, 2nd, 3rd, 4th...
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
 func (m model) renderSparkline() string {
 	if len(m.history) < 2 {
 		return labelStyle.Render("waiting for data...")
@@ -557,17 +969,38 @@ func (m model) renderSparkline() string {
 		}
 	}
 
-	chars := []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+	chars := glyphs.bars
 
-	var spark string
-	rang := max - min
+	// Widen the range to at least a few ticks and a fraction of the price,
+	// centered on the observed range
+	mid := (min + max) / 2
+	span := max - min
+	floor := m.priceTick(mid) * float64(len(chars))
+	if rel := mid * sparkMinSpan; rel > floor {
+		floor = rel
+	}
+	lo, hi := min, max
+	if span < floor {
+		lo = mid - floor/2
+		hi = mid + floor/2
+	}
+
+	scale := func(v float64) float64 { return v }
+	if m.logScale && lo > 0 {
+		scale = math.Log
+	}
+	rang := scale(hi) - scale(lo)
 	if rang == 0 {
 		rang = 1
 	}
 
+	var spark string
 	for i, v := range m.history {
-		normalized := (v - min) / rang
+		normalized := (scale(v) - scale(lo)) / rang
 		idx := int(normalized * float64(len(chars)-1))
+		if idx < 0 {
+			idx = 0
+		}
 		if idx >= len(chars) {
 			idx = len(chars) - 1
 		}
@@ -582,11 +1015,41 @@ func (m model) renderSparkline() string {
 		}
 	}
 
-	return spark
+	return labelStyle.Render(m.formatPrice(min)+" ") + spark + labelStyle.Render(" "+m.formatPrice(max))
 }
 
 func main() {
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
+	serverURL := "http://localhost:8080"
+	if env := os.Getenv("SIGN_SERVER_URL"); env != "" {
+		serverURL = env
+	}
+	flag.StringVar(&serverURL, "server", serverURL, "API server URL (env SIGN_SERVER_URL)")
+	token := os.Getenv("SIGN_API_TOKEN")
+	flag.StringVar(&token, "token", token, "the server's API token, needed to change coins when it sets one (env SIGN_API_TOKEN)")
+	session.name = os.Getenv("SIGN_SESSION")
+	flag.StringVar(&session.name, "session", session.name, "share the coin, timeframe and scale with other TUIs in this session (env SIGN_SESSION)")
+	flag.BoolVar(&session.follow, "follow", false, "only follow --session, e.g. on a wall display")
+	plain := os.Getenv("SIGN_PLAIN") != ""
+	flag.BoolVar(&plain, "plain", plain, "no color and ASCII only, for screen readers, limited terminals and logging to files (env SIGN_PLAIN)")
+	compact = os.Getenv("SIGN_COMPACT") != ""
+	flag.BoolVar(&compact, "compact", compact, "always show the dashboard as 3-4 unframed lines, as it is anyway in terminals too short for the full one (env SIGN_COMPACT)")
+	flag.Parse()
+	if session.follow && session.name == "" {
+		session.name = "default"
+	}
+	api = client.New(serverURL)
+	api.Token = token
+
+	// Plain output stays in the terminal's scrollback, or the file it is
+	// redirected to, instead of the alternate screen
+	var opts []tea.ProgramOption
+	if plain {
+		setPlain()
+	} else {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	p := tea.NewProgram(initialModel(), opts...)
+	go listenEvents(p)
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,75 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+// glyphSet holds the symbols the views draw with
+type glyphSet struct {
+	up, down, flat string // price change markers
+	header         string
+	cursor         string
+	separator      string // between items on one line
+	rule           string // one cell of a horizontal line
+	ellipsis       string
+	upDown         string // key names in help lines
+	leftRight      string
+	bars           []rune // sparkline levels, lowest first
+}
+
+// glyphs are the symbols in use, Unicode unless --plain is set
+var glyphs = glyphSet{
+	up:        "▲",
+	down:      "▼",
+	flat:      "━",
+	header:    "◆ ",
+	cursor:    "▸ ",
+	separator: " • ",
+	rule:      "─",
+	ellipsis:  "…",
+	upDown:    "↑/↓",
+	leftRight: "→/←",
+	bars:      []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'},
+}
+
+// plainGlyphs are ASCII only, for screen readers, limited terminals and
+// output logged to files
+var plainGlyphs = glyphSet{
+	up:        "+",
+	down:      "-",
+	flat:      "=",
+	header:    "",
+	cursor:    "> ",
+	separator: " | ",
+	rule:      "-",
+	ellipsis:  "...",
+	upDown:    "up/down",
+	leftRight: "right/left",
+	bars:      []rune{'_', '.', ',', '-', '=', '+', '*', '#'},
+}
+
+// asciiBorder frames the views in plain mode
+var asciiBorder = lipgloss.Border{
+	Top:         "-",
+	Bottom:      "-",
+	Left:        "|",
+	Right:       "|",
+	TopLeft:     "+",
+	TopRight:    "+",
+	BottomLeft:  "+",
+	BottomRight: "+",
+}
+
+// setPlain switches to ASCII glyphs and drops color and bold, keeping only
+// the layout of the styles
+func setPlain() {
+	glyphs = plainGlyphs
+
+	boxStyle = lipgloss.NewStyle().Border(asciiBorder).Padding(1, 2)
+	headerStyle = lipgloss.NewStyle().MarginBottom(1)
+	helpStyle = lipgloss.NewStyle().MarginTop(1)
+	for _, style := range []*lipgloss.Style{
+		&priceStyle, &upStyle, &downStyle, &labelStyle, &valueStyle,
+		&errorStyle, &selectedStyle, &itemStyle, &timeStyle,
+	} {
+		*style = lipgloss.NewStyle()
+	}
+}